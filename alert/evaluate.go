@@ -0,0 +1,174 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"sort"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// State of a series with respect to a condition.
+type State string
+
+const (
+	// The latest data point does not breach the condition.
+	StateOK State = "ok"
+	// The condition is breached but not for long enough yet.
+	StatePending State = "pending"
+	// The condition has been breached for at least the required duration.
+	StateFiring State = "firing"
+	// The series has no numeric data points.
+	StateNoData State = "no_data"
+)
+
+// A threshold condition, e.g. Condition{Op: ">", Value: 0.9, For: 5*time.Minute}.
+type Condition struct {
+	// One of ">", ">=", "<", "<=", "==" or "!=".
+	Op string
+
+	// Threshold the data points are compared with.
+	Value float64
+
+	// How long the condition must hold before the series fires. Zero fires
+	// on the first breaching data point.
+	For time.Duration
+}
+
+// Outcome of the evaluation of a single series.
+type SeriesState struct {
+	// Index of the query the series belongs to.
+	Query int
+	Name  string
+	Tags  map[string][]string
+	Group []response.GroupResult
+
+	State State
+
+	// Value and time of the latest data point.
+	Value     float64
+	Timestamp time.Time
+
+	// Start of the ongoing breach. Zero when the state is OK or NoData.
+	Since time.Time
+}
+
+// Evaluates the condition on every series of the query response. A series
+// breaches the condition while its data points do; it fires once the
+// uninterrupted run of breaching data points ending with the latest one spans
+// at least the For duration. Non numeric data points are ignored.
+func Evaluate(qr *response.QueryResponse, cond Condition) ([]SeriesState, error) {
+	cmp, err := comparator(cond.Op)
+	if err != nil {
+		return nil, err
+	}
+
+	var states []SeriesState
+	for qi, q := range qr.QueriesArr {
+		for _, r := range q.ResultsArr {
+			states = append(states, evaluateSeries(qi, r, cond, cmp))
+		}
+	}
+
+	return states, nil
+}
+
+type point struct {
+	ts    int64
+	value float64
+}
+
+func evaluateSeries(query int, r response.Results, cond Condition, cmp func(a, b float64) bool) SeriesState {
+	ss := SeriesState{
+		Query: query,
+		Name:  r.Name,
+		Tags:  r.Tags,
+		Group: r.Group,
+		State: StateNoData,
+	}
+
+	points := make([]point, 0, len(r.DataPoints))
+	for i := range r.DataPoints {
+		if v, ok := numericValue(&r.DataPoints[i]); ok {
+			points = append(points, point{ts: r.DataPoints[i].Timestamp(), value: v})
+		}
+	}
+
+	if len(points) == 0 {
+		return ss
+	}
+
+	sort.SliceStable(points, func(i, j int) bool { return points[i].ts < points[j].ts })
+
+	latest := points[len(points)-1]
+	ss.Value = latest.value
+	ss.Timestamp = msToTime(latest.ts)
+
+	if !cmp(latest.value, cond.Value) {
+		ss.State = StateOK
+		return ss
+	}
+
+	since := latest.ts
+	for i := len(points) - 2; i >= 0 && cmp(points[i].value, cond.Value); i-- {
+		since = points[i].ts
+	}
+
+	ss.Since = msToTime(since)
+	if time.Duration(latest.ts-since)*time.Millisecond >= cond.For {
+		ss.State = StateFiring
+	} else {
+		ss.State = StatePending
+	}
+
+	return ss
+}
+
+func comparator(op string) (func(a, b float64) bool, error) {
+	switch op {
+	case ">":
+		return func(a, b float64) bool { return a > b }, nil
+	case ">=":
+		return func(a, b float64) bool { return a >= b }, nil
+	case "<":
+		return func(a, b float64) bool { return a < b }, nil
+	case "<=":
+		return func(a, b float64) bool { return a <= b }, nil
+	case "==":
+		return func(a, b float64) bool { return a == b }, nil
+	case "!=":
+		return func(a, b float64) bool { return a != b }, nil
+	}
+
+	return nil, ErrorOperatorInvalid
+}
+
+func numericValue(dp *builder.DataPoint) (float64, bool) {
+	if v, err := dp.Float64Value(); err == nil {
+		return v, true
+	}
+
+	if v, err := dp.Int64Value(); err == nil {
+		return float64(v), true
+	}
+
+	return 0, false
+}
+
+func msToTime(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
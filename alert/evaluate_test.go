@@ -0,0 +1,64 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/response"
+	"github.com/stretchr/testify/assert"
+)
+
+func alertResponse(t *testing.T) *response.QueryResponse {
+	data := `{"queries":[{"results":[` +
+		`{"name":"cpu","tags":{"host":["a"]},"values":[[0,0.95],[60000,0.97],[360000,0.99]]},` +
+		`{"name":"cpu","tags":{"host":["b"]},"values":[[0,0.5],[300000,0.95],[360000,0.96]]},` +
+		`{"name":"cpu","tags":{"host":["c"]},"values":[[0,0.95],[360000,0.5]]},` +
+		`{"name":"cpu","tags":{"host":["d"]},"values":[]}]}]}`
+
+	qr := response.NewQueryResponse(200)
+	err := json.Unmarshal([]byte(data), qr)
+	assert.Nil(t, err, "No error expected")
+	return qr
+}
+
+// Success test.
+func TestEvaluate(t *testing.T) {
+	states, err := Evaluate(alertResponse(t), Condition{Op: ">", Value: 0.9, For: 5 * time.Minute})
+	assert.Nil(t, err, "No error expected")
+	assert.Len(t, states, 4, "One state per series expected")
+
+	assert.Equal(t, StateFiring, states[0].State, "Breach spanning 6 minutes must fire")
+	assert.Equal(t, time.Unix(0, 0), states[0].Since, "Breach starts at the first data point")
+	assert.Equal(t, 0.99, states[0].Value, "Latest value expected")
+
+	assert.Equal(t, StatePending, states[1].State, "Breach spanning 1 minute must be pending")
+	assert.Equal(t, time.Unix(300, 0), states[1].Since, "Breach starts at the first breaching data point")
+
+	assert.Equal(t, StateOK, states[2].State, "Recovered series must be ok")
+	assert.True(t, states[2].Since.IsZero(), "No breach expected")
+
+	assert.Equal(t, StateNoData, states[3].State, "Empty series must have no data")
+	assert.Equal(t, []string{"d"}, states[3].Tags["host"], "Series tags expected")
+}
+
+// Failure test.
+func TestEvaluateInvalidOperator(t *testing.T) {
+	states, err := Evaluate(alertResponse(t), Condition{Op: "=>", Value: 0.9})
+	assert.Equal(t, ErrorOperatorInvalid, err, "Invalid operator must be rejected")
+	assert.Nil(t, states, "No states expected")
+}
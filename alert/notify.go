@@ -0,0 +1,180 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// A change of the state of a series worth notifying.
+type Event string
+
+const (
+	// The series started breaching the condition.
+	EventPending Event = "pending"
+	// The series has been breaching the condition for the required duration.
+	EventFiring Event = "firing"
+	// A firing series stopped breaching the condition, has no data anymore
+	// or is gone from the results.
+	EventResolved Event = "resolved"
+)
+
+// Sent to the notifiers when the state of a series changes.
+type Notification struct {
+	Alert  string
+	Event  Event
+	Series SeriesState
+
+	// Time of the evaluation.
+	At time.Time
+}
+
+// Delivers notifications, e.g. to a chat or a paging system.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Adapts a function to a Notifier.
+type NotifierFunc func(ctx context.Context, n Notification) error
+
+func (f NotifierFunc) Notify(ctx context.Context, n Notification) error {
+	return f(ctx, n)
+}
+
+type writerNotifier struct {
+	w io.Writer
+}
+
+// Returns a Notifier writing one line per notification to the writer.
+func NewWriterNotifier(w io.Writer) Notifier {
+	return &writerNotifier{w: w}
+}
+
+// Returns a Notifier writing one line per notification to the standard
+// output.
+func NewStdoutNotifier() Notifier {
+	return NewWriterNotifier(os.Stdout)
+}
+
+func (wn *writerNotifier) Notify(ctx context.Context, n Notification) error {
+	_, err := fmt.Fprintf(wn.w, "%s %s %s %s value=%g\n",
+		n.At.Format(time.RFC3339), n.Alert, n.Event, seriesName(n.Series), n.Series.Value)
+	return err
+}
+
+// A Notifier posting every notification as JSON to a URL.
+type WebhookNotifier struct {
+	URL string
+
+	// Headers added to every request, e.g. for authentication. May be nil.
+	Header http.Header
+
+	// Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+type webhookPayload struct {
+	Alert     string              `json:"alert"`
+	Event     Event               `json:"event"`
+	At        time.Time           `json:"at"`
+	Metric    string              `json:"metric"`
+	Tags      map[string][]string `json:"tags,omitempty"`
+	State     State               `json:"state"`
+	Value     float64             `json:"value"`
+	Timestamp time.Time           `json:"timestamp"`
+	Since     *time.Time          `json:"since,omitempty"`
+}
+
+func (wh *WebhookNotifier) Notify(ctx context.Context, n Notification) error {
+	payload := webhookPayload{
+		Alert:     n.Alert,
+		Event:     n.Event,
+		At:        n.At,
+		Metric:    n.Series.Name,
+		Tags:      n.Series.Tags,
+		State:     n.Series.State,
+		Value:     n.Series.Value,
+		Timestamp: n.Series.Timestamp,
+	}
+	if !n.Series.Since.IsZero() {
+		payload.Since = &n.Series.Since
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", wh.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	for k, vals := range wh.Header {
+		for _, v := range vals {
+			req.Header.Add(k, v)
+		}
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	c := wh.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: %d", ErrorWebhookStatus, resp.StatusCode)
+	}
+
+	return nil
+}
+
+// Returns the series as in cpu{host=a,dc=eu}, tags sorted by name.
+func seriesName(ss SeriesState) string {
+	names := make([]string, 0, len(ss.Tags))
+	for k := range ss.Tags {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString(ss.Name)
+	sb.WriteByte('{')
+	for i, k := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(strings.Join(ss.Tags[k], "|"))
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
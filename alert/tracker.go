@@ -0,0 +1,173 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Options of a Tracker.
+type TrackerOptions struct {
+	// Also notifies series starting to breach the condition, not only the
+	// firing and resolved ones.
+	NotifyPending bool
+}
+
+// Tracks the state of the series of an alert across evaluations and
+// notifies their transitions. The For duration of the condition is measured
+// across evaluations as well: a series breaching the condition in every
+// evaluation fires once the breach lasted long enough, even when every
+// response only holds its latest data points.
+type Tracker struct {
+	name      string
+	cond      Condition
+	opts      TrackerOptions
+	notifiers []Notifier
+
+	mu     sync.Mutex // Guards series.
+	series map[string]SeriesState
+}
+
+func NewTracker(name string, cond Condition, opts TrackerOptions, notifiers ...Notifier) *Tracker {
+	return &Tracker{
+		name:      name,
+		cond:      cond,
+		opts:      opts,
+		notifiers: notifiers,
+		series:    make(map[string]SeriesState),
+	}
+}
+
+// Evaluates the condition on the response, see Evaluate, and notifies the
+// series whose state changed since the previous update. Every notifier gets
+// every notification, the first error they returned is returned.
+func (t *Tracker) Update(ctx context.Context, qr *response.QueryResponse, now time.Time) ([]SeriesState, error) {
+	states, err := Evaluate(qr, t.cond)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	var notifications []Notification
+	seen := make(map[string]bool, len(states))
+	for i := range states {
+		ss := &states[i]
+		key := seriesKey(*ss)
+		seen[key] = true
+
+		prev, tracked := t.series[key]
+		if ss.State == StatePending || ss.State == StateFiring {
+			if tracked && prev.Since.Before(ss.Since) {
+				// The breach started in an earlier evaluation.
+				ss.Since = prev.Since
+			}
+			if ss.Timestamp.Sub(ss.Since) >= t.cond.For {
+				ss.State = StateFiring
+			}
+
+			if !tracked || prev.State != ss.State {
+				if ss.State == StateFiring || t.opts.NotifyPending {
+					notifications = append(notifications, t.notification(eventOf(ss.State), *ss, now))
+				}
+			}
+			t.series[key] = *ss
+			continue
+		}
+
+		if tracked {
+			if prev.State == StateFiring {
+				notifications = append(notifications, t.notification(EventResolved, *ss, now))
+			}
+			delete(t.series, key)
+		}
+	}
+
+	// Series gone from the results.
+	var gone []string
+	for key := range t.series {
+		if !seen[key] {
+			gone = append(gone, key)
+		}
+	}
+	sort.Strings(gone)
+	for _, key := range gone {
+		if prev := t.series[key]; prev.State == StateFiring {
+			notifications = append(notifications, t.notification(EventResolved, prev, now))
+		}
+		delete(t.series, key)
+	}
+	t.mu.Unlock()
+
+	return states, t.notify(ctx, notifications)
+}
+
+// Returns the series currently pending or firing.
+func (t *Tracker) Active() []SeriesState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	keys := make([]string, 0, len(t.series))
+	for key := range t.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	active := make([]SeriesState, 0, len(keys))
+	for _, key := range keys {
+		active = append(active, t.series[key])
+	}
+	return active
+}
+
+func (t *Tracker) notification(event Event, ss SeriesState, now time.Time) Notification {
+	return Notification{Alert: t.name, Event: event, Series: ss, At: now}
+}
+
+func (t *Tracker) notify(ctx context.Context, notifications []Notification) error {
+	var first error
+	for _, n := range notifications {
+		for _, notifier := range t.notifiers {
+			if err := notifier.Notify(ctx, n); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+func eventOf(s State) Event {
+	if s == StateFiring {
+		return EventFiring
+	}
+	return EventPending
+}
+
+// Identifies a series across evaluations.
+func seriesKey(ss SeriesState) string {
+	// Maps are encoded with sorted keys.
+	data, _ := json.Marshal(struct {
+		Query int
+		Name  string
+		Tags  map[string][]string
+		Group []response.GroupResult
+	}{ss.Query, ss.Name, ss.Tags, ss.Group})
+	return string(data)
+}
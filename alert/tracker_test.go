@@ -0,0 +1,142 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/response"
+	"github.com/stretchr/testify/assert"
+)
+
+// Returns a response holding a single data point per host.
+func latestResponse(t *testing.T, ts int64, values map[string]float64) *response.QueryResponse {
+	var results []string
+	for _, host := range []string{"a", "b"} {
+		if v, ok := values[host]; ok {
+			results = append(results, fmt.Sprintf(`{"name":"cpu","tags":{"host":["%s"]},"values":[[%d,%g]]}`, host, ts, v))
+		}
+	}
+
+	data := `{"queries":[{"results":[`
+	for i, r := range results {
+		if i > 0 {
+			data += ","
+		}
+		data += r
+	}
+	data += `]}]}`
+
+	qr := response.NewQueryResponse(200)
+	assert.Nil(t, json.Unmarshal([]byte(data), qr), "No error expected")
+	return qr
+}
+
+// Success test.
+func TestTracker(t *testing.T) {
+	var events []string
+	record := NotifierFunc(func(ctx context.Context, n Notification) error {
+		events = append(events, string(n.Event)+" "+n.Series.Tags["host"][0])
+		return nil
+	})
+
+	tr := NewTracker("cpu-high", Condition{Op: ">", Value: 0.9, For: 2 * time.Minute}, TrackerOptions{NotifyPending: true}, record)
+	update := func(minute int64, values map[string]float64) []SeriesState {
+		states, err := tr.Update(context.Background(), latestResponse(t, minute*60000, values), time.Unix(minute*60, 0))
+		assert.Nil(t, err, "No error expected")
+		return states
+	}
+
+	update(0, map[string]float64{"a": 0.95, "b": 0.5})
+	assert.Equal(t, []string{"pending a"}, events, "a starts breaching")
+
+	states := update(1, map[string]float64{"a": 0.95, "b": 0.95})
+	assert.Equal(t, StatePending, states[0].State, "a breaches for one minute only")
+	assert.Equal(t, time.Unix(0, 0), states[0].Since, "The breach started in the first evaluation")
+
+	states = update(2, map[string]float64{"a": 0.95, "b": 0.95})
+	assert.Equal(t, StateFiring, states[0].State, "a breaches for two minutes")
+	assert.Equal(t, []string{"pending a", "pending b", "firing a"}, events, "Transitions expected")
+	assert.Len(t, tr.Active(), 2, "Two active series expected")
+
+	update(3, map[string]float64{"a": 0.5, "b": 0.95})
+	assert.Equal(t, []string{"pending a", "pending b", "firing a", "resolved a", "firing b"}, events, "a resolves, b fires")
+
+	update(4, map[string]float64{"a": 0.5})
+	assert.Equal(t, "resolved b", events[len(events)-1], "A firing series gone must resolve")
+	assert.Empty(t, tr.Active(), "No active series expected")
+}
+
+// Success test.
+func TestNotifiers(t *testing.T) {
+	n := Notification{
+		Alert:  "cpu-high",
+		Event:  EventFiring,
+		Series: SeriesState{Name: "cpu", Tags: map[string][]string{"host": {"a"}, "dc": {"eu"}}, State: StateFiring, Value: 0.95},
+		At:     time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, NewWriterNotifier(&buf).Notify(context.Background(), n), "No error expected")
+	assert.Equal(t, "2020-01-01T00:00:00Z cpu-high firing cpu{dc=eu,host=a} value=0.95\n", buf.String(), "Line expected")
+
+	var body map[string]interface{}
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		data, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(data, &body)
+	}))
+	defer srv.Close()
+
+	wh := &WebhookNotifier{URL: srv.URL, Header: http.Header{"Authorization": {"Bearer token"}}}
+	assert.Nil(t, wh.Notify(context.Background(), n), "No error expected")
+	assert.Equal(t, "Bearer token", auth, "Headers must be sent")
+	assert.Equal(t, "firing", body["event"], "Event expected")
+	assert.Equal(t, "cpu", body["metric"], "Metric expected")
+	assert.Equal(t, 0.95, body["value"], "Value expected")
+}
+
+// Failure test.
+func TestNotifierErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	wh := &WebhookNotifier{URL: srv.URL}
+	err := wh.Notify(context.Background(), Notification{})
+	assert.True(t, errors.Is(err, ErrorWebhookStatus), "Status error expected")
+
+	// Failing notifiers do not keep the others from being notified.
+	var notified int
+	ok := NotifierFunc(func(context.Context, Notification) error { notified++; return nil })
+	tr := NewTracker("cpu-high", Condition{Op: ">", Value: 0.9}, TrackerOptions{}, wh, ok)
+	_, err = tr.Update(context.Background(), latestResponse(t, 0, map[string]float64{"a": 0.95}), time.Unix(0, 0))
+	assert.True(t, errors.Is(err, ErrorWebhookStatus), "Notifier error expected")
+	assert.Equal(t, 1, notified, "The other notifier must be notified")
+
+	_, err = NewTracker("x", Condition{Op: "~"}, TrackerOptions{}).Update(context.Background(), latestResponse(t, 0, nil), time.Now())
+	assert.Equal(t, ErrorOperatorInvalid, err, "Operator error expected")
+}
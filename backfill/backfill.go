@@ -0,0 +1,243 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package backfill writes historical data to KairosDB at a controlled rate,
+// since backfills pushed unthrottled routinely destabilize clusters.
+package backfill
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/client"
+	"github.com/retoool/go-kairosdb/clock"
+)
+
+// A historical data point of a series.
+type Point struct {
+	Metric    string
+	Tags      map[string]string
+	Timestamp time.Time
+	Value     interface{}
+}
+
+// Yields the data points to backfill in ascending timestamp order. Next
+// returns false once the source is exhausted or failed, Err tells which.
+type Iterator interface {
+	Next() bool
+	Point() Point
+	Err() error
+}
+
+type sliceIterator struct {
+	points []Point
+	next   int
+}
+
+// Returns an Iterator over the points, sorted by timestamp. Points sharing a
+// timestamp keep their relative order.
+func FromSlice(points []Point) Iterator {
+	sorted := make([]Point, len(points))
+	copy(sorted, points)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Timestamp.Before(sorted[j].Timestamp)
+	})
+
+	return &sliceIterator{points: sorted}
+}
+
+func (si *sliceIterator) Next() bool {
+	if si.next >= len(si.points) {
+		return false
+	}
+	si.next++
+	return true
+}
+
+func (si *sliceIterator) Point() Point {
+	return si.points[si.next-1]
+}
+
+func (si *sliceIterator) Err() error {
+	return nil
+}
+
+// Options of a backfill.
+type Options struct {
+	// Target number of data points per second.
+	Rate int
+
+	// Number of data points per push. Defaults to 1000.
+	BatchSize int
+
+	// Data points older than ResumeFrom are skipped. Pass the Through time
+	// of the progress of an interrupted run to resume it: the data points
+	// of that timestamp are written again, which KairosDB takes as
+	// overwrites.
+	ResumeFrom time.Time
+
+	// Called after every successful push. May be nil.
+	OnProgress func(Progress)
+
+	// Paces the pushes. Defaults to the wall clock.
+	Clock clock.Clock
+}
+
+// Progress of a backfill.
+type Progress struct {
+	// Number of data points written.
+	Sent int64
+
+	// Number of data points skipped for being older than ResumeFrom.
+	Skipped int64
+
+	// Number of pushes.
+	Batches int64
+
+	// Timestamp of the last data point written.
+	Through time.Time
+
+	// Time since the start of the run.
+	Elapsed time.Duration
+}
+
+// Writes the data points of the source to the writer, oldest first, at most
+// at the target rate. The run stops at the first failed push, with an error
+// wrapping ErrorPushFailed for an error status, or when the context is done.
+// The progress returned tells where to resume from.
+func Run(ctx context.Context, w client.MetricWriter, source Iterator, opts Options) (Progress, error) {
+	var progress Progress
+
+	if opts.Rate <= 0 {
+		return progress, ErrorRateInvalid
+	}
+
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+
+	clk := clock.OrReal(opts.Clock)
+	start := clk.Now()
+	var batch []Point
+	var last time.Time
+
+	flush := func() error {
+		// Waits until the data points written so far are within the rate.
+		due := start.Add(time.Duration(float64(progress.Sent) / float64(opts.Rate) * float64(time.Second)))
+		if wait := due.Sub(clk.Now()); wait > 0 {
+			timer := clk.NewTimer(wait)
+			select {
+			case <-timer.C():
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+		}
+
+		resp, err := w.PushMetricsContext(ctx, batchBuilder(batch))
+		if err != nil {
+			return err
+		}
+
+		if resp.GetStatusCode() >= http.StatusMultipleChoices {
+			return fmt.Errorf("%w: status %d: %v", ErrorPushFailed, resp.GetStatusCode(), resp.GetErrors())
+		}
+
+		progress.Sent += int64(len(batch))
+		progress.Batches++
+		progress.Through = batch[len(batch)-1].Timestamp
+		progress.Elapsed = clk.Now().Sub(start)
+		batch = batch[:0]
+
+		if opts.OnProgress != nil {
+			opts.OnProgress(progress)
+		}
+		return nil
+	}
+
+	for source.Next() {
+		p := source.Point()
+		if p.Timestamp.Before(last) {
+			return progress, fmt.Errorf("%w: %s at %s after %s", ErrorSourceUnordered, p.Metric,
+				p.Timestamp.Format(time.RFC3339Nano), last.Format(time.RFC3339Nano))
+		}
+		last = p.Timestamp
+
+		if p.Timestamp.Before(opts.ResumeFrom) {
+			progress.Skipped++
+			continue
+		}
+
+		batch = append(batch, p)
+		if len(batch) < opts.BatchSize {
+			continue
+		}
+
+		if err := flush(); err != nil {
+			return progress, err
+		}
+	}
+
+	if err := source.Err(); err != nil {
+		return progress, err
+	}
+
+	if len(batch) > 0 {
+		if err := flush(); err != nil {
+			return progress, err
+		}
+	}
+
+	return progress, nil
+}
+
+// Returns a builder with one metric per series of the batch.
+func batchBuilder(batch []Point) builder.MetricBuilder {
+	mb := builder.NewMetricBuilder()
+	metrics := make(map[string]builder.Metric)
+	for _, p := range batch {
+		key := seriesKey(p.Metric, p.Tags)
+		m, ok := metrics[key]
+		if !ok {
+			m = mb.AddMetric(p.Metric).AddTags(p.Tags)
+			metrics[key] = m
+		}
+		m.AddDataPoint(p.Timestamp.UnixNano()/int64(time.Millisecond), p.Value)
+	}
+
+	return mb
+}
+
+func seriesKey(metric string, tags map[string]string) string {
+	names := make([]string, 0, len(tags))
+	for k := range tags {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString(metric)
+	for _, k := range names {
+		sb.WriteByte(0)
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(tags[k])
+	}
+	return sb.String()
+}
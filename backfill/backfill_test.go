@@ -0,0 +1,130 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backfill
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/response"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingWriter struct {
+	mu      sync.Mutex
+	code    int
+	batches [][]int64 // Timestamps of the data points of every push.
+}
+
+func (rw *recordingWriter) PushMetrics(mb builder.MetricBuilder) (*response.Response, error) {
+	return rw.PushMetricsContext(context.Background(), mb)
+}
+
+func (rw *recordingWriter) PushMetricsContext(ctx context.Context, mb builder.MetricBuilder) (*response.Response, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	var ts []int64
+	for _, m := range mb.GetMetrics() {
+		for _, dp := range m.GetDataPoints() {
+			ts = append(ts, dp.Timestamp())
+		}
+	}
+	rw.batches = append(rw.batches, ts)
+
+	resp := &response.Response{}
+	resp.SetStatusCode(rw.code)
+	return resp, nil
+}
+
+func testPoints(n int) []Point {
+	points := make([]Point, n)
+	for i := range points {
+		points[i] = Point{
+			Metric:    "m1",
+			Tags:      map[string]string{"host": []string{"h1", "h2"}[i%2]},
+			Timestamp: time.Unix(int64(i), 0),
+			Value:     i,
+		}
+	}
+	return points
+}
+
+// Success test.
+func TestRun(t *testing.T) {
+	w := &recordingWriter{code: 204}
+
+	var updates []Progress
+	start := time.Now()
+	progress, err := Run(context.Background(), w, FromSlice(testPoints(50)), Options{
+		Rate:       500,
+		BatchSize:  10,
+		OnProgress: func(p Progress) { updates = append(updates, p) },
+	})
+	assert.Nil(t, err, "No error expected")
+	assert.True(t, time.Since(start) >= 80*time.Millisecond, "Pushes must be throttled")
+
+	assert.Equal(t, int64(50), progress.Sent, "All data points must be sent")
+	assert.Equal(t, int64(5), progress.Batches, "Five batches expected")
+	assert.Equal(t, time.Unix(49, 0), progress.Through, "Last timestamp expected")
+	assert.Len(t, updates, 5, "Progress must be reported after every batch")
+	assert.Equal(t, int64(20), updates[1].Sent, "Progress of the second batch expected")
+
+	assert.Len(t, w.batches, 5, "Five pushes expected")
+	assert.ElementsMatch(t, []int64{0, 1000, 2000, 3000, 4000, 5000, 6000, 7000, 8000, 9000}, w.batches[0],
+		"Oldest data points first")
+}
+
+// Success test.
+func TestRunResume(t *testing.T) {
+	w := &recordingWriter{code: 204}
+
+	progress, err := Run(context.Background(), w, FromSlice(testPoints(10)), Options{
+		Rate:       1000,
+		ResumeFrom: time.Unix(7, 0),
+	})
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, int64(7), progress.Skipped, "Older data points must be skipped")
+	assert.Equal(t, [][]int64{{7000, 9000, 8000}}, w.batches, "Data points from the resume time expected")
+}
+
+// Failure test.
+func TestRunErrors(t *testing.T) {
+	w := &recordingWriter{code: 204}
+
+	_, err := Run(context.Background(), w, FromSlice(nil), Options{})
+	assert.Equal(t, ErrorRateInvalid, err, "Rate error expected")
+
+	unordered := &sliceIterator{points: []Point{{Metric: "m1", Timestamp: time.Unix(2, 0)}, {Metric: "m1", Timestamp: time.Unix(1, 0)}}}
+	_, err = Run(context.Background(), w, unordered, Options{Rate: 1000})
+	assert.True(t, errors.Is(err, ErrorSourceUnordered), "Order error expected")
+
+	w = &recordingWriter{code: 500}
+	progress, err := Run(context.Background(), w, FromSlice(testPoints(20)), Options{Rate: 1000, BatchSize: 10})
+	assert.True(t, errors.Is(err, ErrorPushFailed), "Push error expected")
+	assert.Len(t, w.batches, 1, "The run must stop at the first failure")
+	assert.Equal(t, int64(0), progress.Sent, "Nothing sent")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = &recordingWriter{code: 204}
+	progress, err = Run(ctx, w, FromSlice(testPoints(20)), Options{Rate: 1, BatchSize: 10})
+	assert.Equal(t, context.Canceled, err, "Context error expected")
+	assert.Equal(t, int64(10), progress.Sent, "The first batch goes out right away")
+}
@@ -0,0 +1,65 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregator
+
+// Returns a copy of an aggregator of this package that shares no state
+// with it, so that changing one does not change the other. The values of a
+// custom aggregator are copied one level deep. Returns the value as is
+// when it is not an aggregator of this package.
+func Copy(aggr interface{}) interface{} {
+	switch a := aggr.(type) {
+	case *basicAggregator:
+		return a.copy()
+	case *samplingAggregator:
+		return a.copy()
+	case *percentileAggregator:
+		cp := *a
+		cp.samplingAggregator = a.samplingAggregator.copy()
+		return &cp
+	case *rateAggregator:
+		cp := *a
+		cp.basicAggregator = a.basicAggregator.copy()
+		return &cp
+	case *samplerAggregator:
+		cp := *a
+		cp.basicAggregator = a.basicAggregator.copy()
+		return &cp
+	case *customAggregator:
+		cp := *a
+		cp.KeyVal = make(map[string]interface{}, len(a.KeyVal))
+		for k, v := range a.KeyVal {
+			cp.KeyVal[k] = v
+		}
+		return &cp
+	}
+	return aggr
+}
+
+func (ba *basicAggregator) copy() *basicAggregator {
+	if ba == nil {
+		return nil
+	}
+	cp := *ba
+	return &cp
+}
+
+func (sa *samplingAggregator) copy() *samplingAggregator {
+	if sa == nil {
+		return nil
+	}
+	cp := *sa
+	cp.basicAggregator = sa.basicAggregator.copy()
+	return &cp
+}
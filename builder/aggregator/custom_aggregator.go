@@ -14,10 +14,15 @@
 
 package aggregator
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
 
 type customAggregator struct {
-	KeyVal map[string]interface{}
+	KeyVal   map[string]interface{}
+	validate func() error
 }
 
 func NewCustomAggregator(kv map[string]interface{}) *customAggregator {
@@ -36,9 +41,69 @@ func (ca *customAggregator) Name() string {
 }
 
 func (ca *customAggregator) Validate() error {
-	return nil
+	if ca.validate == nil {
+		return nil
+	}
+	return ca.validate()
 }
 
 func (ca *customAggregator) MarshalJSON() ([]byte, error) {
 	return json.Marshal(ca.KeyVal)
 }
+
+// The aggregators below are custom aggregators that validate their
+// parameters.
+
+func NewScaleAggregator(factor float64) *customAggregator {
+	return &customAggregator{
+		KeyVal: map[string]interface{}{"name": "scale", "factor": factor},
+		validate: func() error {
+			if math.IsNaN(factor) || math.IsInf(factor, 0) {
+				return ErrorScaleFactorInvalid
+			}
+			return nil
+		},
+	}
+}
+
+func NewTrimAggregator(trim string) *customAggregator {
+	return &customAggregator{
+		KeyVal: map[string]interface{}{"name": "trim", "trim": trim},
+		validate: func() error {
+			switch trim {
+			case "first", "last", "both":
+				return nil
+			}
+			return fmt.Errorf("%w: got %q", ErrorTrimInvalid, trim)
+		},
+	}
+}
+
+func NewFilterAggregator(op string, threshold float64) *customAggregator {
+	return &customAggregator{
+		KeyVal: map[string]interface{}{"name": "filter", "filter_op": op, "threshold": threshold},
+		validate: func() error {
+			switch op {
+			case "equal", "lt", "lte", "gt", "gte":
+			default:
+				return fmt.Errorf("%w: got %q", ErrorFilterOpInvalid, op)
+			}
+			if math.IsNaN(threshold) || math.IsInf(threshold, 0) {
+				return ErrorFilterThresholdInvalid
+			}
+			return nil
+		},
+	}
+}
+
+func NewSaveAsAggregator(metricName string) *customAggregator {
+	return &customAggregator{
+		KeyVal: map[string]interface{}{"name": "save_as", "metric_name": metricName},
+		validate: func() error {
+			if metricName == "" {
+				return ErrorSaveAsNameInvalid
+			}
+			return nil
+		},
+	}
+}
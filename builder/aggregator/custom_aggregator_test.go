@@ -0,0 +1,64 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregator
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestCustomAggregators(t *testing.T) {
+	tests := []struct {
+		aggr *customAggregator
+		json string
+	}{
+		{NewScaleAggregator(2.5), `{"factor":2.5,"name":"scale"}`},
+		{NewTrimAggregator("both"), `{"name":"trim","trim":"both"}`},
+		{NewFilterAggregator("lte", 0), `{"filter_op":"lte","name":"filter","threshold":0}`},
+		{NewSaveAsAggregator("cpu.saved"), `{"metric_name":"cpu.saved","name":"save_as"}`},
+		{NewCustomAggregator(map[string]interface{}{"name": "div", "divisor": 2}), `{"divisor":2,"name":"div"}`},
+	}
+
+	for _, test := range tests {
+		assert.Nil(t, test.aggr.Validate(), "No error expected")
+
+		data, err := json.Marshal(test.aggr)
+		assert.Nil(t, err, "No error expected")
+		assert.Equal(t, test.json, string(data))
+	}
+}
+
+// Failure test.
+func TestCustomAggregatorsInvalid(t *testing.T) {
+	tests := []struct {
+		aggr *customAggregator
+		err  error
+	}{
+		{NewScaleAggregator(math.Inf(1)), ErrorScaleFactorInvalid},
+		{NewTrimAggregator("middle"), ErrorTrimInvalid},
+		{NewFilterAggregator("ne", 1), ErrorFilterOpInvalid},
+		{NewFilterAggregator("lt", math.NaN()), ErrorFilterThresholdInvalid},
+		{NewSaveAsAggregator(""), ErrorSaveAsNameInvalid},
+	}
+
+	for _, test := range tests {
+		assert.True(t, errors.Is(test.aggr.Validate(), test.err), test.err.Error())
+	}
+}
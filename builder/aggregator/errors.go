@@ -23,4 +23,14 @@ var (
 
 	ErrorSamplingAggrValueInvalid     = errors.New("Sampling Aggregator value must be > 0")
 	ErrorSamplingAggrStartTimeInvalid = errors.New("Sampling Aggregator start time must be > 0")
+	ErrorSamplingAggrUnitInvalid      = errors.New("Sampling Aggregator unit invalid")
+
+	ErrorRateAggrUnitInvalid = errors.New("Rate Aggregator unit invalid")
+
+	ErrorSamplerAggrUnitInvalid = errors.New("Sampler Aggregator unit invalid")
+	ErrorScaleFactorInvalid     = errors.New("Scale Aggregator factor must be finite")
+	ErrorTrimInvalid            = errors.New("Trim Aggregator trim must be first, last or both")
+	ErrorFilterOpInvalid        = errors.New("Filter Aggregator operation invalid")
+	ErrorFilterThresholdInvalid = errors.New("Filter Aggregator threshold must be finite")
+	ErrorSaveAsNameInvalid      = errors.New("Save As Aggregator metric name empty")
 )
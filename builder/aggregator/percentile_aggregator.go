@@ -18,7 +18,7 @@ import "github.com/retoool/go-kairosdb/builder/utils"
 
 type percentileAggregator struct {
 	*samplingAggregator
-	PercentileValue float64
+	PercentileValue float64 `json:"percentile"`
 }
 
 func NewPercentileAggregator(percentile float64, value int, unit utils.TimeUnit) *percentileAggregator {
@@ -15,6 +15,7 @@
 package aggregator
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/retoool/go-kairosdb/builder/utils"
@@ -33,6 +34,14 @@ func TestPercentileAggr(t *testing.T) {
 	assert.EqualValues(t, utils.MINUTES, pa.Unit(), "Percentile aggregator time unit must be set 'minutes'")
 }
 
+// Success test.
+func TestPercentileAggrJSON(t *testing.T) {
+	data, err := json.Marshal(NewPercentileAggregator(0.95, 1, utils.HOURS))
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `{"name":"percentile","sampling":{"value":1,"unit":"hours"},"percentile":0.95}`, string(data),
+		"Percentile must be encoded as KairosDB expects")
+}
+
 // Failure test.
 func TestPercentileAggrZeroPercentile(t *testing.T) {
 	pa := NewPercentileAggregator(0.0, 100, utils.MINUTES)
@@ -14,7 +14,11 @@
 
 package aggregator
 
-import "github.com/retoool/go-kairosdb/builder/utils"
+import (
+	"fmt"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+)
 
 type rateAggregator struct {
 	*basicAggregator
@@ -38,5 +42,9 @@ func (ra *rateAggregator) Validate() error {
 		return err
 	}
 
+	if !ra.UnitVal.IsValid() {
+		return fmt.Errorf("%w: %s got %q", ErrorRateAggrUnitInvalid, ra.Name(), ra.UnitVal)
+	}
+
 	return nil
 }
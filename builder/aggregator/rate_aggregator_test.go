@@ -15,6 +15,7 @@
 package aggregator
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/retoool/go-kairosdb/builder/utils"
@@ -27,3 +28,9 @@ func TestRateAggregator(t *testing.T) {
 	assert.Equal(t, "rate", ra.Name(), "Rate aggregator name field must be set to 'rate'")
 	assert.EqualValues(t, utils.MINUTES, ra.Unit(), "Rate aggregator unit must be set minutes")
 }
+
+// Failure test.
+func TestRateAggrUnitInvalid(t *testing.T) {
+	err := NewRateAggregator("").Validate()
+	assert.True(t, errors.Is(err, ErrorRateAggrUnitInvalid), "Rate aggregator unit must be set")
+}
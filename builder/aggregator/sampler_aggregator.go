@@ -0,0 +1,51 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregator
+
+import (
+	"fmt"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+)
+
+type samplerAggregator struct {
+	*basicAggregator
+	UnitVal utils.TimeUnit `json:"unit,omitempty"`
+}
+
+// Returns a sampler aggregator computing the sampling rate of change per
+// unit. Without a unit the server default, milliseconds, is used.
+func NewSamplerAggregator(unit utils.TimeUnit) *samplerAggregator {
+	return &samplerAggregator{
+		basicAggregator: NewBasicAggregator("sampler"),
+		UnitVal:         unit,
+	}
+}
+
+func (sa *samplerAggregator) Unit() utils.TimeUnit {
+	return sa.UnitVal
+}
+
+func (sa *samplerAggregator) Validate() error {
+	if err := sa.basicAggregator.Validate(); err != nil {
+		return err
+	}
+
+	if sa.UnitVal != "" && !sa.UnitVal.IsValid() {
+		return fmt.Errorf("%w: got %q", ErrorSamplerAggrUnitInvalid, sa.UnitVal)
+	}
+
+	return nil
+}
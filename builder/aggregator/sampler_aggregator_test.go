@@ -0,0 +1,43 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aggregator
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestSamplerAggregator(t *testing.T) {
+	sa := NewSamplerAggregator(utils.MINUTES)
+	assert.Nil(t, sa.Validate(), "No error expected")
+	assert.Equal(t, "sampler", sa.Name(), "Sampler aggregator name field must be set to 'sampler'")
+
+	data, err := json.Marshal(sa)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `{"name":"sampler","unit":"minutes"}`, string(data))
+
+	assert.Nil(t, NewSamplerAggregator("").Validate(), "Unit must be optional")
+}
+
+// Failure test.
+func TestSamplerAggrUnitInvalid(t *testing.T) {
+	err := NewSamplerAggregator("fortnights").Validate()
+	assert.True(t, errors.Is(err, ErrorSamplerAggrUnitInvalid), "Sampler aggregator unit must be valid")
+}
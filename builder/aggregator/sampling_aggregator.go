@@ -14,7 +14,11 @@
 
 package aggregator
 
-import "github.com/retoool/go-kairosdb/builder/utils"
+import (
+	"fmt"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+)
 
 type sampling struct {
 	Value int            `json:"value,omitempty"`
@@ -99,6 +103,10 @@ func (sa *samplingAggregator) Validate() error {
 		return ErrorSamplingAggrValueInvalid
 	}
 
+	if !sa.Sample.Unit.IsValid() {
+		return fmt.Errorf("%w: %s got %q", ErrorSamplingAggrUnitInvalid, sa.Name(), sa.Sample.Unit)
+	}
+
 	if sa.StartTimeValue < 0 {
 		return ErrorSamplingAggrStartTimeInvalid
 	}
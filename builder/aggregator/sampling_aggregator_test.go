@@ -15,6 +15,7 @@
 package aggregator
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/retoool/go-kairosdb/builder/utils"
@@ -71,3 +72,13 @@ func TestSamplingAggrStartTimeAlign(t *testing.T) {
 	assert.False(t, sa.AlignSampling(), "Sampling Alignment must be false")
 	assert.Equal(t, int64(123), sa.StartTime(), "Sampling Alignment Start Time value is not the same")
 }
+
+// Failure test.
+func TestSamplingAggrUnitInvalid(t *testing.T) {
+	sa := NewSamplingAggregator("test", 1, "fortnights")
+	err := sa.Validate()
+	assert.True(t, errors.Is(err, ErrorSamplingAggrUnitInvalid), "Sampling aggregator unit must be known")
+	assert.Contains(t, err.Error(), `"fortnights"`, "Unit must be named")
+
+	assert.Nil(t, NewSamplingAggregator("test", 1, "Hours").Validate(), "Units are case insensitive")
+}
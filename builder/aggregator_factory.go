@@ -164,6 +164,15 @@ func CreateSamplerAggregator() Aggregator {
 	return aggregator.NewBasicAggregator("sampler")
 }
 
+// Creates an aggregator that computes the sampling rate of change for the data points
+// per the given unit of time.
+//
+// @param unit unit of time
+// @return sampler aggregator
+func CreateSamplerAggregatorWithUnit(unit utils.TimeUnit) Aggregator {
+	return aggregator.NewSamplerAggregator(unit)
+}
+
 // Creates an aggregator that returns the rate of change between each pair of data points
 //
 // @param unit unit of time
@@ -188,10 +197,7 @@ func CreateDivAggregator(divisor float64) Aggregator {
 // @param factor factor to scale by
 // @return sampler aggregator
 func CreateScaleAggregator(factor float64) Aggregator {
-	m := make(map[string]interface{})
-	m["name"] = "scale"
-	m["factor"] = factor
-	return aggregator.NewCustomAggregator(m)
+	return aggregator.NewScaleAggregator(factor)
 }
 
 // Creates an aggregator that saves the results of the query to a new metric.
@@ -199,10 +205,7 @@ func CreateScaleAggregator(factor float64) Aggregator {
 // @param newMetricName metric to save results to
 // @return save as aggregator
 func CreateSaveAsAggregator(newMetricName string) Aggregator {
-	m := make(map[string]interface{})
-	m["name"] = "save_as"
-	m["metric_name"] = newMetricName
-	return aggregator.NewCustomAggregator(m)
+	return aggregator.NewSaveAsAggregator(newMetricName)
 }
 
 // Creates an aggregator that trim of the first, last, or both data points returned by
@@ -211,10 +214,7 @@ func CreateSaveAsAggregator(newMetricName string) Aggregator {
 // @param trim what to trim
 // @return trim aggregator
 func CreateTrimAggregator(trim TrimType) Aggregator {
-	m := make(map[string]interface{})
-	m["name"] = "trim"
-	m["trim"] = trim
-	return aggregator.NewCustomAggregator(m)
+	return aggregator.NewTrimAggregator(string(trim))
 }
 
 // 增加过滤聚合器 20191102 by wutz
@@ -223,9 +223,5 @@ func CreateTrimAggregator(trim TrimType) Aggregator {
 // @param threshold the value the operation is performed on. If the operation is lt, then a null data point is returned if the data point is less than the threshold.
 // @return filter aggregator
 func CreateFilterAggregator(operation FilterOp, threshold float64) Aggregator {
-	m := make(map[string]interface{})
-	m["name"] = "filter"
-	m["filter_op"] = operation
-	m["threshold"] = threshold
-	return aggregator.NewCustomAggregator(m)
+	return aggregator.NewFilterAggregator(string(operation), threshold)
 }
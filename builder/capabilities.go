@@ -0,0 +1,101 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// The query features a KairosDB server supports, see QueryBuilder.BuildFor.
+type Capabilities struct {
+	// Version of the server, named in the errors.
+	Version string
+
+	// Names of the aggregators the server knows. Nil allows all of them.
+	Aggregators []string
+
+	// Names of the group_by types the server knows. Nil allows all of them.
+	GroupBys []string
+
+	// Whether range aggregators accept the align_end_time option.
+	AlignEndTime bool
+}
+
+// The KairosDB version that introduced a feature missing from the older ones.
+var featureSince = map[string]string{
+	`aggregator "first"`:   "1.1",
+	`aggregator "gaps"`:    "1.1",
+	`aggregator "last"`:    "1.1",
+	`aggregator "save_as"`: "1.1",
+	`aggregator "trim"`:    "1.1",
+	`aggregator "filter"`:  "1.2",
+	`group_by "bin"`:       "1.2",
+	"align_end_time":       "1.2",
+}
+
+func (c Capabilities) notSupported(feature string) error {
+	if since, ok := featureSince[feature]; ok {
+		return fmt.Errorf("%w: %s requires KairosDB %s or later, server is %s", ErrorFeatureNotSupported, feature, since, c.Version)
+	}
+	return fmt.Errorf("%w: %s with KairosDB %s", ErrorFeatureNotSupported, feature, c.Version)
+}
+
+// Checks an encoded query against the capabilities.
+func (c Capabilities) check(data []byte) error {
+	var q struct {
+		Metrics []struct {
+			Aggregators []map[string]json.RawMessage `json:"aggregators"`
+			GroupBy     []struct {
+				Name string `json:"name"`
+			} `json:"group_by"`
+		} `json:"metrics"`
+	}
+	if err := json.Unmarshal(data, &q); err != nil {
+		return err
+	}
+
+	for _, m := range q.Metrics {
+		for _, aggr := range m.Aggregators {
+			var name string
+			json.Unmarshal(aggr["name"], &name)
+
+			if c.Aggregators != nil && !containsName(c.Aggregators, name) {
+				return c.notSupported(fmt.Sprintf("aggregator %q", name))
+			}
+
+			if _, ok := aggr["align_end_time"]; ok && !c.AlignEndTime {
+				return c.notSupported("align_end_time")
+			}
+		}
+
+		for _, gp := range m.GroupBy {
+			if c.GroupBys != nil && !containsName(c.GroupBys, gp.Name) {
+				return c.notSupported(fmt.Sprintf("group_by %q", gp.Name))
+			}
+		}
+	}
+
+	return nil
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
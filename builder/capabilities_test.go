@@ -0,0 +1,68 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestBuildFor(t *testing.T) {
+	qb := NewQueryBuilder().SetRelativeStart(1, utils.HOURS)
+	qb.AddMetric("m1").AddAggregator(CreateSumAggregator(1, utils.MINUTES)).AddGrouper(CreateTagsGroupBy([]string{"host"}))
+
+	caps := Capabilities{Version: "0.9.x", Aggregators: []string{"sum"}, GroupBys: []string{"tag"}}
+	j, err := qb.BuildFor(caps)
+	assert.Nil(t, err, "No error expected")
+
+	built, _ := qb.Build()
+	assert.Equal(t, built, j, "Same JSON as Build expected")
+
+	j, err = qb.BuildFor(Capabilities{})
+	assert.Nil(t, err, "Nil lists allow everything")
+	assert.Equal(t, built, j, "Same JSON as Build expected")
+}
+
+// Failure test.
+func TestBuildForNotSupported(t *testing.T) {
+	caps := Capabilities{Version: "1.1.x", Aggregators: []string{"sum"}, GroupBys: []string{"tag", "time", "value"}}
+
+	qb := NewQueryBuilder().SetRelativeStart(1, utils.HOURS)
+	qb.AddMetric("m1").AddAggregator(CreateFilterAggregator(FilterOp_LT, 1))
+	_, err := qb.BuildFor(caps)
+	assert.True(t, errors.Is(err, ErrorFeatureNotSupported), "Aggregator must be rejected")
+	assert.Equal(t, `Feature not supported by the server: aggregator "filter" requires KairosDB 1.2 or later, server is 1.1.x`, err.Error())
+
+	qb = NewQueryBuilder().SetRelativeStart(1, utils.HOURS)
+	qb.AddMetric("m1").AddAggregator(CreateSumAggregator(1, utils.MINUTES)).AddGrouper(groupByName("custom"))
+	_, err = qb.BuildFor(caps)
+	assert.Equal(t, `Feature not supported by the server: group_by "custom" with KairosDB 1.1.x`, err.Error())
+
+	frozen, _ := qb.Freeze()
+	_, err = frozen.BuildFor(caps)
+	assert.True(t, errors.Is(err, ErrorFeatureNotSupported), "Frozen queries must be checked as well")
+}
+
+type groupByName string
+
+func (g groupByName) Name() string    { return string(g) }
+func (g groupByName) Validate() error { return nil }
+func (g groupByName) MarshalJSON() ([]byte, error) {
+	return []byte(`{"name":"` + string(g) + `"}`), nil
+}
@@ -0,0 +1,26 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+// Name of the KairosDB data type storing complex numbers, as real and
+// imaginary parts. Metrics holding Go complex64 or complex128 values are sent
+// with this type unless another one was set with AddType.
+const ComplexType = "complex-number"
+
+// JSON encoding of a complex number data point value.
+type complexValue struct {
+	Real      float64 `json:"real"`
+	Imaginary float64 `json:"imaginary"`
+}
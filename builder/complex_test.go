@@ -0,0 +1,53 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestComplexDataPoint(t *testing.T) {
+	m := NewMetric("fft").AddTag("sensor", "s1").AddDataPoint(1, complex(1.5, -2)).AddDataPoint(2, complex64(3))
+	j, err := m.Build()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `{"name":"fft","type":"complex-number","tags":{"sensor":"s1"},`+
+		`"datapoints":[[1,{"real":1.5,"imaginary":-2}],[2,{"real":3,"imaginary":0}]]}`, string(j),
+		"Complex values must be encoded as real and imaginary parts")
+
+	val, err := m.GetDataPoints()[0].ComplexValue()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, complex(1.5, -2), val, "Got different value")
+
+	var dp DataPoint
+	err = json.Unmarshal([]byte(`[3,{"real":0.5,"imaginary":4}]`), &dp)
+	assert.Nil(t, err, "No error expected")
+	val, err = dp.ComplexValue()
+	assert.Nil(t, err, "Decoded complex value expected")
+	assert.Equal(t, complex(0.5, 4), val, "Got different value")
+}
+
+// Failure test.
+func TestComplexDataPointInvalid(t *testing.T) {
+	_, err := NewDataPoint(1, 2.5).ComplexValue()
+	assert.Equal(t, ErrorDataPointComplex, err, "Expecting an error")
+
+	j, err := NewMetric("fft").AddDataPoint(1, complex(0, 1)).AddType("my_type").Build()
+	assert.Nil(t, err, "No error expected")
+	assert.Contains(t, string(j), `"type":"my_type"`, "Explicit type must win")
+}
@@ -0,0 +1,54 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+// The data points of a series, laid out as the push API expects them. It is
+// a lighter alternative to MetricBuilder for code that already holds
+// structured data, e.g. decoded from a message queue: the fields are used as
+// is, without normalization or timestamp conversion.
+type DataPointSet struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type,omitempty"` // Custom data type, if any.
+	Tags       map[string]string `json:"tags"`
+	DataPoints []DataPoint       `json:"datapoints"`    // Timestamps in milliseconds.
+	TTL        int64             `json:"ttl,omitempty"` // In seconds, zero for none.
+}
+
+// Checks the set the same way Metric validation does.
+func (s *DataPointSet) Validate() error {
+	if s.Name == "" {
+		return ErrorMetricNameInvalid
+	}
+
+	for k, v := range s.Tags {
+		if k == "" {
+			return ErrorTagNameInvalid
+		} else if v == "" {
+			return ErrorTagValueInvalid
+		}
+	}
+
+	if s.TTL < 0 {
+		return ErrorTTLInvalid
+	}
+
+	for _, dp := range s.DataPoints {
+		if err := validateValue(dp.value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -15,14 +15,17 @@
 package builder
 
 import (
+	"bytes"
 	"encoding/json"
-	"errors"
+	"math"
+	"strconv"
 )
 
 // Represents a measurement. Stores the time when the measurement occurred and its value.
 type DataPoint struct {
 	timestamp int64
 	value     interface{}
+	number    json.Number // The value as written in JSON, when decoded from a number.
 }
 
 func NewDataPoint(ts int64, val interface{}) *DataPoint {
@@ -36,6 +39,19 @@ func (dp *DataPoint) Timestamp() int64 {
 	return dp.timestamp
 }
 
+// Returns the value as is, e.g. a float64 for a data point decoded from a
+// query response.
+func (dp *DataPoint) Value() interface{} {
+	return dp.value
+}
+
+// Returns the number a data point decoded from JSON was written as, which
+// float64 loses: whether it is integral, such as 2 as opposed to 2.0, and
+// the exact value of longs beyond ±2^53. False for other data points.
+func (dp *DataPoint) Number() (json.Number, bool) {
+	return dp.number, dp.number != ""
+}
+
 func (dp *DataPoint) Int64Value() (int64, error) {
 	val, ok := dp.value.(int64)
 	if !ok {
@@ -59,34 +75,177 @@ func (dp *DataPoint) Float64Value() (float64, error) {
 
 //20191101 add by wutz (no need)
 func (dp *DataPoint) Float32Value() (float32, error) {
-	val, ok := dp.value.(float32)
-	if !ok {
-		return 0, ErrorDataPointFloat32
+	switch val := dp.value.(type) {
+	case float32:
+		return val, nil
+	case float64:
+		// float32 values added to a metric are stored as float64.
+		return float32(val), nil
 	}
-	return val, nil
+	return 0, ErrorDataPointFloat32
+}
+
+// Converts the Go numeric types to the int64 and float64 values KairosDB
+// stores as long and double. Unsigned values above math.MaxInt64 are kept as
+// is and rejected when the metric is validated. Other values are returned
+// unchanged.
+func normalizeValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint:
+		if uint64(v) <= math.MaxInt64 {
+			return int64(v)
+		}
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v)
+		}
+	case float32:
+		// Go through the shortest decimal representation so that e.g. 0.1
+		// is not sent as 0.10000000149011612.
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(v), 'g', -1, 32), 64)
+		return f
+	case complex64:
+		return complexValue{Real: float64(real(v)), Imaginary: float64(imag(v))}
+	case complex128:
+		return complexValue{Real: real(v), Imaginary: imag(v)}
+	}
+
+	return value
+}
+
+// Checks that the value fits in the type KairosDB will store it as.
+func validateValue(value interface{}) error {
+	switch value.(type) {
+	case uint, uint64:
+		// Only left unsigned by normalizeValue when above math.MaxInt64.
+		return ErrorDataPointOverflow
+	}
+
+	if isNonFinite(value) {
+		return ErrorDataPointNonFinite
+	}
+
+	return nil
+}
+
+// Returns whether the value is NaN or an infinity, which JSON cannot encode.
+func isNonFinite(value interface{}) bool {
+	switch v := value.(type) {
+	case float64:
+		return math.IsNaN(v) || math.IsInf(v, 0)
+	case complexValue:
+		return isNonFinite(v.Real) || isNonFinite(v.Imaginary)
+	}
+	return false
+}
+
+// Returns the value of a complex number data point, either added to a metric
+// as a Go complex number or decoded from a query response.
+func (dp *DataPoint) ComplexValue() (complex128, error) {
+	switch v := dp.value.(type) {
+	case complexValue:
+		return complex(v.Real, v.Imaginary), nil
+	case complex128:
+		return v, nil
+	case map[string]interface{}:
+		re, ok1 := v["real"].(float64)
+		im, ok2 := v["imaginary"].(float64)
+		if ok1 && ok2 {
+			return complex(re, im), nil
+		}
+	}
+	return 0, ErrorDataPointComplex
 }
 
 func (dp *DataPoint) MarshalJSON() ([]byte, error) {
 	data := []interface{}{dp.timestamp, dp.value}
+	if dp.number != "" {
+		// Encoded again exactly as decoded.
+		data[1] = dp.number
+	}
 	return json.Marshal(data)
 }
 
 func (dp *DataPoint) UnmarshalJSON(data []byte) error {
-	var arr []interface{}
+	var arr []json.RawMessage
 	err := json.Unmarshal(data, &arr)
 	if err != nil {
 		return err
 	}
 
-	var v float64
-	ok := false
-	if v, ok = arr[0].(float64); !ok {
-		return errors.New("Invalid Timestamp type")
+	if len(arr) != 2 {
+		return ErrorDataPointMalformed
+	}
+
+	ts, err := decodeTimestamp(arr[0])
+	if err != nil {
+		return err
+	}
+
+	// Values are left as decoded by encoding/json: float64 for numbers,
+	// whether KairosDB stored them as long or double, nil for null, string
+	// or map[string]interface{} for complex values. Numbers are kept as
+	// written as well, see Number.
+	dec := json.NewDecoder(bytes.NewReader(arr[1]))
+	dec.UseNumber()
+	var v interface{}
+	if err = dec.Decode(&v); err != nil {
+		return err
+	}
+
+	var num json.Number
+	switch n := v.(type) {
+	case json.Number:
+		num = n
+		if v, err = n.Float64(); err != nil {
+			return err
+		}
+	case map[string]interface{}, []interface{}:
+		// Numbers nested in complex values are float64.
+		if err = json.Unmarshal(arr[1], &v); err != nil {
+			return err
+		}
 	}
 
 	// Update the receiver with the values decoded.
-	dp.timestamp = int64(v)
-	dp.value = arr[1]
+	dp.timestamp = ts
+	dp.value = v
+	dp.number = num
 
 	return nil
 }
+
+// Decodes a timestamp sent as a JSON number or, by some proxies, as a string
+// holding a number.
+func decodeTimestamp(data json.RawMessage) (int64, error) {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		s = string(data)
+	}
+
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return ts, nil
+	}
+
+	// Numbers such as 1.5e12 are valid JSON as well.
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, ErrorDataPointTimestampInvalid
+	}
+
+	return int64(f), nil
+}
@@ -15,6 +15,7 @@
 package builder
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -67,3 +68,98 @@ func TestDataPointInvalidValue(t *testing.T) {
 	_, err = dp.Float64Value()
 	assert.Equal(t, ErrorDataPointFloat64, err, "Expecting an error")
 }
+
+func TestDataPointUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		data  string
+		ts    int64
+		value interface{}
+	}{
+		{`[1000, 1.5]`, 1000, 1.5},
+		{`[1000, 7]`, 1000, float64(7)},
+		{`["1000", "7"]`, 1000, "7"},
+		{`[1.5e3, null]`, 1500, nil},
+		{`[9007199254740993, 1]`, 9007199254740993, float64(1)},
+	}
+
+	for _, test := range tests {
+		var dp DataPoint
+		err := dp.UnmarshalJSON([]byte(test.data))
+		assert.Nil(t, err, test.data)
+		assert.Equal(t, test.ts, dp.Timestamp(), test.data)
+		assert.Equal(t, test.value, dp.Value(), test.data)
+	}
+}
+
+func TestDataPointUnmarshalJSONNumber(t *testing.T) {
+	tests := []struct {
+		data   string
+		value  interface{}
+		number json.Number
+	}{
+		{`[1000, 2.0]`, 2.0, "2.0"},
+		{`[1000, 2]`, float64(2), "2"},
+		{`[1000, 9007199254740993]`, float64(9007199254740993), "9007199254740993"},
+		{`[1000, "2"]`, "2", ""},
+		{`[1000, {"real": 1, "imaginary": 2}]`, map[string]interface{}{"real": float64(1), "imaginary": float64(2)}, ""},
+	}
+
+	for _, test := range tests {
+		var dp DataPoint
+		assert.Nil(t, dp.UnmarshalJSON([]byte(test.data)), test.data)
+		assert.Equal(t, test.value, dp.Value(), test.data)
+
+		n, ok := dp.Number()
+		assert.Equal(t, test.number != "", ok, test.data)
+		assert.Equal(t, test.number, n, test.data)
+
+		// Numbers are encoded again as written.
+		data, err := dp.MarshalJSON()
+		assert.Nil(t, err, test.data)
+		assert.JSONEq(t, test.data, string(data), test.data)
+		if ok {
+			assert.Equal(t, "[1000,"+string(n)+"]", string(data), test.data)
+		}
+	}
+
+	_, ok := NewDataPoint(1000, 2.0).Number()
+	assert.False(t, ok, "No number expected for data points not decoded")
+}
+
+func TestDataPointUnmarshalJSONMalformed(t *testing.T) {
+	tests := []struct {
+		data string
+		err  error
+	}{
+		{`[]`, ErrorDataPointMalformed},
+		{`[1000]`, ErrorDataPointMalformed},
+		{`[1000, 1, 2]`, ErrorDataPointMalformed},
+		{`null`, ErrorDataPointMalformed},
+		{`["abc", 1]`, ErrorDataPointTimestampInvalid},
+		{`[null, 1]`, ErrorDataPointTimestampInvalid},
+		{`[1e300, 1]`, ErrorDataPointTimestampInvalid},
+	}
+
+	for _, test := range tests {
+		var dp DataPoint
+		assert.Equal(t, test.err, dp.UnmarshalJSON([]byte(test.data)), test.data)
+	}
+}
+
+func FuzzDataPointUnmarshalJSON(f *testing.F) {
+	for _, seed := range []string{`[1000, 1.5]`, `["1000", "7"]`, `[1000, {"real": 1, "imaginary": 2}]`, `[]`, `[1]`, `null`, `[1e300, 1]`} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var dp DataPoint
+		if dp.UnmarshalJSON(data) != nil {
+			return
+		}
+
+		// Whatever decodes must encode again.
+		if _, err := dp.MarshalJSON(); err != nil {
+			t.Fatalf("%q decoded but does not encode: %v", data, err)
+		}
+	})
+}
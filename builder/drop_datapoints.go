@@ -0,0 +1,54 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+// Returns a builder holding the metrics of mb as its Build method encodes
+// them, minus the data points for which drop returns true. metric and point
+// are the indexes of a data point in the JSON encoded by Build, which is
+// how KairosDB refers to them in its validation errors. A nil drop keeps
+// all the data points.
+//
+// The non finite policy of mb is applied to the returned metrics, which are
+// copies when they differ from the ones of mb. Metrics left without data
+// points are left out.
+func DropDataPoints(mb MetricBuilder, drop func(metric, point int) bool) MetricBuilder {
+	metrics := mb.GetMetrics()
+	if b, ok := mb.(*mBuilder); ok && b.nonFinite.Action != NonFiniteError {
+		metrics = b.applyNonFinitePolicy()
+	}
+
+	out := &mBuilder{Metrics: make([]Metric, 0, len(metrics))}
+	for i, m := range metrics {
+		mt, ok := m.(*metricType)
+		if !ok || drop == nil {
+			out.Metrics = append(out.Metrics, m)
+			continue
+		}
+
+		cp := *mt
+		cp.DataPoints = make([]DataPoint, 0, len(mt.DataPoints))
+		for j, dp := range mt.DataPoints {
+			if !drop(i, j) {
+				cp.DataPoints = append(cp.DataPoints, dp)
+			}
+		}
+
+		if len(cp.DataPoints) > 0 {
+			out.Metrics = append(out.Metrics, &cp)
+		}
+	}
+
+	return out
+}
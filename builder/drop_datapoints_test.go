@@ -0,0 +1,41 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDropDataPoints(t *testing.T) {
+	var dropped []int64
+	mb := NewMetricBuilder().SetNonFinitePolicy(NonFinitePolicy{
+		Action: NonFiniteDrop,
+		OnDrop: func(metric string, dp DataPoint) { dropped = append(dropped, dp.Timestamp()) },
+	})
+	mb.AddMetric("m1").AddTag("t", "v").AddDataPoint(1, math.NaN()).AddDataPoint(2, 2).AddDataPoint(3, 3)
+	mb.AddMetric("m2").AddTag("t", "v").AddDataPoint(1, 1)
+
+	// Indexes refer to the encoded metrics, without the NaN.
+	out := DropDataPoints(mb, func(metric, point int) bool { return metric == 1 || point == 1 })
+
+	j, err := out.Build()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `[{"name":"m1","tags":{"t":"v"},"datapoints":[[2,2]]}]`, string(j))
+	assert.Equal(t, []int64{1}, dropped, "Non finite policy must be applied once")
+	assert.Len(t, mb.GetMetrics()[0].GetDataPoints(), 3, "Original metrics must not be modified")
+}
@@ -24,9 +24,15 @@ var (
 	ErrorTTLInvalid        = errors.New("TTL value invalid")
 
 	// Data Point Errors.
-	ErrorDataPointInt64   = errors.New("Not an int64 data value")
-	ErrorDataPointFloat32 = errors.New("Not a float32 data value")
-	ErrorDataPointFloat64 = errors.New("Not a float64 data value")
+	ErrorDataPointInt64                = errors.New("Not an int64 data value")
+	ErrorDataPointFloat32              = errors.New("Not a float32 data value")
+	ErrorDataPointFloat64              = errors.New("Not a float64 data value")
+	ErrorDataPointOverflow             = errors.New("Data point value overflows int64")
+	ErrorDataPointNonFinite            = errors.New("Data point value is NaN or infinite")
+	ErrorDataPointComplex              = errors.New("Not a complex data value")
+	ErrorDataPointTimestampImplausible = errors.New("Data point timestamp out of bounds")
+	ErrorDataPointTimestampInvalid     = errors.New("Invalid Timestamp type")
+	ErrorDataPointMalformed            = errors.New("Data point is not a [timestamp, value] pair")
 
 	// Query Metric Errors.
 	ErrorQMetricNameInvalid     = errors.New("Query Metric name empty")
@@ -40,4 +46,25 @@ var (
 	ErrorAbsRelativeEndSet        = errors.New("Both absolute and relative end times cannot be set")
 	ErrorRelativeEndTimeInvalid   = errors.New("Relative end time duration must be > 0")
 	ErrorStartTimeNotSpecified    = errors.New("Start time not specified")
+	ErrorAbsoluteStartInvalid     = errors.New("Absolute start time invalid")
+	ErrorAbsoluteEndInvalid       = errors.New("Absolute end time invalid")
+	ErrorRelativeStartUnitInvalid = errors.New("Relative start time unit invalid")
+	ErrorRelativeEndUnitInvalid   = errors.New("Relative end time unit invalid")
+	ErrorChunkSizeInvalid         = errors.New("Chunk size must be >= 1ms")
+	ErrorQueryNotSplittable       = errors.New("Query results would change if split")
+	ErrorMetricIndexInvalid       = errors.New("Metric index out of range")
+	ErrorQueryFrozen              = errors.New("Query is frozen and cannot be modified")
+	ErrorTimeGroupRangeTooLarge   = errors.New("Time group range size exceeds the query time range")
+	ErrorFeatureNotSupported      = errors.New("Feature not supported by the server")
+
+	// Roll-up Builder Errors.
+	ErrorRollupNameInvalid        = errors.New("Roll-up task name empty")
+	ErrorExecutionIntervalInvalid = errors.New("Roll-up execution interval must be > 0")
+	ErrorNoRollups                = errors.New("Roll-up task has no roll-ups")
+	ErrorRollupSaveAsInvalid      = errors.New("Roll-up metric name empty")
+	ErrorRollupStartNotRelative   = errors.New("Roll-up query must have a relative start time")
+	ErrorRollupSaveAsQueried      = errors.New("Roll-up saved as a metric it queries")
+
+	// Extension Errors.
+	ErrorExtensionInvalid = errors.New("Extension name empty or value not valid JSON")
 )
@@ -0,0 +1,150 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+)
+
+// Number of buckets per series above which Explain warns about an
+// aggregator.
+const ExplainMaxBuckets = 10000
+
+// The analysis of a query returned by QueryBuilder.Explain.
+type QueryExplanation struct {
+	// The absolute time range of the query and its length.
+	Range TimeRange
+	Span  time.Duration
+
+	Metrics []MetricExplanation
+
+	// Human readable warnings about queries likely to return more data than
+	// meant, e.g. raw data points over a long range.
+	Warnings []string
+}
+
+// The analysis of a metric of a query.
+type MetricExplanation struct {
+	Name        string
+	Limit       int
+	Aggregators []AggregatorExplanation
+}
+
+// The analysis of an aggregator of a query metric.
+type AggregatorExplanation struct {
+	Name string
+
+	// Sampling of the aggregator and the number of buckets it yields per
+	// series over the time range. Both are zero for aggregators without
+	// sampling.
+	Sampling time.Duration
+	Buckets  int64
+}
+
+func (qe *QueryExplanation) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "range %s, span %s, %d metric(s)\n", qe.Range, qe.Span, len(qe.Metrics))
+	for _, m := range qe.Metrics {
+		fmt.Fprintf(&sb, "  metric %q", m.Name)
+		if m.Limit > 0 {
+			fmt.Fprintf(&sb, " limit %d", m.Limit)
+		}
+		sb.WriteString("\n")
+
+		for _, a := range m.Aggregators {
+			if a.Sampling > 0 {
+				fmt.Fprintf(&sb, "    %s every %s: %d bucket(s) per series\n", a.Name, a.Sampling, a.Buckets)
+			} else {
+				fmt.Fprintf(&sb, "    %s\n", a.Name)
+			}
+		}
+	}
+
+	for _, w := range qe.Warnings {
+		fmt.Fprintf(&sb, "warning: %s\n", w)
+	}
+
+	return sb.String()
+}
+
+type sampler interface {
+	Value() int
+	Unit() utils.TimeUnit
+}
+
+func (qb *qBuilder) Explain() (*QueryExplanation, error) {
+	if _, err := qb.Build(); err != nil {
+		return nil, err
+	}
+
+	return explain(qb, time.Now())
+}
+
+func (fq *frozenQuery) Explain() (*QueryExplanation, error) {
+	return explain(fq, time.Now())
+}
+
+func explain(qb QueryBuilder, now time.Time) (*QueryExplanation, error) {
+	tr, err := ResolveTimeRange(qb, now)
+	if err != nil {
+		return nil, err
+	}
+
+	qe := &QueryExplanation{
+		Range: tr,
+		Span:  tr.End.Sub(tr.Start),
+	}
+
+	if qb.RelativeEnd() == nil && qb.AbsoluteEnd().Equal(time.Unix(0, 0)) {
+		qe.Warnings = append(qe.Warnings, "no end time, the range grows up to now on every run")
+	}
+
+	for _, qm := range qb.Metrics() {
+		m, ok := qm.(*qMetric)
+		if !ok {
+			continue
+		}
+
+		me := MetricExplanation{Name: m.Name, Limit: m.Limit}
+		for _, aggr := range m.Aggregators {
+			ae := AggregatorExplanation{Name: aggr.Name()}
+			if s, ok := aggr.(sampler); ok && s.Value() > 0 {
+				// Calendar units such as months are measured back from now.
+				ae.Sampling = now.Sub(utils.NewRelativeTime(s.Value(), s.Unit()).RelativeTimeTo(now))
+			}
+			if ae.Sampling > 0 {
+				ae.Buckets = int64((qe.Span + ae.Sampling - 1) / ae.Sampling)
+				if ae.Buckets > ExplainMaxBuckets {
+					qe.Warnings = append(qe.Warnings, fmt.Sprintf("metric %q: aggregator %q yields %d buckets per series",
+						m.Name, ae.Name, ae.Buckets))
+				}
+			}
+			me.Aggregators = append(me.Aggregators, ae)
+		}
+
+		if len(m.Aggregators) == 0 && m.Limit == 0 {
+			qe.Warnings = append(qe.Warnings, fmt.Sprintf("metric %q: no aggregator and no limit, every raw data point of the %s range is returned",
+				m.Name, qe.Span))
+		}
+
+		qe.Metrics = append(qe.Metrics, me)
+	}
+
+	return qe, nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestExplain(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	qb := NewQueryBuilder()
+	qb.SetTimeRange(now.Add(-24*time.Hour), now)
+	qb.AddMetric("m1").AddAggregator(CreateAverageAggregator(5, utils.MINUTES)).AddAggregator(CreateDiffAggregator())
+	qb.AddMetric("m2").SetLimit(100)
+
+	qe, err := explain(qb, now)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 24*time.Hour, qe.Span)
+	assert.Len(t, qe.Metrics, 2)
+	assert.Equal(t, AggregatorExplanation{Name: "avg", Sampling: 5 * time.Minute, Buckets: 288}, qe.Metrics[0].Aggregators[0])
+	assert.Equal(t, AggregatorExplanation{Name: "diff"}, qe.Metrics[0].Aggregators[1])
+	assert.Equal(t, 100, qe.Metrics[1].Limit)
+	assert.Empty(t, qe.Warnings, "Bounded aggregated query must not raise warnings")
+
+	frozen, err := qb.Freeze()
+	assert.Nil(t, err, "No error expected")
+	fqe, err := frozen.Explain()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, qe.Metrics, fqe.Metrics, "Frozen query must explain the same")
+}
+
+// Success test.
+func TestExplainWarnings(t *testing.T) {
+	now := time.Unix(1000000, 0)
+	qb := NewQueryBuilder()
+	qb.SetRelativeStart(30, utils.DAYS)
+	qb.AddMetric("raw")
+	qb.AddMetric("fine").AddAggregator(CreateSumAggregator(1, utils.SECONDS))
+
+	qe, err := explain(qb, now)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, int64(30*24*3600), qe.Metrics[1].Aggregators[0].Buckets)
+	assert.Equal(t, []string{
+		"no end time, the range grows up to now on every run",
+		`metric "raw": no aggregator and no limit, every raw data point of the 720h0m0s range is returned`,
+		`metric "fine": aggregator "sum" yields 2592000 buckets per series`,
+	}, qe.Warnings)
+	assert.Contains(t, qe.String(), "sum every 1s: 2592000 bucket(s) per series")
+}
+
+// Failure test.
+func TestExplainInvalid(t *testing.T) {
+	qb := NewQueryBuilder()
+	qb.AddMetric("m1")
+
+	_, err := qb.Explain()
+	assert.Equal(t, ErrorStartTimeNotSpecified, err, "Invalid query must not be explained")
+}
@@ -0,0 +1,90 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import "encoding/json"
+
+// Extension properties let users send fields of the KairosDB API this
+// library does not model yet. They are merged verbatim into the JSON object
+// of the element they are attached to, and take precedence over the fields
+// the library sets itself. Objects carrying extensions are encoded with
+// their keys sorted.
+
+// Checks that the extension names are set and the values are valid JSON.
+func validateExtensions(ext map[string]json.RawMessage) error {
+	for k, v := range ext {
+		if k == "" || !json.Valid(v) {
+			return ErrorExtensionInvalid
+		}
+	}
+
+	return nil
+}
+
+// Merges the extensions into the JSON object encoded in data.
+func mergeExtensions(data []byte, ext map[string]json.RawMessage) ([]byte, error) {
+	if len(ext) == 0 {
+		return data, nil
+	}
+
+	if err := validateExtensions(ext); err != nil {
+		return nil, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	for k, v := range ext {
+		fields[k] = v
+	}
+
+	return json.Marshal(fields)
+}
+
+type extAggregator struct {
+	Aggregator
+	ext map[string]json.RawMessage
+}
+
+// Returns an aggregator encoded as aggr with the extension properties
+// merged in, e.g. to set an option of a server side aggregator:
+//
+//	builder.WithExtensions(builder.CreateAverageAggregator(1, utils.MINUTES),
+//		map[string]json.RawMessage{"trim": json.RawMessage(`"first"`)})
+func WithExtensions(aggr Aggregator, ext map[string]json.RawMessage) Aggregator {
+	return &extAggregator{
+		Aggregator: aggr,
+		ext:        ext,
+	}
+}
+
+func (ea *extAggregator) Validate() error {
+	if err := ea.Aggregator.Validate(); err != nil {
+		return err
+	}
+
+	return validateExtensions(ea.ext)
+}
+
+func (ea *extAggregator) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(ea.Aggregator)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeExtensions(data, ea.ext)
+}
@@ -0,0 +1,51 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestExtensions(t *testing.T) {
+	qm := NewQueryMetric("qm1").
+		AddExtension("exclude_tags", json.RawMessage(`true`)).
+		AddAggregator(WithExtensions(CreateAverageAggregator(1, utils.MINUTES),
+			map[string]json.RawMessage{"trim": json.RawMessage(`"first"`)}))
+	assert.Nil(t, qm.Validate(), "No error expected")
+
+	j, err := json.Marshal(qm)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `{"aggregators":[{"name":"avg","sampling":{"value":1,"unit":"minutes"},"trim":"first"}],`+
+		`"exclude_tags":true,"name":"qm1"}`, string(j), "Extensions must be merged with sorted keys")
+
+	j, err = NewMetric("m1").AddExtension("ttl", json.RawMessage(`60`)).AddDataPoint(1, 2).Build()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `{"datapoints":[[1,2]],"name":"m1","ttl":60}`, string(j), "Metric extensions must be merged")
+}
+
+// Failure test.
+func TestExtensionsInvalid(t *testing.T) {
+	err := NewQueryMetric("qm1").AddExtension("bad", json.RawMessage(`{`)).Validate()
+	assert.Equal(t, ErrorExtensionInvalid, err, "Invalid JSON must be rejected")
+
+	j, err := NewMetric("m1").AddExtension("", json.RawMessage(`1`)).Build()
+	assert.Nil(t, j, "Metric object must be nil")
+	assert.Equal(t, ErrorExtensionInvalid, err, "Empty extension name must be rejected")
+}
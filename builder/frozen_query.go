@@ -0,0 +1,208 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder/aggregator"
+	"github.com/retoool/go-kairosdb/builder/grouper"
+	"github.com/retoool/go-kairosdb/builder/utils"
+)
+
+// An immutable snapshot of a query, returned by QueryBuilder.Freeze. The
+// JSON is encoded once at freeze time, so later changes to the original
+// builder or its metrics do not affect what the snapshot sends. The
+// methods modifying a query panic with ErrorQueryFrozen.
+type frozenQuery struct {
+	data       []byte
+	startAbs   time.Time
+	endAbs     time.Time
+	startRel   *utils.RelativeTime
+	endRel     *utils.RelativeTime
+	cacheTime  int
+	metricsArr []QueryMetric
+}
+
+func (qb *qBuilder) Freeze() (QueryBuilder, error) {
+	data, err := qb.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	fq := &frozenQuery{
+		data:       data,
+		startAbs:   qb.AbsoluteStart(),
+		endAbs:     qb.AbsoluteEnd(),
+		cacheTime:  qb.CacheTimeMs,
+		metricsArr: make([]QueryMetric, len(qb.MetricsArr)),
+	}
+	for i, qm := range qb.MetricsArr {
+		fq.metricsArr[i] = copyQueryMetric(qm)
+	}
+
+	if qb.StartRel != nil {
+		rel := *qb.StartRel
+		fq.startRel = &rel
+	}
+
+	if qb.EndRel != nil {
+		rel := *qb.EndRel
+		fq.endRel = &rel
+	}
+
+	return fq, nil
+}
+
+func (fq *frozenQuery) SetAbsoluteStart(date time.Time) QueryBuilder {
+	panic(ErrorQueryFrozen)
+}
+
+func (fq *frozenQuery) SetRelativeStart(duration int, unit utils.TimeUnit) QueryBuilder {
+	panic(ErrorQueryFrozen)
+}
+
+func (fq *frozenQuery) SetAbsoluteEnd(date time.Time) QueryBuilder {
+	panic(ErrorQueryFrozen)
+}
+
+func (fq *frozenQuery) SetRelativeEnd(duration int, unit utils.TimeUnit) QueryBuilder {
+	panic(ErrorQueryFrozen)
+}
+
+func (fq *frozenQuery) SetAbsoluteStartRFC3339(date string) QueryBuilder {
+	panic(ErrorQueryFrozen)
+}
+
+func (fq *frozenQuery) SetAbsoluteEndRFC3339(date string) QueryBuilder {
+	panic(ErrorQueryFrozen)
+}
+
+func (fq *frozenQuery) SetTimeRange(start, end time.Time) QueryBuilder {
+	panic(ErrorQueryFrozen)
+}
+
+func (fq *frozenQuery) SetLookback(d time.Duration) QueryBuilder {
+	panic(ErrorQueryFrozen)
+}
+
+func (fq *frozenQuery) SetCacheTime(cacheTimeMs int) QueryBuilder {
+	panic(ErrorQueryFrozen)
+}
+
+func (fq *frozenQuery) AddMetric(name string) QueryMetric {
+	panic(ErrorQueryFrozen)
+}
+
+func (fq *frozenQuery) AppendMetrics(metrics ...QueryMetric) QueryBuilder {
+	panic(ErrorQueryFrozen)
+}
+
+func (fq *frozenQuery) AbsoluteStart() time.Time {
+	return fq.startAbs
+}
+
+func (fq *frozenQuery) RelativeStart() *utils.RelativeTime {
+	if fq.startRel == nil {
+		return nil
+	}
+	rel := *fq.startRel
+	return &rel
+}
+
+func (fq *frozenQuery) AbsoluteEnd() time.Time {
+	return fq.endAbs
+}
+
+func (fq *frozenQuery) RelativeEnd() *utils.RelativeTime {
+	if fq.endRel == nil {
+		return nil
+	}
+	rel := *fq.endRel
+	return &rel
+}
+
+func (fq *frozenQuery) CacheTime() int {
+	return fq.cacheTime
+}
+
+// The metrics are copies of the ones of the builder at freeze time, shared
+// by every caller, and must not be modified.
+func (fq *frozenQuery) Metrics() []QueryMetric {
+	return append([]QueryMetric(nil), fq.metricsArr...)
+}
+
+func (fq *frozenQuery) Freeze() (QueryBuilder, error) {
+	return fq, nil
+}
+
+func (fq *frozenQuery) Build() ([]byte, error) {
+	return append([]byte(nil), fq.data...), nil
+}
+
+func (fq *frozenQuery) DumpIndent() (string, error) {
+	return dumpIndent(fq)
+}
+
+func (fq *frozenQuery) String() string {
+	return dumpString(fq)
+}
+
+func (fq *frozenQuery) BuildFor(caps Capabilities) ([]byte, error) {
+	if err := caps.check(fq.data); err != nil {
+		return nil, err
+	}
+
+	return fq.Build()
+}
+
+// Returns a copy of the metric sharing no state with it, down to its
+// aggregators and groupers, so that changing the metric after the freeze
+// does not show in the snapshot.
+func copyQueryMetric(qm QueryMetric) QueryMetric {
+	m, ok := qm.(*qMetric)
+	if !ok {
+		return qm
+	}
+
+	cp := *m
+	cp.Tags = make(map[string][]string, len(m.Tags))
+	for k, vals := range m.Tags {
+		if vals != nil {
+			vals = append(make([]string, 0, len(vals)), vals...)
+		}
+		cp.Tags[k] = vals
+	}
+
+	cp.GroupBy = make([]Grouper, len(m.GroupBy))
+	for i, gp := range m.GroupBy {
+		cp.GroupBy[i] = grouper.Copy(gp).(Grouper)
+	}
+
+	cp.Aggregators = make([]Aggregator, len(m.Aggregators))
+	for i, aggr := range m.Aggregators {
+		cp.Aggregators[i] = aggregator.Copy(aggr).(Aggregator)
+	}
+
+	if m.Extensions != nil {
+		cp.Extensions = make(map[string]json.RawMessage, len(m.Extensions))
+		for k, v := range m.Extensions {
+			cp.Extensions[k] = append(json.RawMessage(nil), v...)
+		}
+	}
+
+	return &cp
+}
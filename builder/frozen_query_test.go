@@ -0,0 +1,92 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"encoding/json"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQBFreeze(t *testing.T) {
+	qb := NewQueryBuilder().SetRelativeStart(1, "hours")
+	qm := qb.AddMetric("m1")
+
+	fq, err := qb.Freeze()
+	assert.Nil(t, err, "No error expected")
+	want, _ := qb.Build()
+
+	// Changes to the builder must not leak into the snapshot.
+	qb.SetCacheTime(10)
+	qm.SetLimit(5)
+	fq.RelativeStart().RTvalue = 7
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			j, err := fq.Build()
+			assert.Nil(t, err, "No error expected")
+			assert.Equal(t, string(want), string(j), "Snapshot must not change")
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, fq.RelativeStart().Value(), "Snapshot start must not change")
+	assert.PanicsWithValue(t, ErrorQueryFrozen, func() { fq.AddMetric("m2") }, "Frozen query must not be modified")
+}
+
+// Success test.
+func TestQBFreezeMetrics(t *testing.T) {
+	hosts := []string{"web-1"}
+	group := []string{"host"}
+	pct := CreatePercentileAggregator(0.9, 1, utils.MINUTES)
+	custom := CreateScaleAggregator(2)
+
+	qb := NewQueryBuilder().SetRelativeStart(1, utils.HOURS)
+	qm := qb.AddMetric("m1").
+		AddTag("host", hosts).
+		AddGrouper(CreateTagsGroupBy(group)).
+		AddAggregator(pct).
+		AddAggregator(custom).
+		SetAlias("load")
+
+	fq, err := qb.Freeze()
+	assert.Nil(t, err, "No error expected")
+	want, _ := json.Marshal(fq.Metrics()[0])
+
+	// Changes to the metric, down to its aggregators and groupers, must not
+	// leak into the snapshot's metrics.
+	hosts[0] = "web-2"
+	group[0] = "dc"
+	qm.AddTag("dc", []string{"eu"})
+	reflect.ValueOf(pct).MethodByName("SetSamplingAlignment").Call(nil)
+	reflect.ValueOf(custom).Elem().FieldByName("KeyVal").Interface().(map[string]interface{})["factor"] = 3.0
+
+	got, _ := json.Marshal(fq.Metrics()[0])
+	assert.Equal(t, string(want), string(got), "Snapshot metrics must not change")
+	assert.Equal(t, "load", fq.Metrics()[0].Alias(), "Alias must be kept")
+}
+
+func TestQBFreezeInvalid(t *testing.T) {
+	fq, err := NewQueryBuilder().Freeze()
+	assert.Equal(t, ErrorStartTimeNotSpecified, err, "Invalid query cannot be frozen")
+	assert.Nil(t, fq, "No snapshot expected")
+}
@@ -0,0 +1,43 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grouper
+
+// Returns a copy of a grouper of this package that shares no state with
+// it, so that changing one does not change the other. Returns the value as
+// is when it is not a grouper of this package.
+func Copy(gp interface{}) interface{} {
+	switch g := gp.(type) {
+	case *std_Grouper:
+		cp := *g
+		if g.Tags != nil {
+			cp.Tags = append(make([]string, 0, len(g.Tags)), g.Tags...)
+		}
+		if g.Range_size != nil {
+			rs := *g.Range_size
+			cp.Range_size = &rs
+		}
+		return &cp
+	case *valueGrouper:
+		cp := *g
+		return &cp
+	case *binGrouper:
+		cp := *g
+		if g.BinsArr != nil {
+			cp.BinsArr = append(make([]float64, 0, len(g.BinsArr)), g.BinsArr...)
+		}
+		return &cp
+	}
+	return gp
+}
@@ -3,5 +3,9 @@ package grouper
 import "errors"
 
 var (
-	ErrorGroupByNameInvalid = errors.New("Group by name empty")
+	ErrorGroupByNameInvalid       = errors.New("Group by name empty")
+	ErrorGroupByRangeSizeInvalid  = errors.New("Group by range size must be > 0")
+	ErrorGroupByRangeUnitInvalid  = errors.New("Group by range size unit invalid")
+	ErrorGroupByGroupCountInvalid = errors.New("Group by group count must be > 0")
+	ErrorGroupByBinsInvalid       = errors.New("Group by bins must be distinct and in ascending order")
 )
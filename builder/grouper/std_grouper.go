@@ -1,6 +1,10 @@
 package grouper
 
-import "github.com/retoool/go-kairosdb/builder/utils"
+import (
+	"fmt"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+)
 
 type range_size2 struct {
 	Value int            `json:"value,omitempty"`
@@ -8,11 +12,10 @@ type range_size2 struct {
 }
 
 type std_Grouper struct {
-	GPName      string      `json:"name,omitempty"`
-	Tags        []string    `json:"tags,omitempty"`
-	Group_count int64       `json:"group_count,omitempty"`
-	Range_size1 int64       `json:"range_size,omitempty"`
-	Range_size2 range_size2 `json:"range_size,omitempty"`
+	GPName      string       `json:"name,omitempty"`
+	Tags        []string     `json:"tags,omitempty"`
+	Group_count int64        `json:"group_count,omitempty"`
+	Range_size  *range_size2 `json:"range_size,omitempty"`
 }
 
 func NewTagsGroup(tags []string) *std_Grouper {
@@ -22,14 +25,61 @@ func NewTagsGroup(tags []string) *std_Grouper {
 	}
 }
 
+// Groups the data points into groupCount time ranges of the given size. The
+// groups repeat every range size times group count, so a range of 1 day and
+// a count of 7 groups the data points by day of the week.
+func NewTimeGroup(rangeValue int, rangeUnit utils.TimeUnit, groupCount int64) *std_Grouper {
+	return &std_Grouper{
+		GPName:      "time",
+		Group_count: groupCount,
+		Range_size: &range_size2{
+			Value: rangeValue,
+			Unit:  rangeUnit,
+		},
+	}
+}
+
 func (gp *std_Grouper) Name() string {
 	return gp.GPName
 }
 
+// Returns the size of a time group, nil for the other groupers.
+func (gp *std_Grouper) RangeSize() *utils.RelativeTime {
+	if gp.Range_size == nil {
+		return nil
+	}
+	return utils.NewRelativeTime(gp.Range_size.Value, gp.Range_size.Unit)
+}
+
+// Returns the number of groups of a time group.
+func (gp *std_Grouper) GroupCount() int64 {
+	return gp.Group_count
+}
+
 func (gp *std_Grouper) Validate() error {
+	if gp.GPName == "time" {
+		return gp.validateTime()
+	}
+
 	if gp.Tags == nil {
 		return ErrorGroupByNameInvalid
 	}
 
 	return nil
 }
+
+func (gp *std_Grouper) validateTime() error {
+	if gp.Range_size == nil || gp.Range_size.Value <= 0 {
+		return ErrorGroupByRangeSizeInvalid
+	}
+
+	if !gp.Range_size.Unit.IsValid() {
+		return fmt.Errorf("%w: %q", ErrorGroupByRangeUnitInvalid, gp.Range_size.Unit)
+	}
+
+	if gp.Group_count <= 0 {
+		return ErrorGroupByGroupCountInvalid
+	}
+
+	return nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grouper
+
+import "sort"
+
+type valueGrouper struct {
+	GPName     string `json:"name,omitempty"`
+	Range_size int64  `json:"range_size"`
+}
+
+type binGrouper struct {
+	GPName  string    `json:"name,omitempty"`
+	BinsArr []float64 `json:"bins"`
+}
+
+// Groups data points by value, in ranges of rangeSize, e.g. 0-999, 1000-1999
+// and so forth for a range size of 1000.
+func NewValueGroup(rangeSize int64) *valueGrouper {
+	return &valueGrouper{
+		GPName:     "value",
+		Range_size: rangeSize,
+	}
+}
+
+// Groups data points by value into the bins bounded by the given values,
+// e.g. below 10, 10-19 and 20 or more for the bins 10 and 20.
+func NewBinGroup(bins []float64) *binGrouper {
+	return &binGrouper{
+		GPName:  "bin",
+		BinsArr: append([]float64(nil), bins...),
+	}
+}
+
+func (gp *valueGrouper) Name() string {
+	return gp.GPName
+}
+
+func (gp *valueGrouper) Range() int64 {
+	return gp.Range_size
+}
+
+func (gp *valueGrouper) Validate() error {
+	if gp.Range_size <= 0 {
+		return ErrorGroupByRangeSizeInvalid
+	}
+
+	return nil
+}
+
+func (gp *binGrouper) Name() string {
+	return gp.GPName
+}
+
+func (gp *binGrouper) Bins() []float64 {
+	return gp.BinsArr
+}
+
+func (gp *binGrouper) Validate() error {
+	if len(gp.BinsArr) == 0 || !sort.Float64sAreSorted(gp.BinsArr) {
+		return ErrorGroupByBinsInvalid
+	}
+
+	for i := 1; i < len(gp.BinsArr); i++ {
+		if gp.BinsArr[i] == gp.BinsArr[i-1] {
+			return ErrorGroupByBinsInvalid
+		}
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package builder
 
 import (
 	"github.com/retoool/go-kairosdb/builder/grouper"
+	"github.com/retoool/go-kairosdb/builder/utils"
 )
 
 type GroupByType string
@@ -16,3 +17,22 @@ const (
 func CreateTagsGroupBy(tags []string) Grouper {
 	return grouper.NewTagsGroup(tags)
 }
+
+// Groups the data points into groupCount ranges of rangeValue rangeUnit each,
+// e.g. CreateTimeGroupBy(1, utils.DAYS, 7) groups them by day of the week.
+func CreateTimeGroupBy(rangeValue int, rangeUnit utils.TimeUnit, groupCount int64) Grouper {
+	return grouper.NewTimeGroup(rangeValue, rangeUnit, groupCount)
+}
+
+// Groups the data points by value in ranges of rangeSize, e.g. 0-99, 100-199
+// and so forth for a range size of 100.
+func CreateValueGroupBy(rangeSize int64) Grouper {
+	return grouper.NewValueGroup(rangeSize)
+}
+
+// Groups the data points into the bins bounded by the given values, which
+// must be distinct and ascending, e.g. below 10, 10-19 and 20 or more for the
+// bins 10 and 20.
+func CreateBinGroupBy(bins []float64) Grouper {
+	return grouper.NewBinGroup(bins)
+}
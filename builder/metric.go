@@ -28,15 +28,36 @@ type Metric interface {
 	// Adds a TTL, expressed in seconds, to the metric.
 	AddTTL(ttl int64) Metric
 
-	// Adds a custom type of value stored in datapoint.
+	// Adds a custom type of value stored in datapoint. The type must be
+	// registered on the KairosDB server; the data point values are then sent
+	// as is, so they must encode to the JSON the registered type expects.
 	AddType(t string) Metric
 
 	// Adds a tag to the datapoint.
 	AddTag(name, val string) Metric
 
-	// Adds a datapoint to the metric. The value is of int64 type.
+	// Adds a map of tags to the datapoint.
+	AddTags(tags map[string]string) Metric
+
+	// Adds a property merged verbatim into the JSON of the metric, for server
+	// features this library does not model.
+	AddExtension(name string, value json.RawMessage) Metric
+
+	// Adds a datapoint to the metric. Signed and unsigned integers are sent
+	// as long values and float32/float64 as double values. Unsigned values
+	// that overflow int64 fail the validation. complex64/complex128 values
+	// are sent as complex numbers, see ComplexType.
 	AddDataPoint(timestamp int64, value interface{}) Metric
 
+	// Sets the unit of the timestamps given to AddDataPoint afterwards. The
+	// default is milliseconds.
+	SetTimestampPrecision(p TimestampPrecision) Metric
+
+	// Makes the validation reject data points whose timestamp is out of
+	// bounds, e.g. PlausibleTimestamps to catch timestamps of the wrong
+	// precision.
+	SetTimestampBounds(b TimestampBounds) Metric
+
 	// Returns the TLL associated with the metric.
 	GetTTL() int64
 
@@ -61,11 +82,15 @@ type Metric interface {
 
 // Type that implements the Metric interface.
 type metricType struct {
-	Name       string            `json:"name,omitempty"`       // Name of the metric.
-	Type       string            `json:"type,omitempty"`       // Type of the metric being stored.
-	Tags       map[string]string `json:"tags,omitempty"`       // Map of tag names and the values associated.
-	DataPoints []DataPoint       `json:"datapoints,omitempty"` // List of DataPoints.
-	TTL        int64             `json:"ttl,omitempty"`        // TTL associated with the metric.
+	Name       string                     `json:"name,omitempty"`       // Name of the metric.
+	Type       string                     `json:"type,omitempty"`       // Type of the metric being stored.
+	Tags       map[string]string          `json:"tags,omitempty"`       // Map of tag names and the values associated.
+	DataPoints []DataPoint                `json:"datapoints,omitempty"` // List of DataPoints.
+	TTL        int64                      `json:"ttl,omitempty"`        // TTL associated with the metric.
+	Extensions map[string]json.RawMessage `json:"-"`                    // Properties merged into the JSON output.
+
+	precision TimestampPrecision
+	bounds    TimestampBounds
 }
 
 func NewMetric(name string) Metric {
@@ -90,8 +115,38 @@ func (m *metricType) AddTag(name, val string) Metric {
 	return m
 }
 
+func (m *metricType) AddTags(tags map[string]string) Metric {
+	for name, val := range tags {
+		m.Tags[name] = val
+	}
+	return m
+}
+
+func (m *metricType) AddExtension(name string, value json.RawMessage) Metric {
+	if m.Extensions == nil {
+		m.Extensions = make(map[string]json.RawMessage)
+	}
+	m.Extensions[name] = value
+	return m
+}
+
 func (m *metricType) AddDataPoint(timestamp int64, value interface{}) Metric {
-	m.DataPoints = append(m.DataPoints, DataPoint{timestamp: timestamp, value: value})
+	v := normalizeValue(value)
+	if _, ok := v.(complexValue); ok && m.Type == "" {
+		m.Type = ComplexType
+	}
+
+	m.DataPoints = append(m.DataPoints, DataPoint{timestamp: m.precision.ToMillis(timestamp), value: v})
+	return m
+}
+
+func (m *metricType) SetTimestampPrecision(p TimestampPrecision) Metric {
+	m.precision = p
+	return m
+}
+
+func (m *metricType) SetTimestampBounds(b TimestampBounds) Metric {
+	m.bounds = b
 	return m
 }
 
@@ -135,7 +190,29 @@ func (m *metricType) validate() error {
 		return ErrorTTLInvalid
 	}
 
-	return nil
+	// Check if the data point values can be stored.
+	for _, dp := range m.DataPoints {
+		if err := validateValue(dp.value); err != nil {
+			return err
+		}
+
+		if err := m.bounds.check(m.Name, dp.timestamp); err != nil {
+			return err
+		}
+	}
+
+	return validateExtensions(m.Extensions)
+}
+
+func (m *metricType) MarshalJSON() ([]byte, error) {
+	// Encode the fields without recursing into this method.
+	type plain metricType
+	data, err := json.Marshal((*plain)(m))
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeExtensions(data, m.Extensions)
 }
 
 func (m *metricType) Build() ([]byte, error) {
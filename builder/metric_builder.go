@@ -20,16 +20,61 @@ type MetricBuilder interface {
 	// Add a new metric to the builder.
 	AddMetric(name string) Metric
 
+	// Add already constructed metrics to the builder.
+	AppendMetrics(metrics ...Metric) MetricBuilder
+
 	// Get a list of all the metrics that are part of the builder.
 	GetMetrics() []Metric
 
-	// Encode the Metrics list into JSON.
+	// Set how NaN and infinite data point values are handled by Build.
+	SetNonFinitePolicy(policy NonFinitePolicy) MetricBuilder
+
+	// Get the policy applied to NaN and infinite data point values.
+	GetNonFinitePolicy() NonFinitePolicy
+
+	// Set the timestamp precision of the metrics added with AddMetric
+	// afterwards, see Metric.SetTimestampPrecision.
+	SetTimestampPrecision(p TimestampPrecision) MetricBuilder
+
+	// Set the timestamp bounds of the metrics added with AddMetric
+	// afterwards, see Metric.SetTimestampBounds.
+	SetTimestampBounds(b TimestampBounds) MetricBuilder
+
+	// Encode the Metrics list into JSON. Fields come in a fixed order and tag
+	// names are sorted, the metrics and data points keep the order they were
+	// added in.
 	Build() ([]byte, error)
 }
 
+// What to do with a NaN or infinite data point value.
+type NonFiniteAction int
+
+const (
+	// Fail the build with ErrorDataPointNonFinite. This is the default.
+	NonFiniteError NonFiniteAction = iota
+	// Leave the data point out of the request.
+	NonFiniteDrop
+	// Send a substitute value instead.
+	NonFiniteSubstitute
+)
+
+// Handling of the data point values JSON cannot encode.
+type NonFinitePolicy struct {
+	Action NonFiniteAction
+
+	// Value sent in place of NaN and infinities by NonFiniteSubstitute.
+	Substitute float64
+
+	// Invoked for every data point left out by NonFiniteDrop. May be nil.
+	OnDrop func(metric string, dp DataPoint)
+}
+
 // Type that implements the MetricBuilder interface.
 type mBuilder struct {
-	Metrics []Metric `json:"metrics"`
+	Metrics   []Metric `json:"metrics"`
+	nonFinite NonFinitePolicy
+	precision TimestampPrecision
+	bounds    TimestampBounds
 }
 
 func NewMetricBuilder() MetricBuilder {
@@ -37,23 +82,96 @@ func NewMetricBuilder() MetricBuilder {
 }
 
 func (mb *mBuilder) AddMetric(name string) Metric {
-	m := NewMetric(name)
+	m := NewMetric(name).SetTimestampPrecision(mb.precision).SetTimestampBounds(mb.bounds)
 	mb.Metrics = append(mb.Metrics, m)
 	return m
 }
 
+func (mb *mBuilder) AppendMetrics(metrics ...Metric) MetricBuilder {
+	mb.Metrics = append(mb.Metrics, metrics...)
+	return mb
+}
+
 func (mb *mBuilder) GetMetrics() []Metric {
 	return mb.Metrics
 }
 
+func (mb *mBuilder) SetNonFinitePolicy(policy NonFinitePolicy) MetricBuilder {
+	mb.nonFinite = policy
+	return mb
+}
+
+func (mb *mBuilder) GetNonFinitePolicy() NonFinitePolicy {
+	return mb.nonFinite
+}
+
+func (mb *mBuilder) SetTimestampPrecision(p TimestampPrecision) MetricBuilder {
+	mb.precision = p
+	return mb
+}
+
+func (mb *mBuilder) SetTimestampBounds(b TimestampBounds) MetricBuilder {
+	mb.bounds = b
+	return mb
+}
+
 func (mb *mBuilder) Build() ([]byte, error) {
+	metrics := mb.Metrics
+	if mb.nonFinite.Action != NonFiniteError {
+		metrics = mb.applyNonFinitePolicy()
+	}
+
 	// Make sure the contents of each metric object are correct.
-	for _, m := range mb.Metrics {
+	for _, m := range metrics {
 		err := m.validate()
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	return json.Marshal(mb.Metrics)
+	return json.Marshal(metrics)
+}
+
+// Returns the metrics with the non finite values dropped or substituted.
+// Metrics holding such values are copied, the ones of the builder are left
+// untouched. Metrics whose data points were all dropped are left out.
+func (mb *mBuilder) applyNonFinitePolicy() []Metric {
+	metrics := make([]Metric, 0, len(mb.Metrics))
+	for _, m := range mb.Metrics {
+		mt, ok := m.(*metricType)
+		if !ok || !hasNonFinite(mt.DataPoints) {
+			metrics = append(metrics, m)
+			continue
+		}
+
+		cp := *mt
+		cp.DataPoints = make([]DataPoint, 0, len(mt.DataPoints))
+		for _, dp := range mt.DataPoints {
+			if !isNonFinite(dp.value) {
+				cp.DataPoints = append(cp.DataPoints, dp)
+				continue
+			}
+
+			if mb.nonFinite.Action == NonFiniteSubstitute {
+				cp.DataPoints = append(cp.DataPoints, DataPoint{timestamp: dp.timestamp, value: mb.nonFinite.Substitute})
+			} else if mb.nonFinite.OnDrop != nil {
+				mb.nonFinite.OnDrop(mt.Name, dp)
+			}
+		}
+
+		if len(cp.DataPoints) > 0 {
+			metrics = append(metrics, &cp)
+		}
+	}
+
+	return metrics
+}
+
+func hasNonFinite(dps []DataPoint) bool {
+	for _, dp := range dps {
+		if isNonFinite(dp.value) {
+			return true
+		}
+	}
+	return false
 }
@@ -16,6 +16,7 @@ package builder
 
 import (
 	"io/ioutil"
+	"math"
 	"strings"
 	"testing"
 
@@ -49,6 +50,28 @@ func TestMetricBuilder(t *testing.T) {
 	assert.Equal(t, str1, str2, "Builder output & file contents must be equal")
 }
 
+// Success test.
+func TestMetricBuilderCustomType(t *testing.T) {
+	testData := `[{"name":"metric1","type":"kairos_complex","tags":{"tag1":"val1"},"datapoints":[[1,{"imaginary":2,"real":1}]]},` +
+		`{"name":"metric2","tags":{"tag2":"val2"},"datapoints":[[2,30]]}]`
+
+	b := NewMetricBuilder()
+	b.AddMetric("metric1").
+		AddType("kairos_complex").
+		AddDataPoint(1, map[string]interface{}{"real": 1, "imaginary": 2}).
+		AddTag("tag1", "val1")
+
+	b.AddMetric("metric2").
+		AddDataPoint(2, int64(30)).
+		AddTag("tag2", "val2")
+
+	s, err := b.Build()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, testData, string(s), "Custom type must only be emitted on the metric it was set on")
+	assert.Equal(t, "kairos_complex", b.GetMetrics()[0].GetType(), "Metric type must be preserved")
+	assert.Equal(t, "", b.GetMetrics()[1].GetType(), "Metric type must be empty by default")
+}
+
 // Failure test.
 func TestMetricBuilderEmptyMetricName(t *testing.T) {
 	b := NewMetricBuilder()
@@ -91,3 +114,46 @@ func TestMetricBuilderTSNegative(t *testing.T) {
 	assert.Nil(t, err, "Don't expect an error")
 	assert.NotNil(t, s, "Should have a non-nil output")
 }
+
+func nonFiniteBuilder() MetricBuilder {
+	b := NewMetricBuilder()
+	b.AddMetric("metric1").
+		AddDataPoint(1, 1.5).
+		AddDataPoint(2, math.NaN()).
+		AddDataPoint(3, math.Inf(1))
+	b.AddMetric("metric2").
+		AddDataPoint(1, math.Inf(-1))
+	return b
+}
+
+// Success test.
+func TestMetricBuilderNonFiniteDrop(t *testing.T) {
+	var dropped []int64
+	b := nonFiniteBuilder().SetNonFinitePolicy(NonFinitePolicy{
+		Action: NonFiniteDrop,
+		OnDrop: func(metric string, dp DataPoint) { dropped = append(dropped, dp.Timestamp()) },
+	})
+
+	s, err := b.Build()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `[{"name":"metric1","datapoints":[[1,1.5]]}]`, string(s), "Non finite values must be dropped")
+	assert.Equal(t, []int64{2, 3, 1}, dropped, "Dropped data points must be reported")
+	assert.Len(t, b.GetMetrics()[0].GetDataPoints(), 3, "Builder metrics must not be modified")
+}
+
+// Success test.
+func TestMetricBuilderNonFiniteSubstitute(t *testing.T) {
+	b := nonFiniteBuilder().SetNonFinitePolicy(NonFinitePolicy{Action: NonFiniteSubstitute, Substitute: -1})
+
+	s, err := b.Build()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `[{"name":"metric1","datapoints":[[1,1.5],[2,-1],[3,-1]]},{"name":"metric2","datapoints":[[1,-1]]}]`,
+		string(s), "Non finite values must be substituted")
+}
+
+// Failure test.
+func TestMetricBuilderNonFiniteError(t *testing.T) {
+	s, err := nonFiniteBuilder().Build()
+	assert.Nil(t, s, "Builder output must be nil")
+	assert.Equal(t, ErrorDataPointNonFinite, err, "Non finite error expected")
+}
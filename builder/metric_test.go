@@ -15,6 +15,7 @@
 package builder
 
 import (
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -57,3 +58,38 @@ func TestTTLValueNegative(t *testing.T) {
 	assert.Nil(t, m, "Metric object must be nil")
 	assert.Equal(t, ErrorTTLInvalid, err, "Invalid TTL error expected")
 }
+
+// Success test.
+func TestMetricNumericTypes(t *testing.T) {
+	testData := `{"name":"m1","datapoints":[[1,1],[2,2],[3,3],[4,18446744073709551],[5,0.1],[6,9223372036854775807]]}`
+	m := NewMetric("m1").
+		AddDataPoint(1, int(1)).
+		AddDataPoint(2, int32(2)).
+		AddDataPoint(3, uint32(3)).
+		AddDataPoint(4, uint64(18446744073709551)).
+		AddDataPoint(5, float32(0.1)).
+		AddDataPoint(6, uint64(math.MaxInt64))
+	j, err := m.Build()
+
+	assert.Nil(t, err, "Dont' expect error")
+	assert.Equal(t, testData, string(j), "Values must be encoded as long and double")
+
+	val, err := m.GetDataPoints()[2].Int64Value()
+	assert.Nil(t, err, "Unsigned values must be stored as int64")
+	assert.Equal(t, int64(3), val, "Got different value")
+}
+
+// Failure test.
+func TestMetricUint64Overflow(t *testing.T) {
+	j, err := NewMetric("m1").AddDataPoint(1, uint64(math.MaxInt64)+1).Build()
+	assert.Nil(t, j, "Metric object must be nil")
+	assert.Equal(t, ErrorDataPointOverflow, err, "Overflow error expected")
+}
+
+// Success test.
+func TestMetricAddTags(t *testing.T) {
+	j, err := NewMetric("m1").AddTags(map[string]string{"a": "1", "b": "2"}).AddTag("c", "3").AddDataPoint(1, 2).Build()
+
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `{"name":"m1","tags":{"a":"1","b":"2","c":"3"},"datapoints":[[1,2]]}`, string(j))
+}
@@ -15,12 +15,17 @@
 package builder
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/retoool/go-kairosdb/builder/utils"
 )
 
+// A QueryBuilder must not be modified concurrently. Once built, Build may be
+// called from several goroutines as long as nobody modifies the query; use
+// Freeze to get a snapshot that can be shared safely.
 type QueryBuilder interface {
 	// The beginning time in the time range.
 	SetAbsoluteStart(date time.Time) QueryBuilder
@@ -35,12 +40,34 @@ type QueryBuilder interface {
 	// The ending time of the time range relative to now.
 	SetRelativeEnd(duration int, unit utils.TimeUnit) QueryBuilder
 
+	// Same as SetAbsoluteStart with an RFC 3339 time, e.g.
+	// "2024-05-01T12:00:00Z" or "2024-05-01T14:00:00.5+02:00". A time that
+	// does not parse fails Build with an error wrapping
+	// ErrorAbsoluteStartInvalid.
+	SetAbsoluteStartRFC3339(date string) QueryBuilder
+
+	// Same as SetAbsoluteEnd with an RFC 3339 time, see
+	// SetAbsoluteStartRFC3339.
+	SetAbsoluteEndRFC3339(date string) QueryBuilder
+
+	// Sets both ends of the time range to absolute times, replacing any
+	// relative start or end set before.
+	SetTimeRange(start, end time.Time) QueryBuilder
+
+	// Queries the last d up to now. The start is set relative to now, see
+	// utils.RelativeTimeFromDuration, and any other start or end is cleared.
+	SetLookback(d time.Duration) QueryBuilder
+
 	// How long to cache this exact query. The default is to never cache.
 	SetCacheTime(cacheTimeMs int) QueryBuilder
 
 	// The metric to query for.
 	AddMetric(name string) QueryMetric
 
+	// Adds already constructed query metrics, e.g. built inline with
+	// NewQueryMetric, so that a whole query is built in one expression.
+	AppendMetrics(metrics ...QueryMetric) QueryBuilder
+
 	// Returns the absolute range start time.
 	AbsoluteStart() time.Time
 
@@ -59,8 +86,36 @@ type QueryBuilder interface {
 	// Returns array of metrics.
 	Metrics() []QueryMetric
 
-	// Encodes the QueryBuilder into JSON.
+	// Returns an immutable snapshot of the query, validated and encoded
+	// once, that can be shared between goroutines. Later changes to the
+	// builder do not affect the snapshot, whose modifying methods panic with
+	// ErrorQueryFrozen.
+	Freeze() (QueryBuilder, error)
+
+	// Encodes the QueryBuilder into JSON. The output is stable: fields come
+	// in a fixed order, map keys and tag values are sorted, so equal queries
+	// encode to the same bytes whatever the order they were built in.
 	Build() ([]byte, error)
+
+	// Same as Build, but fails with an error wrapping
+	// ErrorFeatureNotSupported when the query uses an aggregator, group_by
+	// or option the server lacks. The error names the feature and, when
+	// known, the first KairosDB version that has it.
+	BuildFor(caps Capabilities) ([]byte, error)
+
+	// Returns the JSON of Build indented with two spaces, i.e. exactly
+	// what is sent to KairosDB, for troubleshooting.
+	DumpIndent() (string, error)
+
+	// Same as DumpIndent, for logs and fmt. An invalid query is described
+	// by its error.
+	String() string
+
+	// Analyses the query without running it: its time range, the number of
+	// buckets every sampling aggregator yields per series and warnings about
+	// queries likely to return huge results, such as raw data points without
+	// a limit. Relative times are resolved against now.
+	Explain() (*QueryExplanation, error)
 }
 
 // Type that implements the QueryBuilder interface.v
@@ -71,6 +126,10 @@ type qBuilder struct {
 	EndRel      *utils.RelativeTime `json:"end_relative,omitempty"`
 	CacheTimeMs int                 `json:"cache_time,omitempty"`
 	MetricsArr  []QueryMetric       `json:"metrics,omitempty"`
+
+	// Errors of the RFC 3339 times, reported by Build.
+	startErr error
+	endErr   error
 }
 
 func NewQueryBuilder() QueryBuilder {
@@ -85,6 +144,7 @@ func (qb *qBuilder) timeInMs(t time.Time) int64 {
 
 func (qb *qBuilder) SetAbsoluteStart(date time.Time) QueryBuilder {
 	qb.StartAbs = qb.timeInMs(date)
+	qb.startErr = nil
 	return qb
 }
 
@@ -95,6 +155,7 @@ func (qb *qBuilder) SetRelativeStart(duration int, unit utils.TimeUnit) QueryBui
 
 func (qb *qBuilder) SetAbsoluteEnd(date time.Time) QueryBuilder {
 	qb.EndAbs = qb.timeInMs(date)
+	qb.endErr = nil
 	return qb
 }
 
@@ -103,6 +164,40 @@ func (qb *qBuilder) SetRelativeEnd(duration int, unit utils.TimeUnit) QueryBuild
 	return qb
 }
 
+func (qb *qBuilder) SetAbsoluteStartRFC3339(date string) QueryBuilder {
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		qb.startErr = fmt.Errorf("%w: %v", ErrorAbsoluteStartInvalid, err)
+		return qb
+	}
+	return qb.SetAbsoluteStart(t)
+}
+
+func (qb *qBuilder) SetAbsoluteEndRFC3339(date string) QueryBuilder {
+	t, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		qb.endErr = fmt.Errorf("%w: %v", ErrorAbsoluteEndInvalid, err)
+		return qb
+	}
+	return qb.SetAbsoluteEnd(t)
+}
+
+func (qb *qBuilder) SetTimeRange(start, end time.Time) QueryBuilder {
+	qb.StartRel = nil
+	qb.EndRel = nil
+	return qb.SetAbsoluteStart(start).SetAbsoluteEnd(end)
+}
+
+func (qb *qBuilder) SetLookback(d time.Duration) QueryBuilder {
+	qb.StartAbs = 0
+	qb.EndAbs = 0
+	qb.startErr = nil
+	qb.endErr = nil
+	qb.EndRel = nil
+	qb.StartRel = utils.RelativeTimeFromDuration(d)
+	return qb
+}
+
 func (qb *qBuilder) SetCacheTime(cacheTimeMs int) QueryBuilder {
 	qb.CacheTimeMs = cacheTimeMs
 	return qb
@@ -114,6 +209,11 @@ func (qb *qBuilder) AddMetric(name string) QueryMetric {
 	return qm
 }
 
+func (qb *qBuilder) AppendMetrics(metrics ...QueryMetric) QueryBuilder {
+	qb.MetricsArr = append(qb.MetricsArr, metrics...)
+	return qb
+}
+
 func (qb *qBuilder) AbsoluteStart() time.Time {
 	return time.Unix(0, qb.StartAbs*int64(time.Millisecond))
 }
@@ -138,7 +238,23 @@ func (qb *qBuilder) Metrics() []QueryMetric {
 	return qb.MetricsArr
 }
 
+func (qb *qBuilder) DumpIndent() (string, error) {
+	return dumpIndent(qb)
+}
+
+func (qb *qBuilder) String() string {
+	return dumpString(qb)
+}
+
 func (qb *qBuilder) Build() ([]byte, error) {
+	if qb.startErr != nil {
+		return nil, qb.startErr
+	}
+
+	if qb.endErr != nil {
+		return nil, qb.endErr
+	}
+
 	if qb.StartAbs != 0 && qb.StartRel != nil {
 		return nil, ErrorAbsRelativeStartSet
 	}
@@ -159,6 +275,14 @@ func (qb *qBuilder) Build() ([]byte, error) {
 		return nil, ErrorStartTimeNotSpecified
 	}
 
+	if qb.StartRel != nil && !qb.StartRel.Unit().IsValid() {
+		return nil, fmt.Errorf("%w: %q", ErrorRelativeStartUnitInvalid, qb.StartRel.Unit())
+	}
+
+	if qb.EndRel != nil && !qb.EndRel.Unit().IsValid() {
+		return nil, fmt.Errorf("%w: %q", ErrorRelativeEndUnitInvalid, qb.EndRel.Unit())
+	}
+
 	for _, qm := range qb.MetricsArr {
 		err := qm.Validate()
 		if err != nil {
@@ -166,5 +290,78 @@ func (qb *qBuilder) Build() ([]byte, error) {
 		}
 	}
 
+	if err := qb.validateTimeGroups(time.Now()); err != nil {
+		return nil, err
+	}
+
 	return json.Marshal(qb)
 }
+
+func (qb *qBuilder) BuildFor(caps Capabilities) ([]byte, error) {
+	data, err := qb.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := caps.check(data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// Returns the JSON of the query indented with two spaces.
+func dumpIndent(qb QueryBuilder) (string, error) {
+	data, err := qb.Build()
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func dumpString(qb QueryBuilder) string {
+	s, err := dumpIndent(qb)
+	if err != nil {
+		return fmt.Sprintf("invalid query: %v", err)
+	}
+	return s
+}
+
+type timeGrouper interface {
+	RangeSize() *utils.RelativeTime
+}
+
+// A time group larger than the queried time range puts all the data points
+// into a single group, which is never what was meant. A range size times
+// group count shorter than the time range is fine, the groups then repeat.
+func (qb *qBuilder) validateTimeGroups(now time.Time) error {
+	tr, err := ResolveTimeRange(qb, now)
+	if err != nil {
+		return err
+	}
+
+	for _, qm := range qb.MetricsArr {
+		m, ok := qm.(*qMetric)
+		if !ok {
+			continue
+		}
+
+		for _, gp := range m.GroupBy {
+			tg, ok := gp.(timeGrouper)
+			if !ok || tg.RangeSize() == nil {
+				continue
+			}
+
+			if tg.RangeSize().RelativeTimeTo(tr.End).Before(tr.Start) {
+				return fmt.Errorf("%w: %d %s", ErrorTimeGroupRangeTooLarge, tg.RangeSize().Value(), tg.RangeSize().Unit())
+			}
+		}
+	}
+
+	return nil
+}
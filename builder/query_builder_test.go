@@ -15,6 +15,9 @@
 package builder
 
 import (
+	"errors"
+	"io/ioutil"
+	"strings"
 	"testing"
 	"time"
 
@@ -92,3 +95,149 @@ func TestQBRelativeEndDurationNeg(t *testing.T) {
 	assert.Equal(t, ErrorRelativeEndTimeInvalid, err, "Relative end durartion cannot be negative")
 	assert.Nil(t, j, "No output expected")
 }
+
+func TestQBRelativeStartUnitInvalid(t *testing.T) {
+	qb := NewQueryBuilder()
+	qb.SetRelativeStart(1, "fortnights").AddMetric("metric")
+
+	j, err := qb.Build()
+	assert.True(t, errors.Is(err, ErrorRelativeStartUnitInvalid), "Relative start unit must be known")
+	assert.Nil(t, j, "No output expected")
+}
+
+func TestQBRelativeEndUnitInvalid(t *testing.T) {
+	qb := NewQueryBuilder()
+	qb.SetRelativeStart(1, "hours").SetRelativeEnd(1, "fortnights").AddMetric("metric")
+
+	j, err := qb.Build()
+	assert.True(t, errors.Is(err, ErrorRelativeEndUnitInvalid), "Relative end unit must be known")
+	assert.Nil(t, j, "No output expected")
+}
+
+func TestQBRelativeUnitCaseInsensitive(t *testing.T) {
+	qb := NewQueryBuilder()
+	qb.SetRelativeStart(1, "HOURS").AddMetric("metric")
+
+	_, err := qb.Build()
+	assert.Nil(t, err, "Relative time units are case insensitive")
+}
+
+func TestQBAppendMetrics(t *testing.T) {
+	j, err := NewQueryBuilder().SetRelativeStart(1, "hours").AppendMetrics(
+		NewQueryMetric("cpu").AddTag("host", []string{"h1"}).SetLimit(10),
+		NewQueryMetric("mem"),
+	).Build()
+
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `{"start_relative":{"value":1,"unit":"hours"},"metrics":[{"tags":{"host":["h1"]},"name":"cpu","limit":10},{"name":"mem"}]}`,
+		string(j), "Appended metrics must be queried")
+}
+
+func TestQBSetTimeRange(t *testing.T) {
+	start := time.Unix(1000, 0)
+	end := time.Unix(2000, 0)
+
+	qb := NewQueryBuilder().SetRelativeStart(1, "hours").SetRelativeEnd(1, "minutes")
+	qb.SetTimeRange(start, end).AddMetric("cpu")
+
+	j, err := qb.Build()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `{"start_absolute":1000000,"end_absolute":2000000,"metrics":[{"name":"cpu"}]}`,
+		string(j), "Relative times must be replaced")
+}
+
+func TestQBSetLookback(t *testing.T) {
+	qb := NewQueryBuilder().SetTimeRange(time.Unix(1000, 0), time.Unix(2000, 0))
+	qb.SetLookback(90 * time.Minute).AddMetric("cpu")
+
+	j, err := qb.Build()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `{"start_relative":{"value":90,"unit":"minutes"},"metrics":[{"name":"cpu"}]}`,
+		string(j), "Absolute times must be replaced")
+}
+
+func TestQBAbsoluteRFC3339(t *testing.T) {
+	qb := NewQueryBuilder().
+		SetAbsoluteStartRFC3339("2024-05-01T12:00:00Z").
+		SetAbsoluteEndRFC3339("2024-05-01T14:00:00.5+02:00")
+	qb.AddMetric("cpu")
+
+	j, err := qb.Build()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `{"start_absolute":1714564800000,"end_absolute":1714564800500,"metrics":[{"name":"cpu"}]}`,
+		string(j), "Times must be sent as epoch milliseconds")
+}
+
+func TestQBAbsoluteRFC3339Invalid(t *testing.T) {
+	qb := NewQueryBuilder().SetAbsoluteStartRFC3339("yesterday")
+	qb.AddMetric("cpu")
+
+	j, err := qb.Build()
+	assert.True(t, errors.Is(err, ErrorAbsoluteStartInvalid), "Start time must parse")
+	assert.Nil(t, j, "No output expected")
+
+	qb.SetAbsoluteStart(time.Now()).SetAbsoluteEndRFC3339("2024-05-01")
+	_, err = qb.Build()
+	assert.True(t, errors.Is(err, ErrorAbsoluteEndInvalid), "End time must parse")
+
+	qb.SetLookback(time.Hour)
+	_, err = qb.Build()
+	assert.Nil(t, err, "Errors must be cleared with the times")
+}
+
+func TestQBStableOutput(t *testing.T) {
+	build := func(hosts []string, first, second string) []byte {
+		qb := NewQueryBuilder()
+		qb.SetAbsoluteStart(time.Unix(1600000000, 0)).SetAbsoluteEnd(time.Unix(1600003600, 0))
+		qm := qb.AddMetric("cpu").
+			AddTag(first, []string{first + "-1"}).
+			AddTag(second, []string{second + "-1"}).
+			AddTag("host", hosts).
+			AddAggregator(CreateSaveAsAggregator("cpu.saved")).
+			AddAggregator(CreateScaleAggregator(2))
+		qm.AddGrouper(CreateTagsGroupBy([]string{"host"}))
+
+		j, err := qb.Build()
+		assert.Nil(t, err, "No error expected")
+		return j
+	}
+
+	a := build([]string{"h2", "h1", "h3"}, "dc", "rack")
+	b := build([]string{"h3", "h1", "h2"}, "rack", "dc")
+	assert.Equal(t, string(a), string(b), "Equal queries must encode to the same bytes")
+
+	golden, err := ioutil.ReadFile("../test_resources/stable_query.json")
+	assert.Nil(t, err, "No error expected")
+	assert.JSONEq(t, string(golden), string(a))
+	assert.Equal(t, strings.TrimSpace(string(golden)), string(a), "Output must match the golden file byte for byte")
+}
+
+func TestQBDumpIndent(t *testing.T) {
+	qb := NewQueryBuilder()
+	qb.SetAbsoluteStart(time.Unix(1600000000, 0)).AddMetric("cpu").AddTag("host", []string{"h1"})
+
+	want := `{
+  "start_absolute": 1600000000000,
+  "metrics": [
+    {
+      "tags": {
+        "host": [
+          "h1"
+        ]
+      },
+      "name": "cpu"
+    }
+  ]
+}`
+	dump, err := qb.DumpIndent()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, want, dump)
+	assert.Equal(t, want, qb.String())
+
+	frozen, _ := qb.Freeze()
+	assert.Equal(t, want, frozen.String(), "A frozen query must dump the same")
+
+	_, err = NewQueryBuilder().DumpIndent()
+	assert.Equal(t, ErrorStartTimeNotSpecified, err)
+	assert.Equal(t, "invalid query: "+ErrorStartTimeNotSpecified.Error(), NewQueryBuilder().String())
+}
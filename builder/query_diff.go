@@ -0,0 +1,160 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// A field that differs between two queries, see Diff.
+type Difference struct {
+	// Path of the field in the JSON of the query, e.g.
+	// "metrics[0].aggregators[1].sampling.value".
+	Path string
+
+	// JSON values of the field in either query, nil when the field is
+	// missing from it.
+	A, B json.RawMessage
+}
+
+func (d Difference) String() string {
+	a, b := string(d.A), string(d.B)
+	if d.A == nil {
+		a = "<missing>"
+	}
+	if d.B == nil {
+		b = "<missing>"
+	}
+	return fmt.Sprintf("%s: %s != %s", d.Path, a, b)
+}
+
+// Tells whether the queries are the same, i.e. send the same JSON to
+// KairosDB, whatever the order they were built in. Aliases are not sent and
+// do not count. A query that fails to build equals no other.
+func Equal(a, b QueryBuilder) bool {
+	da, err := a.Build()
+	if err != nil {
+		return false
+	}
+
+	db, err := b.Build()
+	if err != nil {
+		return false
+	}
+
+	// Build output is stable, equal queries encode to the same bytes.
+	return bytes.Equal(da, db)
+}
+
+// Returns the fields that differ between the queries, in path order, empty
+// for equal queries. Fails when either query does not build.
+func Diff(a, b QueryBuilder) ([]Difference, error) {
+	va, err := decodeQuery(a)
+	if err != nil {
+		return nil, err
+	}
+
+	vb, err := decodeQuery(b)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []Difference
+	diffValues("", va, vb, &diffs)
+	return diffs, nil
+}
+
+func decodeQuery(qb QueryBuilder) (interface{}, error) {
+	data, err := qb.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	err = dec.Decode(&v)
+	return v, err
+}
+
+// Appends the differences between two decoded JSON values. Absent values
+// are reported as missing.
+func diffValues(path string, a, b interface{}, diffs *[]Difference) {
+	switch va := a.(type) {
+	case map[string]interface{}:
+		if vb, ok := b.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(va)+len(vb))
+			for k := range va {
+				keys = append(keys, k)
+			}
+			for k := range vb {
+				if _, ok := va[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				p := k
+				if path != "" {
+					p = path + "." + k
+				}
+
+				ea, okA := va[k]
+				eb, okB := vb[k]
+				switch {
+				case !okA:
+					*diffs = append(*diffs, Difference{Path: p, B: rawJSON(eb)})
+				case !okB:
+					*diffs = append(*diffs, Difference{Path: p, A: rawJSON(ea)})
+				default:
+					diffValues(p, ea, eb, diffs)
+				}
+			}
+			return
+		}
+
+	case []interface{}:
+		if vb, ok := b.([]interface{}); ok {
+			for i := 0; i < len(va) || i < len(vb); i++ {
+				p := path + "[" + strconv.Itoa(i) + "]"
+				switch {
+				case i >= len(va):
+					*diffs = append(*diffs, Difference{Path: p, B: rawJSON(vb[i])})
+				case i >= len(vb):
+					*diffs = append(*diffs, Difference{Path: p, A: rawJSON(va[i])})
+				default:
+					diffValues(p, va[i], vb[i], diffs)
+				}
+			}
+			return
+		}
+	}
+
+	ra, rb := rawJSON(a), rawJSON(b)
+	if !bytes.Equal(ra, rb) {
+		*diffs = append(*diffs, Difference{Path: path, A: ra, B: rb})
+	}
+}
+
+func rawJSON(v interface{}) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
@@ -0,0 +1,69 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func diffQuery(hosts []string, sampling int) QueryBuilder {
+	qb := NewQueryBuilder().SetAbsoluteStart(time.Unix(1600000000, 0))
+	qb.AddMetric("cpu").
+		AddTag("host", hosts).
+		AddAggregator(CreateAverageAggregator(sampling, utils.MINUTES))
+	return qb
+}
+
+func TestEqual(t *testing.T) {
+	a := diffQuery([]string{"h1", "h2"}, 1)
+	b := diffQuery([]string{"h2", "h1"}, 1)
+	b.Metrics()[0].SetAlias("load")
+
+	assert.True(t, Equal(a, b), "Tag order and aliases must not count")
+	assert.False(t, Equal(a, diffQuery([]string{"h1"}, 1)))
+	assert.False(t, Equal(a, NewQueryBuilder()), "An invalid query equals no other")
+
+	diffs, err := Diff(a, b)
+	assert.Nil(t, err, "No error expected")
+	assert.Empty(t, diffs)
+}
+
+func TestDiff(t *testing.T) {
+	a := diffQuery([]string{"h1", "h2"}, 1)
+	b := diffQuery([]string{"h1"}, 5).SetCacheTime(1000)
+
+	diffs, err := Diff(a, b)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, []string{
+		`cache_time: <missing> != 1000`,
+		`metrics[0].aggregators[0].sampling.value: 1 != 5`,
+		`metrics[0].tags.host[1]: "h2" != <missing>`,
+	}, diffStrings(diffs))
+
+	_, err = Diff(a, NewQueryBuilder())
+	assert.Equal(t, ErrorStartTimeNotSpecified, err)
+}
+
+func diffStrings(diffs []Difference) []string {
+	var s []string
+	for _, d := range diffs {
+		s = append(s, d.String())
+	}
+	return s
+}
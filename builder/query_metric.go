@@ -14,6 +14,11 @@
 
 package builder
 
+import (
+	"encoding/json"
+	"sort"
+)
+
 // Query request for a metric. If a metric is queried by name only then all
 // data points for all tags are returned. You can narrow down the query by
 // adding tags so only data points associated with those tags are returned.
@@ -60,17 +65,34 @@ type QueryMetric interface {
 	// Orders the data points. The server default is ascending.
 	SetOrder(order OrderType) QueryMetric
 
+	// Adds a property merged verbatim into the JSON of the query metric, for
+	// server features this library does not model.
+	AddExtension(name string, value json.RawMessage) QueryMetric
+
+	// Sets a name identifying the metric in the query response, e.g. the
+	// business concept it stands for. The alias is not sent to KairosDB, it
+	// is copied to the results of the metric by the client.
+	SetAlias(alias string) QueryMetric
+
+	// Returns the alias of the metric, if any.
+	Alias() string
+
+	// Returns the name of the metric.
+	GetName() string
+
 	// Validates the contents of the QueryMetric instance.
 	Validate() error
 }
 
 type qMetric struct {
-	Tags        map[string][]string `json:"tags,omitempty"`
-	Name        string              `json:"name,omitempty"`
-	Limit       int                 `json:"limit,omitempty"`
-	GroupBy     []Grouper           `json:"group_by,omitempty"`
-	Aggregators []Aggregator        `json:"aggregators,omitempty"`
-	Order       OrderType           `json:"order,omitempty"`
+	Tags        map[string][]string        `json:"tags,omitempty"`
+	Name        string                     `json:"name,omitempty"`
+	Limit       int                        `json:"limit,omitempty"`
+	GroupBy     []Grouper                  `json:"group_by,omitempty"`
+	Aggregators []Aggregator               `json:"aggregators,omitempty"`
+	Order       OrderType                  `json:"order,omitempty"`
+	Extensions  map[string]json.RawMessage `json:"-"`
+	AliasName   string                     `json:"-"`
 }
 
 func NewQueryMetric(name string) QueryMetric {
@@ -116,6 +138,63 @@ func (qm *qMetric) SetOrder(order OrderType) QueryMetric {
 	return qm
 }
 
+func (qm *qMetric) AddExtension(name string, value json.RawMessage) QueryMetric {
+	if qm.Extensions == nil {
+		qm.Extensions = make(map[string]json.RawMessage)
+	}
+	qm.Extensions[name] = value
+	return qm
+}
+
+func (qm *qMetric) SetAlias(alias string) QueryMetric {
+	qm.AliasName = alias
+	return qm
+}
+
+func (qm *qMetric) Alias() string {
+	return qm.AliasName
+}
+
+func (qm *qMetric) GetName() string {
+	return qm.Name
+}
+
+func (qm *qMetric) MarshalJSON() ([]byte, error) {
+	// Encode the fields without recursing into this method.
+	type plain qMetric
+	cp := plain(*qm)
+	cp.Tags = sortedTagValues(qm.Tags)
+	data, err := json.Marshal(&cp)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeExtensions(data, qm.Extensions)
+}
+
+// Returns the tags with their values sorted, so that the order values were
+// added in does not change the encoded query. The tags are copied only when
+// some values are out of order.
+func sortedTagValues(tags map[string][]string) map[string][]string {
+	sorted := true
+	for _, vals := range tags {
+		if !sort.StringsAreSorted(vals) {
+			sorted = false
+			break
+		}
+	}
+	if sorted {
+		return tags
+	}
+
+	cp := make(map[string][]string, len(tags))
+	for k, vals := range tags {
+		cp[k] = append([]string(nil), vals...)
+		sort.Strings(cp[k])
+	}
+	return cp
+}
+
 func (qm *qMetric) Validate() error {
 	if qm.Name == "" {
 		return ErrorQMetricNameInvalid
@@ -140,5 +219,12 @@ func (qm *qMetric) Validate() error {
 		}
 	}
 
-	return nil
+	for _, gp := range qm.GroupBy {
+		err := gp.Validate()
+		if err != nil {
+			return err
+		}
+	}
+
+	return validateExtensions(qm.Extensions)
 }
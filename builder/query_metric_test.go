@@ -16,14 +16,18 @@ package builder
 
 import (
 	"encoding/json"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/retoool/go-kairosdb/builder/grouper"
+	"github.com/retoool/go-kairosdb/builder/utils"
 	"github.com/stretchr/testify/assert"
 )
 
 // Success test.
 func TestQMetric(t *testing.T) {
-	testData := `{"tags":{"tag1":"val1"},"name":"qm1","limit":100,"order":"desc"}`
+	testData := `{"tags":{"tag1":["val1"]},"name":"qm1","limit":100,"order":"desc"}`
 	qm := NewQueryMetric("qm1").AddTag("tag1", []string{"val1"}).SetLimit(100).SetOrder(DESCENDING)
 	err := qm.Validate()
 
@@ -81,3 +85,89 @@ func TestQMetricLimitNegative(t *testing.T) {
 
 	assert.Equal(t, ErrorQMetricLimitInvalid, err, "Query Metric limit cannot be negative")
 }
+
+// Success test.
+func TestQueryMetricTimeGroup(t *testing.T) {
+	qb := NewQueryBuilder().SetLookback(14 * 24 * time.Hour)
+	qb.AddMetric("cpu").AddGrouper(CreateTimeGroupBy(1, utils.DAYS, 7))
+
+	j, err := qb.Build()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `{"start_relative":{"value":2,"unit":"weeks"},"metrics":[{"name":"cpu","group_by":[{"name":"time","group_count":7,"range_size":{"value":1,"unit":"days"}}]}]}`,
+		string(j), "Time group must be encoded")
+}
+
+// Failure test.
+func TestQueryMetricTimeGroupInvalid(t *testing.T) {
+	tests := []struct {
+		gp  Grouper
+		err error
+	}{
+		{CreateTimeGroupBy(0, utils.DAYS, 7), grouper.ErrorGroupByRangeSizeInvalid},
+		{CreateTimeGroupBy(1, "fortnights", 7), grouper.ErrorGroupByRangeUnitInvalid},
+		{CreateTimeGroupBy(1, utils.DAYS, 0), grouper.ErrorGroupByGroupCountInvalid},
+	}
+
+	for _, test := range tests {
+		qm := NewQueryMetric("cpu").AddGrouper(test.gp)
+		assert.True(t, errors.Is(qm.Validate(), test.err), test.err.Error())
+	}
+}
+
+// Success test.
+func TestQueryMetricValueAndBinGroups(t *testing.T) {
+	qm := NewQueryMetric("cpu").
+		AddGrouper(CreateValueGroupBy(100)).
+		AddGrouper(CreateBinGroupBy([]float64{10, 20.5})).
+		SetLimit(10).
+		SetOrder(DESCENDING)
+	assert.Nil(t, qm.Validate(), "No error expected")
+
+	data, err := json.Marshal(qm)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `{"name":"cpu","limit":10,"group_by":[{"name":"value","range_size":100},{"name":"bin","bins":[10,20.5]}],"order":"desc"}`,
+		string(data), "Value and bin groups must be encoded")
+}
+
+// Failure test.
+func TestQueryMetricValueAndBinGroupsInvalid(t *testing.T) {
+	tests := []struct {
+		gp  Grouper
+		err error
+	}{
+		{CreateValueGroupBy(0), grouper.ErrorGroupByRangeSizeInvalid},
+		{CreateBinGroupBy(nil), grouper.ErrorGroupByBinsInvalid},
+		{CreateBinGroupBy([]float64{20, 10}), grouper.ErrorGroupByBinsInvalid},
+		{CreateBinGroupBy([]float64{10, 10}), grouper.ErrorGroupByBinsInvalid},
+	}
+
+	for _, test := range tests {
+		qm := NewQueryMetric("cpu").AddGrouper(test.gp)
+		assert.True(t, errors.Is(qm.Validate(), test.err), test.err.Error())
+	}
+}
+
+// Failure test.
+func TestQueryMetricTimeGroupTooLarge(t *testing.T) {
+	qb := NewQueryBuilder().SetLookback(time.Hour)
+	qb.AddMetric("cpu").AddGrouper(CreateTimeGroupBy(1, utils.DAYS, 7))
+
+	_, err := qb.Build()
+	assert.True(t, errors.Is(err, ErrorTimeGroupRangeTooLarge), "Range size must fit in the query")
+
+	qb = NewQueryBuilder().SetTimeRange(time.Unix(0, 0).Add(time.Hour), time.Unix(0, 0).Add(3*time.Hour))
+	qb.AddMetric("cpu").AddGrouper(CreateTimeGroupBy(1, "HOURS", 24))
+
+	_, err = qb.Build()
+	assert.Nil(t, err, "Range size fits in the query")
+}
+
+// Success test.
+func TestQueryMetricAlias(t *testing.T) {
+	qm := NewQueryMetric("cpu").SetAlias("web load")
+	assert.Equal(t, "web load", qm.Alias(), "Alias expected")
+
+	data, err := json.Marshal(qm)
+	assert.Nil(t, err, "No error expected")
+	assert.NotContains(t, string(data), "web load", "Alias must not be sent to KairosDB")
+}
@@ -0,0 +1,246 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+)
+
+// An absolute time range. Both ends are inclusive, like the start and end
+// times of a KairosDB query.
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+func (tr TimeRange) String() string {
+	return fmt.Sprintf("[%s, %s]", tr.Start.Format(time.RFC3339Nano), tr.End.Format(time.RFC3339Nano))
+}
+
+// A slice of a larger query covering a part of its time range.
+type QueryChunk struct {
+	Range TimeRange
+	Query QueryBuilder
+}
+
+// Returns the absolute time range covered by the query. Relative times are
+// resolved against now and a missing end time defaults to now.
+func ResolveTimeRange(qb QueryBuilder, now time.Time) (TimeRange, error) {
+	var tr TimeRange
+
+	switch {
+	case qb.RelativeStart() != nil:
+		tr.Start = qb.RelativeStart().RelativeTimeTo(now)
+	case !qb.AbsoluteStart().Equal(time.Unix(0, 0)):
+		tr.Start = qb.AbsoluteStart()
+	default:
+		return tr, ErrorStartTimeNotSpecified
+	}
+
+	switch {
+	case qb.RelativeEnd() != nil:
+		tr.End = qb.RelativeEnd().RelativeTimeTo(now)
+	case !qb.AbsoluteEnd().Equal(time.Unix(0, 0)):
+		tr.End = qb.AbsoluteEnd()
+	default:
+		tr.End = now
+	}
+
+	return tr, nil
+}
+
+type alignedSampler interface {
+	AlignSampling() bool
+	StartTime() int64
+}
+
+// Aggregators whose output for a data point depends on the one before it or
+// on the first and last data points of the range.
+var neighborAggregators = map[string]bool{
+	"diff":    true,
+	"rate":    true,
+	"sampler": true,
+	"trim":    true,
+}
+
+// Returns the length the chunks of a split query must be a multiple of so
+// that no sampling bucket of its aggregators straddles two chunks, zero when
+// any length will do. The buckets of unaligned sampling aggregators start at
+// the query start, hence at the start of every chunk.
+//
+// Queries whose results would change if split fail with an error wrapping
+// ErrorQueryNotSplittable: metrics with a limit, which applies to every chunk,
+// aggregators comparing neighboring data points such as rate, sampling in
+// months or years or aligned to the sampling or to a start time, and time
+// groupers, whose groups are numbered from the query start.
+func SplitAlignment(qb QueryBuilder) (time.Duration, error) {
+	var align time.Duration
+	for _, qm := range qb.Metrics() {
+		m, ok := qm.(*qMetric)
+		if !ok {
+			continue
+		}
+
+		if m.Limit > 0 {
+			return 0, fmt.Errorf("%w: metric %q has a limit", ErrorQueryNotSplittable, m.Name)
+		}
+
+		for _, gp := range m.GroupBy {
+			if tg, ok := gp.(timeGrouper); ok && tg.RangeSize() != nil {
+				return 0, fmt.Errorf("%w: metric %q is grouped by time", ErrorQueryNotSplittable, m.Name)
+			}
+		}
+
+		for _, aggr := range m.Aggregators {
+			if neighborAggregators[aggr.Name()] {
+				return 0, fmt.Errorf("%w: metric %q: aggregator %q", ErrorQueryNotSplittable, m.Name, aggr.Name())
+			}
+
+			s, ok := aggr.(sampler)
+			if !ok || s.Value() <= 0 {
+				continue
+			}
+
+			if as, ok := aggr.(alignedSampler); ok && (as.AlignSampling() || as.StartTime() != 0) {
+				return 0, fmt.Errorf("%w: metric %q: aggregator %q is aligned", ErrorQueryNotSplittable, m.Name, aggr.Name())
+			}
+
+			period, ok := utils.FixedDuration(s.Value(), s.Unit())
+			if !ok {
+				return 0, fmt.Errorf("%w: metric %q: aggregator %q samples %d %s", ErrorQueryNotSplittable,
+					m.Name, aggr.Name(), s.Value(), s.Unit())
+			}
+			align = lcm(align, period)
+		}
+	}
+
+	return align, nil
+}
+
+// Returns the least common multiple of two durations, b when a is zero.
+func lcm(a, b time.Duration) time.Duration {
+	if a == 0 {
+		return b
+	}
+
+	x, y := a, b
+	for y != 0 {
+		x, y = y, x%y
+	}
+	return a / x * b
+}
+
+// Splits the query into consecutive chunks of at most the given size, in
+// ascending time order. Every chunk queries the same metrics over its own
+// absolute time range. The metrics are shared with the original query and
+// must not be modified while the chunks are in use.
+func SplitQuery(qb QueryBuilder, chunk time.Duration, now time.Time) ([]QueryChunk, error) {
+	if chunk < time.Millisecond {
+		return nil, ErrorChunkSizeInvalid
+	}
+
+	// Make sure the query itself is valid before slicing it up.
+	if _, err := qb.Build(); err != nil {
+		return nil, err
+	}
+
+	tr, err := ResolveTimeRange(qb, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []QueryChunk
+	for start := tr.Start; !start.After(tr.End); start = start.Add(chunk) {
+		// Ranges are inclusive, so the chunk ends one millisecond before the
+		// next one starts.
+		end := start.Add(chunk - time.Millisecond)
+		if end.After(tr.End) {
+			end = tr.End
+		}
+
+		sub := &qBuilder{
+			CacheTimeMs: qb.CacheTime(),
+			MetricsArr:  qb.Metrics(),
+		}
+		sub.SetAbsoluteStart(start).SetAbsoluteEnd(end)
+
+		chunks = append(chunks, QueryChunk{
+			Range: TimeRange{Start: start, End: end},
+			Query: sub,
+		})
+	}
+
+	return chunks, nil
+}
+
+// Returns a copy of the query restricted to the metrics at the given
+// indexes, e.g. to retry the metrics that failed in a previous run. The time
+// range is copied and the metrics are shared with the original query.
+func SelectMetrics(qb QueryBuilder, indexes ...int) (QueryBuilder, error) {
+	metrics := qb.Metrics()
+
+	sub := &qBuilder{
+		StartRel:    qb.RelativeStart(),
+		EndRel:      qb.RelativeEnd(),
+		CacheTimeMs: qb.CacheTime(),
+		MetricsArr:  make([]QueryMetric, 0, len(indexes)),
+	}
+
+	if !qb.AbsoluteStart().Equal(time.Unix(0, 0)) {
+		sub.SetAbsoluteStart(qb.AbsoluteStart())
+	}
+
+	if !qb.AbsoluteEnd().Equal(time.Unix(0, 0)) {
+		sub.SetAbsoluteEnd(qb.AbsoluteEnd())
+	}
+
+	for _, i := range indexes {
+		if i < 0 || i >= len(metrics) {
+			return nil, ErrorMetricIndexInvalid
+		}
+		sub.MetricsArr = append(sub.MetricsArr, metrics[i])
+	}
+
+	return sub, nil
+}
+
+// Returns a query of the metric at the given index over an absolute time
+// range, with the metric's limit set and its data points in ascending order,
+// e.g. to page through a series. The metric is copied, the one of the
+// original query is not modified.
+func PageQuery(qb QueryBuilder, index int, tr TimeRange, limit int) (QueryBuilder, error) {
+	metrics := qb.Metrics()
+	if index < 0 || index >= len(metrics) {
+		return nil, ErrorMetricIndexInvalid
+	}
+
+	qm := metrics[index]
+	if m, ok := qm.(*qMetric); ok {
+		cp := *m
+		qm = &cp
+	}
+	qm.SetLimit(limit).SetOrder(ASCENDING)
+
+	sub := &qBuilder{
+		CacheTimeMs: qb.CacheTime(),
+		MetricsArr:  []QueryMetric{qm},
+	}
+	sub.SetAbsoluteStart(tr.Start).SetAbsoluteEnd(tr.End)
+
+	return sub, nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestSplitQuery(t *testing.T) {
+	now := time.Unix(10000, 0)
+	qb := NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("m1")
+
+	chunks, err := SplitQuery(qb, 25*time.Minute, now)
+	assert.Nil(t, err, "No error expected")
+	assert.Len(t, chunks, 3, "Three chunks expected")
+
+	assert.Equal(t, now.Add(-time.Hour), chunks[0].Range.Start, "First chunk must start at the query start")
+	assert.Equal(t, now.Add(-35*time.Minute-time.Millisecond), chunks[0].Range.End, "Chunks must not overlap")
+	assert.Equal(t, now.Add(-35*time.Minute), chunks[1].Range.Start, "Chunks must be contiguous")
+	assert.Equal(t, now, chunks[2].Range.End, "Last chunk must end at the query end")
+
+	j, err := chunks[1].Query.Build()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `{"start_absolute":7900000,"end_absolute":9399999,"metrics":[{"name":"m1"}]}`, string(j),
+		"Chunk query json output must match")
+}
+
+// Failure test.
+func TestSplitQueryInvalid(t *testing.T) {
+	qb := NewQueryBuilder()
+	qb.AddMetric("m1")
+
+	_, err := SplitQuery(qb, time.Minute, time.Now())
+	assert.Equal(t, ErrorStartTimeNotSpecified, err, "Start time must be required")
+
+	qb.SetRelativeStart(1, utils.HOURS)
+	_, err = SplitQuery(qb, 0, time.Now())
+	assert.Equal(t, ErrorChunkSizeInvalid, err, "Chunk size must be positive")
+}
+
+type alignedAggregator struct{}
+
+func (alignedAggregator) Name() string         { return "avg" }
+func (alignedAggregator) Validate() error      { return nil }
+func (alignedAggregator) Value() int           { return 1 }
+func (alignedAggregator) Unit() utils.TimeUnit { return utils.MINUTES }
+func (alignedAggregator) AlignSampling() bool  { return true }
+func (alignedAggregator) StartTime() int64     { return 0 }
+
+// Success test.
+func TestSplitAlignment(t *testing.T) {
+	qb := NewQueryBuilder()
+	qb.AddMetric("m1").AddAggregator(CreateAverageAggregator(2, utils.MINUTES)).AddAggregator(CreateScaleAggregator(2))
+	qb.AddMetric("m2").AddAggregator(CreateSumAggregator(3, utils.MINUTES)).AddGrouper(CreateTagsGroupBy([]string{"host"}))
+
+	align, err := SplitAlignment(qb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 6*time.Minute, align, "Least common multiple of the samplings expected")
+
+	align, err = SplitAlignment(NewQueryBuilder().AppendMetrics(NewQueryMetric("m1")))
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, time.Duration(0), align, "No alignment without sampling expected")
+}
+
+// Failure test.
+func TestSplitAlignmentNotSplittable(t *testing.T) {
+	metrics := map[string]QueryMetric{
+		"limit":      NewQueryMetric("m1").SetLimit(10),
+		"rate":       NewQueryMetric("m1").AddAggregator(CreateRateAggregator(utils.SECONDS)),
+		"months":     NewQueryMetric("m1").AddAggregator(CreateSumAggregator(1, utils.MONTHS)),
+		"aligned":    NewQueryMetric("m1").AddAggregator(alignedAggregator{}),
+		"time group": NewQueryMetric("m1").AddGrouper(CreateTimeGroupBy(1, utils.HOURS, 24)),
+	}
+
+	for name, qm := range metrics {
+		_, err := SplitAlignment(NewQueryBuilder().AppendMetrics(qm))
+		assert.ErrorIs(t, err, ErrorQueryNotSplittable, name)
+	}
+}
+
+// Success test.
+func TestSelectMetrics(t *testing.T) {
+	qb := NewQueryBuilder()
+	qb.SetAbsoluteStart(time.Unix(1, 0)).SetRelativeEnd(1, utils.HOURS)
+	qb.AddMetric("m1")
+	qb.AddMetric("m2")
+	qb.AddMetric("m3")
+
+	sub, err := SelectMetrics(qb, 0, 2)
+	assert.Nil(t, err, "No error expected")
+
+	j, err := sub.Build()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `{"start_absolute":1000,"end_relative":{"value":1,"unit":"hours"},"metrics":[{"name":"m1"},{"name":"m3"}]}`,
+		string(j), "Selected query json output must match")
+
+	_, err = SelectMetrics(qb, 3)
+	assert.Equal(t, ErrorMetricIndexInvalid, err, "Out of range index must be rejected")
+}
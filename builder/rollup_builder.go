@@ -0,0 +1,150 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+)
+
+// A roll-up task run periodically by KairosDB. Every run executes the
+// queries of the task and saves their results as new metrics, typically to
+// keep a downsampled copy of raw data.
+type RollupBuilder interface {
+	// The name of the roll-up task.
+	SetName(name string) RollupBuilder
+
+	// How often the task is run.
+	SetExecutionInterval(value int, unit utils.TimeUnit) RollupBuilder
+
+	// Adds a roll-up saving the results of the returned query as the given
+	// metric. The query must have a relative start time since it is run
+	// over and over again.
+	AddRollup(saveAs string) QueryBuilder
+
+	// Returns the name of the task.
+	Name() string
+
+	// Returns the execution interval of the task.
+	ExecutionInterval() *utils.RelativeTime
+
+	// Encodes the RollupBuilder into JSON.
+	Build() ([]byte, error)
+}
+
+type rollup struct {
+	saveAs string
+	query  QueryBuilder
+}
+
+// Type that implements the RollupBuilder interface.
+type rBuilder struct {
+	TaskName string              `json:"name"`
+	Interval *utils.RelativeTime `json:"execution_interval"`
+	Rollups  []*rollup           `json:"-"`
+}
+
+func NewRollupBuilder(name string) RollupBuilder {
+	return &rBuilder{
+		TaskName: name,
+	}
+}
+
+func (rb *rBuilder) SetName(name string) RollupBuilder {
+	rb.TaskName = name
+	return rb
+}
+
+func (rb *rBuilder) SetExecutionInterval(value int, unit utils.TimeUnit) RollupBuilder {
+	rb.Interval = utils.NewRelativeTime(value, unit)
+	return rb
+}
+
+func (rb *rBuilder) AddRollup(saveAs string) QueryBuilder {
+	qb := NewQueryBuilder()
+	rb.Rollups = append(rb.Rollups, &rollup{saveAs: saveAs, query: qb})
+	return qb
+}
+
+func (rb *rBuilder) Name() string {
+	return rb.TaskName
+}
+
+func (rb *rBuilder) ExecutionInterval() *utils.RelativeTime {
+	return rb.Interval
+}
+
+func (rb *rBuilder) Build() ([]byte, error) {
+	if rb.TaskName == "" {
+		return nil, ErrorRollupNameInvalid
+	}
+
+	if rb.Interval == nil || rb.Interval.Value() <= 0 {
+		return nil, ErrorExecutionIntervalInvalid
+	}
+
+	if !rb.Interval.Unit().IsValid() {
+		return nil, fmt.Errorf("%w: unit %q", ErrorExecutionIntervalInvalid, rb.Interval.Unit())
+	}
+
+	if len(rb.Rollups) == 0 {
+		return nil, ErrorNoRollups
+	}
+
+	type rollupJSON struct {
+		SaveAs string          `json:"save_as"`
+		Query  json.RawMessage `json:"query"`
+	}
+
+	rollups := make([]rollupJSON, 0, len(rb.Rollups))
+	for _, r := range rb.Rollups {
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+
+		query, err := r.query.Build()
+		if err != nil {
+			return nil, err
+		}
+
+		rollups = append(rollups, rollupJSON{SaveAs: r.saveAs, Query: query})
+	}
+
+	return json.Marshal(struct {
+		*rBuilder
+		Rollups []rollupJSON `json:"rollups"`
+	}{rb, rollups})
+}
+
+func (r *rollup) validate() error {
+	if r.saveAs == "" {
+		return ErrorRollupSaveAsInvalid
+	}
+
+	if r.query.RelativeStart() == nil {
+		return ErrorRollupStartNotRelative
+	}
+
+	for _, qm := range r.query.Metrics() {
+		if m, ok := qm.(*qMetric); ok && m.Name == r.saveAs {
+			// Every run would roll up the output of the previous one.
+			return ErrorRollupSaveAsQueried
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRBBuild(t *testing.T) {
+	rb := NewRollupBuilder("hourly").SetExecutionInterval(1, utils.HOURS)
+	rb.AddRollup("cpu.hourly").SetRelativeStart(1, utils.HOURS).AddMetric("cpu").
+		AddAggregator(CreateSumAggregator(1, utils.HOURS))
+
+	j, err := rb.Build()
+	assert.Nil(t, err, "No error expected")
+	assert.JSONEq(t, `{"name":"hourly","execution_interval":{"value":1,"unit":"hours"},"rollups":[{"save_as":"cpu.hourly",
+		"query":{"start_relative":{"value":1,"unit":"hours"},"metrics":[{"name":"cpu",
+		"aggregators":[{"name":"sum","sampling":{"value":1,"unit":"hours"}}]}]}}]}`, string(j))
+}
+
+func TestRBNameEmpty(t *testing.T) {
+	rb := NewRollupBuilder("").SetExecutionInterval(1, utils.HOURS)
+	rb.AddRollup("cpu.hourly").SetRelativeStart(1, utils.HOURS).AddMetric("cpu")
+
+	j, err := rb.Build()
+	assert.Equal(t, ErrorRollupNameInvalid, err, "Task name cannot be empty")
+	assert.Nil(t, j, "No output expected")
+}
+
+func TestRBIntervalNotSet(t *testing.T) {
+	rb := NewRollupBuilder("hourly")
+	rb.AddRollup("cpu.hourly").SetRelativeStart(1, utils.HOURS).AddMetric("cpu")
+
+	j, err := rb.Build()
+	assert.Equal(t, ErrorExecutionIntervalInvalid, err, "Execution interval must be set")
+	assert.Nil(t, j, "No output expected")
+}
+
+func TestRBNoRollups(t *testing.T) {
+	rb := NewRollupBuilder("hourly").SetExecutionInterval(1, utils.HOURS)
+
+	j, err := rb.Build()
+	assert.Equal(t, ErrorNoRollups, err, "At least one roll-up expected")
+	assert.Nil(t, j, "No output expected")
+}
+
+func TestRBStartAbsolute(t *testing.T) {
+	rb := NewRollupBuilder("hourly").SetExecutionInterval(1, utils.HOURS)
+	rb.AddRollup("cpu.hourly").SetAbsoluteStart(time.Unix(1, 0)).AddMetric("cpu")
+
+	j, err := rb.Build()
+	assert.Equal(t, ErrorRollupStartNotRelative, err, "Roll-up start must be relative")
+	assert.Nil(t, j, "No output expected")
+}
+
+func TestRBSaveAsQueried(t *testing.T) {
+	rb := NewRollupBuilder("hourly").SetExecutionInterval(1, utils.HOURS)
+	rb.AddRollup("cpu").SetRelativeStart(1, utils.HOURS).AddMetric("cpu")
+
+	j, err := rb.Build()
+	assert.Equal(t, ErrorRollupSaveAsQueried, err, "Roll-up cannot feed on itself")
+	assert.Nil(t, j, "No output expected")
+}
+
+func TestRBInvalidQuery(t *testing.T) {
+	rb := NewRollupBuilder("hourly").SetExecutionInterval(1, utils.HOURS)
+	rb.AddRollup("cpu.hourly").SetRelativeStart(1, utils.HOURS).AddMetric("")
+
+	j, err := rb.Build()
+	assert.Equal(t, ErrorQMetricNameInvalid, err, "Query errors must be reported")
+	assert.Nil(t, j, "No output expected")
+}
@@ -0,0 +1,96 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"fmt"
+	"time"
+)
+
+// Unit of the timestamps given to AddDataPoint. KairosDB stores milliseconds
+// since the epoch; timestamps of the other units are converted when they are
+// added, rounding down.
+type TimestampPrecision int
+
+const (
+	// The default.
+	TimestampMillis TimestampPrecision = iota
+	TimestampSeconds
+	TimestampMicros
+	TimestampNanos
+)
+
+// Converts a timestamp of the precision to milliseconds.
+func (p TimestampPrecision) ToMillis(ts int64) int64 {
+	switch p {
+	case TimestampSeconds:
+		return ts * 1000
+	case TimestampMicros:
+		return floorDiv(ts, 1000)
+	case TimestampNanos:
+		return floorDiv(ts, int64(time.Millisecond))
+	}
+	return ts
+}
+
+func floorDiv(a, b int64) int64 {
+	q := a / b
+	if a%b != 0 && a < 0 {
+		q--
+	}
+	return q
+}
+
+// Range of the timestamps a metric accepts, both ends included. The zero
+// value disables the check.
+type TimestampBounds struct {
+	Min time.Time
+	Max time.Time
+}
+
+// Timestamps between 2000 and 2100. A timestamp in seconds, microseconds or
+// nanoseconds taken for milliseconds falls outside of these bounds.
+var PlausibleTimestamps = TimestampBounds{
+	Min: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+	Max: time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+}
+
+// Returns an error wrapping ErrorDataPointTimestampImplausible when the
+// millisecond timestamp is out of bounds.
+func (b TimestampBounds) check(metric string, ts int64) error {
+	if b.contains(ts) {
+		return nil
+	}
+
+	err := fmt.Errorf("%w: %s at %d", ErrorDataPointTimestampImplausible, metric, ts)
+	for _, p := range []TimestampPrecision{TimestampSeconds, TimestampMicros, TimestampNanos} {
+		if b.contains(p.ToMillis(ts)) {
+			return fmt.Errorf("%w, %s", err, precisionHints[p])
+		}
+	}
+
+	return err
+}
+
+func (b TimestampBounds) contains(ts int64) bool {
+	t := time.UnixMilli(ts)
+	return (b.Min.IsZero() || !t.Before(b.Min)) && (b.Max.IsZero() || !t.After(b.Max))
+}
+
+var precisionHints = map[TimestampPrecision]string{
+	TimestampSeconds: "looks like seconds",
+	TimestampMicros:  "looks like microseconds",
+	TimestampNanos:   "looks like nanoseconds",
+}
@@ -0,0 +1,65 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestTimestampPrecision(t *testing.T) {
+	mb := NewMetricBuilder().SetTimestampPrecision(TimestampSeconds)
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1700000000, 1)
+	mb.AddMetric("m2").AddTag("host", "h1").SetTimestampPrecision(TimestampNanos).AddDataPoint(1700000000123456789, 2)
+	mb.AddMetric("m3").AddTag("host", "h1").SetTimestampPrecision(TimestampMicros).AddDataPoint(-1500, 3)
+
+	j, err := mb.Build()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `[{"name":"m1","tags":{"host":"h1"},"datapoints":[[1700000000000,1]]},`+
+		`{"name":"m2","tags":{"host":"h1"},"datapoints":[[1700000000123,2]]},`+
+		`{"name":"m3","tags":{"host":"h1"},"datapoints":[[-2,3]]}]`, string(j), "Timestamps must be sent in milliseconds")
+}
+
+// Failure test.
+func TestTimestampBounds(t *testing.T) {
+	mb := NewMetricBuilder().SetTimestampBounds(PlausibleTimestamps)
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1700000000000, 1)
+
+	_, err := mb.Build()
+	assert.Nil(t, err, "Timestamp is plausible")
+
+	tests := []struct {
+		ts   int64
+		hint string
+	}{
+		{1700000000, "looks like seconds"},
+		{1700000000000000, "looks like microseconds"},
+		{1700000000000000000, "looks like nanoseconds"},
+		{1, ""},
+	}
+
+	for _, test := range tests {
+		_, err := NewMetric("m1").SetTimestampBounds(PlausibleTimestamps).AddDataPoint(test.ts, 1).Build()
+		assert.True(t, errors.Is(err, ErrorDataPointTimestampImplausible), "Timestamp is out of bounds")
+		assert.Contains(t, err.Error(), test.hint, "Precision hint expected")
+	}
+
+	_, err = NewMetric("m1").SetTimestampBounds(TimestampBounds{Max: time.Unix(1, 0)}).AddDataPoint(1000, 1).Build()
+	assert.Nil(t, err, "Bounds are inclusive")
+}
@@ -0,0 +1,63 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+// Numeric data point value types.
+type Number interface {
+	int | int8 | int16 | int32 | int64 | uint | uint8 | uint16 | uint32 | uint64 | float32 | float64
+}
+
+// Data point value types that KairosDB stores natively: long and double
+// values for numbers, string values for strings.
+type Value interface {
+	Number | string
+}
+
+// A metric whose data point values all have the same Go type, checked at
+// compile time. It wraps a regular Metric, see Metric for the meaning of
+// the methods.
+type TypedMetric[T Value] struct {
+	m Metric
+}
+
+// Creates a typed metric, e.g. NewTypedMetric[float64]("cpu.load").
+func NewTypedMetric[T Value](name string) *TypedMetric[T] {
+	return &TypedMetric[T]{m: NewMetric(name)}
+}
+
+func (tm *TypedMetric[T]) AddTTL(ttl int64) *TypedMetric[T] {
+	tm.m.AddTTL(ttl)
+	return tm
+}
+
+func (tm *TypedMetric[T]) AddTag(name, val string) *TypedMetric[T] {
+	tm.m.AddTag(name, val)
+	return tm
+}
+
+func (tm *TypedMetric[T]) AddDataPoint(timestamp int64, value T) *TypedMetric[T] {
+	tm.m.AddDataPoint(timestamp, value)
+	return tm
+}
+
+// Returns the underlying metric, e.g. to add it to a MetricBuilder with
+// AppendMetrics.
+func (tm *TypedMetric[T]) Metric() Metric {
+	return tm.m
+}
+
+func (tm *TypedMetric[T]) Build() ([]byte, error) {
+	return tm.m.Build()
+}
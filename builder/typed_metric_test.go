@@ -0,0 +1,38 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestTypedMetric(t *testing.T) {
+	j, err := NewTypedMetric[float32]("m1").
+		AddTag("tag", "val").
+		AddDataPoint(1, 0.5).
+		AddDataPoint(2, 1.25).
+		Build()
+	assert.Nil(t, err, "Dont' expect error")
+	assert.Equal(t, `{"name":"m1","tags":{"tag":"val"},"datapoints":[[1,0.5],[2,1.25]]}`, string(j),
+		"Typed metric output must match")
+
+	m := NewTypedMetric[string]("status").AddDataPoint(1, "up").Metric()
+	b, err := NewMetricBuilder().AppendMetrics(m).Build()
+	assert.Nil(t, err, "Dont' expect error")
+	assert.Equal(t, `[{"name":"status","datapoints":[[1,"up"]]}]`, string(b), "String values must be kept")
+}
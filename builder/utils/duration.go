@@ -0,0 +1,77 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"strings"
+	"time"
+)
+
+// Units with a fixed length, from the largest to the smallest, milliseconds
+// aside. Months and years vary in length and are never picked for a duration.
+var durationUnits = []struct {
+	unit TimeUnit
+	size time.Duration
+}{
+	{WEEKS, 7 * 24 * time.Hour},
+	{DAYS, 24 * time.Hour},
+	{HOURS, time.Hour},
+	{MINUTES, time.Minute},
+	{SECONDS, time.Second},
+}
+
+// Returns the value and unit of a sampling spanning d, using the largest unit
+// that divides d exactly, e.g. 90 minutes for 1h30m. d is truncated to
+// milliseconds, the smallest unit KairosDB knows of.
+//
+// The result fits SetRelativeStart and SetRelativeEnd of the query builder as
+// well as NewSamplingAggregator and its variants.
+func SamplingFromDuration(d time.Duration) (int, TimeUnit) {
+	d = d.Truncate(time.Millisecond)
+	if d == 0 {
+		return 0, MILLISECONDS
+	}
+
+	for _, du := range durationUnits {
+		if d%du.size == 0 {
+			return int(d / du.size), du.unit
+		}
+	}
+
+	return int(d / time.Millisecond), MILLISECONDS
+}
+
+// Returns the length of value times unit, false for months and years, whose
+// length varies.
+func FixedDuration(value int, unit TimeUnit) (time.Duration, bool) {
+	u := TimeUnit(strings.ToLower(string(unit)))
+	if u == MILLISECONDS {
+		return time.Duration(value) * time.Millisecond, true
+	}
+
+	for _, du := range durationUnits {
+		if u == du.unit {
+			return time.Duration(value) * du.size, true
+		}
+	}
+
+	return 0, false
+}
+
+// Returns a relative time of d before now, using the most natural unit as
+// described in SamplingFromDuration.
+func RelativeTimeFromDuration(d time.Duration) *RelativeTime {
+	return NewRelativeTime(SamplingFromDuration(d))
+}
@@ -0,0 +1,70 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestSamplingFromDuration(t *testing.T) {
+	tests := []struct {
+		d     time.Duration
+		value int
+		unit  TimeUnit
+	}{
+		{2 * 7 * 24 * time.Hour, 2, WEEKS},
+		{3 * 24 * time.Hour, 3, DAYS},
+		{time.Hour, 1, HOURS},
+		{90 * time.Minute, 90, MINUTES},
+		{30 * time.Second, 30, SECONDS},
+		{1500 * time.Millisecond, 1500, MILLISECONDS},
+		{time.Second + 300*time.Microsecond, 1, SECONDS},
+		{0, 0, MILLISECONDS},
+	}
+
+	for _, test := range tests {
+		value, unit := SamplingFromDuration(test.d)
+		assert.Equal(t, test.value, value, test.d.String())
+		assert.Equal(t, test.unit, unit, test.d.String())
+	}
+}
+
+// Success test.
+func TestRelativeTimeFromDuration(t *testing.T) {
+	rt := RelativeTimeFromDuration(36 * time.Hour)
+	assert.Equal(t, 36, rt.Value())
+	assert.Equal(t, TimeUnit(HOURS), rt.Unit())
+
+	now := time.Now()
+	assert.Equal(t, now.Add(-36*time.Hour), rt.RelativeTimeTo(now))
+}
+
+// Success test.
+func TestFixedDuration(t *testing.T) {
+	d, ok := FixedDuration(90, MINUTES)
+	assert.True(t, ok)
+	assert.Equal(t, 90*time.Minute, d)
+
+	d, ok = FixedDuration(250, "MILLISECONDS")
+	assert.True(t, ok)
+	assert.Equal(t, 250*time.Millisecond, d)
+
+	_, ok = FixedDuration(1, MONTHS)
+	assert.False(t, ok, "Months vary in length")
+}
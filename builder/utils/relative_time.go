@@ -14,7 +14,10 @@
 
 package utils
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 type RelativeTime struct {
 	RTvalue int      `json:"value,omitempty"`
@@ -39,7 +42,7 @@ func (rt *RelativeTime) Unit() TimeUnit {
 func (rt *RelativeTime) RelativeTimeTo(t time.Time) time.Time {
 	var newTime time.Time
 
-	switch rt.RTunit {
+	switch TimeUnit(strings.ToLower(string(rt.RTunit))) {
 	case YEARS:
 		newTime = t.AddDate(-rt.RTvalue, 0, 0)
 	case MONTHS:
@@ -55,6 +58,8 @@ func (rt *RelativeTime) RelativeTimeTo(t time.Time) time.Time {
 		newTime = t.Add(-(time.Duration(rt.RTvalue) * time.Minute))
 	case SECONDS:
 		newTime = t.Add(-(time.Duration(rt.RTvalue) * time.Second))
+	case MILLISECONDS:
+		newTime = t.Add(-(time.Duration(rt.RTvalue) * time.Millisecond))
 	}
 
 	return newTime
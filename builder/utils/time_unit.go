@@ -14,6 +14,8 @@
 
 package utils
 
+import "strings"
+
 type TimeUnit string
 
 const (
@@ -26,3 +28,13 @@ const (
 	MONTHS                = "months"
 	YEARS                 = "years"
 )
+
+// Returns whether the unit is one of the units KairosDB accepts. Like the
+// server, the comparison ignores case.
+func (tu TimeUnit) IsValid() bool {
+	switch TimeUnit(strings.ToLower(string(tu))) {
+	case MILLISECONDS, SECONDS, MINUTES, HOURS, DAYS, WEEKS, MONTHS, YEARS:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"encoding/base64"
+	"net"
+	"net/http"
+)
+
+// Provides the Authorization header of the requests sent to KairosDB, for
+// authentication schemes the client does not implement itself. The header
+// replaces the basic authentication set with SetCredentials.
+type AuthProvider interface {
+	// Returns the value of the Authorization header for the request, or an
+	// empty string to send the request without one.
+	Authorization(req *http.Request) (string, error)
+}
+
+// Adapts a function to the AuthProvider interface.
+type AuthProviderFunc func(req *http.Request) (string, error)
+
+func (f AuthProviderFunc) Authorization(req *http.Request) (string, error) {
+	return f(req)
+}
+
+// Authenticates every request with the given provider.
+func WithAuthProvider(p AuthProvider) Option {
+	return func(hc *httpClient) {
+		hc.authProvider = p
+	}
+}
+
+// Returns an AuthProvider for SPNEGO (Negotiate) authentication, used by
+// Kerberos protected proxies. initSecContext produces the SPNEGO token for
+// the service principal name, HTTP/<host>, typically with a Kerberos library
+// such as gokrb5; keeping it pluggable spares the client a Kerberos
+// dependency.
+func NegotiateAuth(initSecContext func(spn string) ([]byte, error)) AuthProvider {
+	return AuthProviderFunc(func(req *http.Request) (string, error) {
+		host := req.URL.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		token, err := initSecContext("HTTP/" + host)
+		if err != nil {
+			return "", err
+		}
+
+		return "Negotiate " + base64.StdEncoding.EncodeToString(token), nil
+	})
+}
+
+// Sets the Authorization header from the auth provider, if any.
+func (hc *httpClient) authorize(req *http.Request) error {
+	if hc.authProvider == nil {
+		return nil
+	}
+
+	auth, err := hc.authProvider.Authorization(req)
+	if err != nil {
+		return err
+	}
+
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestNegotiateAuth(t *testing.T) {
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	var spn string
+	cli := NewHttpClientWithOptions(srv.URL, WithAuthProvider(NegotiateAuth(func(s string) ([]byte, error) {
+		spn = s
+		return []byte("token"), nil
+	})))
+	cli.SetCredentials("user", "pass")
+
+	_, err := cli.HealthCheck()
+	assert.Nil(t, err, "No error expected")
+
+	u, _ := url.Parse(srv.URL)
+	assert.Equal(t, "HTTP/"+u.Hostname(), spn, "Service principal must name the host")
+	assert.Equal(t, "Negotiate dG9rZW4=", auth, "Negotiate header must replace basic auth")
+}
+
+// Failure test.
+func TestAuthProviderError(t *testing.T) {
+	srv := newHealthServer(http.StatusNoContent)
+	defer srv.Close()
+
+	authErr := errors.New("no ticket")
+	cli := NewHttpClientWithOptions(srv.URL, WithAuthProvider(AuthProviderFunc(func(*http.Request) (string, error) {
+		return "", authErr
+	})))
+
+	_, err := cli.HealthCheck()
+	assert.ErrorIs(t, err, authErr, "Provider error expected")
+}
@@ -0,0 +1,160 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Fails the queries whose response body, once decompressed, is larger than
+// n bytes with ErrorResponseTooLarge instead of holding it in memory. Zero
+// means no limit.
+func WithMaxResponseSize(n int64) Option {
+	return func(hc *httpClient) {
+		hc.maxResponseSize = n
+	}
+}
+
+// Options of the automatic query splitting.
+type AutoSplitOptions struct {
+	// Time range below which a query is no longer split and its error is
+	// returned. Defaults to one minute.
+	MinSpan time.Duration
+
+	// Invoked with the time range of every query that is split. May be nil.
+	OnSplit func(tr builder.TimeRange)
+}
+
+// Retries the queries whose response exceeds WithMaxResponseSize or that
+// time out, either on the client or with a 504 from a gateway, as two
+// queries over the halves of the time range, splitting further as needed.
+// The results are merged as if a single query had been run: the split is
+// moved to a multiple of the sampling of the aggregators, and queries whose
+// results would change if split, see builder.SplitAlignment, fail as they
+// would without splitting. Queries whose context is done are not split. A
+// single call opts out with ContextWithoutAutoSplit.
+func WithAutoSplit(opts AutoSplitOptions) Option {
+	return func(hc *httpClient) {
+		if opts.MinSpan <= 0 {
+			opts.MinSpan = time.Minute
+		}
+		hc.autoSplit = &opts
+	}
+}
+
+type noAutoSplitKey struct{}
+
+// Returns a copy of the context disabling the automatic splitting of the
+// queries run with it.
+func ContextWithoutAutoSplit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noAutoSplitKey{}, true)
+}
+
+// Returned by querySplit when the query is too short to be split.
+var errNoSplit = errors.New("query not split")
+
+func (hc *httpClient) readQueryBody(r io.Reader) ([]byte, error) {
+	if hc.maxResponseSize <= 0 {
+		return ioutil.ReadAll(r)
+	}
+
+	contents, err := ioutil.ReadAll(io.LimitReader(r, hc.maxResponseSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(contents)) > hc.maxResponseSize {
+		return nil, ErrorResponseTooLarge
+	}
+	return contents, nil
+}
+
+// Returns whether the outcome of a query calls for splitting it.
+func (hc *httpClient) shouldSplit(ctx context.Context, qr *response.QueryResponse, err error) bool {
+	if ctx.Err() != nil || ctx.Value(noAutoSplitKey{}) != nil {
+		return false
+	}
+
+	if err != nil {
+		var ne net.Error
+		return errors.Is(err, ErrorResponseTooLarge) || (errors.As(err, &ne) && ne.Timeout())
+	}
+
+	return qr.GetStatusCode() == http.StatusGatewayTimeout
+}
+
+// Runs the query over the two halves of its time range and merges the
+// results.
+func (hc *httpClient) querySplit(ctx context.Context, qb builder.QueryBuilder) (*response.QueryResponse, error) {
+	align, err := builder.SplitAlignment(qb)
+	if err != nil {
+		return nil, errNoSplit
+	}
+
+	now := hc.clock.Now()
+	tr, err := builder.ResolveTimeRange(qb, now)
+	if err != nil {
+		return nil, err
+	}
+
+	half := (tr.End.Sub(tr.Start) / 2).Truncate(time.Millisecond)
+	if half < hc.autoSplit.MinSpan {
+		return nil, errNoSplit
+	}
+
+	// The range is inclusive, so it is one millisecond longer than the
+	// difference of its ends.
+	span, first := tr.End.Sub(tr.Start)+time.Millisecond, half+time.Millisecond
+	if align > 0 {
+		// Rounded up so that no sampling bucket straddles the split.
+		first = (first + align - 1) / align * align
+		if first >= span {
+			return nil, errNoSplit
+		}
+	}
+
+	chunks, err := builder.SplitQuery(qb, first, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if hc.autoSplit.OnSplit != nil {
+		hc.autoSplit.OnSplit(tr)
+	}
+
+	merged := response.NewQueryResponse(http.StatusOK)
+	for _, chunk := range chunks {
+		qr, err := hc.QueryContext(ctx, chunk.Query)
+		if err != nil {
+			return nil, err
+		}
+
+		if qr.GetStatusCode() >= http.StatusMultipleChoices {
+			return qr, nil
+		}
+		merged.Merge(qr)
+	}
+
+	return merged.ApplyAliases(qb), nil
+}
@@ -0,0 +1,164 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// Answers with one data point per minute of the queried range, or with
+// status when the range is longer than maxSpan and status is set.
+func newRangeServer(maxSpan time.Duration, status int, ranges *[][2]int64) *httptest.Server {
+	var mu sync.Mutex
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		var q struct {
+			Start int64 `json:"start_absolute"`
+			End   int64 `json:"end_absolute"`
+		}
+		json.Unmarshal(body, &q)
+
+		mu.Lock()
+		*ranges = append(*ranges, [2]int64{q.Start, q.End})
+		mu.Unlock()
+
+		if status != 0 && time.Duration(q.End-q.Start)*time.Millisecond > maxSpan {
+			w.WriteHeader(status)
+			return
+		}
+
+		var dps []string
+		for ts := q.Start; ts <= q.End; ts += time.Minute.Milliseconds() {
+			dps = append(dps, fmt.Sprintf("[%d,1]", ts))
+		}
+		fmt.Fprintf(w, `{"queries":[{"sample_size":%d,"results":[{"name":"m1","tags":{},"values":[%s]}]}]}`,
+			len(dps), strings.Join(dps, ","))
+	}))
+}
+
+func splitQuery(span time.Duration) builder.QueryBuilder {
+	start := time.Unix(0, 0).Add(time.Hour)
+	qb := builder.NewQueryBuilder()
+	qb.SetTimeRange(start, start.Add(span-time.Millisecond))
+	qb.AddMetric("m1").SetAlias("load")
+	return qb
+}
+
+// Success test.
+func TestWithAutoSplitResponseSize(t *testing.T) {
+	var ranges [][2]int64
+	srv := newRangeServer(0, 0, &ranges)
+	defer srv.Close()
+
+	var splits int
+	cli := NewHttpClientWithOptions(srv.URL,
+		WithMaxResponseSize(1500),
+		WithAutoSplit(AutoSplitOptions{OnSplit: func(builder.TimeRange) { splits++ }}))
+
+	qr, err := cli.Query(splitQuery(4 * time.Hour))
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 3, splits, "The whole range and both halves must be split")
+	assert.Len(t, ranges, 7)
+
+	res := qr.QueriesArr[0].ResultsArr[0]
+	assert.Len(t, res.DataPoints, 240, "Every data point must be returned once")
+	assert.Equal(t, int64(240), qr.QueriesArr[0].SampleSize)
+	assert.Equal(t, "load", res.Alias, "Aliases must be applied to the merged results")
+}
+
+// Success test.
+func TestWithAutoSplitGatewayTimeout(t *testing.T) {
+	var ranges [][2]int64
+	srv := newRangeServer(time.Hour, http.StatusGatewayTimeout, &ranges)
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithAutoSplit(AutoSplitOptions{}))
+
+	qr, err := cli.Query(splitQuery(2 * time.Hour))
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusOK, qr.GetStatusCode())
+	assert.Len(t, qr.QueriesArr[0].ResultsArr[0].DataPoints, 120)
+}
+
+// Failure test.
+func TestWithAutoSplitOptOut(t *testing.T) {
+	var ranges [][2]int64
+	srv := newRangeServer(0, 0, &ranges)
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithMaxResponseSize(1500), WithAutoSplit(AutoSplitOptions{}))
+
+	_, err := cli.QueryContext(ContextWithoutAutoSplit(context.Background()), splitQuery(4*time.Hour))
+	assert.ErrorIs(t, err, ErrorResponseTooLarge)
+	assert.Len(t, ranges, 1, "Query must not be split")
+}
+
+// Failure test.
+func TestWithAutoSplitMinSpan(t *testing.T) {
+	var ranges [][2]int64
+	srv := newRangeServer(0, 0, &ranges)
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithMaxResponseSize(100), WithAutoSplit(AutoSplitOptions{MinSpan: time.Hour}))
+
+	_, err := cli.Query(splitQuery(4 * time.Hour))
+	assert.ErrorIs(t, err, ErrorResponseTooLarge, "Error must be returned once the minimum span is reached")
+	assert.Len(t, ranges, 2, "The first half must not be split and fail the query")
+}
+
+// Success test.
+func TestWithAutoSplitSampling(t *testing.T) {
+	var ranges [][2]int64
+	srv := newRangeServer(2*time.Hour, http.StatusGatewayTimeout, &ranges)
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithAutoSplit(AutoSplitOptions{}))
+
+	qb := splitQuery(3 * time.Hour)
+	qb.Metrics()[0].AddAggregator(builder.CreateAverageAggregator(2, utils.HOURS))
+	_, err := cli.Query(qb)
+	assert.Nil(t, err, "No error expected")
+	assert.Len(t, ranges, 3)
+	assert.Equal(t, ranges[1][0]+2*time.Hour.Milliseconds(), ranges[2][0], "Split must fall on a sampling boundary")
+}
+
+// Failure test.
+func TestWithAutoSplitNotSplittable(t *testing.T) {
+	var ranges [][2]int64
+	srv := newRangeServer(time.Hour, http.StatusGatewayTimeout, &ranges)
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithAutoSplit(AutoSplitOptions{}))
+
+	qb := splitQuery(2 * time.Hour)
+	qb.Metrics()[0].SetLimit(10)
+	qr, err := cli.Query(qb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusGatewayTimeout, qr.GetStatusCode(), "Query with a limit must not be split")
+	assert.Len(t, ranges, 1)
+}
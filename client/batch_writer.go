@@ -0,0 +1,583 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Options of the BatchWriter.
+type BatchOptions struct {
+	// Number of data points that triggers a flush. Defaults to 5000.
+	BatchSize int
+
+	// Maximum time data points wait for a flush when the batch does not
+	// fill up. Defaults to 1 second.
+	FlushInterval time.Duration
+
+	// Estimated size of the JSON of a batch that triggers a flush, however
+	// few data points it holds. Defaults to 4 MiB.
+	MaxPendingBytes int
+
+	// Number of full batches that can wait to be pushed. When the queue is
+	// full new batches are dropped. Defaults to 16.
+	QueueSize int
+
+	// Number of times a batch failing with a request error or a 5xx status
+	// is pushed before it is dropped. Defaults to 3.
+	MaxAttempts int
+
+	// Delay before the first retry, doubled for every subsequent one.
+	// Defaults to 100ms.
+	Backoff time.Duration
+
+	// Invoked with every batch that is dropped and the reason, including
+	// the batches abandoned by Close without DeadLetter. May be nil.
+	OnError func(mb builder.MetricBuilder, err error)
+
+	// Receives the batches of a push abandoned because the context of Flush
+	// or Close is done, e.g. to write them aside. Nil re-queues the batches
+	// abandoned by Flush and hands the ones abandoned by Close to OnError.
+	DeadLetter func(mb builder.MetricBuilder, err error)
+
+	// File the data points Close could not push are saved to, instead of
+	// being handed to DeadLetter or OnError, to be restored by
+	// RestoreSnapshot after a restart. Empty disables snapshots.
+	SnapshotPath string
+
+	// Paces the flushes and the retries. Defaults to the wall clock.
+	Clock clock.Clock
+}
+
+type batchItem struct {
+	mb      builder.MetricBuilder
+	points  int
+	flushed chan struct{} // Closed once the items before it are pushed.
+}
+
+// A MetricWriter collecting data points into batches pushed in the
+// background, for high throughput ingestion: a batch is pushed once it
+// holds BatchSize data points or MaxPendingBytes of JSON, or FlushInterval
+// after the last push. Pushes return a 202 response without contacting
+// KairosDB. Compression of the batches is up to the underlying client, see
+// WithGzip.
+//
+// Close must be called to push the buffered data points.
+type BatchWriter struct {
+	MetricWriter
+	opts  BatchOptions
+	queue chan batchItem
+	done  chan struct{}
+
+	// Context of the pushes, cancelled by Close to abandon them.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	closeMu sync.RWMutex // Held for writing while the queue is closed.
+
+	mu      sync.Mutex // Guards the fields below.
+	batch   builder.MetricBuilder
+	series  map[string]builder.Metric
+	points  int
+	bytes   int
+	pending int // Data points buffered or queued.
+	closed  bool
+	abandon context.CancelFunc     // Cancels the push in progress, if any.
+	unsent  []builder.DataPointSet // Abandoned by Close, to be saved.
+}
+
+// Creates a batching writer and starts pushing in the background.
+func NewBatchWriter(w MetricWriter, opts BatchOptions) *BatchWriter {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = time.Second
+	}
+	if opts.MaxPendingBytes <= 0 {
+		opts.MaxPendingBytes = 4 << 20
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 16
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 100 * time.Millisecond
+	}
+	opts.Clock = clock.OrReal(opts.Clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bw := &BatchWriter{
+		MetricWriter: w,
+		opts:         opts,
+		queue:        make(chan batchItem, opts.QueueSize),
+		done:         make(chan struct{}),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+	bw.reset()
+	go bw.run()
+
+	return bw
+}
+
+// Adds a data point, the timestamp in milliseconds, to the current batch.
+func (bw *BatchWriter) Add(metric string, tags map[string]string, ts int64, value interface{}) error {
+	if metric == "" {
+		return builder.ErrorMetricNameInvalid
+	}
+	for k, v := range tags {
+		if k == "" {
+			return builder.ErrorTagNameInvalid
+		} else if v == "" {
+			return builder.ErrorTagValueInvalid
+		}
+	}
+
+	bw.mu.Lock()
+	if bw.closed {
+		bw.mu.Unlock()
+		return ErrorBatchWriterClosed
+	}
+
+	bw.add(metric, tags, "", 0, ts, value)
+	dropped := bw.flushIfFull()
+	bw.mu.Unlock()
+
+	bw.dropAll(dropped)
+	return nil
+}
+
+// Adds the data points of the builder to the current batch. The response
+// is a 202 Accepted, the outcome of the push is only known to OnError.
+func (bw *BatchWriter) PushMetrics(mb builder.MetricBuilder) (*response.Response, error) {
+	return bw.PushMetricsContext(context.Background(), mb)
+}
+
+// Same as PushMetrics, the context is not used.
+func (bw *BatchWriter) PushMetricsContext(ctx context.Context, mb builder.MetricBuilder) (*response.Response, error) {
+	if _, err := mb.Build(); err != nil {
+		return nil, err
+	}
+
+	bw.mu.Lock()
+	if bw.closed {
+		bw.mu.Unlock()
+		return nil, ErrorBatchWriterClosed
+	}
+
+	var dropped []batchItem
+	for _, m := range mb.GetMetrics() {
+		for _, dp := range m.GetDataPoints() {
+			bw.add(m.GetName(), m.GetTags(), m.GetType(), m.GetTTL(), dp.Timestamp(), dp.Value())
+		}
+		dropped = append(dropped, bw.flushIfFull()...)
+	}
+	bw.mu.Unlock()
+
+	bw.dropAll(dropped)
+
+	resp := &response.Response{}
+	resp.SetStatusCode(http.StatusAccepted)
+	return resp, nil
+}
+
+// Pushes the current batch and waits until all the batches queued before
+// are pushed or dropped, or the context is done. Once the context is done
+// the push in progress is abandoned, its batch re-queued or handed to
+// DeadLetter, and the batches still queued are pushed in the background.
+func (bw *BatchWriter) Flush(ctx context.Context) error {
+	bw.closeMu.RLock()
+	defer bw.closeMu.RUnlock()
+
+	bw.mu.Lock()
+	if bw.closed {
+		bw.mu.Unlock()
+		return ErrorBatchWriterClosed
+	}
+	item := bw.take()
+	bw.mu.Unlock()
+
+	if item.points > 0 {
+		select {
+		case bw.queue <- item:
+		case <-ctx.Done():
+			bw.mu.Lock()
+			bw.pending -= item.points
+			bw.mu.Unlock()
+
+			bw.abandoned(item, ctx.Err())
+			return ctx.Err()
+		}
+	}
+
+	flushed := make(chan struct{})
+	select {
+	case bw.queue <- batchItem{flushed: flushed}:
+	case <-ctx.Done():
+		bw.abandonPush()
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		bw.abandonPush()
+		return ctx.Err()
+	}
+}
+
+// Pushes the buffered data points and stops the writer. When the context
+// is done first, the push in progress is abandoned, it and the batches
+// still queued are saved to the snapshot file or handed to DeadLetter, or
+// OnError, and the context error is returned.
+func (bw *BatchWriter) Close(ctx context.Context) error {
+	err := bw.Flush(ctx)
+	if err == ErrorBatchWriterClosed {
+		return nil
+	}
+	defer bw.cancel()
+
+	bw.closeMu.Lock()
+	bw.mu.Lock()
+	closed := bw.closed
+	bw.closed = true
+	bw.mu.Unlock()
+	if !closed {
+		close(bw.queue)
+	}
+	bw.closeMu.Unlock()
+
+	if err == nil {
+		select {
+		case <-bw.done:
+			return nil
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	bw.cancel()
+	<-bw.done
+
+	// Left by a push abandoned by Flush and re-queued meanwhile.
+	bw.mu.Lock()
+	item := bw.take()
+	bw.pending -= item.points
+	bw.mu.Unlock()
+	if item.points > 0 {
+		bw.abandoned(item, err)
+	}
+
+	if serr := bw.saveSnapshot(); serr != nil {
+		return serr
+	}
+	return err
+}
+
+// Adds the data points saved by Close before a restart to the current batch
+// and removes the snapshot file. A missing file is not an error.
+func (bw *BatchWriter) RestoreSnapshot() error {
+	if bw.opts.SnapshotPath == "" {
+		return nil
+	}
+
+	var sets []builder.DataPointSet
+	if ok, err := readSnapshot(bw.opts.SnapshotPath, &sets); !ok || err != nil {
+		return err
+	}
+
+	bw.mu.Lock()
+	if bw.closed {
+		bw.mu.Unlock()
+		return ErrorBatchWriterClosed
+	}
+
+	var dropped []batchItem
+	for _, s := range sets {
+		for _, dp := range s.DataPoints {
+			bw.add(s.Name, s.Tags, s.Type, s.TTL, dp.Timestamp(), dp.Value())
+		}
+		dropped = append(dropped, bw.flushIfFull()...)
+	}
+	bw.mu.Unlock()
+
+	bw.dropAll(dropped)
+	return os.Remove(bw.opts.SnapshotPath)
+}
+
+// Saves the data points abandoned by Close to the snapshot file, along with
+// the ones of a snapshot not restored yet.
+func (bw *BatchWriter) saveSnapshot() error {
+	bw.mu.Lock()
+	unsent := bw.unsent
+	bw.unsent = nil
+	bw.mu.Unlock()
+
+	if len(unsent) == 0 {
+		return nil
+	}
+
+	var sets []builder.DataPointSet
+	_, err := readSnapshot(bw.opts.SnapshotPath, &sets)
+	if err == nil {
+		err = writeSnapshot(bw.opts.SnapshotPath, append(sets, unsent...))
+	}
+	if err != nil {
+		bw.opts.onError(metricBuilderOf(unsent), err)
+	}
+
+	return err
+}
+
+// Returns the number of data points buffered or waiting to be pushed.
+func (bw *BatchWriter) Pending() int {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return bw.pending
+}
+
+// Returns the number of data points of a full queue, see Backlog.
+func (bw *BatchWriter) Capacity() int {
+	return bw.opts.QueueSize * bw.opts.BatchSize
+}
+
+const defaultBatchSize = 5000
+
+// Estimated JSON size of the data point and of the series it starts.
+const (
+	batchPointBytes  = 32
+	batchSeriesBytes = 48
+)
+
+func (bw *BatchWriter) add(name string, tags map[string]string, typ string, ttl, ts int64, value interface{}) {
+	key := preAggKey(name, tags) + "\x01" + typ + "\x01" + strconv.FormatInt(ttl, 10)
+	m, ok := bw.series[key]
+	if !ok {
+		m = bw.batch.AddMetric(name).AddTags(tags)
+		if typ != "" {
+			m.AddType(typ)
+		}
+		if ttl > 0 {
+			m.AddTTL(ttl)
+		}
+		bw.series[key] = m
+
+		bw.bytes += batchSeriesBytes + len(name) + len(typ)
+		for k, v := range tags {
+			bw.bytes += len(k) + len(v) + 6
+		}
+	}
+
+	m.AddDataPoint(ts, value)
+	bw.points++
+	bw.pending++
+	bw.bytes += batchPointBytes
+}
+
+// Queues the current batch when it is full. Must be called with mu held.
+// Returns the batch when the queue is full.
+func (bw *BatchWriter) flushIfFull() []batchItem {
+	if bw.points < bw.opts.BatchSize && bw.bytes < bw.opts.MaxPendingBytes {
+		return nil
+	}
+	return bw.flushNow()
+}
+
+// Queues the current batch. Must be called with mu held. Returns the batch
+// when the queue is full, to be dropped once mu is released.
+func (bw *BatchWriter) flushNow() []batchItem {
+	item := bw.take()
+	select {
+	case bw.queue <- item:
+		return nil
+	default:
+		bw.pending -= item.points
+		return []batchItem{item}
+	}
+}
+
+// Hands a batch whose push is abandoned to DeadLetter. Without DeadLetter,
+// the batch is re-queued unless the writer is closing. Batches abandoned by
+// Close are kept for the snapshot when there is one.
+func (bw *BatchWriter) abandoned(item batchItem, err error) {
+	bw.mu.Lock()
+	closing := bw.closed || bw.ctx.Err() != nil
+	if closing && bw.opts.SnapshotPath != "" {
+		bw.unsent = append(bw.unsent, dataPointSetsOf(item.mb)...)
+		bw.mu.Unlock()
+		return
+	}
+
+	if bw.opts.DeadLetter != nil {
+		bw.mu.Unlock()
+		bw.opts.DeadLetter(item.mb, err)
+		return
+	}
+
+	if closing {
+		bw.mu.Unlock()
+		bw.opts.onError(item.mb, err)
+		return
+	}
+
+	var dropped []batchItem
+	for _, m := range item.mb.GetMetrics() {
+		for _, dp := range m.GetDataPoints() {
+			bw.add(m.GetName(), m.GetTags(), m.GetType(), m.GetTTL(), dp.Timestamp(), dp.Value())
+		}
+		dropped = append(dropped, bw.flushIfFull()...)
+	}
+	bw.mu.Unlock()
+
+	bw.dropAll(dropped)
+}
+
+// Abandons the push in progress, if any.
+func (bw *BatchWriter) abandonPush() {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	if bw.abandon != nil {
+		bw.abandon()
+	}
+}
+
+func (bw *BatchWriter) dropAll(items []batchItem) {
+	for _, item := range items {
+		bw.opts.onError(item.mb, ErrorBatchQueueFull)
+	}
+}
+
+// Returns the current batch and starts a new one. Must be called with mu
+// held.
+func (bw *BatchWriter) take() batchItem {
+	item := batchItem{mb: bw.batch, points: bw.points}
+	bw.reset()
+	return item
+}
+
+func (bw *BatchWriter) reset() {
+	bw.batch = builder.NewMetricBuilder()
+	bw.series = make(map[string]builder.Metric)
+	bw.points = 0
+	bw.bytes = 0
+}
+
+func (bw *BatchWriter) run() {
+	defer close(bw.done)
+
+	for {
+		timer := bw.opts.Clock.NewTimer(bw.opts.FlushInterval)
+		select {
+		case item, ok := <-bw.queue:
+			timer.Stop()
+			if !ok {
+				return
+			}
+
+			if item.flushed != nil {
+				close(item.flushed)
+				continue
+			}
+
+			ctx, cancel := context.WithCancel(bw.ctx)
+			bw.mu.Lock()
+			bw.abandon = cancel
+			bw.mu.Unlock()
+
+			err := bw.push(ctx, item.mb)
+			abandoned := ctx.Err() != nil
+
+			bw.mu.Lock()
+			bw.abandon = nil
+			bw.pending -= item.points
+			bw.mu.Unlock()
+			cancel()
+
+			if err != nil && abandoned {
+				bw.abandoned(item, err)
+			} else if err != nil {
+				bw.opts.onError(item.mb, err)
+			}
+
+		case <-timer.C():
+			var dropped []batchItem
+			bw.mu.Lock()
+			if bw.points > 0 && !bw.closed {
+				dropped = bw.flushNow()
+			}
+			bw.mu.Unlock()
+
+			bw.dropAll(dropped)
+		}
+	}
+}
+
+// Pushes the batch until it is accepted, rejected or the context is done.
+func (bw *BatchWriter) push(ctx context.Context, mb builder.MetricBuilder) error {
+	backoff := bw.opts.Backoff
+
+	for attempt := 1; ; attempt++ {
+		if err := ctx.Err(); err != nil {
+			// Abandoned by Flush or Close.
+			return err
+		}
+
+		resp, err := bw.MetricWriter.PushMetricsContext(ctx, mb)
+		retry := err != nil
+		if err == nil {
+			code := resp.GetStatusCode()
+			if code < http.StatusMultipleChoices {
+				return nil
+			}
+
+			err = fmt.Errorf("%w: status %d: %v", ErrorBatchRejected, code, resp.GetErrors())
+			retry = code >= http.StatusInternalServerError
+		}
+
+		if !retry || attempt >= bw.opts.MaxAttempts {
+			return err
+		}
+
+		timer := bw.opts.Clock.NewTimer(backoff)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+func (opts BatchOptions) onError(mb builder.MetricBuilder, err error) {
+	if opts.OnError != nil {
+		opts.OnError(mb, err)
+	}
+}
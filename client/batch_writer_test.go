@@ -0,0 +1,307 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestBatchWriterBatchSize(t *testing.T) {
+	pr := newPushRecorder(http.StatusNoContent)
+	defer pr.srv.Close()
+
+	bw := NewBatchWriter(NewHttpClient(pr.srv.URL), BatchOptions{
+		BatchSize:     2,
+		FlushInterval: time.Hour,
+		Clock:         clock.NewFake(time.Now()),
+	})
+	for ts := int64(1); ts <= 5; ts++ {
+		assert.Nil(t, bw.Add("m1", map[string]string{"host": "a"}, ts, ts), "No error expected")
+	}
+	assert.Equal(t, 5, bw.Pending(), "Pending data points expected")
+
+	assert.Nil(t, bw.Close(context.Background()), "No error expected")
+	assert.Equal(t, []string{
+		`[{"name":"m1","tags":{"host":"a"},"datapoints":[[1,1],[2,2]]}]`,
+		`[{"name":"m1","tags":{"host":"a"},"datapoints":[[3,3],[4,4]]}]`,
+		`[{"name":"m1","tags":{"host":"a"},"datapoints":[[5,5]]}]`,
+	}, pr.Bodies(), "Batches of two data points expected")
+	assert.Equal(t, 0, bw.Pending(), "No pending data points expected")
+
+	assert.Equal(t, ErrorBatchWriterClosed, bw.Add("m1", nil, 6, 6), "Closed writer expected")
+	assert.Nil(t, bw.Close(context.Background()), "Close must be idempotent")
+}
+
+// Success test.
+func TestBatchWriterMaxPendingBytes(t *testing.T) {
+	pr := newPushRecorder(http.StatusNoContent)
+	defer pr.srv.Close()
+
+	bw := NewBatchWriter(NewHttpClient(pr.srv.URL), BatchOptions{
+		MaxPendingBytes: batchSeriesBytes + 2 + batchPointBytes*2,
+		FlushInterval:   time.Hour,
+		Clock:           clock.NewFake(time.Now()),
+	})
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddDataPoint(1, 1).AddDataPoint(2, 2).AddDataPoint(3, 3)
+	resp, err := bw.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusAccepted, resp.GetStatusCode(), "Accepted response expected")
+
+	assert.Nil(t, bw.Flush(context.Background()), "No error expected")
+	assert.Equal(t, []string{`[{"name":"m1","datapoints":[[1,1],[2,2],[3,3]]}]`}, pr.Bodies(),
+		"Batch flushed once over the size expected")
+	bw.Close(context.Background())
+}
+
+// Success test.
+func TestBatchWriterFlushInterval(t *testing.T) {
+	pr := newPushRecorder(http.StatusNoContent)
+	defer pr.srv.Close()
+
+	clk := clock.NewFake(time.Now())
+	bw := NewBatchWriter(NewHttpClient(pr.srv.URL), BatchOptions{FlushInterval: time.Second, Clock: clk})
+	defer bw.Close(context.Background())
+
+	assert.Nil(t, bw.Add("m1", nil, 1, 1.5), "No error expected")
+	clk.BlockUntil(1)
+	clk.Advance(time.Second)
+
+	assert.Eventually(t, func() bool { return len(pr.Bodies()) == 1 }, time.Second, time.Millisecond,
+		"Batch pushed after the interval expected")
+	assert.Equal(t, `[{"name":"m1","datapoints":[[1,1.5]]}]`, pr.Bodies()[0])
+}
+
+// Failure test.
+func TestBatchWriterRetries(t *testing.T) {
+	for _, tc := range []struct {
+		code int
+		hits int32
+	}{
+		{http.StatusServiceUnavailable, 3},
+		{http.StatusBadRequest, 1},
+	} {
+		var hits int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&hits, 1)
+			w.WriteHeader(tc.code)
+			w.Write([]byte(`{"errors":["failed"]}`))
+		}))
+
+		var mu sync.Mutex
+		var dropped []error
+		bw := NewBatchWriter(NewHttpClient(srv.URL), BatchOptions{
+			MaxAttempts: 3,
+			Backoff:     time.Millisecond,
+			OnError: func(mb builder.MetricBuilder, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				dropped = append(dropped, err)
+			},
+		})
+
+		bw.Add("m1", nil, 1, 1)
+		assert.Nil(t, bw.Close(context.Background()), "No error expected")
+		assert.Equal(t, tc.hits, atomic.LoadInt32(&hits), "Attempts for status %d", tc.code)
+		assert.Len(t, dropped, 1, "Dropped batch expected")
+		assert.True(t, errors.Is(dropped[0], ErrorBatchRejected), "Rejected batch expected")
+		srv.Close()
+	}
+}
+
+// Failure test.
+func TestBatchWriterQueueFull(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	var dropped int32
+	bw := NewBatchWriter(NewHttpClient(srv.URL), BatchOptions{
+		BatchSize:     1,
+		QueueSize:     1,
+		FlushInterval: time.Hour,
+		Clock:         clock.NewFake(time.Now()),
+		OnError: func(mb builder.MetricBuilder, err error) {
+			if errors.Is(err, ErrorBatchQueueFull) {
+				atomic.AddInt32(&dropped, 1)
+			}
+		},
+	})
+
+	// The first batch is being pushed, the second one queued.
+	bw.Add("m1", nil, 1, 1)
+	assert.Eventually(t, func() bool { return len(bw.queue) == 0 }, time.Second, time.Millisecond)
+	bw.Add("m1", nil, 2, 2)
+	bw.Add("m1", nil, 3, 3)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&dropped), "Batch dropped when the queue is full expected")
+	assert.Equal(t, 2, bw.Pending(), "Dropped data points not pending expected")
+
+	close(release)
+	assert.Nil(t, bw.Close(context.Background()), "No error expected")
+}
+
+// Failure test.
+func TestBatchWriterCloseAbandons(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	unavailable := newPushRecorder(http.StatusServiceUnavailable)
+	defer unavailable.srv.Close()
+
+	for name, url := range map[string]string{"push": srv.URL, "backoff": unavailable.srv.URL} {
+		var mu sync.Mutex
+		var abandoned []error
+		bw := NewBatchWriter(NewHttpClient(url), BatchOptions{
+			BatchSize:     1,
+			FlushInterval: time.Hour,
+			Backoff:       time.Hour,
+			OnError: func(mb builder.MetricBuilder, err error) {
+				mu.Lock()
+				defer mu.Unlock()
+				abandoned = append(abandoned, err)
+			},
+		})
+
+		// The first batch is being pushed, the second one queued.
+		bw.Add("m1", nil, 1, 1)
+		assert.Eventually(t, func() bool { return len(bw.queue) == 0 }, time.Second, time.Millisecond)
+		bw.Add("m1", nil, 2, 2)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		start := time.Now()
+		err := bw.Close(ctx)
+		cancel()
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "%s: deadline expected", name)
+		assert.Less(t, time.Since(start), 5*time.Second, "%s: Close must not wait for the push", name)
+		assert.Len(t, abandoned, 2, "%s: abandoned batches must be handed to OnError", name)
+		assert.Equal(t, 0, bw.Pending(), "%s: no pending data points expected", name)
+	}
+}
+
+// Failure test.
+func TestBatchWriterFlushAbandons(t *testing.T) {
+	var hold int32 = 1
+	var pushed int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		if atomic.LoadInt32(&hold) == 1 {
+			<-r.Context().Done()
+			return
+		}
+		atomic.AddInt32(&pushed, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var dead []builder.MetricBuilder
+	for _, deadLetter := range []func(builder.MetricBuilder, error){nil, func(mb builder.MetricBuilder, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		dead = append(dead, mb)
+	}} {
+		atomic.StoreInt32(&hold, 1)
+		bw := NewBatchWriter(NewHttpClient(srv.URL), BatchOptions{
+			FlushInterval: time.Hour,
+			Clock:         clock.NewFake(time.Now()),
+			DeadLetter:    deadLetter,
+		})
+		bw.Add("m1", nil, 1, 1)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		err := bw.Flush(ctx)
+		cancel()
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "The flush must be abandoned")
+
+		if deadLetter == nil {
+			assert.Eventually(t, func() bool { bw.mu.Lock(); defer bw.mu.Unlock(); return bw.points == 1 },
+				time.Second, time.Millisecond, "The abandoned batch must be re-queued")
+			assert.Equal(t, 1, bw.Pending(), "Re-queued data points must be pending")
+		} else {
+			assert.Eventually(t, func() bool { mu.Lock(); defer mu.Unlock(); return len(dead) == 1 },
+				time.Second, time.Millisecond, "The abandoned batch must be dead-lettered")
+			assert.Equal(t, 0, bw.Pending(), "Dead-lettered data points must not be pending")
+		}
+
+		atomic.StoreInt32(&hold, 0)
+		assert.Nil(t, bw.Close(context.Background()), "No error expected")
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&pushed), "Only the re-queued batch must be pushed again")
+}
+
+// Success test.
+func TestBatchWriterSnapshot(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+	pr := newPushRecorder(http.StatusNoContent)
+	defer pr.srv.Close()
+
+	var dropped int32
+	opts := BatchOptions{
+		BatchSize:     1,
+		FlushInterval: time.Hour,
+		SnapshotPath:  filepath.Join(t.TempDir(), "batches.json"),
+		OnError:       func(mb builder.MetricBuilder, err error) { atomic.AddInt32(&dropped, 1) },
+	}
+
+	bw := NewBatchWriter(NewHttpClient(srv.URL), opts)
+	assert.Nil(t, bw.RestoreSnapshot(), "A missing snapshot is not an error")
+
+	// The first batch is being pushed, the second one queued.
+	bw.Add("m1", map[string]string{"host": "a"}, 1, 1)
+	assert.Eventually(t, func() bool { return len(bw.queue) == 0 }, time.Second, time.Millisecond)
+	bw.Add("m1", map[string]string{"host": "a"}, 2, "on")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.True(t, errors.Is(bw.Close(ctx), context.DeadlineExceeded), "Deadline expected")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&dropped), "Unsent data points must be saved, not dropped")
+
+	// After the restart the saved data points are pushed.
+	opts.BatchSize = 10
+	bw = NewBatchWriter(NewHttpClient(pr.srv.URL), opts)
+	assert.Nil(t, bw.RestoreSnapshot(), "No error expected")
+	assert.Equal(t, 2, bw.Pending(), "Saved data points must be restored")
+	_, err := os.Stat(opts.SnapshotPath)
+	assert.True(t, os.IsNotExist(err), "The snapshot must be removed once restored")
+
+	assert.Nil(t, bw.Close(context.Background()), "No error expected")
+	assert.Equal(t, []string{`[{"name":"m1","tags":{"host":"a"},"datapoints":[[1,1],[2,"on"]]}]`}, pr.Bodies())
+}
@@ -0,0 +1,134 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Options of a bulk metric deletion.
+type DeleteOptions struct {
+	// Minimum time between the start of two deletions, limiting the load put
+	// on the server. Defaults to no limit.
+	Interval time.Duration
+
+	// Called with the result of every deletion as soon as it completes, e.g.
+	// to report progress. Called from several goroutines at once.
+	OnResult func(DeleteResult)
+
+	// Paces the deletions. Defaults to the wall clock.
+	Clock clock.Clock
+}
+
+// Result of the deletion of one metric.
+type DeleteResult struct {
+	Name     string
+	Response *response.Response
+	Err      error
+}
+
+// Tells whether the metric was deleted.
+func (r DeleteResult) Deleted() bool {
+	return r.Err == nil && r.Response != nil && r.Response.GetStatusCode() < http.StatusMultipleChoices
+}
+
+// Deletes the metrics, running up to concurrency deletions at once. The
+// results are returned in the order of the names, whether or not the
+// deletions succeeded.
+//
+// When some metrics are not deleted, an error wrapping ErrorDeleteMetrics is
+// returned along with the results. When the context is done, no further
+// deletion is started, the deletions in flight complete and the metrics
+// left out carry the context error, which is returned as well.
+func DeleteMetrics(ctx context.Context, a Admin, names []string, concurrency int, opts DeleteOptions) ([]DeleteResult, error) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	clk := clock.OrReal(opts.Clock)
+
+	results := make([]DeleteResult, len(names))
+	for i, name := range names {
+		results[i].Name = name
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	started := 0
+	for i := range names {
+		if i > 0 && opts.Interval > 0 && !pause(ctx, clk, opts.Interval) {
+			break
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		started++
+		wg.Add(1)
+		go func(r *DeleteResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			r.Response, r.Err = a.DeleteMetric(r.Name)
+			if opts.OnResult != nil {
+				opts.OnResult(*r)
+			}
+		}(&results[i])
+	}
+	wg.Wait()
+
+	if started < len(names) {
+		for i := started; i < len(names); i++ {
+			results[i].Err = ctx.Err()
+		}
+		return results, ctx.Err()
+	}
+
+	failed := 0
+	for _, r := range results {
+		if !r.Deleted() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("%w: %d of %d", ErrorDeleteMetrics, failed, len(names))
+	}
+
+	return results, nil
+}
+
+// Waits for d, returning false if the context is done first.
+func pause(ctx context.Context, clk clock.Clock, d time.Duration) bool {
+	timer := clk.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Records the deleted metrics, answering 404 for the ones named "missing*".
+type deleteRecorder struct {
+	srv      *httptest.Server
+	mu       sync.Mutex
+	deleted  []string
+	inFlight int32
+	maxSeen  int32
+}
+
+func newDeleteRecorder(delay time.Duration) *deleteRecorder {
+	d := &deleteRecorder{}
+	d.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&d.inFlight, 1)
+		defer atomic.AddInt32(&d.inFlight, -1)
+		for {
+			seen := atomic.LoadInt32(&d.maxSeen)
+			if n <= seen || atomic.CompareAndSwapInt32(&d.maxSeen, seen, n) {
+				break
+			}
+		}
+		time.Sleep(delay)
+
+		name := strings.TrimPrefix(r.URL.Path, delmetric_ep)
+		if strings.HasPrefix(name, "missing") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		d.mu.Lock()
+		d.deleted = append(d.deleted, name)
+		d.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	return d
+}
+
+func (d *deleteRecorder) Deleted() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.deleted...)
+}
+
+// Success test.
+func TestDeleteMetrics(t *testing.T) {
+	rec := newDeleteRecorder(10 * time.Millisecond)
+	defer rec.srv.Close()
+	cli := NewHttpClient(rec.srv.URL)
+
+	names := []string{"m0", "m1", "m2", "m3", "m4", "m5", "m6", "m7"}
+	var reported int32
+	results, err := DeleteMetrics(context.Background(), cli, names, 3, DeleteOptions{
+		OnResult: func(DeleteResult) { atomic.AddInt32(&reported, 1) },
+	})
+	assert.Nil(t, err, "No error expected")
+	assert.ElementsMatch(t, names, rec.Deleted(), "All metrics deleted expected")
+	assert.LessOrEqual(t, atomic.LoadInt32(&rec.maxSeen), int32(3), "At most 3 deletions at once expected")
+	assert.Equal(t, int32(len(names)), atomic.LoadInt32(&reported), "Every result reported expected")
+	for i, r := range results {
+		assert.Equal(t, names[i], r.Name, "Results in the order of the names expected")
+		assert.True(t, r.Deleted(), "Deleted metric expected")
+	}
+}
+
+// Failure test.
+func TestDeleteMetricsFailures(t *testing.T) {
+	rec := newDeleteRecorder(0)
+	defer rec.srv.Close()
+	cli := NewHttpClient(rec.srv.URL)
+
+	results, err := DeleteMetrics(context.Background(), cli, []string{"m0", "missing", "m1"}, 2, DeleteOptions{})
+	assert.True(t, errors.Is(err, ErrorDeleteMetrics), "Bulk deletion error expected")
+	assert.EqualError(t, err, "Some metrics could not be deleted: 1 of 3")
+	assert.True(t, results[0].Deleted(), "Deleted metric expected")
+	assert.False(t, results[1].Deleted(), "Metric not deleted expected")
+	assert.True(t, errors.Is(results[1].Err, ErrorMetricNotFound), "Metric not found expected")
+	assert.True(t, results[2].Deleted(), "Deleted metric expected")
+}
+
+// Success test.
+func TestDeleteMetricsInterval(t *testing.T) {
+	rec := newDeleteRecorder(0)
+	defer rec.srv.Close()
+	cli := NewHttpClient(rec.srv.URL)
+	clk := clock.NewFake(time.Now())
+
+	done := make(chan error)
+	go func() {
+		_, err := DeleteMetrics(context.Background(), cli, []string{"m0", "m1", "m2"}, 3, DeleteOptions{
+			Interval: time.Second,
+			Clock:    clk,
+		})
+		done <- err
+	}()
+
+	for i := 1; i <= 2; i++ {
+		clk.BlockUntil(1)
+		assert.Eventually(t, func() bool { return len(rec.Deleted()) == i }, time.Second, time.Millisecond,
+			"One deletion per interval expected")
+		clk.Advance(time.Second)
+	}
+	assert.Nil(t, <-done, "No error expected")
+	assert.Len(t, rec.Deleted(), 3, "All metrics deleted expected")
+}
+
+// Failure test.
+func TestDeleteMetricsCanceled(t *testing.T) {
+	rec := newDeleteRecorder(0)
+	defer rec.srv.Close()
+	cli := NewHttpClient(rec.srv.URL)
+	clk := clock.NewFake(time.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		clk.BlockUntil(1)
+		cancel()
+	}()
+
+	results, err := DeleteMetrics(ctx, cli, []string{"m0", "m1", "m2"}, 1, DeleteOptions{
+		Interval: time.Hour,
+		Clock:    clk,
+	})
+	assert.True(t, errors.Is(err, context.Canceled), "Context error expected")
+	assert.True(t, results[0].Deleted(), "First metric deleted expected")
+	assert.True(t, errors.Is(results[1].Err, context.Canceled), "Metric left out expected")
+	assert.True(t, errors.Is(results[2].Err, context.Canceled), "Metric left out expected")
+	assert.Equal(t, []string{"m0"}, rec.Deleted(), "Only the first metric deleted expected")
+}
@@ -0,0 +1,185 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Options of the CachingClient.
+type CacheOptions struct {
+	// How long a cached response is served as fresh.
+	TTL time.Duration
+
+	// How long after TTL a cached response is still served while it is
+	// refreshed in the background. Zero disables stale-while-revalidate.
+	StaleWhileRevalidate time.Duration
+
+	// Maximum number of cached queries. The oldest entry is evicted when
+	// the cache is full. Zero means no limit.
+	MaxEntries int
+
+	// Invoked when a background refresh fails. The stale response stays in
+	// the cache until it expires. May be nil.
+	OnRefreshError func(qb builder.QueryBuilder, err error)
+
+	// Tells the age of the cached responses. Defaults to the wall clock.
+	Clock clock.Clock
+}
+
+type cacheEntry struct {
+	resp       *response.QueryResponse
+	storedAt   time.Time
+	refreshing bool
+}
+
+// A Client that caches the responses of queries, keyed by the JSON of the
+// query. Only successful responses are cached. Cached responses are shared
+// between callers and must not be modified.
+type CachingClient struct {
+	Client
+	opts CacheOptions
+
+	mu      sync.Mutex // Guards entries.
+	entries map[string]*cacheEntry
+}
+
+func NewCachingClient(c Client, opts CacheOptions) *CachingClient {
+	opts.Clock = clock.OrReal(opts.Clock)
+
+	return &CachingClient{
+		Client:  c,
+		opts:    opts,
+		entries: make(map[string]*cacheEntry),
+	}
+}
+
+// Queries KairosDB using the query built using builder.
+func (cc *CachingClient) Query(qb builder.QueryBuilder) (*response.QueryResponse, error) {
+	return cc.QueryContext(context.Background(), qb)
+}
+
+// Same as Query, but a request sent to KairosDB is aborted when the context
+// is done. Background refreshes are not bound to the context.
+func (cc *CachingClient) QueryContext(ctx context.Context, qb builder.QueryBuilder) (*response.QueryResponse, error) {
+	data, err := qb.Build()
+	if err != nil {
+		return nil, err
+	}
+	key := cacheKey(data, qb)
+
+	cc.mu.Lock()
+	entry, ok := cc.entries[key]
+	if ok {
+		age := cc.opts.Clock.Now().Sub(entry.storedAt)
+		switch {
+		case age < cc.opts.TTL:
+			cc.mu.Unlock()
+			return entry.resp, nil
+		case age < cc.opts.TTL+cc.opts.StaleWhileRevalidate:
+			if !entry.refreshing {
+				entry.refreshing = true
+				go cc.refresh(key, qb)
+			}
+			cc.mu.Unlock()
+			return entry.resp, nil
+		}
+	}
+	cc.mu.Unlock()
+
+	resp, err := cc.Client.QueryContext(ctx, qb)
+	if err != nil {
+		return nil, err
+	}
+
+	cc.store(key, resp)
+	return resp, nil
+}
+
+// Drops all the cached responses.
+func (cc *CachingClient) Purge() {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.entries = make(map[string]*cacheEntry)
+}
+
+// Returns the cache key of a query. The aliases of the metrics are not part
+// of the query JSON but end up in the response, so they are appended.
+func cacheKey(data []byte, qb builder.QueryBuilder) string {
+	key := string(data)
+	for _, qm := range qb.Metrics() {
+		key += "\x00" + qm.Alias()
+	}
+	return key
+}
+
+func (cc *CachingClient) refresh(key string, qb builder.QueryBuilder) {
+	resp, err := cc.Client.QueryContext(context.Background(), qb)
+	if err == nil && resp.GetStatusCode() >= http.StatusMultipleChoices {
+		err = ErrorRefreshFailed
+	}
+
+	if err != nil {
+		cc.mu.Lock()
+		if entry, ok := cc.entries[key]; ok {
+			entry.refreshing = false
+		}
+		cc.mu.Unlock()
+
+		if cc.opts.OnRefreshError != nil {
+			cc.opts.OnRefreshError(qb, err)
+		}
+		return
+	}
+
+	cc.store(key, resp)
+}
+
+func (cc *CachingClient) store(key string, resp *response.QueryResponse) {
+	if resp.GetStatusCode() >= http.StatusMultipleChoices {
+		return
+	}
+
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	if _, ok := cc.entries[key]; !ok && cc.opts.MaxEntries > 0 && len(cc.entries) >= cc.opts.MaxEntries {
+		cc.evictOldest()
+	}
+
+	cc.entries[key] = &cacheEntry{
+		resp:     resp,
+		storedAt: cc.opts.Clock.Now(),
+	}
+}
+
+func (cc *CachingClient) evictOldest() {
+	var oldestKey string
+	var oldest time.Time
+	for k, e := range cc.entries {
+		if oldestKey == "" || e.storedAt.Before(oldest) {
+			oldestKey, oldest = k, e.storedAt
+		}
+	}
+
+	delete(cc.entries, oldestKey)
+}
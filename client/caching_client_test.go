@@ -0,0 +1,115 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Answers with the number of queries received so far as the sample size.
+func newCountingServer(hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(hits, 1)
+		fmt.Fprintf(w, `{"queries":[{"sample_size":%d,"results":[]}]}`, n)
+	}))
+}
+
+// Success test.
+func TestCachingClientFresh(t *testing.T) {
+	var hits int32
+	srv := newCountingServer(&hits)
+	defer srv.Close()
+
+	cc := NewCachingClient(NewHttpClient(srv.URL), CacheOptions{TTL: time.Hour})
+	r1, err := cc.Query(hedgeQuery())
+	assert.Nil(t, err, "No error expected")
+	r2, err := cc.Query(hedgeQuery())
+	assert.Nil(t, err, "No error expected")
+
+	assert.Same(t, r1, r2, "Cached response expected")
+	assert.EqualValues(t, 1, atomic.LoadInt32(&hits), "Server must be queried once")
+
+	cc.Purge()
+	cc.Query(hedgeQuery())
+	assert.EqualValues(t, 2, atomic.LoadInt32(&hits), "Purge must drop the cache")
+}
+
+// Success test.
+func TestCachingClientStaleWhileRevalidate(t *testing.T) {
+	var hits int32
+	srv := newCountingServer(&hits)
+	defer srv.Close()
+
+	cc := NewCachingClient(NewHttpClient(srv.URL), CacheOptions{TTL: 10 * time.Millisecond, StaleWhileRevalidate: time.Hour})
+	cc.Query(hedgeQuery())
+	time.Sleep(20 * time.Millisecond)
+
+	stale, err := cc.Query(hedgeQuery())
+	assert.Nil(t, err, "No error expected")
+	assert.EqualValues(t, 1, stale.QueriesArr[0].SampleSize, "Stale response must be served immediately")
+
+	assert.Eventually(t, func() bool {
+		r, _ := cc.Query(hedgeQuery())
+		return r.QueriesArr[0].SampleSize == 2
+	}, time.Second, 5*time.Millisecond, "Refreshed response must replace the stale one")
+}
+
+// Success test.
+func TestCachingClientExpired(t *testing.T) {
+	var hits int32
+	srv := newCountingServer(&hits)
+	defer srv.Close()
+
+	clk := clock.NewFake(time.Now())
+	cc := NewCachingClient(NewHttpClient(srv.URL), CacheOptions{TTL: time.Minute, Clock: clk})
+	cc.Query(hedgeQuery())
+	clk.Advance(59 * time.Second)
+	r, _ := cc.Query(hedgeQuery())
+	assert.EqualValues(t, 1, r.QueriesArr[0].SampleSize, "Fresh response must be cached")
+
+	clk.Advance(time.Second)
+	r, _ = cc.Query(hedgeQuery())
+	assert.EqualValues(t, 2, r.QueriesArr[0].SampleSize, "Expired response must be fetched synchronously")
+}
+
+// Success test.
+func TestCachingClientAliases(t *testing.T) {
+	var hits int32
+	srv := newCountingServer(&hits)
+	defer srv.Close()
+
+	cc := NewCachingClient(NewHttpClient(srv.URL), CacheOptions{TTL: time.Hour})
+
+	qb := hedgeQuery()
+	qb.Metrics()[0].SetAlias("a")
+	r1, err := cc.Query(qb)
+	assert.Nil(t, err, "No error expected")
+
+	qb = hedgeQuery()
+	qb.Metrics()[0].SetAlias("b")
+	r2, err := cc.Query(qb)
+	assert.Nil(t, err, "No error expected")
+
+	assert.NotSame(t, r1, r2, "Queries with different aliases must not share a response")
+	assert.EqualValues(t, 2, atomic.LoadInt32(&hits), "Server must be queried twice")
+}
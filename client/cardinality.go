@@ -0,0 +1,128 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+)
+
+// Cardinality of a single metric over the report window.
+type MetricCardinality struct {
+	Name string
+
+	// Number of distinct tag combinations that received data.
+	Series int
+
+	// Number of distinct values of every tag.
+	TagValues map[string]int
+}
+
+// Cardinality of a set of metrics, sorted by descending number of series.
+type CardinalityReport struct {
+	Start   time.Time
+	End     time.Time
+	Metrics []MetricCardinality
+}
+
+// Counts the distinct tag combinations of every metric over the trailing
+// window, to find the metrics blowing up the cluster. The tag names and
+// values are retrieved with a tags query, then the series are counted with a
+// query grouped by all the tags that aggregates every series into a single
+// data point. The cost of the second query grows with the number of series,
+// so the window should be kept short on large clusters.
+func EstimateCardinality(ctx context.Context, c MetricReader, metrics []string, window time.Duration) (*CardinalityReport, error) {
+	report := &CardinalityReport{End: clockOf(c).Now()}
+	report.Start = report.End.Add(-window)
+
+	tqb := builder.NewQueryBuilder()
+	tqb.SetAbsoluteStart(report.Start).SetAbsoluteEnd(report.End)
+	for _, m := range metrics {
+		tqb.AddMetric(m)
+	}
+
+	tags, err := c.QueryTags(tqb)
+	if err != nil {
+		return nil, err
+	}
+	if tags.GetStatusCode() >= http.StatusMultipleChoices {
+		return nil, ErrorCardinalityQuery
+	}
+
+	// Aggregate every series over the whole window.
+	seconds := int(window / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	sqb := builder.NewQueryBuilder()
+	sqb.SetAbsoluteStart(report.Start).SetAbsoluteEnd(report.End)
+	for i, m := range metrics {
+		mc := MetricCardinality{Name: m, TagValues: make(map[string]int)}
+		if i < len(tags.QueriesArr) {
+			for _, r := range tags.QueriesArr[i].ResultsArr {
+				for name, vals := range r.Tags {
+					mc.TagValues[name] = len(vals)
+				}
+			}
+		}
+		report.Metrics = append(report.Metrics, mc)
+
+		qm := sqb.AddMetric(m).AddAggregator(builder.CreateCountAggregator(seconds, utils.SECONDS))
+		if names := sortedKeys(mc.TagValues); len(names) > 0 {
+			qm.AddGrouper(builder.CreateTagsGroupBy(names))
+		}
+	}
+
+	series, err := c.QueryContext(ctx, sqb)
+	if err != nil {
+		return nil, err
+	}
+	if series.GetStatusCode() >= http.StatusMultipleChoices {
+		return nil, ErrorCardinalityQuery
+	}
+
+	for i := range report.Metrics {
+		if i >= len(series.QueriesArr) {
+			break
+		}
+		for _, r := range series.QueriesArr[i].ResultsArr {
+			// Metrics without data come back as a single empty result.
+			if len(r.DataPoints) > 0 {
+				report.Metrics[i].Series++
+			}
+		}
+	}
+
+	sort.SliceStable(report.Metrics, func(i, j int) bool {
+		return report.Metrics[i].Series > report.Metrics[j].Series
+	})
+
+	return report, nil
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,67 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestEstimateCardinality(t *testing.T) {
+	var seriesQuery string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		switch r.URL.Path {
+		case querytags_ep:
+			fmt.Fprint(w, `{"queries":[`+
+				`{"results":[{"name":"small","tags":{"host":["a"]}}]},`+
+				`{"results":[{"name":"big","tags":{"host":["a","b"],"pod":["p1","p2","p3"]}}]}]}`)
+		case query_ep:
+			seriesQuery = string(body)
+			fmt.Fprint(w, `{"queries":[`+
+				`{"results":[{"name":"small","values":[[1,5]]}]},`+
+				`{"results":[{"name":"big","values":[[1,1]]},{"name":"big","values":[[1,2]]},{"name":"big","values":[[1,3]]}]}]}`)
+		}
+	}))
+	defer srv.Close()
+
+	report, err := EstimateCardinality(context.Background(), NewHttpClient(srv.URL), []string{"small", "big"}, time.Hour)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, time.Hour, report.End.Sub(report.Start), "Report must cover the window")
+	assert.Equal(t, []MetricCardinality{
+		{Name: "big", Series: 3, TagValues: map[string]int{"host": 2, "pod": 3}},
+		{Name: "small", Series: 1, TagValues: map[string]int{"host": 1}},
+	}, report.Metrics, "Metrics must be sorted by series")
+	assert.True(t, strings.Contains(seriesQuery, `"tags":["host","pod"]`), "Series must be grouped by all tags")
+}
+
+// Failure test.
+func TestEstimateCardinalityError(t *testing.T) {
+	srv := newNamesServer(http.StatusBadRequest, `{"errors":["bad query"]}`, 0)
+	defer srv.Close()
+
+	report, err := EstimateCardinality(context.Background(), NewHttpClient(srv.URL), []string{"m1"}, time.Hour)
+	assert.Nil(t, report, "No report expected")
+	assert.Equal(t, ErrorCardinalityQuery, err, "Query error expected")
+}
@@ -0,0 +1,223 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/retoool/go-kairosdb/clock"
+)
+
+// Options of the CertReloader.
+type CertReloaderOptions struct {
+	// PEM encoded client certificate and key. Both empty means no client
+	// certificate is presented.
+	CertFile string
+	KeyFile  string
+
+	// PEM encoded CA certificates used to verify the server. Empty means the
+	// system roots are used.
+	CAFile string
+
+	// How often the files are checked for changes. Defaults to one minute.
+	Interval time.Duration
+
+	// Invoked when reloading the files fails. The previously loaded
+	// certificates stay in use. May be nil.
+	OnError func(error)
+
+	// Paces the checks. Defaults to the wall clock.
+	Clock clock.Clock
+}
+
+// Keeps the client certificate and the CA pool in sync with files on disk,
+// so that long lived clients pick up rotated certificates without restart.
+type CertReloader struct {
+	opts CertReloaderOptions
+
+	mu      sync.RWMutex // Guards the fields below.
+	cert    *tls.Certificate
+	roots   *x509.CertPool
+	modTime time.Time
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// Loads the certificates and starts watching the files for changes. Close
+// stops the watch.
+func NewCertReloader(opts CertReloaderOptions) (*CertReloader, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = time.Minute
+	}
+	opts.Clock = clock.OrReal(opts.Clock)
+
+	cr := &CertReloader{
+		opts: opts,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+
+	if err := cr.Reload(); err != nil {
+		return nil, err
+	}
+
+	go cr.watch()
+	return cr, nil
+}
+
+// Loads the files again, regardless of whether they changed.
+func (cr *CertReloader) Reload() error {
+	var cert *tls.Certificate
+	if cr.opts.CertFile != "" || cr.opts.KeyFile != "" {
+		c, err := tls.LoadX509KeyPair(cr.opts.CertFile, cr.opts.KeyFile)
+		if err != nil {
+			return err
+		}
+		cert = &c
+	}
+
+	var roots *x509.CertPool
+	if cr.opts.CAFile != "" {
+		pem, err := ioutil.ReadFile(cr.opts.CAFile)
+		if err != nil {
+			return err
+		}
+
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return ErrorNoCACertificates
+		}
+	}
+
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+	cr.cert = cert
+	cr.roots = roots
+	cr.modTime = cr.latestModTime()
+
+	return nil
+}
+
+// Returns a TLS configuration that always uses the most recently loaded
+// certificates. It can be passed to WithTLSConfig.
+func (cr *CertReloader) TLSConfig() *tls.Config {
+	cfg := &tls.Config{
+		GetClientCertificate: cr.GetClientCertificate,
+	}
+
+	if cr.opts.CAFile != "" {
+		// The standard verification is replaced by VerifyConnection, which
+		// checks the chain against the current pool instead of a pool fixed
+		// at configuration time.
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = cr.VerifyConnection
+	}
+
+	return cfg
+}
+
+// Returns the current client certificate. It is meant to be used as
+// tls.Config.GetClientCertificate.
+func (cr *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+
+	if cr.cert == nil {
+		// An empty certificate tells the server we have none.
+		return &tls.Certificate{}, nil
+	}
+	return cr.cert, nil
+}
+
+// Verifies the server certificate chain and host name against the current
+// CA pool. It is meant to be used as tls.Config.VerifyConnection.
+func (cr *CertReloader) VerifyConnection(cs tls.ConnectionState) error {
+	if len(cs.PeerCertificates) == 0 {
+		return ErrorNoPeerCertificates
+	}
+
+	cr.mu.RLock()
+	roots := cr.roots
+	cr.mu.RUnlock()
+
+	opts := x509.VerifyOptions{
+		DNSName:       cs.ServerName,
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, c := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// Stops watching the files.
+func (cr *CertReloader) Close() {
+	cr.once.Do(func() {
+		close(cr.stop)
+		<-cr.done
+	})
+}
+
+func (cr *CertReloader) watch() {
+	defer close(cr.done)
+
+	for {
+		timer := cr.opts.Clock.NewTimer(cr.opts.Interval)
+		select {
+		case <-cr.stop:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		cr.mu.RLock()
+		changed := cr.latestModTime().After(cr.modTime)
+		cr.mu.RUnlock()
+
+		if !changed {
+			continue
+		}
+
+		if err := cr.Reload(); err != nil && cr.opts.OnError != nil {
+			cr.opts.OnError(err)
+		}
+	}
+}
+
+// Returns the most recent modification time of the watched files.
+func (cr *CertReloader) latestModTime() time.Time {
+	var latest time.Time
+	for _, f := range []string{cr.opts.CertFile, cr.opts.KeyFile, cr.opts.CAFile} {
+		if f == "" {
+			continue
+		}
+
+		if fi, err := os.Stat(f); err == nil && fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+
+	return latest
+}
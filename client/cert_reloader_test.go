@@ -0,0 +1,124 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeServerCA(t *testing.T, path string, srv *httptest.Server) {
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	assert.Nil(t, ioutil.WriteFile(path, data, 0600), "No error expected")
+}
+
+func writeClientCert(t *testing.T, certPath, keyPath, cn string) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.Nil(t, err, "No error expected")
+	keyDer, _ := x509.MarshalECPrivateKey(key)
+
+	ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+}
+
+// Success test.
+func TestCertReloaderCA(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	srv1 := httptest.NewTLSServer(handler)
+	defer srv1.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeServerCA(t, caFile, srv1)
+
+	cr, err := NewCertReloader(CertReloaderOptions{CAFile: caFile, Interval: time.Hour})
+	assert.Nil(t, err, "No error expected")
+	defer cr.Close()
+
+	cli := NewHttpClientWithOptions(srv1.URL, WithTLSConfig(cr.TLSConfig()))
+	_, err = cli.HealthCheck()
+	assert.Nil(t, err, "Server must be trusted")
+
+	// Point the CA file at a certificate that does not match the server.
+	otherCA := filepath.Join(t.TempDir(), "other.pem")
+	writeClientCert(t, otherCA, filepath.Join(t.TempDir(), "key.pem"), "other")
+	data, _ := ioutil.ReadFile(otherCA)
+	ioutil.WriteFile(caFile, data, 0600)
+	assert.Nil(t, cr.Reload(), "No error expected")
+
+	// A new client forces a new handshake.
+	cli = NewHttpClientWithOptions(srv1.URL, WithTLSConfig(cr.TLSConfig()))
+	_, err = cli.HealthCheck()
+	assert.NotNil(t, err, "Server must no longer be trusted")
+}
+
+// Success test.
+func TestCertReloaderClientCert(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writeClientCert(t, certFile, keyFile, "first")
+
+	var reloadErr error
+	cr, err := NewCertReloader(CertReloaderOptions{
+		CertFile: certFile,
+		KeyFile:  keyFile,
+		Interval: 10 * time.Millisecond,
+		OnError:  func(err error) { reloadErr = err },
+	})
+	assert.Nil(t, err, "No error expected")
+	defer cr.Close()
+
+	cert, _ := cr.GetClientCertificate(nil)
+	first, _ := x509.ParseCertificate(cert.Certificate[0])
+	assert.Equal(t, "first", first.Subject.CommonName, "Initial certificate expected")
+
+	writeClientCert(t, certFile, keyFile, "second")
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, future, future)
+	os.Chtimes(keyFile, future, future)
+
+	assert.Eventually(t, func() bool {
+		cert, _ := cr.GetClientCertificate(nil)
+		c, _ := x509.ParseCertificate(cert.Certificate[0])
+		return c.Subject.CommonName == "second"
+	}, time.Second, 10*time.Millisecond, "Rotated certificate must be picked up")
+	assert.Nil(t, reloadErr, "No reload error expected")
+}
+
+// Failure test.
+func TestCertReloaderMissingFiles(t *testing.T) {
+	_, err := NewCertReloader(CertReloaderOptions{CAFile: filepath.Join(t.TempDir(), "missing.pem")})
+	assert.NotNil(t, err, "Missing CA file must be reported")
+}
@@ -0,0 +1,149 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Returned along with the partial results of a chunked query that was cut
+// short by its context. Missing lists the time ranges that were not
+// retrieved.
+type ErrPartialResult struct {
+	Missing []builder.TimeRange
+	Cause   error
+}
+
+func (e *ErrPartialResult) Error() string {
+	return fmt.Sprintf("partial query result, %d time ranges missing: %v", len(e.Missing), e.Cause)
+}
+
+func (e *ErrPartialResult) Unwrap() error {
+	return e.Cause
+}
+
+// Options of a chunked query.
+type ChunkOptions struct {
+	// Size of the time slices the query is split into.
+	Size time.Duration
+
+	// Number of slices queried in parallel. Defaults to 1.
+	Concurrency int
+
+	// Resolves the relative times of the query. Defaults to the wall clock.
+	Clock clock.Clock
+}
+
+// Splits the query into time slices, runs them against the client and
+// merges the results as if a single query had been run. Queries whose
+// results would change if split, see builder.SplitAlignment, are rejected,
+// as are slices that are not a multiple of the sampling of the aggregators.
+//
+// When the context is done before all the slices are retrieved, the results
+// of the completed slices are returned along with an *ErrPartialResult. A
+// slice answered with an error status aborts the query and its response is
+// returned as is.
+func QueryChunked(ctx context.Context, c MetricReader, qb builder.QueryBuilder, opts ChunkOptions) (*response.QueryResponse, error) {
+	align, err := builder.SplitAlignment(qb)
+	if err != nil {
+		return nil, err
+	}
+	if align > 0 && opts.Size%align != 0 {
+		return nil, fmt.Errorf("%w: slices of %s are not a multiple of the %s sampling", builder.ErrorQueryNotSplittable,
+			opts.Size, align)
+	}
+
+	chunks, err := builder.SplitQuery(qb, opts.Size, clock.OrReal(opts.Clock).Now())
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	resps := make([]*response.QueryResponse, len(chunks))
+	errs := make([]error, len(chunks))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+	for i := range chunks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			resps[i], errs[i] = c.QueryContext(ctx, chunks[i].Query)
+			if errs[i] == nil && resps[i].GetStatusCode() >= http.StatusMultipleChoices {
+				// No point in running the other slices.
+				cancel()
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	merged := response.NewQueryResponse(http.StatusOK)
+	var missing []builder.TimeRange
+	for i, chunk := range chunks {
+		if resps[i] != nil && errs[i] == nil && resps[i].GetStatusCode() >= http.StatusMultipleChoices {
+			return resps[i], nil
+		}
+
+		if resps[i] == nil || errs[i] != nil {
+			if errs[i] != nil && ctx.Err() == nil {
+				return nil, errs[i]
+			}
+			missing = append(missing, chunk.Range)
+			continue
+		}
+
+		merged.Merge(resps[i])
+	}
+
+	if len(missing) > 0 {
+		return merged, &ErrPartialResult{Missing: missing, Cause: ctx.Err()}
+	}
+
+	return merged, nil
+}
+
+func containsString(vals []string, s string) bool {
+	for _, v := range vals {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
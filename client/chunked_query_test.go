@@ -0,0 +1,109 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// Answers every query with a single data point at its start time. Queries
+// starting at or after slowFrom block until the client gives up.
+func newChunkServer(slowFrom int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var q struct {
+			Start int64 `json:"start_absolute"`
+		}
+		json.NewDecoder(r.Body).Decode(&q)
+
+		if slowFrom > 0 && q.Start >= slowFrom {
+			<-r.Context().Done()
+			return
+		}
+
+		fmt.Fprintf(w, `{"queries":[{"sample_size":1,"results":[{"name":"m1","group_by":[{"name":"type","type":"number"}],`+
+			`"tags":{"host":["h%d"]},"values":[[%d,1]]}]}]}`, q.Start, q.Start)
+	}))
+}
+
+func chunkQuery() builder.QueryBuilder {
+	qb := builder.NewQueryBuilder()
+	qb.SetAbsoluteStart(time.Unix(0, 1000*int64(time.Millisecond))).
+		SetAbsoluteEnd(time.Unix(0, 3999*int64(time.Millisecond))).
+		AddMetric("m1")
+	return qb
+}
+
+// Success test.
+func TestQueryChunked(t *testing.T) {
+	srv := newChunkServer(0)
+	defer srv.Close()
+
+	qr, err := QueryChunked(context.Background(), NewHttpClient(srv.URL), chunkQuery(),
+		ChunkOptions{Size: time.Second, Concurrency: 2})
+	assert.Nil(t, err, "No error expected")
+	assert.EqualValues(t, 3, qr.QueriesArr[0].SampleSize, "Sample sizes must be added up")
+	assert.Len(t, qr.QueriesArr[0].ResultsArr, 1, "Chunk results must be merged")
+
+	r := qr.QueriesArr[0].ResultsArr[0]
+	assert.Len(t, r.DataPoints, 3, "Data points of all chunks expected")
+	for i, dp := range r.DataPoints {
+		assert.Equal(t, int64(1000*(i+1)), dp.Timestamp(), "Data points must be in chunk order")
+	}
+	assert.Equal(t, []string{"h1000", "h2000", "h3000"}, r.Tags["host"], "Tags must be merged")
+}
+
+// Failure test.
+func TestQueryChunkedPartial(t *testing.T) {
+	srv := newChunkServer(3000)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	qr, err := QueryChunked(ctx, NewHttpClient(srv.URL), chunkQuery(), ChunkOptions{Size: time.Second})
+
+	var partial *ErrPartialResult
+	assert.True(t, errors.As(err, &partial), "Partial result error expected")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "Deadline must be the cause")
+	assert.Len(t, partial.Missing, 1, "One range must be missing")
+	assert.Equal(t, int64(3000), partial.Missing[0].Start.UnixNano()/int64(time.Millisecond), "Last chunk must be missing")
+	assert.Len(t, qr.QueriesArr[0].ResultsArr[0].DataPoints, 2, "Completed chunks must be returned")
+}
+
+// Failure test.
+func TestQueryChunkedNotSplittable(t *testing.T) {
+	cli := NewHttpClient("http://localhost:1")
+
+	qb := chunkQuery()
+	qb.Metrics()[0].SetLimit(10)
+	_, err := QueryChunked(context.Background(), cli, qb, ChunkOptions{Size: time.Second})
+	assert.ErrorIs(t, err, builder.ErrorQueryNotSplittable, "Limit must be rejected")
+
+	qb = chunkQuery()
+	qb.Metrics()[0].AddAggregator(builder.CreateAverageAggregator(2, utils.SECONDS))
+	_, err = QueryChunked(context.Background(), cli, qb, ChunkOptions{Size: time.Second})
+	assert.ErrorIs(t, err, builder.ErrorQueryNotSplittable, "Slices shorter than the sampling must be rejected")
+}
@@ -15,34 +15,147 @@
 package client
 
 import (
+	"context"
+
 	"github.com/retoool/go-kairosdb/builder"
 	"github.com/retoool/go-kairosdb/response"
 )
 
-type Client interface {
+// The read operations of the KairosDB API.
+type MetricReader interface {
 	// Returns a list of all metrics names.
 	GetMetricNames() (*response.GetResponse, error)
 
+	// Same as GetMetricNames, but the request is aborted when the context
+	// is done.
+	GetMetricNamesContext(ctx context.Context) (*response.GetResponse, error)
+
+	// Returns a list of the metric names starting with the prefix.
+	GetMetricNamesWithPrefix(prefix string) (*response.GetResponse, error)
+
+	// Same as GetMetricNamesWithPrefix, but the request is aborted when the
+	// context is done.
+	GetMetricNamesWithPrefixContext(ctx context.Context, prefix string) (*response.GetResponse, error)
+
 	// Returns a list of all tag names.
 	GetTagNames() (*response.GetResponse, error)
 
+	// Same as GetTagNames, but the request is aborted when the context is
+	// done.
+	GetTagNamesContext(ctx context.Context) (*response.GetResponse, error)
+
 	// Returns a list of all tag values.
 	GetTagValues() (*response.GetResponse, error)
 
+	// Same as GetTagValues, but the request is aborted when the context is
+	// done.
+	GetTagValuesContext(ctx context.Context) (*response.GetResponse, error)
+
 	// Queries KairosDB using the query built using builder.
 	Query(qb builder.QueryBuilder) (*response.QueryResponse, error)
 
+	// Same as Query, but the request is aborted when the context is done.
+	QueryContext(ctx context.Context, qb builder.QueryBuilder) (*response.QueryResponse, error)
+
 	QueryTags(qb builder.QueryBuilder) (*response.QueryResponse, error)
 
+	// Same as QueryTags, but the request is aborted when the context is
+	// done.
+	QueryTagsContext(ctx context.Context, qb builder.QueryBuilder) (*response.QueryResponse, error)
+}
+
+// The write operations of the KairosDB API.
+type MetricWriter interface {
 	// Sends metrics from the builder to the KairosDB server.
 	PushMetrics(mb builder.MetricBuilder) (*response.Response, error)
 
+	// Same as PushMetrics, but the request is aborted when the context is
+	// done.
+	PushMetricsContext(ctx context.Context, mb builder.MetricBuilder) (*response.Response, error)
+}
+
+// Deletion, health checking and connection management.
+type Admin interface {
 	// Deletes a metric. This is the metric and all its datapoints.
 	DeleteMetric(name string) (*response.Response, error)
 
+	// Same as DeleteMetric, but the request is aborted when the context is
+	// done.
+	DeleteMetricContext(ctx context.Context, name string) (*response.Response, error)
+
 	// Deletes data in KairosDB using the query built by the builder.
 	Delete(builder builder.QueryBuilder) (*response.Response, error)
 
+	// Same as Delete, but the request is aborted when the context is done.
+	DeleteContext(ctx context.Context, builder builder.QueryBuilder) (*response.Response, error)
+
 	// Checks the health of the KairosDB Server.
-	HealthCheck() (*response.Response, error)
+	HealthCheck() (*response.HealthResponse, error)
+
+	// Same as HealthCheck, but the request is aborted when the context is
+	// done.
+	HealthCheckContext(ctx context.Context) (*response.HealthResponse, error)
+
+	// Returns the version of the KairosDB server.
+	GetVersion() (*response.VersionResponse, error)
+
+	// Same as GetVersion, but the request is aborted when the context is
+	// done.
+	GetVersionContext(ctx context.Context) (*response.VersionResponse, error)
+
+	// Changes the address of the KairosDB server used by subsequent requests.
+	// Safe to call while other requests are in flight.
+	SetServerAddress(serverAddress string)
+
+	// Replaces the list of KairosDB servers used by subsequent requests,
+	// which are spread over them in a round robin fashion. An empty list is
+	// ignored. Safe to call while other requests are in flight.
+	SetServerAddresses(serverAddresses []string)
+
+	// Returns the list of KairosDB servers currently in use.
+	ServerAddresses() []string
+
+	// Returns a snapshot of the request, query and push counters of the
+	// client.
+	Stats() Stats
+
+	// Changes the basic authentication credentials used by subsequent
+	// requests. An empty username disables authentication. Safe to call
+	// while other requests are in flight.
+	SetCredentials(username, password string)
+}
+
+// Management of the roll-up tasks run by KairosDB.
+type RollupManager interface {
+	// Creates a roll-up task. The response holds the created task with its
+	// ID.
+	CreateRollup(rb builder.RollupBuilder) (*response.RollupResponse, error)
+
+	// Returns all the roll-up tasks.
+	GetRollups() (*response.RollupResponse, error)
+
+	// Returns the roll-up task with the given ID.
+	GetRollup(id string) (*response.RollupResponse, error)
+
+	// Replaces the definition of the roll-up task with the given ID.
+	UpdateRollup(id string, rb builder.RollupBuilder) (*response.RollupResponse, error)
+
+	// Deletes the roll-up task with the given ID.
+	DeleteRollup(id string) (*response.Response, error)
+
+	// Returns the execution status of the roll-up task with the given ID.
+	GetRollupStatus(id string) (*response.RollupStatusResponse, error)
+
+	// Asks the server to run the roll-up task with the given ID right away,
+	// where the server allows it.
+	TriggerRollup(id string) (*response.Response, error)
+}
+
+// The complete KairosDB API. Code that only reads, writes or administers
+// should depend on MetricReader, MetricWriter or Admin instead.
+type Client interface {
+	MetricReader
+	MetricWriter
+	Admin
+	RollupManager
 }
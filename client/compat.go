@@ -0,0 +1,214 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/retoool/go-kairosdb/builder"
+)
+
+// Describes the parts of the KairosDB API supported by a range of server
+// versions. Requests using a feature the profile lacks fail with an error
+// wrapping ErrorNotSupported instead of being silently misinterpreted by the
+// server. Custom profiles can be declared for servers that differ from the
+// predefined ones.
+type Profile struct {
+	Name string
+
+	// Whether pushed metrics may carry a TTL.
+	MetricTTL bool
+
+	// Whether the server has the /health/check endpoint. Without it
+	// HealthCheck queries the version endpoint instead.
+	HealthCheck bool
+
+	// Whether range aggregators accept the align_end_time option.
+	AlignEndTime bool
+
+	// Names of the aggregators the server knows. Nil allows all of them.
+	Aggregators []string
+
+	// Names of the group_by types the server knows. Nil allows all of them.
+	GroupBys []string
+}
+
+var (
+	aggregators09 = []string{"avg", "count", "dev", "diff", "div", "least_squares", "max", "min",
+		"percentile", "rate", "sampler", "scale", "sum"}
+	aggregators11 = append(aggregators09[:len(aggregators09):len(aggregators09)],
+		"first", "gaps", "last", "save_as", "trim")
+	aggregators12 = append(aggregators11[:len(aggregators11):len(aggregators11)], "filter")
+
+	groupBys09 = []string{"tag", "time", "value"}
+	groupBys12 = append(groupBys09[:len(groupBys09):len(groupBys09)], "bin")
+
+	// KairosDB 0.9.x.
+	Profile09 = Profile{
+		Name:        "0.9.x",
+		Aggregators: aggregators09,
+		GroupBys:    groupBys09,
+	}
+
+	// KairosDB 1.1.x.
+	Profile11 = Profile{
+		Name:        "1.1.x",
+		MetricTTL:   true,
+		HealthCheck: true,
+		Aggregators: aggregators11,
+		GroupBys:    groupBys09,
+	}
+
+	// KairosDB 1.2.x.
+	Profile12 = Profile{
+		Name:         "1.2+",
+		MetricTTL:    true,
+		HealthCheck:  true,
+		AlignEndTime: true,
+		Aggregators:  aggregators12,
+		GroupBys:     groupBys12,
+	}
+
+	// KairosDB 1.3 and later, which also accept aggregators from plugins.
+	Profile13 = Profile{
+		Name:         "1.3+",
+		MetricTTL:    true,
+		HealthCheck:  true,
+		AlignEndTime: true,
+	}
+)
+
+// Checks the requests against the profile of the KairosDB version the
+// client talks to. Without this option requests are sent as built.
+func WithCompatibility(p Profile) Option {
+	return func(hc *httpClient) {
+		hc.profile = &p
+	}
+}
+
+// Matches the major and minor version in the version string of KairosDB,
+// e.g. "KairosDB 1.2.2-1.20180716".
+var versionRegexp = regexp.MustCompile(`(\d+)\.(\d+)`)
+
+// Returns the profile matching a KairosDB version string, as returned by
+// GetVersion.
+func ProfileForVersion(version string) (Profile, error) {
+	m := versionRegexp.FindStringSubmatch(version)
+	if m == nil {
+		return Profile{}, fmt.Errorf("%w: %q", ErrorVersionUnknown, version)
+	}
+
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	switch {
+	case major < 1 || major == 1 && minor < 1:
+		return Profile09, nil
+	case major == 1 && minor == 1:
+		return Profile11, nil
+	case major == 1 && minor == 2:
+		return Profile12, nil
+	}
+	return Profile13, nil
+}
+
+// Asks the server for its version and returns the matching profile, e.g. to
+// pass to WithCompatibility or to check queries with
+// QueryBuilder.BuildFor(profile.Capabilities()).
+func DetectProfile(c Admin) (Profile, error) {
+	vr, err := c.GetVersion()
+	if err != nil {
+		return Profile{}, err
+	}
+
+	if vr.GetStatusCode() >= http.StatusMultipleChoices {
+		return Profile{}, fmt.Errorf("%w: status %d", ErrorVersionUnknown, vr.GetStatusCode())
+	}
+
+	return ProfileForVersion(vr.GetVersion())
+}
+
+// Returns the query features of the profile, for QueryBuilder.BuildFor.
+func (p Profile) Capabilities() builder.Capabilities {
+	return builder.Capabilities{
+		Version:      p.Name,
+		Aggregators:  p.Aggregators,
+		GroupBys:     p.GroupBys,
+		AlignEndTime: p.AlignEndTime,
+	}
+}
+
+func (p *Profile) notSupported(feature string) error {
+	return fmt.Errorf("%w: %s with KairosDB %s", ErrorNotSupported, feature, p.Name)
+}
+
+// Checks an encoded query against the profile.
+func (p *Profile) checkQuery(data []byte) error {
+	var q struct {
+		Metrics []struct {
+			Aggregators []map[string]json.RawMessage `json:"aggregators"`
+			GroupBy     []struct {
+				Name string `json:"name"`
+			} `json:"group_by"`
+		} `json:"metrics"`
+	}
+	if err := json.Unmarshal(data, &q); err != nil {
+		return err
+	}
+
+	for _, m := range q.Metrics {
+		for _, aggr := range m.Aggregators {
+			var name string
+			json.Unmarshal(aggr["name"], &name)
+
+			if p.Aggregators != nil && !containsString(p.Aggregators, name) {
+				return p.notSupported(fmt.Sprintf("aggregator %q", name))
+			}
+
+			if _, ok := aggr["align_end_time"]; ok && !p.AlignEndTime {
+				return p.notSupported("align_end_time")
+			}
+		}
+
+		for _, gp := range m.GroupBy {
+			if p.GroupBys != nil && !containsString(p.GroupBys, gp.Name) {
+				return p.notSupported(fmt.Sprintf("group_by %q", gp.Name))
+			}
+		}
+	}
+
+	return nil
+}
+
+// Checks encoded metrics against the profile.
+func (p *Profile) checkMetrics(data []byte) error {
+	var metrics []struct {
+		TTL int64 `json:"ttl"`
+	}
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return err
+	}
+
+	for _, m := range metrics {
+		if m.TTL != 0 && !p.MetricTTL {
+			return p.notSupported("metric TTL")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestCompatibilityHealthCheck(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.Write([]byte(`{"version":"KairosDB 0.9.4"}`))
+	}))
+	defer srv.Close()
+
+	resp, err := NewHttpClientWithOptions(srv.URL, WithCompatibility(Profile09)).HealthCheck()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusOK, resp.GetStatusCode(), "Version status expected")
+
+	NewHttpClientWithOptions(srv.URL, WithCompatibility(Profile13)).HealthCheck()
+	assert.Equal(t, []string{version_ep, health_ep}, paths, "Health endpoint depends on the profile")
+}
+
+// Failure test.
+func TestCompatibilityNotSupported(t *testing.T) {
+	srv := newNamesServer(http.StatusOK, `{"queries":[]}`, 0)
+	defer srv.Close()
+
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("m1").AddAggregator(builder.CreateFirstAggregator(1, utils.MINUTES))
+
+	_, err := NewHttpClientWithOptions(srv.URL, WithCompatibility(Profile09)).Query(qb)
+	assert.True(t, errors.Is(err, ErrorNotSupported), "Unknown aggregator must be rejected")
+	assert.Contains(t, err.Error(), `"first"`, "Aggregator must be named")
+
+	_, err = NewHttpClientWithOptions(srv.URL, WithCompatibility(Profile11)).Query(qb)
+	assert.Nil(t, err, "Aggregator supported by 1.1")
+
+	aligned := builder.NewQueryBuilder()
+	aligned.SetRelativeStart(1, utils.HOURS).AddMetric("m1").AddAggregator(builder.WithExtensions(
+		builder.CreateSumAggregator(1, utils.MINUTES), map[string]json.RawMessage{"align_end_time": json.RawMessage(`true`)}))
+	_, err = NewHttpClientWithOptions(srv.URL, WithCompatibility(Profile11)).Query(aligned)
+	assert.True(t, errors.Is(err, ErrorNotSupported), "align_end_time must be rejected by 1.1")
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTTL(60).AddDataPoint(1, 1)
+	_, err = NewHttpClientWithOptions(srv.URL, WithCompatibility(Profile09)).PushMetrics(mb)
+	assert.True(t, errors.Is(err, ErrorNotSupported), "TTL must be rejected by 0.9")
+}
+
+// Success test.
+func TestDetectProfile(t *testing.T) {
+	srv := newNamesServer(http.StatusOK, `{"version":"KairosDB 1.1.3-1.20170102"}`, 0)
+	defer srv.Close()
+
+	p, err := DetectProfile(NewHttpClient(srv.URL))
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, Profile11.Name, p.Name, "Profile of the version expected")
+
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("m1").AddAggregator(builder.CreateFilterAggregator(builder.FilterOp_LT, 1))
+
+	_, err = qb.BuildFor(p.Capabilities())
+	assert.True(t, errors.Is(err, builder.ErrorFeatureNotSupported), "filter must be rejected by 1.1")
+	assert.Contains(t, err.Error(), "requires KairosDB 1.2 or later", "Minimum version must be named")
+
+	for version, name := range map[string]string{
+		"KairosDB 0.9.4":            Profile09.Name,
+		"KairosDB 1.2.2-1.20180716": Profile12.Name,
+		"KairosDB 1.3.0":            Profile13.Name,
+		"2.0":                       Profile13.Name,
+	} {
+		p, err := ProfileForVersion(version)
+		assert.Nil(t, err, version)
+		assert.Equal(t, name, p.Name, version)
+	}
+}
+
+// Failure test.
+func TestDetectProfileUnknown(t *testing.T) {
+	srv := newNamesServer(http.StatusOK, `{"version":"unknown"}`, 0)
+	defer srv.Close()
+
+	_, err := DetectProfile(NewHttpClient(srv.URL))
+	assert.True(t, errors.Is(err, ErrorVersionUnknown), "Version must be parsed")
+
+	errSrv := newNamesServer(http.StatusNotFound, `{}`, 0)
+	defer errSrv.Close()
+
+	_, err = DetectProfile(NewHttpClient(errSrv.URL))
+	assert.True(t, errors.Is(err, ErrorVersionUnknown), "Version must be found")
+}
@@ -0,0 +1,132 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Compresses the request bodies of pushes, see WithCompression.
+type Codec interface {
+	// Returns the compressed data.
+	Encode(data []byte) ([]byte, error)
+
+	// Returns the Content-Type and Content-Encoding headers of a compressed
+	// body. An empty encoding sends no Content-Encoding header.
+	Headers() (contentType, contentEncoding string)
+}
+
+// Compresses the metrics pushed to KairosDB with the codec when their JSON
+// encoding is at least threshold bytes long. Small writes are sent as is
+// since compressing them costs more CPU than it saves bandwidth. KairosDB
+// itself only understands GzipCodec, other codecs need a proxy that
+// decompresses the requests.
+func WithCompression(threshold int, codec Codec) Option {
+	return func(hc *httpClient) {
+		hc.codec = codec
+		hc.compressThreshold = threshold
+	}
+}
+
+// Same as WithCompression with GzipCodec(level). level is one of the
+// compress/gzip levels, e.g. gzip.BestSpeed or gzip.DefaultCompression.
+func WithGzip(threshold, level int) Option {
+	return WithCompression(threshold, GzipCodec(level))
+}
+
+type gzipCodec struct {
+	level int
+	err   error
+	pool  sync.Pool // Of *gzip.Writer, reset for every body.
+}
+
+// Returns a codec compressing with gzip at the given level, sent with the
+// application/gzip content type KairosDB expects. Bulk loaders are often CPU
+// bound on compression, gzip.BestSpeed trades some ratio for much less CPU.
+// Writers are pooled, so the codec may be shared by several clients.
+func GzipCodec(level int) Codec {
+	gc := &gzipCodec{level: level}
+	if _, err := gzip.NewWriterLevel(ioutil.Discard, level); err != nil {
+		gc.err = err
+	}
+	return gc
+}
+
+func (gc *gzipCodec) Encode(data []byte) ([]byte, error) {
+	if gc.err != nil {
+		return nil, gc.err
+	}
+
+	var buf bytes.Buffer
+	zw, ok := gc.pool.Get().(*gzip.Writer)
+	if ok {
+		zw.Reset(&buf)
+	} else {
+		zw, _ = gzip.NewWriterLevel(&buf, gc.level)
+	}
+	defer gc.pool.Put(zw)
+
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (gc *gzipCodec) Headers() (string, string) {
+	return "application/gzip", ""
+}
+
+// Returns the body and headers of a push, compressed when above the
+// compression threshold.
+func (hc *httpClient) encodePush(data []byte) ([]byte, string, string, error) {
+	if hc.codec == nil || len(data) < hc.compressThreshold {
+		return data, "application/json", "", nil
+	}
+
+	body, err := hc.codec.Encode(data)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	contentType, contentEncoding := hc.codec.Headers()
+	return body, contentType, contentEncoding, nil
+}
+
+// Leaves the negotiation of the response compression to the HTTP transport,
+// which asks for gzip itself and transparently decompresses the responses.
+// By default the client asks for compressed responses explicitly and
+// decompresses them on its own, which some proxies mishandle.
+func WithTransportDecompression() Option {
+	return func(hc *httpClient) {
+		hc.autoDecompress = true
+	}
+}
+
+func (hc *httpClient) setAcceptEncoding(req *http.Request) {
+	if hc.autoDecompress {
+		return
+	}
+
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+}
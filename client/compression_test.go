@@ -0,0 +1,184 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type pushedBody struct {
+	contentType string
+	data        string
+}
+
+func newGzipServer(bodies *[]pushedBody) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := r.Body
+		if r.Header.Get("Content-Type") == "application/gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+
+		data, _ := ioutil.ReadAll(body)
+		*bodies = append(*bodies, pushedBody{contentType: r.Header.Get("Content-Type"), data: string(data)})
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+// Success test.
+func TestGzipThreshold(t *testing.T) {
+	var bodies []pushedBody
+	srv := newGzipServer(&bodies)
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithGzip(100, gzip.BestSpeed))
+
+	small := builder.NewMetricBuilder()
+	small.AddMetric("m1").AddDataPoint(1, 1)
+	resp, err := cli.PushMetrics(small)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode(), "Push must succeed")
+
+	large := builder.NewMetricBuilder()
+	m := large.AddMetric("m1")
+	for i := int64(0); i < 20; i++ {
+		m.AddDataPoint(i, i)
+	}
+	resp, err = cli.PushMetrics(large)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode(), "Push must succeed")
+
+	expected, _ := large.Build()
+	assert.Equal(t, []pushedBody{
+		{contentType: "application/json", data: `[{"name":"m1","datapoints":[[1,1]]}]`},
+		{contentType: "application/gzip", data: string(expected)},
+	}, bodies, "Only the payload above the threshold must be compressed")
+}
+
+// Failure test.
+func TestGzipInvalidLevel(t *testing.T) {
+	var bodies []pushedBody
+	srv := newGzipServer(&bodies)
+	defer srv.Close()
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddDataPoint(1, 1)
+	_, err := NewHttpClientWithOptions(srv.URL, WithGzip(0, 42)).PushMetrics(mb)
+	assert.NotNil(t, err, "Invalid compression level must fail")
+	assert.Empty(t, bodies, "Nothing must be sent")
+}
+
+type upperCodec struct{}
+
+func (upperCodec) Encode(data []byte) ([]byte, error) {
+	return bytes.ToUpper(data), nil
+}
+
+func (upperCodec) Headers() (string, string) {
+	return "application/json", "upper"
+}
+
+// Success test.
+func TestWithCompressionCodec(t *testing.T) {
+	var encoding, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding = r.Header.Get("Content-Encoding")
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddDataPoint(1, 1)
+	_, err := NewHttpClientWithOptions(srv.URL, WithCompression(0, upperCodec{})).PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, "upper", encoding, "Content encoding of the codec expected")
+	assert.Equal(t, `[{"NAME":"M1","DATAPOINTS":[[1,1]]}]`, body, "Body must be encoded by the codec")
+}
+
+// Success test.
+func TestGzipCodecReuse(t *testing.T) {
+	codec := GzipCodec(gzip.BestCompression)
+	for _, s := range []string{"first body", "second body"} {
+		data, err := codec.Encode([]byte(s))
+		assert.Nil(t, err, "No error expected")
+
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		assert.Nil(t, err, "No error expected")
+		plain, _ := ioutil.ReadAll(zr)
+		assert.Equal(t, s, string(plain), "Pooled writers must not leak previous bodies")
+	}
+}
+
+// Success test.
+func TestTransportDecompression(t *testing.T) {
+	var accepted []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accepted = append(accepted, r.Header.Get("Accept-Encoding"))
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(`{"queries":[{"sample_size":3}]}`))
+		zw.Close()
+	}))
+	defer srv.Close()
+
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("m1")
+
+	for _, cli := range []Client{NewHttpClient(srv.URL), NewHttpClientWithOptions(srv.URL, WithTransportDecompression())} {
+		qr, err := cli.Query(qb)
+		assert.Nil(t, err, "No error expected")
+		assert.EqualValues(t, 3, qr.QueriesArr[0].SampleSize, "Response must be decompressed")
+	}
+
+	assert.Equal(t, []string{"gzip, deflate", "gzip"}, accepted, "Transport must negotiate the compression")
+}
+
+// Failure test.
+func TestGzipHeaderWithPlainBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors":["not gzip"]}`))
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClient(srv.URL)
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("m1")
+
+	_, err := cli.Query(qb)
+	assert.True(t, errors.Is(err, gzip.ErrHeader), "Invalid gzip body must fail the query")
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddDataPoint(1, 1)
+	_, err = cli.PushMetrics(mb)
+	assert.True(t, errors.Is(err, gzip.ErrHeader), "Invalid gzip body must fail the push")
+}
@@ -0,0 +1,344 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Declarative configuration of a client, as read by FromConfig. Unset
+// sections keep the defaults of NewHttpClientWithOptions.
+type Config struct {
+	// Addresses of the KairosDB servers, used in round robin. At least one
+	// is required.
+	Endpoints []string `json:"endpoints" yaml:"endpoints"`
+
+	// Replaces the /api/v1 prefix of the endpoints, see WithBasePath.
+	BasePath *string `json:"base_path,omitempty" yaml:"base_path,omitempty"`
+
+	// Bounds every request, see WithTimeout.
+	Timeout Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+
+	Auth  *AuthConfig  `json:"auth,omitempty" yaml:"auth,omitempty"`
+	TLS   *TLSConfig   `json:"tls,omitempty" yaml:"tls,omitempty"`
+	Retry *RetryConfig `json:"retry,omitempty" yaml:"retry,omitempty"`
+	Gzip  *GzipConfig  `json:"gzip,omitempty" yaml:"gzip,omitempty"`
+
+	// Batching of the writer created by NewBatchWriterFromConfig. The
+	// client itself does not batch.
+	Batch *BatchConfig `json:"batch,omitempty" yaml:"batch,omitempty"`
+
+	// Tags added to every pushed metric that does not set them, see
+	// WithDefaultTags.
+	DefaultTags map[string]string `json:"default_tags,omitempty" yaml:"default_tags,omitempty"`
+}
+
+// Credentials sent to KairosDB. Basic and bearer authentication are
+// mutually exclusive.
+type AuthConfig struct {
+	Username string `json:"username,omitempty" yaml:"username,omitempty"`
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+	Token    string `json:"token,omitempty" yaml:"token,omitempty"`
+}
+
+// TLS settings of the connections to KairosDB. The files are PEM encoded.
+type TLSConfig struct {
+	// CA certificates used to verify the server. Empty means the system
+	// roots are used.
+	CAFile string `json:"ca_file,omitempty" yaml:"ca_file,omitempty"`
+
+	// Client certificate and key. Both empty means no client certificate
+	// is presented.
+	CertFile string `json:"cert_file,omitempty" yaml:"cert_file,omitempty"`
+	KeyFile  string `json:"key_file,omitempty" yaml:"key_file,omitempty"`
+
+	// Name the server certificate is verified against, when it differs
+	// from the host of the endpoints.
+	ServerName string `json:"server_name,omitempty" yaml:"server_name,omitempty"`
+
+	// Disables the verification of the server certificate. For testing
+	// only.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
+}
+
+// Retries of the requests, see WithRetry.
+type RetryConfig struct {
+	Attempts         int      `json:"attempts" yaml:"attempts"`
+	WriteAttempts    int      `json:"write_attempts,omitempty" yaml:"write_attempts,omitempty"`
+	Backoff          Duration `json:"backoff,omitempty" yaml:"backoff,omitempty"`
+	WriteDedupHeader string   `json:"write_dedup_header,omitempty" yaml:"write_dedup_header,omitempty"`
+}
+
+// Compression of the pushed metrics, see WithGzip. A zero level means
+// gzip.DefaultCompression.
+type GzipConfig struct {
+	Threshold int `json:"threshold" yaml:"threshold"`
+	Level     int `json:"level,omitempty" yaml:"level,omitempty"`
+}
+
+// Batching of the pushes, see BatchOptions. Zero values keep the defaults.
+type BatchConfig struct {
+	// Number of data points that triggers a flush.
+	Size int `json:"size,omitempty" yaml:"size,omitempty"`
+
+	// Maximum time data points wait for a flush.
+	Interval Duration `json:"interval,omitempty" yaml:"interval,omitempty"`
+
+	// Number of data points that can wait to be pushed, rounded up to full
+	// batches, see BatchWriter.Capacity.
+	Capacity int `json:"capacity,omitempty" yaml:"capacity,omitempty"`
+
+	// Estimated JSON size of a batch that triggers a flush, in bytes.
+	MaxPendingBytes int `json:"max_pending_bytes,omitempty" yaml:"max_pending_bytes,omitempty"`
+}
+
+// A time.Duration written as a string such as "1.5s" or "250ms" in config
+// files.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return time.Duration(d).String(), nil
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	var s string
+	if err := value.Decode(&s); err != nil {
+		return err
+	}
+	return d.parse(s)
+}
+
+func (d *Duration) parse(s string) error {
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Reads a client configuration from a YAML (.yaml, .yml) or JSON (.json)
+// file. Unknown fields are rejected, so that a misspelled setting does not
+// go unnoticed.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		err = dec.Decode(cfg)
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(cfg)
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrorConfigFormat, path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrorConfigInvalid, path, err)
+	}
+
+	return cfg, nil
+}
+
+// Creates a client from the configuration file at path, see LoadConfig. The
+// options are applied after the configured ones, e.g. to add hooks that
+// cannot be expressed in a file.
+func FromConfig(path string, opts ...Option) (Client, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFromConfig(cfg, opts...)
+}
+
+// Creates a client from the configuration. The options are applied after
+// the configured ones.
+func NewFromConfig(cfg *Config, opts ...Option) (Client, error) {
+	cfgOpts, err := cfg.options()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewHttpClientWithOptions(cfg.Endpoints[0], append(cfgOpts, opts...)...), nil
+}
+
+// Creates a client from the configuration, see NewFromConfig, wrapped in a
+// BatchWriter sized by the batch section. The batch options provide what a
+// file cannot express, such as OnError, and the defaults of the sizes the
+// configuration leaves unset.
+func NewBatchWriterFromConfig(cfg *Config, batchOpts BatchOptions, opts ...Option) (*BatchWriter, error) {
+	cli, err := NewFromConfig(cfg, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewBatchWriter(cli, cfg.BatchOptions(batchOpts)), nil
+}
+
+// Returns the batch options with the sizes set by the batch section, if
+// any, replacing the ones given.
+func (cfg *Config) BatchOptions(opts BatchOptions) BatchOptions {
+	b := cfg.Batch
+	if b == nil {
+		return opts
+	}
+
+	if b.Size > 0 {
+		opts.BatchSize = b.Size
+	}
+	if b.Interval > 0 {
+		opts.FlushInterval = time.Duration(b.Interval)
+	}
+	if b.MaxPendingBytes > 0 {
+		opts.MaxPendingBytes = b.MaxPendingBytes
+	}
+	if b.Capacity > 0 {
+		size := opts.BatchSize
+		if size <= 0 {
+			size = defaultBatchSize
+		}
+		opts.QueueSize = (b.Capacity + size - 1) / size
+	}
+
+	return opts
+}
+
+// Returns the client options matching the configuration.
+func (cfg *Config) options() ([]Option, error) {
+	if len(cfg.Endpoints) == 0 {
+		return nil, ErrorConfigNoEndpoints
+	}
+
+	// Set up front rather than on the created client, so that options such
+	// as WithWarmUp see all the servers and the credentials.
+	opts := []Option{func(hc *httpClient) {
+		hc.serverAddresses = append([]string(nil), cfg.Endpoints...)
+		if cfg.Auth != nil {
+			hc.username, hc.password = cfg.Auth.Username, cfg.Auth.Password
+		}
+	}}
+
+	if cfg.BasePath != nil {
+		opts = append(opts, WithBasePath(*cfg.BasePath))
+	}
+
+	if cfg.Timeout > 0 {
+		opts = append(opts, WithTimeout(time.Duration(cfg.Timeout)))
+	}
+
+	if cfg.Auth != nil && cfg.Auth.Token != "" {
+		if cfg.Auth.Username != "" {
+			return nil, fmt.Errorf("%w: both username and token set", ErrorConfigInvalid)
+		}
+
+		auth := "Bearer " + cfg.Auth.Token
+		opts = append(opts, WithAuthProvider(AuthProviderFunc(func(*http.Request) (string, error) {
+			return auth, nil
+		})))
+	}
+
+	if cfg.TLS != nil {
+		tlsCfg, err := cfg.TLS.load()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithTLSConfig(tlsCfg))
+	}
+
+	if cfg.Retry != nil {
+		opts = append(opts, WithRetry(RetryOptions{
+			Attempts:         cfg.Retry.Attempts,
+			WriteAttempts:    cfg.Retry.WriteAttempts,
+			Backoff:          time.Duration(cfg.Retry.Backoff),
+			WriteDedupHeader: cfg.Retry.WriteDedupHeader,
+		}))
+	}
+
+	if cfg.Gzip != nil {
+		level := cfg.Gzip.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		opts = append(opts, WithGzip(cfg.Gzip.Threshold, level))
+	}
+
+	if len(cfg.DefaultTags) > 0 {
+		opts = append(opts, WithDefaultTags(cfg.DefaultTags))
+	}
+
+	return opts, nil
+}
+
+// Loads the certificates and returns the matching TLS configuration.
+func (tc *TLSConfig) load() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         tc.ServerName,
+		InsecureSkipVerify: tc.InsecureSkipVerify,
+	}
+
+	if tc.CertFile != "" || tc.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(tc.CertFile, tc.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	if tc.CAFile != "" {
+		pem, err := ioutil.ReadFile(tc.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, ErrorNoCACertificates
+		}
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,171 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, name, contents string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(contents), 0600), "No error expected")
+	return path
+}
+
+// Success test.
+func TestFromConfigYAML(t *testing.T) {
+	var auth, body string
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		data, _ := ioutil.ReadAll(r.Body)
+		body = string(data)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writeServerCA(t, caFile, srv)
+
+	path := writeConfig(t, "kairosdb.yaml", `
+endpoints:
+  - `+srv.URL+`
+timeout: 5s
+auth:
+  token: secret
+tls:
+  ca_file: `+caFile+`
+retry:
+  attempts: 3
+  write_attempts: 2
+  backoff: 10ms
+default_tags:
+  env: prod
+`)
+
+	cfg, err := LoadConfig(path)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, Duration(5*time.Second), cfg.Timeout)
+	assert.Equal(t, Duration(10*time.Millisecond), cfg.Retry.Backoff)
+
+	cli, err := FromConfig(path)
+	assert.Nil(t, err, "No error expected")
+
+	hc := cli.(*httpClient)
+	assert.Equal(t, 5*time.Second, hc.httpCli.Timeout)
+	assert.Equal(t, 3, hc.retry.opts.Attempts)
+	assert.Equal(t, 2, hc.retry.opts.WriteAttempts)
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1, 2)
+	_, err = cli.PushMetrics(mb)
+	assert.Nil(t, err, "Server must be trusted")
+	assert.Equal(t, "Bearer secret", auth)
+	assert.Equal(t, `[{"datapoints":[[1,2]],"name":"m1","tags":{"env":"prod","host":"h1"}}]`, body)
+}
+
+// Success test.
+func TestFromConfigJSON(t *testing.T) {
+	var user, pass string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ = r.BasicAuth()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	path := writeConfig(t, "kairosdb.json", `{
+		"endpoints": ["`+srv.URL+`", "`+srv.URL+`"],
+		"auth": {"username": "u", "password": "p"},
+		"gzip": {"threshold": 1024}
+	}`)
+
+	cli, err := FromConfig(path)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, []string{srv.URL, srv.URL}, cli.(*httpClient).ServerAddresses())
+	assert.NotNil(t, cli.(*httpClient).codec)
+
+	_, err = cli.HealthCheck()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, "u", user)
+	assert.Equal(t, "p", pass)
+}
+
+// Success test.
+func TestNewBatchWriterFromConfig(t *testing.T) {
+	pr := newPushRecorder(http.StatusNoContent)
+	defer pr.srv.Close()
+
+	path := writeConfig(t, "kairosdb.yaml", `
+endpoints:
+  - `+pr.srv.URL+`
+batch:
+  size: 100
+  interval: 250ms
+  capacity: 1050
+  max_pending_bytes: 65536
+`)
+
+	cfg, err := LoadConfig(path)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, BatchOptions{BatchSize: 100, FlushInterval: 250 * time.Millisecond, QueueSize: 11, MaxPendingBytes: 65536, MaxAttempts: 5},
+		cfg.BatchOptions(BatchOptions{BatchSize: 10, MaxAttempts: 5}), "Configured sizes expected")
+	assert.Equal(t, BatchOptions{MaxAttempts: 5}, (&Config{}).BatchOptions(BatchOptions{MaxAttempts: 5}), "Options kept without batch section")
+
+	cfg.Batch.Size = 0
+	assert.Equal(t, 1, cfg.BatchOptions(BatchOptions{}).QueueSize, "Capacity rounded up to the default batch size expected")
+	cfg.Batch.Size = 100
+
+	bw, err := NewBatchWriterFromConfig(cfg, BatchOptions{})
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 1100, bw.Capacity())
+
+	assert.Nil(t, bw.Add("m1", nil, 1, 1), "No error expected")
+	assert.Nil(t, bw.Close(context.Background()), "No error expected")
+	assert.Equal(t, []string{`[{"name":"m1","datapoints":[[1,1]]}]`}, pr.Bodies())
+
+	_, err = NewBatchWriterFromConfig(&Config{}, BatchOptions{})
+	assert.Equal(t, ErrorConfigNoEndpoints, err)
+}
+
+// Failure test.
+func TestFromConfigInvalid(t *testing.T) {
+	_, err := FromConfig(writeConfig(t, "kairosdb.toml", `endpoints = []`))
+	assert.ErrorIs(t, err, ErrorConfigFormat)
+
+	_, err = FromConfig(writeConfig(t, "kairosdb.yml", "endpoints: [http://localhost:8080]\ntimeuot: 5s\n"))
+	assert.ErrorIs(t, err, ErrorConfigInvalid, "Unknown fields must be rejected")
+
+	_, err = FromConfig(writeConfig(t, "kairosdb.json", `{"endpoints": ["http://localhost:8080"], "timeout": "5 seconds"}`))
+	assert.ErrorIs(t, err, ErrorConfigInvalid, "Invalid durations must be rejected")
+
+	_, err = FromConfig(writeConfig(t, "kairosdb.json", `{"endpoints": []}`))
+	assert.ErrorIs(t, err, ErrorConfigNoEndpoints)
+
+	_, err = FromConfig(writeConfig(t, "kairosdb.json",
+		`{"endpoints": ["http://localhost:8080"], "auth": {"username": "u", "token": "t"}}`))
+	assert.ErrorIs(t, err, ErrorConfigInvalid, "Basic and bearer authentication are exclusive")
+
+	_, err = FromConfig(writeConfig(t, "kairosdb.json",
+		`{"endpoints": ["http://localhost:8080"], "tls": {"ca_file": "`+writeConfig(t, "ca.pem", "not a certificate")+`"}}`))
+	assert.ErrorIs(t, err, ErrorNoCACertificates)
+}
@@ -0,0 +1,266 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/retoool/go-kairosdb/clock"
+)
+
+// Provides the client configuration, e.g. read from a file or fetched from
+// a configuration service.
+type ConfigProvider interface {
+	Load() (*Config, error)
+}
+
+// Adapts a function to the ConfigProvider interface.
+type ConfigProviderFunc func() (*Config, error)
+
+func (f ConfigProviderFunc) Load() (*Config, error) {
+	return f()
+}
+
+// Returns a provider reading the configuration file at path, see
+// LoadConfig.
+func FileConfig(path string) ConfigProvider {
+	return ConfigProviderFunc(func() (*Config, error) {
+		return LoadConfig(path)
+	})
+}
+
+// Options of the ConfigWatcher.
+type ConfigWatcherOptions struct {
+	// How often the configuration is loaded. Defaults to 30 seconds.
+	Interval time.Duration
+
+	// Invoked with the previous and the new configuration once a change is
+	// applied to the client, e.g. to retune components configured from the
+	// same source. The batch section is only reported here: a BatchWriter is
+	// sized at creation, so applying new batch sizes takes replacing it,
+	// e.g. with NewBatchWriterFromConfig. May be nil.
+	OnChange func(old, new *Config)
+
+	// Invoked when loading or applying the configuration fails. May be nil.
+	OnError func(error)
+
+	// Paces the reloads. Defaults to the wall clock.
+	Clock clock.Clock
+}
+
+// Applies the changes of a configuration to a running client, so that
+// tuning it does not take a redeploy. The endpoints, the basic
+// authentication credentials and the default tags of a client created by
+// this package are swapped together under one lock: a request created
+// after Reload returns only uses the new settings, and no request pairs a
+// server with the credentials of another configuration. A push encoded
+// while the reload runs may still carry the previous default tags. The
+// batch section is left to OnChange. The other settings are baked into the
+// client at creation; changing them is reported with
+// ErrorConfigNotReloadable and takes a new client.
+type ConfigWatcher struct {
+	client   Client
+	provider ConfigProvider
+	opts     ConfigWatcherOptions
+	current  atomic.Pointer[Config]
+
+	mu   sync.Mutex // Serializes the reloads.
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// Loads the configuration and starts watching it for changes. The client is
+// expected to be created from the same configuration, e.g. with
+// NewFromConfig: the first configuration loaded is the baseline changes are
+// computed against, it is not applied. Close stops the watch.
+func WatchConfig(c Client, p ConfigProvider, opts ConfigWatcherOptions) (*ConfigWatcher, error) {
+	if opts.Interval <= 0 {
+		opts.Interval = 30 * time.Second
+	}
+	opts.Clock = clock.OrReal(opts.Clock)
+
+	cfg, err := p.Load()
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Endpoints) == 0 {
+		return nil, ErrorConfigNoEndpoints
+	}
+
+	cw := &ConfigWatcher{
+		client:   c,
+		provider: p,
+		opts:     opts,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	cw.current.Store(cfg)
+
+	go cw.watch()
+	return cw, nil
+}
+
+// Returns the configuration in effect.
+func (cw *ConfigWatcher) Config() *Config {
+	return cw.current.Load()
+}
+
+// Loads the configuration and applies its changes, regardless of the
+// interval. An invalid configuration is not applied. Changes to settings
+// that cannot be reloaded are reported with ErrorConfigNotReloadable, after
+// the others are applied.
+func (cw *ConfigWatcher) Reload() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	cfg, err := cw.provider.Load()
+	if err != nil {
+		return err
+	}
+	if len(cfg.Endpoints) == 0 {
+		return ErrorConfigNoEndpoints
+	}
+
+	old := cw.current.Load()
+	if reflect.DeepEqual(old, cfg) {
+		return nil
+	}
+
+	var fixed []string
+	if hc, ok := cw.client.(*httpClient); ok {
+		hc.applyConfig(old, cfg)
+	} else {
+		if !reflect.DeepEqual(old.Endpoints, cfg.Endpoints) {
+			cw.client.SetServerAddresses(cfg.Endpoints)
+		}
+		if user, pass := basicAuth(cfg); basicAuthChanged(old, cfg) {
+			cw.client.SetCredentials(user, pass)
+		}
+		if !reflect.DeepEqual(old.DefaultTags, cfg.DefaultTags) {
+			fixed = append(fixed, "default_tags")
+		}
+	}
+
+	fixed = append(fixed, fixedChanges(old, cfg)...)
+	cw.current.Store(cfg)
+
+	if cw.opts.OnChange != nil {
+		cw.opts.OnChange(old, cfg)
+	}
+
+	if len(fixed) > 0 {
+		return fmt.Errorf("%w: %s", ErrorConfigNotReloadable, strings.Join(fixed, ", "))
+	}
+	return nil
+}
+
+// Stops watching the configuration.
+func (cw *ConfigWatcher) Close() {
+	cw.once.Do(func() {
+		close(cw.stop)
+		<-cw.done
+	})
+}
+
+func (cw *ConfigWatcher) watch() {
+	defer close(cw.done)
+
+	for {
+		timer := cw.opts.Clock.NewTimer(cw.opts.Interval)
+		select {
+		case <-cw.stop:
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		if err := cw.Reload(); err != nil && cw.opts.OnError != nil {
+			cw.opts.OnError(err)
+		}
+	}
+}
+
+// Applies the changes of the endpoints, the basic authentication
+// credentials and the default tags under one lock.
+func (hc *httpClient) applyConfig(old, cfg *Config) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+
+	if !reflect.DeepEqual(old.Endpoints, cfg.Endpoints) {
+		hc.serverAddresses = append([]string(nil), cfg.Endpoints...)
+	}
+
+	if basicAuthChanged(old, cfg) {
+		hc.username, hc.password = basicAuth(cfg)
+	}
+
+	if !reflect.DeepEqual(old.DefaultTags, cfg.DefaultTags) {
+		tags := make(map[string]string, len(cfg.DefaultTags))
+		for k, v := range cfg.DefaultTags {
+			tags[k] = v
+		}
+		hc.defaultTags = tags
+	}
+}
+
+func basicAuth(cfg *Config) (string, string) {
+	if cfg.Auth == nil {
+		return "", ""
+	}
+	return cfg.Auth.Username, cfg.Auth.Password
+}
+
+func basicAuthChanged(old, cfg *Config) bool {
+	oldUser, oldPass := basicAuth(old)
+	user, pass := basicAuth(cfg)
+	return user != oldUser || pass != oldPass
+}
+
+// Returns the names of the changed settings that are fixed at creation.
+func fixedChanges(old, cfg *Config) []string {
+	var names []string
+	if !reflect.DeepEqual(old.BasePath, cfg.BasePath) {
+		names = append(names, "base_path")
+	}
+	if old.Timeout != cfg.Timeout {
+		names = append(names, "timeout")
+	}
+	if authToken(old) != authToken(cfg) {
+		names = append(names, "auth.token")
+	}
+	if !reflect.DeepEqual(old.TLS, cfg.TLS) {
+		names = append(names, "tls")
+	}
+	if !reflect.DeepEqual(old.Retry, cfg.Retry) {
+		names = append(names, "retry")
+	}
+	if !reflect.DeepEqual(old.Gzip, cfg.Gzip) {
+		names = append(names, "gzip")
+	}
+	return names
+}
+
+func authToken(cfg *Config) string {
+	if cfg.Auth == nil {
+		return ""
+	}
+	return cfg.Auth.Token
+}
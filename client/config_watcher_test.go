@@ -0,0 +1,133 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+type pushRecord struct {
+	server, user, body string
+}
+
+func newRecordingServer(name string, records chan<- pushRecord) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		body, _ := ioutil.ReadAll(r.Body)
+		records <- pushRecord{server: name, user: user, body: string(body)}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func watchedPush(t *testing.T, cli Client) {
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1, 1)
+	_, err := cli.PushMetrics(mb)
+	assert.NoError(t, err)
+}
+
+// Success test.
+func TestConfigWatcher(t *testing.T) {
+	records := make(chan pushRecord, 10)
+	srv1 := newRecordingServer("srv1", records)
+	defer srv1.Close()
+	srv2 := newRecordingServer("srv2", records)
+	defer srv2.Close()
+
+	path := writeConfig(t, "kairosdb.json", `{"endpoints":["`+srv1.URL+`"],"auth":{"username":"u1"},"default_tags":{"env":"dev"}}`)
+	cli, err := FromConfig(path)
+	assert.NoError(t, err)
+
+	clk := clock.NewFake(time.Now())
+	changed := make(chan *Config, 1)
+	cw, err := WatchConfig(cli, FileConfig(path), ConfigWatcherOptions{
+		Clock:    clk,
+		OnChange: func(old, new *Config) { changed <- new },
+	})
+	assert.NoError(t, err)
+	defer cw.Close()
+
+	watchedPush(t, cli)
+	assert.Equal(t, pushRecord{"srv1", "u1", `[{"datapoints":[[1,1]],"name":"m1","tags":{"env":"dev","host":"h1"}}]`}, <-records)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"endpoints":["`+srv2.URL+`"],"auth":{"username":"u2"},"default_tags":{"env":"prod"}}`), 0600))
+	clk.BlockUntil(1)
+	clk.Advance(30 * time.Second)
+	cfg := <-changed
+	assert.Equal(t, []string{srv2.URL}, cfg.Endpoints)
+	assert.Same(t, cfg, cw.Config())
+
+	watchedPush(t, cli)
+	assert.Equal(t, pushRecord{"srv2", "u2", `[{"datapoints":[[1,1]],"name":"m1","tags":{"env":"prod","host":"h1"}}]`}, <-records)
+}
+
+// Failure test.
+func TestConfigWatcherNotReloadable(t *testing.T) {
+	srv := newHealthServer(http.StatusNoContent)
+	defer srv.Close()
+
+	path := writeConfig(t, "kairosdb.json", `{"endpoints":["`+srv.URL+`"]}`)
+	cli, err := FromConfig(path)
+	assert.NoError(t, err)
+
+	cw, err := WatchConfig(cli, FileConfig(path), ConfigWatcherOptions{})
+	assert.NoError(t, err)
+	defer cw.Close()
+
+	// Invalid configurations are not applied.
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"endpoints":[]}`), 0600))
+	assert.ErrorIs(t, cw.Reload(), ErrorConfigNoEndpoints)
+	assert.Equal(t, []string{srv.URL}, cw.Config().Endpoints)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"endpoints":["`+srv.URL+`"],"timeout":"1s","default_tags":{"env":"prod"}}`), 0600))
+	err = cw.Reload()
+	assert.ErrorIs(t, err, ErrorConfigNotReloadable)
+	assert.Contains(t, err.Error(), "timeout")
+	assert.Equal(t, map[string]string{"env": "prod"}, cw.Config().DefaultTags)
+
+	os.Remove(path)
+	assert.Error(t, cw.Reload())
+}
+
+// Success test.
+func TestConfigWatcherBatch(t *testing.T) {
+	srv := newHealthServer(http.StatusNoContent)
+	defer srv.Close()
+
+	path := writeConfig(t, "kairosdb.json", `{"endpoints":["`+srv.URL+`"],"batch":{"size":100}}`)
+	cli, err := FromConfig(path)
+	assert.NoError(t, err)
+
+	var sizes []int
+	cw, err := WatchConfig(cli, FileConfig(path), ConfigWatcherOptions{
+		OnChange: func(old, new *Config) { sizes = append(sizes, old.Batch.Size, new.Batch.Size) },
+	})
+	assert.NoError(t, err)
+	defer cw.Close()
+
+	// Batch sizes are left to OnChange, they are not a setting of the client.
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"endpoints":["`+srv.URL+`"],"batch":{"size":200}}`), 0600))
+	assert.NoError(t, cw.Reload())
+	assert.Equal(t, []int{100, 200}, sizes)
+}
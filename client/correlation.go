@@ -0,0 +1,54 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import "context"
+
+// Header used to send the correlation ID when none is configured.
+const DefaultCorrelationHeader = "X-Correlation-ID"
+
+type correlationIDKey struct{}
+
+// Returns a copy of the context carrying the correlation ID.
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationIDKey{}, id)
+}
+
+// Returns the correlation ID stored by ContextWithCorrelationID, or an
+// empty string.
+func CorrelationIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(correlationIDKey{}).(string)
+	return id
+}
+
+// Sends the correlation ID of the request context in the given header, so
+// that the logs of proxies in front of KairosDB can be joined with the
+// application traces. extract pulls the ID out of the context, e.g. from a
+// tracing span; a nil extract uses CorrelationIDFromContext. An empty header
+// defaults to DefaultCorrelationHeader. Requests without an ID are sent
+// without the header.
+func WithCorrelationID(header string, extract func(ctx context.Context) string) Option {
+	if header == "" {
+		header = DefaultCorrelationHeader
+	}
+	if extract == nil {
+		extract = CorrelationIDFromContext
+	}
+
+	return func(hc *httpClient) {
+		hc.correlationHeader = header
+		hc.correlationID = extract
+	}
+}
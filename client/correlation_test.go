@@ -0,0 +1,62 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestCorrelationID(t *testing.T) {
+	var got []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("X-Request-ID"))
+		w.Write([]byte(`{"queries":[]}`))
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithCorrelationID("X-Request-ID", nil))
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("m1")
+
+	_, err := cli.QueryContext(ContextWithCorrelationID(context.Background(), "abc-123"), qb)
+	assert.Nil(t, err, "No error expected")
+	_, err = cli.Query(qb)
+	assert.Nil(t, err, "No error expected")
+
+	assert.Equal(t, []string{"abc-123", ""}, got, "Correlation ID must only be sent when present")
+}
+
+// Success test.
+func TestCorrelationIDCustomExtractor(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(DefaultCorrelationHeader)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithCorrelationID("", func(ctx context.Context) string { return "fixed" }))
+	cli.HealthCheck()
+
+	assert.Equal(t, "fixed", got, "Extracted ID must be sent in the default header")
+}
@@ -0,0 +1,40 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Implemented by the writers able to push data point sets without going
+// through a MetricBuilder, such as the client of NewHttpClient.
+type DataPointSetWriter interface {
+	PushDataPointSets(ctx context.Context, sets []builder.DataPointSet) (*response.Response, error)
+}
+
+// Pushes already structured data points, bypassing the MetricBuilder. The
+// sets are validated and encoded directly when the writer is a
+// DataPointSetWriter. Other writers, e.g. wrappers that inspect the metrics
+// they are given, receive the sets through a MetricBuilder.
+func PushDataPointSets(ctx context.Context, w MetricWriter, sets []builder.DataPointSet) (*response.Response, error) {
+	if dw, ok := w.(DataPointSetWriter); ok {
+		return dw.PushDataPointSets(ctx, sets)
+	}
+
+	return w.PushMetricsContext(ctx, metricBuilderOf(sets))
+}
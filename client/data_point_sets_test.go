@@ -0,0 +1,90 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+func dataPointSets() []builder.DataPointSet {
+	return []builder.DataPointSet{
+		{
+			Name:       "cpu",
+			Tags:       map[string]string{"host": "h1"},
+			DataPoints: []builder.DataPoint{*builder.NewDataPoint(1, 1.5), *builder.NewDataPoint(2, 2.5)},
+			TTL:        60,
+		},
+		{
+			Name:       "mem",
+			Tags:       map[string]string{"host": "h1"},
+			DataPoints: []builder.DataPoint{*builder.NewDataPoint(1, int64(3))},
+		},
+	}
+}
+
+// Success test.
+func TestPushDataPointSets(t *testing.T) {
+	var bodies []string
+	srv := newValidatingServer(&bodies)
+	defer srv.Close()
+
+	var pushed PushInfo
+	cli := NewHttpClientWithOptions(srv.URL, WithPushHooks(PushHooks{OnSuccess: func(pi PushInfo) { pushed = pi }}))
+	resp, err := PushDataPointSets(context.Background(), cli, dataPointSets())
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode())
+	assert.Equal(t, []string{`[{"name":"cpu","tags":{"host":"h1"},"datapoints":[[1,1.5],[2,2.5]],"ttl":60},{"name":"mem","tags":{"host":"h1"},"datapoints":[[1,3]]}]`}, bodies)
+	assert.Equal(t, 2, pushed.Metrics)
+	assert.Equal(t, 3, pushed.DataPoints)
+	assert.Equal(t, int64(3), cli.Stats().DataPointsWritten)
+}
+
+// Success test.
+func TestPushDataPointSetsFallback(t *testing.T) {
+	var bodies []string
+	srv := newValidatingServer(&bodies)
+	defer srv.Close()
+
+	// The quarantine writer is not a DataPointSetWriter, the sets go through
+	// a MetricBuilder.
+	qw := NewQuarantineWriter(NewHttpClient(srv.URL), QuarantineOptions{})
+	_, err := PushDataPointSets(context.Background(), qw, dataPointSets())
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, []string{`[{"name":"cpu","tags":{"host":"h1"},"datapoints":[[1,1.5],[2,2.5]],"ttl":60},{"name":"mem","tags":{"host":"h1"},"datapoints":[[1,3]]}]`}, bodies)
+}
+
+// Failure test.
+func TestPushDataPointSetsInvalid(t *testing.T) {
+	var bodies []string
+	srv := newValidatingServer(&bodies)
+	defer srv.Close()
+
+	cli := NewHttpClient(srv.URL)
+	sets := dataPointSets()
+	sets[1].DataPoints = append(sets[1].DataPoints, *builder.NewDataPoint(2, math.NaN()))
+	_, err := PushDataPointSets(context.Background(), cli, sets)
+	assert.Equal(t, builder.ErrorDataPointNonFinite, err)
+
+	sets[1].Name = ""
+	_, err = PushDataPointSets(context.Background(), cli, sets)
+	assert.Equal(t, builder.ErrorMetricNameInvalid, err)
+	assert.Empty(t, bodies, "Invalid sets must not be sent")
+}
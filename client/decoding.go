@@ -0,0 +1,89 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Makes the client reject responses holding fields the response types do
+// not know about, with an error wrapping ErrorSchemaMismatch. By default such
+// fields are ignored. Useful to validate the client against a new KairosDB
+// release.
+func WithStrictDecoding() Option {
+	return func(hc *httpClient) {
+		hc.strictDecoding = true
+	}
+}
+
+// Makes the client sort the data points of every series of a query response
+// by ascending timestamp. KairosDB returns them in order for plain queries,
+// but not always for grouped or merged series.
+func WithSortedDataPoints() Option {
+	return func(hc *httpClient) {
+		hc.sortDataPoints = true
+	}
+}
+
+// Makes the client convert the data point values of a query response that
+// are strings holding a number, as returned by some KairosDB plugins, to
+// float64. Strings that are not numbers are left as is.
+func WithNumericStrings() Option {
+	return func(hc *httpClient) {
+		hc.numericStrings = true
+	}
+}
+
+// Makes the client skip the data points of a query response that cannot be
+// decoded, e.g. with a timestamp that is not a number, instead of failing
+// the whole query. Each skipped data point is reported to onMalformed, which
+// may be nil. Strict decoding does not apply to query responses in this
+// mode.
+func WithLenientDecoding(onMalformed func(response.MalformedDataPoint)) Option {
+	return func(hc *httpClient) {
+		hc.lenientDecoding = true
+		hc.onMalformed = onMalformed
+	}
+}
+
+// Decodes a response body, honoring the strict decoding setting.
+func (hc *httpClient) unmarshal(data []byte, v interface{}) error {
+	if !hc.strictDecoding {
+		return json.Unmarshal(data, v)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err != nil && strings.HasPrefix(err.Error(), "json: unknown field ") {
+		return fmt.Errorf("%w: %s", ErrorSchemaMismatch, strings.TrimPrefix(err.Error(), "json: "))
+	}
+
+	// The results of queries keep their unknown fields rather than failing
+	// the decoder.
+	if qr, ok := v.(*response.QueryResponse); ok && err == nil {
+		if unknown := qr.UnknownFields(); len(unknown) > 0 {
+			return fmt.Errorf("%w: unknown field %q", ErrorSchemaMismatch, unknown[0])
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,131 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/retoool/go-kairosdb/response"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestStrictDecoding(t *testing.T) {
+	srv := newNamesServer(http.StatusOK, `{"results":["m1"]}`, 0)
+	defer srv.Close()
+
+	gr, err := NewHttpClientWithOptions(srv.URL, WithStrictDecoding()).GetMetricNames()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, []string{"m1"}, gr.GetResults(), "Results expected")
+}
+
+// Failure test.
+func TestStrictDecodingUnknownField(t *testing.T) {
+	srv := newNamesServer(http.StatusOK, `{"results":["m1"],"next_page":"abc"}`, 0)
+	defer srv.Close()
+
+	gr, err := NewHttpClient(srv.URL).GetMetricNames()
+	assert.Nil(t, err, "Unknown fields must be ignored by default")
+	assert.Equal(t, []string{"m1"}, gr.GetResults(), "Results expected")
+
+	gr, err = NewHttpClientWithOptions(srv.URL, WithStrictDecoding()).GetMetricNames()
+	assert.Nil(t, gr, "No response expected")
+	assert.True(t, errors.Is(err, ErrorSchemaMismatch), "Schema mismatch expected")
+	assert.Contains(t, err.Error(), "next_page", "Unknown field must be named")
+}
+
+// Failure test.
+func TestStrictDecodingNestedField(t *testing.T) {
+	srv := newNamesServer(http.StatusOK, `{"queries":[{"results":[{"name":"m1","values":[],"unit":"ms"}]}]}`, 0)
+	defer srv.Close()
+
+	qb := builder.NewQueryBuilder().SetRelativeStart(1, utils.HOURS)
+	qb.AddMetric("m1")
+
+	qr, err := NewHttpClient(srv.URL).Query(qb)
+	assert.Nil(t, err, "Unknown fields must be kept by default")
+	assert.Equal(t, []string{"queries[0].results[0].unit"}, qr.UnknownFields())
+
+	_, err = NewHttpClientWithOptions(srv.URL, WithStrictDecoding()).Query(qb)
+	assert.True(t, errors.Is(err, ErrorSchemaMismatch), "Schema mismatch expected")
+	assert.Contains(t, err.Error(), "queries[0].results[0].unit", "Unknown field must be named")
+}
+
+// Success test.
+func TestSortedDataPoints(t *testing.T) {
+	body := `{"queries":[{"sample_size":3,"results":[{"name":"m1","values":[[3,1],[1,2],[2,3]]}]}]}`
+	srv := newNamesServer(http.StatusOK, body, 0)
+	defer srv.Close()
+
+	qb := builder.NewQueryBuilder().SetRelativeStart(1, utils.HOURS)
+	qb.AddMetric("m1")
+
+	qr, err := NewHttpClient(srv.URL).Query(qb)
+	assert.Nil(t, err, "No error expected")
+	assert.NotNil(t, qr.VerifyOrder(), "Data points are left as is by default")
+
+	qr, err = NewHttpClientWithOptions(srv.URL, WithSortedDataPoints()).Query(qb)
+	assert.Nil(t, err, "No error expected")
+	assert.Nil(t, qr.VerifyOrder(), "Data points must be sorted")
+
+	dps := qr.QueriesArr[0].ResultsArr[0].DataPoints
+	assert.Equal(t, int64(1), dps[0].Timestamp(), "Lowest timestamp first")
+	assert.Equal(t, int64(3), dps[2].Timestamp(), "Highest timestamp last")
+}
+
+// Success test.
+func TestLenientDecoding(t *testing.T) {
+	body := `{"queries":[{"sample_size":3,"results":[{"name":"m1","values":[[1,1],["x",2],[3,3]]}]}]}`
+	srv := newNamesServer(http.StatusOK, body, 0)
+	defer srv.Close()
+
+	qb := builder.NewQueryBuilder().SetRelativeStart(1, utils.HOURS)
+
+	_, err := NewHttpClient(srv.URL).Query(qb)
+	assert.NotNil(t, err, "A malformed data point fails the query by default")
+
+	var malformed []response.MalformedDataPoint
+	qr, err := NewHttpClientWithOptions(srv.URL, WithLenientDecoding(func(m response.MalformedDataPoint) {
+		malformed = append(malformed, m)
+	})).Query(qb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 2, len(qr.QueriesArr[0].ResultsArr[0].DataPoints), "Malformed data point must be skipped")
+	assert.Equal(t, 1, len(malformed), "Malformed data point must be reported")
+	assert.Equal(t, 1, malformed[0].Index, "Index of the data point expected")
+}
+
+// Success test.
+func TestNumericStrings(t *testing.T) {
+	body := `{"queries":[{"sample_size":2,"results":[{"name":"m1","values":[[1,"1.5"],[2,"n/a"]]}]}]}`
+	srv := newNamesServer(http.StatusOK, body, 0)
+	defer srv.Close()
+
+	qb := builder.NewQueryBuilder().SetRelativeStart(1, utils.HOURS)
+
+	qr, err := NewHttpClient(srv.URL).Query(qb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, "1.5", qr.QueriesArr[0].ResultsArr[0].DataPoints[0].Value(), "Strings are left as is by default")
+
+	qr, err = NewHttpClientWithOptions(srv.URL, WithNumericStrings()).Query(qb)
+	assert.Nil(t, err, "No error expected")
+
+	dps := qr.QueriesArr[0].ResultsArr[0].DataPoints
+	assert.Equal(t, 1.5, dps[0].Value(), "Numeric string must be coerced")
+	assert.Equal(t, "n/a", dps[1].Value(), "Other strings must be left as is")
+}
@@ -0,0 +1,70 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import "encoding/json"
+
+// Adds the tags to every pushed metric that does not set them already, e.g.
+// the host or the environment a service runs in. Tags set on the metric take
+// precedence over the default ones.
+func WithDefaultTags(tags map[string]string) Option {
+	return func(hc *httpClient) {
+		hc.setDefaultTags(tags)
+	}
+}
+
+// Replaces the default tags. Safe to call while other requests are in
+// flight.
+func (hc *httpClient) setDefaultTags(tags map[string]string) {
+	copied := make(map[string]string, len(tags))
+	for k, v := range tags {
+		copied[k] = v
+	}
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.defaultTags = copied
+}
+
+// Adds the default tags missing from every metric of an encoded metric list.
+func addDefaultTags(data []byte, defaults map[string]string) ([]byte, error) {
+	var metrics []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return nil, err
+	}
+
+	for _, m := range metrics {
+		tags := make(map[string]string)
+		if raw, ok := m["tags"]; ok {
+			if err := json.Unmarshal(raw, &tags); err != nil {
+				return nil, err
+			}
+		}
+
+		for k, v := range defaults {
+			if _, ok := tags[k]; !ok {
+				tags[k] = v
+			}
+		}
+
+		raw, err := json.Marshal(tags)
+		if err != nil {
+			return nil, err
+		}
+		m["tags"] = raw
+	}
+
+	return json.Marshal(metrics)
+}
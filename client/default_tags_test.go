@@ -0,0 +1,41 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestWithDefaultTags(t *testing.T) {
+	var bodies []string
+	srv := newValidatingServer(&bodies)
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithDefaultTags(map[string]string{"env": "prod", "host": "default"}))
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1, 2)
+	mb.AddMetric("m2").AddDataPoint(1, 3)
+	_, err := cli.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+
+	assert.Equal(t, `[{"datapoints":[[1,2]],"name":"m1","tags":{"env":"prod","host":"h1"}},`+
+		`{"datapoints":[[1,3]],"name":"m2","tags":{"env":"prod","host":"default"}}]`, bodies[0],
+		"Missing default tags must be added without overriding the metric ones")
+}
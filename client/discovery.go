@@ -0,0 +1,228 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/retoool/go-kairosdb/clock"
+)
+
+// A Discoverer resolves the list of KairosDB server addresses, for example
+// from DNS or a service registry such as Consul or etcd.
+type Discoverer interface {
+	// Returns the server addresses in the "scheme://host:port" form.
+	Discover(ctx context.Context) ([]string, error)
+}
+
+// Adapter that allows the use of an ordinary function as a Discoverer. This
+// is the easiest way to plug in registries this package has no support for.
+type DiscovererFunc func(ctx context.Context) ([]string, error)
+
+func (f DiscovererFunc) Discover(ctx context.Context) ([]string, error) {
+	return f(ctx)
+}
+
+type srvDiscoverer struct {
+	service  string
+	proto    string
+	name     string
+	scheme   string
+	resolver *net.Resolver
+}
+
+// Creates a Discoverer that resolves the DNS SRV record
+// _service._proto.name. Every target is turned into an address using the
+// given scheme, e.g. "http".
+func NewSRVDiscoverer(service, proto, name, scheme string) Discoverer {
+	return &srvDiscoverer{
+		service:  service,
+		proto:    proto,
+		name:     name,
+		scheme:   scheme,
+		resolver: net.DefaultResolver,
+	}
+}
+
+func (sd *srvDiscoverer) Discover(ctx context.Context) ([]string, error) {
+	_, srvs, err := sd.resolver.LookupSRV(ctx, sd.service, sd.proto, sd.name)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]string, 0, len(srvs))
+	for _, srv := range srvs {
+		host := strings.TrimSuffix(srv.Target, ".")
+		addrs = append(addrs, sd.scheme+"://"+net.JoinHostPort(host, strconv.Itoa(int(srv.Port))))
+	}
+
+	return addrs, nil
+}
+
+type consulDiscoverer struct {
+	consulAddress string
+	service       string
+	scheme        string
+	httpClient    *http.Client
+}
+
+// Creates a Discoverer that asks the Consul agent at consulAddress for the
+// healthy instances of the service. Every instance is turned into an address
+// using the given scheme, e.g. "http".
+func NewConsulDiscoverer(consulAddress, service, scheme string) Discoverer {
+	return &consulDiscoverer{
+		consulAddress: strings.TrimSuffix(consulAddress, "/"),
+		service:       service,
+		scheme:        scheme,
+		httpClient:    &http.Client{},
+	}
+}
+
+type consulServiceEntry struct {
+	Node struct {
+		Address string `json:"Address"`
+	} `json:"Node"`
+	Service struct {
+		Address string `json:"Address"`
+		Port    int    `json:"Port"`
+	} `json:"Service"`
+}
+
+func (cd *consulDiscoverer) Discover(ctx context.Context) ([]string, error) {
+	u := cd.consulAddress + "/v1/health/service/" + url.PathEscape(cd.service) + "?passing=true"
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := cd.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("consul returned status %d", resp.StatusCode)
+	}
+
+	var entries []consulServiceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+
+	addrs := make([]string, 0, len(entries))
+	for _, e := range entries {
+		// The service address is optional in Consul and defaults to the
+		// address of the node.
+		host := e.Service.Address
+		if host == "" {
+			host = e.Node.Address
+		}
+		addrs = append(addrs, cd.scheme+"://"+net.JoinHostPort(host, strconv.Itoa(e.Service.Port)))
+	}
+
+	return addrs, nil
+}
+
+// Periodically refreshes the server addresses of a client using a
+// Discoverer.
+type DiscoveryWatcher struct {
+	client     Admin
+	discoverer Discoverer
+	interval   time.Duration
+	clock      clock.Clock
+	onError    func(error)
+	cancel     context.CancelFunc
+	done       chan struct{}
+	once       sync.Once
+}
+
+// Starts watching the Discoverer. The addresses are resolved once before
+// returning and then every interval, 30 seconds when zero or less, until
+// Stop is called, paced by the clock of the client. Failed lookups and empty
+// results keep the previously known addresses and are reported to onError,
+// which may be nil.
+func WatchDiscovery(c Admin, d Discoverer, interval time.Duration, onError func(error)) *DiscoveryWatcher {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dw := &DiscoveryWatcher{
+		client:     c,
+		discoverer: d,
+		interval:   interval,
+		clock:      clockOf(c),
+		onError:    onError,
+		cancel:     cancel,
+		done:       make(chan struct{}),
+	}
+
+	dw.Refresh(ctx)
+	go dw.run(ctx)
+
+	return dw
+}
+
+// Resolves the addresses once and applies them to the client.
+func (dw *DiscoveryWatcher) Refresh(ctx context.Context) error {
+	addrs, err := dw.discoverer.Discover(ctx)
+	if err == nil && len(addrs) == 0 {
+		err = ErrorNoEndpoints
+	}
+
+	if err != nil {
+		if dw.onError != nil {
+			dw.onError(err)
+		}
+		return err
+	}
+
+	dw.client.SetServerAddresses(addrs)
+	return nil
+}
+
+// Stops the periodic refresh and waits for it to finish.
+func (dw *DiscoveryWatcher) Stop() {
+	dw.once.Do(func() {
+		dw.cancel()
+		<-dw.done
+	})
+}
+
+func (dw *DiscoveryWatcher) run(ctx context.Context) {
+	defer close(dw.done)
+
+	for {
+		timer := dw.clock.NewTimer(dw.interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		dw.Refresh(ctx)
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestWatchDiscovery(t *testing.T) {
+	cli := NewHttpClient("http://initial:8080")
+	results := [][]string{{"http://a:8080", "http://b:8080"}, nil}
+	calls := 0
+	d := DiscovererFunc(func(ctx context.Context) ([]string, error) {
+		r := results[calls]
+		calls++
+		return r, nil
+	})
+
+	var errs []error
+	dw := WatchDiscovery(cli, d, time.Hour, func(err error) { errs = append(errs, err) })
+	defer dw.Stop()
+
+	assert.Equal(t, []string{"http://a:8080", "http://b:8080"}, cli.ServerAddresses(), "Discovered addresses must be applied")
+
+	err := dw.Refresh(context.Background())
+	assert.Equal(t, ErrorNoEndpoints, err, "Empty discovery result must be reported")
+	assert.Equal(t, []error{ErrorNoEndpoints}, errs, "Error callback must be invoked")
+	assert.Equal(t, []string{"http://a:8080", "http://b:8080"}, cli.ServerAddresses(), "Previous addresses must be kept")
+}
+
+// Failure test.
+func TestWatchDiscoveryError(t *testing.T) {
+	cli := NewHttpClient("http://initial:8080")
+	lookupErr := errors.New("lookup failed")
+	d := DiscovererFunc(func(ctx context.Context) ([]string, error) {
+		return nil, lookupErr
+	})
+
+	var got error
+	dw := WatchDiscovery(cli, d, time.Hour, func(err error) { got = err })
+	dw.Stop()
+
+	assert.Equal(t, lookupErr, got, "Lookup error must be reported")
+	assert.Equal(t, []string{"http://initial:8080"}, cli.ServerAddresses(), "Initial address must be kept")
+}
+
+// Success test.
+func TestWatchDiscoveryDefaultInterval(t *testing.T) {
+	cli := NewHttpClient("http://initial:8080")
+	d := DiscovererFunc(func(ctx context.Context) ([]string, error) {
+		return []string{"http://a:8080"}, nil
+	})
+
+	dw := WatchDiscovery(cli, d, 0, nil)
+	defer dw.Stop()
+
+	assert.Equal(t, 30*time.Second, dw.interval, "Default interval expected")
+	assert.Equal(t, []string{"http://a:8080"}, cli.ServerAddresses())
+}
+
+// Success test.
+func TestConsulDiscoverer(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/health/service/kairosdb", r.URL.Path, "Consul health endpoint expected")
+		assert.Equal(t, "true", r.URL.Query().Get("passing"), "Only passing instances expected")
+		w.Write([]byte(`[{"Node":{"Address":"10.0.0.1"},"Service":{"Address":"","Port":8080}},` +
+			`{"Node":{"Address":"10.0.0.2"},"Service":{"Address":"10.1.0.2","Port":9090}}]`))
+	}))
+	defer srv.Close()
+
+	addrs, err := NewConsulDiscoverer(srv.URL, "kairosdb", "http").Discover(context.Background())
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, []string{"http://10.0.0.1:8080", "http://10.1.0.2:9090"}, addrs, "Addresses must match")
+}
+
+// Success test.
+func TestRoundRobinAddresses(t *testing.T) {
+	var hits []string
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			hits = append(hits, name)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+	a := httptest.NewServer(handler("a"))
+	defer a.Close()
+	b := httptest.NewServer(handler("b"))
+	defer b.Close()
+
+	cli := NewHttpClient(a.URL)
+	cli.SetServerAddresses([]string{a.URL, b.URL})
+	for i := 0; i < 4; i++ {
+		cli.HealthCheck()
+	}
+
+	assert.Equal(t, []string{"a", "b", "a", "b"}, hits, "Requests must be spread round robin")
+}
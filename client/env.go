@@ -0,0 +1,208 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Prefix of the environment variables read by FromEnv.
+const EnvPrefix = "KAIROSDB_"
+
+// Builds a client configuration from the environment:
+//
+//	KAIROSDB_CONFIG                    configuration file the variables below override
+//	KAIROSDB_URL                       comma separated server addresses
+//	KAIROSDB_BASE_PATH                 see WithBasePath
+//	KAIROSDB_TIMEOUT                   request timeout, e.g. 10s
+//	KAIROSDB_USERNAME                  basic authentication
+//	KAIROSDB_PASSWORD
+//	KAIROSDB_TOKEN                     bearer authentication
+//	KAIROSDB_TLS_CA_FILE               PEM files, see TLSConfig
+//	KAIROSDB_TLS_CERT_FILE
+//	KAIROSDB_TLS_KEY_FILE
+//	KAIROSDB_TLS_SERVER_NAME
+//	KAIROSDB_TLS_INSECURE_SKIP_VERIFY  true or false
+//	KAIROSDB_RETRY_ATTEMPTS            see WithRetry
+//	KAIROSDB_RETRY_WRITE_ATTEMPTS
+//	KAIROSDB_RETRY_BACKOFF
+//	KAIROSDB_GZIP_THRESHOLD            see WithGzip
+//	KAIROSDB_DEFAULT_TAGS              comma separated key=value pairs
+//
+// Unset and empty variables are ignored.
+func ConfigFromEnv() (*Config, error) {
+	cfg := &Config{}
+	if path := getenv("CONFIG"); path != "" {
+		var err error
+		if cfg, err = LoadConfig(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if v := getenv("URL"); v != "" {
+		cfg.Endpoints = splitList(v)
+	}
+	if v, ok := os.LookupEnv(EnvPrefix + "BASE_PATH"); ok {
+		cfg.BasePath = &v
+	}
+	if err := envDuration("TIMEOUT", &cfg.Timeout); err != nil {
+		return nil, err
+	}
+
+	if v := getenv("USERNAME"); v != "" {
+		cfg.auth().Username = v
+	}
+	if v := getenv("PASSWORD"); v != "" {
+		cfg.auth().Password = v
+	}
+	if v := getenv("TOKEN"); v != "" {
+		cfg.auth().Token = v
+	}
+
+	if v := getenv("TLS_CA_FILE"); v != "" {
+		cfg.tls().CAFile = v
+	}
+	if v := getenv("TLS_CERT_FILE"); v != "" {
+		cfg.tls().CertFile = v
+	}
+	if v := getenv("TLS_KEY_FILE"); v != "" {
+		cfg.tls().KeyFile = v
+	}
+	if v := getenv("TLS_SERVER_NAME"); v != "" {
+		cfg.tls().ServerName = v
+	}
+	if v := getenv("TLS_INSECURE_SKIP_VERIFY"); v != "" {
+		skip, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, envError("TLS_INSECURE_SKIP_VERIFY", err)
+		}
+		cfg.tls().InsecureSkipVerify = skip
+	}
+
+	if v := getenv("RETRY_ATTEMPTS"); v != "" {
+		attempts, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, envError("RETRY_ATTEMPTS", err)
+		}
+		cfg.retry().Attempts = attempts
+	}
+	if v := getenv("RETRY_WRITE_ATTEMPTS"); v != "" {
+		attempts, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, envError("RETRY_WRITE_ATTEMPTS", err)
+		}
+		cfg.retry().WriteAttempts = attempts
+	}
+	if getenv("RETRY_BACKOFF") != "" {
+		if err := envDuration("RETRY_BACKOFF", &cfg.retry().Backoff); err != nil {
+			return nil, err
+		}
+	}
+
+	if v := getenv("GZIP_THRESHOLD"); v != "" {
+		threshold, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, envError("GZIP_THRESHOLD", err)
+		}
+		if cfg.Gzip == nil {
+			cfg.Gzip = &GzipConfig{}
+		}
+		cfg.Gzip.Threshold = threshold
+	}
+
+	if v := getenv("DEFAULT_TAGS"); v != "" {
+		if cfg.DefaultTags == nil {
+			cfg.DefaultTags = make(map[string]string)
+		}
+		for _, pair := range splitList(v) {
+			k, val, ok := strings.Cut(pair, "=")
+			if !ok || k == "" {
+				return nil, envError("DEFAULT_TAGS", fmt.Errorf("%q is not a key=value pair", pair))
+			}
+			cfg.DefaultTags[strings.TrimSpace(k)] = strings.TrimSpace(val)
+		}
+	}
+
+	return cfg, nil
+}
+
+// Creates a client configured from the environment, see ConfigFromEnv. The
+// options are applied after the configured ones.
+func FromEnv(opts ...Option) (Client, error) {
+	cfg, err := ConfigFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewFromConfig(cfg, opts...)
+}
+
+func getenv(name string) string {
+	return os.Getenv(EnvPrefix + name)
+}
+
+func envError(name string, err error) error {
+	return fmt.Errorf("%w: %s%s: %v", ErrorConfigInvalid, EnvPrefix, name, err)
+}
+
+func envDuration(name string, d *Duration) error {
+	v := getenv(name)
+	if v == "" {
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(v)
+	if err != nil {
+		return envError(name, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// Splits a comma separated list, dropping the blank entries.
+func splitList(s string) []string {
+	var vals []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			vals = append(vals, v)
+		}
+	}
+	return vals
+}
+
+func (cfg *Config) auth() *AuthConfig {
+	if cfg.Auth == nil {
+		cfg.Auth = &AuthConfig{}
+	}
+	return cfg.Auth
+}
+
+func (cfg *Config) tls() *TLSConfig {
+	if cfg.TLS == nil {
+		cfg.TLS = &TLSConfig{}
+	}
+	return cfg.TLS
+}
+
+func (cfg *Config) retry() *RetryConfig {
+	if cfg.Retry == nil {
+		cfg.Retry = &RetryConfig{}
+	}
+	return cfg.Retry
+}
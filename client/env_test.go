@@ -0,0 +1,88 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestFromEnv(t *testing.T) {
+	var user, pass string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ = r.BasicAuth()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	t.Setenv("KAIROSDB_URL", srv.URL+", "+srv.URL)
+	t.Setenv("KAIROSDB_USERNAME", "u")
+	t.Setenv("KAIROSDB_PASSWORD", "p")
+	t.Setenv("KAIROSDB_TIMEOUT", "3s")
+	t.Setenv("KAIROSDB_RETRY_ATTEMPTS", "2")
+	t.Setenv("KAIROSDB_DEFAULT_TAGS", "env=prod, dc=eu")
+
+	cfg, err := ConfigFromEnv()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, []string{srv.URL, srv.URL}, cfg.Endpoints)
+	assert.Equal(t, map[string]string{"env": "prod", "dc": "eu"}, cfg.DefaultTags)
+
+	cli, err := FromEnv()
+	assert.Nil(t, err, "No error expected")
+
+	hc := cli.(*httpClient)
+	assert.Equal(t, 3*time.Second, hc.httpCli.Timeout)
+	assert.Equal(t, 2, hc.retry.opts.Attempts)
+
+	_, err = cli.HealthCheck()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, "u", user)
+	assert.Equal(t, "p", pass)
+}
+
+// Success test.
+func TestFromEnvOverridesConfigFile(t *testing.T) {
+	path := writeConfig(t, "kairosdb.yaml", "endpoints: [http://file:8080]\ntimeout: 1s\n")
+	t.Setenv("KAIROSDB_CONFIG", path)
+	t.Setenv("KAIROSDB_TIMEOUT", "2s")
+
+	cfg, err := ConfigFromEnv()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, []string{"http://file:8080"}, cfg.Endpoints, "Unset variables must keep the file values")
+	assert.Equal(t, Duration(2*time.Second), cfg.Timeout, "Variables must override the file")
+}
+
+// Failure test.
+func TestFromEnvInvalid(t *testing.T) {
+	t.Setenv("KAIROSDB_URL", "")
+	_, err := FromEnv()
+	assert.ErrorIs(t, err, ErrorConfigNoEndpoints)
+
+	t.Setenv("KAIROSDB_URL", "http://localhost:8080")
+	t.Setenv("KAIROSDB_TIMEOUT", "10")
+	_, err = FromEnv()
+	assert.ErrorIs(t, err, ErrorConfigInvalid)
+	assert.Contains(t, err.Error(), "KAIROSDB_TIMEOUT")
+
+	t.Setenv("KAIROSDB_TIMEOUT", "")
+	t.Setenv("KAIROSDB_DEFAULT_TAGS", "env")
+	_, err = FromEnv()
+	assert.ErrorIs(t, err, ErrorConfigInvalid)
+}
@@ -0,0 +1,117 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import "errors"
+
+var (
+	// Discovery Errors.
+	ErrorNoEndpoints = errors.New("Discovery returned no endpoints")
+
+	// Sharding Errors.
+	ErrorNoShards        = errors.New("At least one shard is required")
+	ErrorShardOutOfRange = errors.New("Shard function returned an index out of range")
+
+	// Mirroring Errors.
+	ErrorMirrorQueueFull = errors.New("Mirror queue full")
+	ErrorMirrorClosed    = errors.New("Mirror client closed")
+
+	// Fallback Errors.
+	ErrorPrimaryTimeout = errors.New("Primary did not answer in time")
+
+	// Cache Errors.
+	ErrorRefreshFailed = errors.New("Background refresh returned an error status")
+
+	// Paging Errors.
+	ErrorPageQuery = errors.New("Page query returned an error status")
+
+	// Retention Errors.
+	ErrorRetentionAgeInvalid = errors.New("Age must be > 0")
+
+	// Snapshot Errors.
+	ErrorSnapshotInterval = errors.New("Snapshot taken with another interval")
+
+	// Metric Name Listing Errors.
+	ErrorMetricNamesQuery = errors.New("Metric names request returned an error status")
+
+	// Cardinality Errors.
+	ErrorCardinalityQuery = errors.New("Cardinality query returned an error status")
+
+	// Compatibility Errors.
+	ErrorNotSupported   = errors.New("Not supported by the KairosDB version")
+	ErrorVersionUnknown = errors.New("Unknown KairosDB version")
+
+	// Delete Metric Errors.
+	ErrorMetricNotFound     = errors.New("Metric not found")
+	ErrorMetricNameRejected = errors.New("Metric name rejected by the server")
+
+	// Tenant Errors.
+	ErrorTenantMismatch     = errors.New("Tenant tag set to another tenant")
+	ErrorTenantDeleteMetric = errors.New("Deleting a whole metric is not allowed for a tenant")
+	ErrorTenantMetricNull   = errors.New("Metric of a tenant scoped request is null")
+
+	// Decoding Errors.
+	ErrorSchemaMismatch = errors.New("Response does not match the expected schema")
+
+	// Signing Errors.
+	ErrorNoAWSCredentials = errors.New("AWS credentials not found in the environment")
+
+	// TLS Errors.
+	ErrorNoCACertificates   = errors.New("No CA certificates found in file")
+	ErrorNoPeerCertificates = errors.New("Server presented no certificates")
+
+	// Config Errors.
+	ErrorConfigFormat      = errors.New("Unknown config file format")
+	ErrorConfigInvalid     = errors.New("Invalid client configuration")
+	ErrorConfigNoEndpoints = errors.New("No endpoints configured")
+
+	// Config Reload Errors.
+	ErrorConfigNotReloadable = errors.New("Setting cannot be changed at runtime")
+
+	// Warm-up Errors.
+	ErrorWarmUpUnhealthy = errors.New("Server unhealthy during warm-up")
+
+	// Response Size Errors.
+	ErrorResponseTooLarge = errors.New("Query response exceeds the maximum size")
+
+	// Tag Pattern Errors.
+	ErrorTagPatternInvalid = errors.New("Invalid tag pattern")
+	ErrorTagsQuery         = errors.New("Tags query returned an error status")
+	ErrorNoTagMatch        = errors.New("No tag value matches the pattern")
+
+	// Idle Ping Errors.
+	ErrorPingUnhealthy = errors.New("Idle health check reported the server unhealthy")
+
+	// Spooling Errors.
+	ErrorSpoolDir     = errors.New("Spool directory not set")
+	ErrorSpoolFull    = errors.New("Spool is full")
+	ErrorSpoolReplay  = errors.New("Spooled batch replay failed")
+	ErrorSpoolCorrupt = errors.New("Spooled batch unreadable")
+
+	// Latest Data Points Errors.
+	ErrorLatestCount = errors.New("Number of data points must be positive")
+	ErrorLatestQuery = errors.New("Latest data points query returned an error status")
+
+	// Query Proxy Errors.
+	ErrorRawQueryUnsupported = errors.New("Client does not support raw queries")
+
+	// Bulk Deletion Errors.
+	ErrorDeleteMetrics = errors.New("Some metrics could not be deleted")
+
+	// Batch Writer Errors.
+	ErrorBatchQueueFull    = errors.New("Batch queue full")
+	ErrorBatchWriterClosed = errors.New("Batch writer closed")
+	ErrorBatchRejected     = errors.New("Batch rejected")
+)
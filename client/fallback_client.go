@@ -0,0 +1,198 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Decides whether a failed read on the primary is retried on the fallback.
+// err is the request error, if any, and statusCode the HTTP status code
+// returned by the primary when the request went through.
+type FallbackPolicy func(err error, statusCode int) bool
+
+// Falls back on request errors and on 5xx responses. Client errors such as
+// a malformed query are returned as is since the fallback would reject them
+// as well.
+func DefaultFallbackPolicy(err error, statusCode int) bool {
+	return err != nil || statusCode >= http.StatusInternalServerError
+}
+
+// Options of the FallbackClient.
+type FallbackOptions struct {
+	// Decides which failures trigger the fallback. Defaults to
+	// DefaultFallbackPolicy.
+	Policy FallbackPolicy
+
+	// Time after which a primary that has not answered yet is considered
+	// failed and the fallback is consulted. Zero means no timeout.
+	Timeout time.Duration
+
+	// Runs the timeout. Defaults to the wall clock.
+	Clock clock.Clock
+}
+
+// A Client whose read operations are retried on a secondary cluster when
+// the primary fails or times out. Writes, deletes and health checks only go
+// to the primary.
+type FallbackClient struct {
+	Client
+	fallback Client
+	opts     FallbackOptions
+}
+
+func NewFallbackClient(primary, fallback Client, opts FallbackOptions) *FallbackClient {
+	if opts.Policy == nil {
+		opts.Policy = DefaultFallbackPolicy
+	}
+	opts.Clock = clock.OrReal(opts.Clock)
+
+	return &FallbackClient{
+		Client:   primary,
+		fallback: fallback,
+		opts:     opts,
+	}
+}
+
+// Returns a list of all metrics names.
+func (fc *FallbackClient) GetMetricNames() (*response.GetResponse, error) {
+	return fc.GetMetricNamesContext(context.Background())
+}
+
+// Same as GetMetricNames, but the requests are aborted when the context is
+// done.
+func (fc *FallbackClient) GetMetricNamesContext(ctx context.Context) (*response.GetResponse, error) {
+	return withFallback(ctx, fc, fc.Client.GetMetricNamesContext, fc.fallback.GetMetricNamesContext)
+}
+
+// Returns a list of the metric names starting with the prefix.
+func (fc *FallbackClient) GetMetricNamesWithPrefix(prefix string) (*response.GetResponse, error) {
+	return fc.GetMetricNamesWithPrefixContext(context.Background(), prefix)
+}
+
+// Same as GetMetricNamesWithPrefix, but the requests are aborted when the
+// context is done.
+func (fc *FallbackClient) GetMetricNamesWithPrefixContext(ctx context.Context, prefix string) (*response.GetResponse, error) {
+	return withFallback(ctx, fc,
+		func(ctx context.Context) (*response.GetResponse, error) {
+			return fc.Client.GetMetricNamesWithPrefixContext(ctx, prefix)
+		},
+		func(ctx context.Context) (*response.GetResponse, error) {
+			return fc.fallback.GetMetricNamesWithPrefixContext(ctx, prefix)
+		})
+}
+
+// Returns a list of all tag names.
+func (fc *FallbackClient) GetTagNames() (*response.GetResponse, error) {
+	return fc.GetTagNamesContext(context.Background())
+}
+
+// Same as GetTagNames, but the requests are aborted when the context is
+// done.
+func (fc *FallbackClient) GetTagNamesContext(ctx context.Context) (*response.GetResponse, error) {
+	return withFallback(ctx, fc, fc.Client.GetTagNamesContext, fc.fallback.GetTagNamesContext)
+}
+
+// Returns a list of all tag values.
+func (fc *FallbackClient) GetTagValues() (*response.GetResponse, error) {
+	return fc.GetTagValuesContext(context.Background())
+}
+
+// Same as GetTagValues, but the requests are aborted when the context is
+// done.
+func (fc *FallbackClient) GetTagValuesContext(ctx context.Context) (*response.GetResponse, error) {
+	return withFallback(ctx, fc, fc.Client.GetTagValuesContext, fc.fallback.GetTagValuesContext)
+}
+
+// Queries KairosDB using the query built using builder.
+func (fc *FallbackClient) Query(qb builder.QueryBuilder) (*response.QueryResponse, error) {
+	return fc.QueryContext(context.Background(), qb)
+}
+
+// Same as Query, but the requests are aborted when the context is done.
+func (fc *FallbackClient) QueryContext(ctx context.Context, qb builder.QueryBuilder) (*response.QueryResponse, error) {
+	return withFallback(ctx, fc,
+		func(ctx context.Context) (*response.QueryResponse, error) { return fc.Client.QueryContext(ctx, qb) },
+		func(ctx context.Context) (*response.QueryResponse, error) { return fc.fallback.QueryContext(ctx, qb) })
+}
+
+func (fc *FallbackClient) QueryTags(qb builder.QueryBuilder) (*response.QueryResponse, error) {
+	return fc.QueryTagsContext(context.Background(), qb)
+}
+
+// Same as QueryTags, but the requests are aborted when the context is done.
+func (fc *FallbackClient) QueryTagsContext(ctx context.Context, qb builder.QueryBuilder) (*response.QueryResponse, error) {
+	return withFallback(ctx, fc,
+		func(ctx context.Context) (*response.QueryResponse, error) { return fc.Client.QueryTagsContext(ctx, qb) },
+		func(ctx context.Context) (*response.QueryResponse, error) {
+			return fc.fallback.QueryTagsContext(ctx, qb)
+		})
+}
+
+type statusCoder interface {
+	GetStatusCode() int
+}
+
+type fallbackResult[T statusCoder] struct {
+	resp T
+	err  error
+}
+
+// Runs primary and, when it fails or times out as the policy says, fallback.
+// The primary is canceled once its answer is no longer waited for.
+func withFallback[T statusCoder](ctx context.Context, fc *FallbackClient, primary, fallback func(context.Context) (T, error)) (T, error) {
+	primaryCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Buffered so that a primary answering after the timeout does not block.
+	ch := make(chan fallbackResult[T], 1)
+	go func() {
+		resp, err := primary(primaryCtx)
+		ch <- fallbackResult[T]{resp: resp, err: err}
+	}()
+
+	var timeout <-chan time.Time
+	if fc.opts.Timeout > 0 {
+		timer := fc.opts.Clock.NewTimer(fc.opts.Timeout)
+		defer timer.Stop()
+		timeout = timer.C()
+	}
+
+	select {
+	case r := <-ch:
+		code := 0
+		if r.err == nil {
+			code = r.resp.GetStatusCode()
+		}
+
+		if !fc.opts.Policy(r.err, code) {
+			return r.resp, r.err
+		}
+	case <-timeout:
+		cancel()
+		if !fc.opts.Policy(ErrorPrimaryTimeout, 0) {
+			var zero T
+			return zero, ErrorPrimaryTimeout
+		}
+	}
+
+	return fallback(ctx)
+}
@@ -0,0 +1,115 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newNamesServer(code int, body string, delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.WriteHeader(code)
+		w.Write([]byte(body))
+	}))
+}
+
+// Success test.
+func TestFallbackClientPrimaryOK(t *testing.T) {
+	primary := newNamesServer(http.StatusOK, `{"results":["primary"]}`, 0)
+	defer primary.Close()
+	fallback := newNamesServer(http.StatusOK, `{"results":["fallback"]}`, 0)
+	defer fallback.Close()
+
+	fc := NewFallbackClient(NewHttpClient(primary.URL), NewHttpClient(fallback.URL), FallbackOptions{})
+	resp, err := fc.GetMetricNames()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, []string{"primary"}, resp.GetResults(), "Primary results expected")
+}
+
+// Success test.
+func TestFallbackClientPrimaryFails(t *testing.T) {
+	primary := newNamesServer(http.StatusInternalServerError, `{"errors":["boom"]}`, 0)
+	defer primary.Close()
+	fallback := newNamesServer(http.StatusOK, `{"results":["fallback"]}`, 0)
+	defer fallback.Close()
+
+	fc := NewFallbackClient(NewHttpClient(primary.URL), NewHttpClient(fallback.URL), FallbackOptions{})
+	resp, err := fc.GetMetricNames()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, []string{"fallback"}, resp.GetResults(), "Fallback results expected")
+}
+
+// Success test.
+func TestFallbackClientPrimaryTimeout(t *testing.T) {
+	primary := newNamesServer(http.StatusOK, `{"results":["primary"]}`, 200*time.Millisecond)
+	defer primary.Close()
+	fallback := newNamesServer(http.StatusOK, `{"results":["fallback"]}`, 0)
+	defer fallback.Close()
+
+	fc := NewFallbackClient(NewHttpClient(primary.URL), NewHttpClient(fallback.URL), FallbackOptions{Timeout: 20 * time.Millisecond})
+	resp, err := fc.GetMetricNames()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, []string{"fallback"}, resp.GetResults(), "Fallback results expected")
+}
+
+// Success test.
+func TestFallbackClientCancelsPrimary(t *testing.T) {
+	canceled := make(chan struct{})
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		<-r.Context().Done()
+		close(canceled)
+	}))
+	defer primary.Close()
+	fallback := newNamesServer(http.StatusOK, `{"queries":[{"sample_size":1}]}`, 0)
+	defer fallback.Close()
+
+	fc := NewFallbackClient(NewHttpClient(primary.URL), NewHttpClient(fallback.URL), FallbackOptions{Timeout: 20 * time.Millisecond})
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("m1")
+
+	resp, err := fc.QueryContext(context.Background(), qb)
+	assert.Nil(t, err, "No error expected")
+	assert.EqualValues(t, 1, resp.QueriesArr[0].SampleSize, "Fallback results expected")
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("Primary request must be canceled when falling back")
+	}
+}
+
+// Failure test.
+func TestFallbackClientPolicy(t *testing.T) {
+	primary := newNamesServer(http.StatusBadRequest, `{"errors":["bad query"]}`, 0)
+	defer primary.Close()
+	fallback := newNamesServer(http.StatusOK, `{"results":["fallback"]}`, 0)
+	defer fallback.Close()
+
+	fc := NewFallbackClient(NewHttpClient(primary.URL), NewHttpClient(fallback.URL), FallbackOptions{})
+	resp, err := fc.GetMetricNames()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusBadRequest, resp.GetStatusCode(), "Client errors must not fall back")
+}
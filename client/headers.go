@@ -0,0 +1,44 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+type headersKey struct{}
+
+// Returns a copy of the context carrying extra headers for the requests
+// sent with it, e.g. a priority hint or a tenant override for a single
+// query. They are added to the headers set on the previous calls for the
+// same context and override the headers set by the client itself.
+func ContextWithHeaders(ctx context.Context, h http.Header) context.Context {
+	merged := headersFromContext(ctx).Clone()
+	if merged == nil {
+		merged = make(http.Header)
+	}
+
+	for k, vals := range h {
+		merged[http.CanonicalHeaderKey(k)] = append([]string(nil), vals...)
+	}
+
+	return context.WithValue(ctx, headersKey{}, merged)
+}
+
+func headersFromContext(ctx context.Context) http.Header {
+	h, _ := ctx.Value(headersKey{}).(http.Header)
+	return h
+}
@@ -0,0 +1,65 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestContextWithHeaders(t *testing.T) {
+	var headers []http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header.Clone())
+		if r.URL.Path == query_ep {
+			w.Write([]byte(`{"queries":[]}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithTenant("acme", TenantOptions{Header: "X-Tenant"}))
+
+	ctx := ContextWithHeaders(context.Background(), http.Header{"x-priority": {"low"}})
+	ctx = ContextWithHeaders(ctx, http.Header{"X-Tenant": {"other"}})
+
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("m1")
+	_, err := cli.QueryContext(ctx, qb)
+	assert.Nil(t, err, "No error expected")
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddDataPoint(1, 2)
+	_, err = cli.PushMetricsContext(ctx, mb)
+	assert.Nil(t, err, "No error expected")
+
+	_, err = cli.Query(qb)
+	assert.Nil(t, err, "No error expected")
+
+	for _, h := range headers[:2] {
+		assert.Equal(t, "low", h.Get("X-Priority"), "Per call header expected")
+		assert.Equal(t, []string{"other"}, h.Values("X-Tenant"), "Per call header must override the client one")
+	}
+	assert.Equal(t, "", headers[2].Get("X-Priority"), "No per call header expected without the context")
+	assert.Equal(t, "acme", headers[2].Get("X-Tenant"), "Client header expected without the context")
+}
@@ -0,0 +1,120 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// A Client that hedges queries: when the first attempt has not answered
+// after the hedge delay, the same query is sent a second time and the first
+// successful response wins. The slower request is canceled.
+//
+// Without a dedicated hedge client the second attempt goes through the
+// wrapped client, which sends it to the next server of its round robin.
+type HedgedClient struct {
+	Client
+	hedge Client
+	delay time.Duration
+	clock clock.Clock
+}
+
+// Creates a hedging client. hedge may be nil to reuse c for the second
+// attempt.
+func NewHedgedClient(c Client, hedge Client, delay time.Duration) *HedgedClient {
+	if hedge == nil {
+		hedge = c
+	}
+
+	return &HedgedClient{
+		Client: c,
+		hedge:  hedge,
+		delay:  delay,
+		clock:  clockOf(c),
+	}
+}
+
+// Queries KairosDB using the query built using builder.
+func (hc *HedgedClient) Query(qb builder.QueryBuilder) (*response.QueryResponse, error) {
+	return hc.QueryContext(context.Background(), qb)
+}
+
+type hedgeResult struct {
+	resp *response.QueryResponse
+	err  error
+}
+
+func (hr hedgeResult) ok() bool {
+	return hr.err == nil && hr.resp.GetStatusCode() < http.StatusInternalServerError
+}
+
+// Same as Query, but both attempts are aborted when the context is done.
+func (hc *HedgedClient) QueryContext(ctx context.Context, qb builder.QueryBuilder) (*response.QueryResponse, error) {
+	// Build once up front so that both attempts fail fast on a bad query.
+	if _, err := qb.Build(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	// Cancels the attempt that lost the race.
+	defer cancel()
+
+	results := make(chan hedgeResult, 2)
+	attempt := func(c Client) {
+		resp, err := c.QueryContext(ctx, qb)
+		results <- hedgeResult{resp: resp, err: err}
+	}
+
+	go attempt(hc.Client)
+
+	timer := hc.clock.NewTimer(hc.delay)
+	defer timer.Stop()
+
+	inFlight := 1
+	hedged := false
+	var last hedgeResult
+	for inFlight > 0 {
+		select {
+		case <-timer.C():
+			if !hedged {
+				hedged = true
+				inFlight++
+				go attempt(hc.hedge)
+			}
+		case r := <-results:
+			inFlight--
+			if r.ok() {
+				return r.resp, nil
+			}
+			last = r
+
+			// The first attempt failed before the hedge delay expired, try
+			// the other node right away.
+			if !hedged {
+				hedged = true
+				inFlight++
+				go attempt(hc.hedge)
+			}
+		}
+	}
+
+	return last.resp, last.err
+}
@@ -0,0 +1,95 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newQueryServer(sampleSize int, delay time.Duration, canceled chan<- struct{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices a closed connection once the body is read.
+		ioutil.ReadAll(r.Body)
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			if canceled != nil {
+				canceled <- struct{}{}
+			}
+			return
+		}
+		w.Write([]byte(`{"queries":[{"sample_size":` + strconv.Itoa(sampleSize) + `,"results":[]}]}`))
+	}))
+}
+
+func hedgeQuery() builder.QueryBuilder {
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("m1")
+	return qb
+}
+
+// Success test.
+func TestHedgedClientSlowPrimary(t *testing.T) {
+	canceled := make(chan struct{}, 1)
+	slow := newQueryServer(1, 5*time.Second, canceled)
+	defer slow.Close()
+	fast := newQueryServer(2, 0, nil)
+	defer fast.Close()
+
+	hc := NewHedgedClient(NewHttpClient(slow.URL), NewHttpClient(fast.URL), 20*time.Millisecond)
+	start := time.Now()
+	resp, err := hc.Query(hedgeQuery())
+
+	assert.Nil(t, err, "No error expected")
+	assert.EqualValues(t, 2, resp.QueriesArr[0].SampleSize, "Hedge response expected")
+	assert.True(t, time.Since(start) < 500*time.Millisecond, "Hedge must not wait for the slow node")
+
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("Slow request must be canceled")
+	}
+}
+
+// Success test.
+func TestHedgedClientFastPrimary(t *testing.T) {
+	fast := newQueryServer(1, 0, nil)
+	defer fast.Close()
+	hedge := newQueryServer(2, 0, nil)
+	defer hedge.Close()
+
+	hc := NewHedgedClient(NewHttpClient(fast.URL), NewHttpClient(hedge.URL), time.Second)
+	resp, err := hc.Query(hedgeQuery())
+
+	assert.Nil(t, err, "No error expected")
+	assert.EqualValues(t, 1, resp.QueriesArr[0].SampleSize, "Primary response expected")
+}
+
+// Failure test.
+func TestHedgedClientInvalidQuery(t *testing.T) {
+	hc := NewHedgedClient(NewHttpClient("http://localhost:1"), nil, time.Second)
+	_, err := hc.Query(builder.NewQueryBuilder())
+
+	assert.Equal(t, builder.ErrorStartTimeNotSpecified, err, "Build error expected")
+}
@@ -17,11 +17,20 @@ package client
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/clock"
 	"github.com/retoool/go-kairosdb/response"
 )
 
@@ -31,7 +40,9 @@ var (
 	deldatapoints_ep = api_version + "/datapoints/delete"
 	query_ep         = api_version + "/datapoints/query"
 	querytags_ep     = api_version + "/datapoints/query/tags"
+	rollups_ep       = api_version + "/rollups"
 	health_ep        = api_version + "/health/check"
+	healthstatus_ep  = api_version + "/health/status"
 	delmetric_ep     = api_version + "/metric/"
 	metricnames_ep   = api_version + "/metricnames"
 	tagnames_ep      = api_version + "/tagnames"
@@ -41,97 +52,545 @@ var (
 
 // This is the type that implements the Client interface.
 type httpClient struct {
-	serverAddress string
+	httpCli            *http.Client
+	traceHook          func(RequestTrace)
+	debugLog           func(format string, args ...interface{})
+	correlationHeader  string
+	correlationID      func(ctx context.Context) string
+	strictDecoding     bool
+	sortDataPoints     bool
+	numericStrings     bool
+	lenientDecoding    bool
+	onMalformed        func(response.MalformedDataPoint)
+	profile            *Profile
+	codec              Codec
+	compressThreshold  int
+	basePath           string
+	basePathSet        bool
+	sigV4              *sigV4Signer
+	authProvider       AuthProvider
+	signer             Signer
+	tenant             *tenantScope
+	healthStatus       bool
+	autoDecompress     bool
+	pushHooks          []PushHooks
+	retry              *retrier
+	warmUpOpts         *WarmUpOptions
+	slowQueryHook      func(SlowQuery)
+	slowQueryThreshold time.Duration
+	maxResponseSize    int64
+	autoSplit          *AutoSplitOptions
+	clock              clock.Clock
+	headers            http.Header
+	stats              clientStats
+
+	mu              sync.RWMutex // Guards the fields below.
+	serverAddresses []string
+	next            uint32 // Round robin index into serverAddresses.
+	username        string
+	password        string
+	defaultTags     map[string]string // Replaced as a whole, never modified.
 }
 
 func NewHttpClient(serverAddress string) Client {
-	return &httpClient{
-		serverAddress: serverAddress,
+	return NewHttpClientWithOptions(serverAddress)
+}
+
+// Creates a client configured with the given options.
+func NewHttpClientWithOptions(serverAddress string, opts ...Option) Client {
+	hc := &httpClient{
+		httpCli:         &http.Client{},
+		clock:           clock.Real,
+		serverAddresses: []string{serverAddress},
+	}
+
+	for _, opt := range opts {
+		opt(hc)
 	}
+
+	if hc.warmUpOpts != nil {
+		hc.warmUp(*hc.warmUpOpts)
+	}
+
+	return hc
 }
 
 // Returns a list of all metrics names.
 func (hc *httpClient) GetMetricNames() (*response.GetResponse, error) {
-	return hc.get(hc.serverAddress + metricnames_ep)
+	return hc.GetMetricNamesContext(context.Background())
+}
+
+// Same as GetMetricNames, but the request is aborted when the context is
+// done.
+func (hc *httpClient) GetMetricNamesContext(ctx context.Context) (*response.GetResponse, error) {
+	return hc.get(ctx, metricnames_ep)
+}
+
+// Returns a list of the metric names starting with the prefix.
+func (hc *httpClient) GetMetricNamesWithPrefix(prefix string) (*response.GetResponse, error) {
+	return hc.GetMetricNamesWithPrefixContext(context.Background(), prefix)
+}
+
+// Same as GetMetricNamesWithPrefix, but the request is aborted when the
+// context is done.
+func (hc *httpClient) GetMetricNamesWithPrefixContext(ctx context.Context, prefix string) (*response.GetResponse, error) {
+	return hc.get(ctx, metricnames_ep+"?prefix="+url.QueryEscape(prefix))
 }
 
 // Returns a list of all tag names.
 func (hc *httpClient) GetTagNames() (*response.GetResponse, error) {
-	return hc.get(hc.serverAddress + tagnames_ep)
+	return hc.GetTagNamesContext(context.Background())
+}
+
+// Same as GetTagNames, but the request is aborted when the context is done.
+func (hc *httpClient) GetTagNamesContext(ctx context.Context) (*response.GetResponse, error) {
+	return hc.get(ctx, tagnames_ep)
 }
 
 // Returns a list of all tag values.
 func (hc *httpClient) GetTagValues() (*response.GetResponse, error) {
-	return hc.get(hc.serverAddress + tagvalues_ep)
+	return hc.GetTagValuesContext(context.Background())
+}
+
+// Same as GetTagValues, but the request is aborted when the context is done.
+func (hc *httpClient) GetTagValuesContext(ctx context.Context) (*response.GetResponse, error) {
+	return hc.get(ctx, tagvalues_ep)
 }
 
 // Queries KairosDB using the query built using builder.
 func (hc *httpClient) Query(qb builder.QueryBuilder) (*response.QueryResponse, error) {
+	return hc.QueryContext(context.Background(), qb)
+}
+
+// Same as Query, but the request is aborted when the context is done.
+func (hc *httpClient) QueryContext(ctx context.Context, qb builder.QueryBuilder) (*response.QueryResponse, error) {
 	// Get the JSON representation of the query.
 	data, err := qb.Build()
 	if err != nil {
 		return nil, err
 	}
 
-	return hc.postQuery(hc.serverAddress+query_ep, data)
+	data, err = hc.prepareQuery(data)
+	if err != nil {
+		return nil, err
+	}
+
+	hc.stats.queries.Add(1)
+	qr, err := hc.postQuery(ctx, query_ep, data)
+	if hc.autoSplit != nil && hc.shouldSplit(ctx, qr, err) {
+		if split, splitErr := hc.querySplit(ctx, qb); splitErr != errNoSplit {
+			return split, splitErr
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return qr.ApplyAliases(qb), nil
 }
 
 func (hc *httpClient) QueryTags(qb builder.QueryBuilder) (*response.QueryResponse, error) {
+	return hc.QueryTagsContext(context.Background(), qb)
+}
+
+// Same as QueryTags, but the request is aborted when the context is done.
+func (hc *httpClient) QueryTagsContext(ctx context.Context, qb builder.QueryBuilder) (*response.QueryResponse, error) {
 	// Get the JSON representation of the query.
 	data, err := qb.Build()
 	if err != nil {
 		return nil, err
 	}
 
-	return hc.postQuery(hc.serverAddress+querytags_ep, data)
+	data, err = hc.prepareQuery(data)
+	if err != nil {
+		return nil, err
+	}
+
+	hc.stats.queries.Add(1)
+	return hc.postQuery(ctx, querytags_ep, data)
 }
 
 // Sends metrics from the builder to the KairosDB server.
 func (hc *httpClient) PushMetrics(mb builder.MetricBuilder) (*response.Response, error) {
+	return hc.PushMetricsContext(context.Background(), mb)
+}
+
+// Same as PushMetrics, but the request is aborted when the context is done.
+func (hc *httpClient) PushMetricsContext(ctx context.Context, mb builder.MetricBuilder) (*response.Response, error) {
 	data, err := mb.Build()
 	if err != nil {
 		return nil, err
 	}
 
-	return hc.postData(hc.serverAddress+datapoints_ep, data)
+	metrics, dataPoints := countDataPoints(mb)
+	return hc.push(ctx, data, metrics, dataPoints)
+}
+
+// Sends the data point sets to the KairosDB server, see PushDataPointSets.
+func (hc *httpClient) PushDataPointSets(ctx context.Context, sets []builder.DataPointSet) (*response.Response, error) {
+	dataPoints := 0
+	for i := range sets {
+		if err := sets[i].Validate(); err != nil {
+			return nil, err
+		}
+		dataPoints += len(sets[i].DataPoints)
+	}
+
+	data, err := json.Marshal(sets)
+	if err != nil {
+		return nil, err
+	}
+
+	return hc.push(ctx, data, len(sets), dataPoints)
+}
+
+// Pushes encoded metrics, accounting for them in the stats and the hooks.
+func (hc *httpClient) push(ctx context.Context, data []byte, metrics, dataPoints int) (*response.Response, error) {
+	data, err := hc.prepareMetrics(data)
+	if err != nil {
+		return nil, err
+	}
+
+	body, contentType, contentEncoding, err := hc.encodePush(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if hc.retry != nil {
+		ctx = hc.retry.withDedupKey(ctx)
+	}
+
+	start := time.Now()
+	resp, err := hc.postBody(ctx, datapoints_ep, body, contentType, contentEncoding)
+	latency := time.Since(start)
+
+	if err == nil && resp.GetStatusCode() < http.StatusMultipleChoices {
+		hc.stats.pushes.Add(1)
+		hc.stats.dataPoints.Add(int64(dataPoints))
+		hc.stats.pushRawBytes.Add(int64(len(data)))
+		hc.stats.pushBytes.Add(int64(len(body)))
+		hc.stats.pushLatency.Add(int64(latency))
+	}
+	hc.notifyPush(PushInfo{
+		Metrics:    metrics,
+		DataPoints: dataPoints,
+		Bytes:      len(body),
+		RawBytes:   len(data),
+		Latency:    latency,
+		Err:        err,
+	}, resp)
+
+	return resp, err
 }
 
-// Deletes a metric. This is the metric and all its datapoints.
+// Deletes a metric. This is the metric and all its datapoints. A metric
+// unknown to the server is reported with an error wrapping
+// ErrorMetricNotFound and a name rejected by the server with one wrapping
+// ErrorMetricNameRejected. The response is returned along with these errors.
 func (hc *httpClient) DeleteMetric(name string) (*response.Response, error) {
-	return hc.delete(hc.serverAddress + delmetric_ep + name)
+	return hc.DeleteMetricContext(context.Background(), name)
+}
+
+// Same as DeleteMetric, but the request is aborted when the context is done.
+func (hc *httpClient) DeleteMetricContext(ctx context.Context, name string) (*response.Response, error) {
+	if name == "" {
+		return nil, builder.ErrorMetricNameInvalid
+	}
+
+	if hc.tenant != nil && hc.tenant.tag != "" {
+		// Would delete the data of all the tenants.
+		return nil, ErrorTenantDeleteMetric
+	}
+
+	httpResp, err := hc.sendRequest(ctx, delmetric_ep+url.PathEscape(name), "DELETE")
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	contents, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return nil, responseError(httpResp, err)
+	}
+
+	resp := &response.Response{}
+	resp.SetStatusCode(httpResp.StatusCode)
+	if len(bytes.TrimSpace(contents)) > 0 {
+		if err := hc.unmarshal(contents, resp); err != nil && httpResp.StatusCode < http.StatusMultipleChoices {
+			return nil, responseError(httpResp, err)
+		}
+		// Error pages of proxies and servlet containers are not JSON, the
+		// status code is enough for them.
+	}
+
+	switch httpResp.StatusCode {
+	case http.StatusNotFound:
+		return resp, deleteMetricError(ErrorMetricNotFound, name, resp)
+	case http.StatusBadRequest:
+		return resp, deleteMetricError(ErrorMetricNameRejected, name, resp)
+	}
+
+	return resp, nil
+}
+
+func deleteMetricError(err error, name string, resp *response.Response) error {
+	if len(resp.GetErrors()) == 0 {
+		return fmt.Errorf("%w: %s", err, name)
+	}
+
+	return fmt.Errorf("%w: %s: %s", err, name, strings.Join(resp.GetErrors(), "; "))
 }
 
 // Deletes data in KairosDB using the query built by the builder.
 func (hc *httpClient) Delete(qb builder.QueryBuilder) (*response.Response, error) {
+	return hc.DeleteContext(context.Background(), qb)
+}
+
+// Same as Delete, but the request is aborted when the context is done.
+func (hc *httpClient) DeleteContext(ctx context.Context, qb builder.QueryBuilder) (*response.Response, error) {
 	data, err := qb.Build()
 	if err != nil {
 		return nil, err
 	}
 
-	return hc.postData(hc.serverAddress+deldatapoints_ep, data)
+	data, err = hc.prepareQuery(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return hc.postData(ctx, deldatapoints_ep, data)
+}
+
+// Returns the version of the KairosDB server.
+func (hc *httpClient) GetVersion() (*response.VersionResponse, error) {
+	return hc.GetVersionContext(context.Background())
 }
 
-// Checks the health of the KairosDB Server.
-func (hc *httpClient) HealthCheck() (*response.Response, error) {
-	resp, err := hc.sendRequest(hc.serverAddress+health_ep, "GET")
+// Same as GetVersion, but the request is aborted when the context is done.
+func (hc *httpClient) GetVersionContext(ctx context.Context) (*response.VersionResponse, error) {
+	resp, err := hc.sendRequest(ctx, version_ep, "GET")
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
-	r := &response.Response{}
-	r.SetStatusCode(resp.StatusCode)
-	return r, nil
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, responseError(resp, err)
+	}
+
+	vr := response.NewVersionResponse(resp.StatusCode)
+	if err := hc.unmarshal(contents, vr); err != nil {
+		return nil, responseError(resp, err)
+	}
+
+	return vr, nil
+}
+
+// Checks the health of the KairosDB Server. With WithHealthStatus the
+// statuses of the server components are fetched as well.
+func (hc *httpClient) HealthCheck() (*response.HealthResponse, error) {
+	return hc.HealthCheckContext(context.Background())
+}
+
+// Same as HealthCheck, but the request is aborted when the context is done.
+func (hc *httpClient) HealthCheckContext(ctx context.Context) (*response.HealthResponse, error) {
+	endpoint := health_ep
+	if hc.healthStatus {
+		endpoint = healthstatus_ep
+	}
+	if hc.profile != nil && !hc.profile.HealthCheck {
+		// Servers without health endpoint are deemed healthy when they answer.
+		endpoint = version_ep
+	}
+
+	start := time.Now()
+	resp, err := hc.sendRequest(ctx, endpoint, "GET")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	hr := response.NewHealthResponse(resp.StatusCode, time.Since(start))
+	if endpoint != healthstatus_ep || resp.StatusCode >= http.StatusMultipleChoices {
+		return hr, nil
+	}
+
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, responseError(resp, err)
+	}
+
+	var statuses []string
+	if err := hc.unmarshal(contents, &statuses); err != nil {
+		return nil, responseError(resp, err)
+	}
+	hr.SetComponents(statuses)
+
+	return hr, nil
+}
+
+// Changes the address of the KairosDB server. It takes effect for all the
+// requests issued after the call, including those of long lived writers.
+func (hc *httpClient) SetServerAddress(serverAddress string) {
+	hc.SetServerAddresses([]string{serverAddress})
+}
+
+// Replaces the list of KairosDB servers. Requests are spread over the servers
+// in a round robin fashion. An empty list is ignored so that a failed
+// discovery never leaves the client without a server.
+func (hc *httpClient) SetServerAddresses(serverAddresses []string) {
+	if len(serverAddresses) == 0 {
+		return
+	}
+
+	addrs := make([]string, len(serverAddresses))
+	copy(addrs, serverAddresses)
+
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.serverAddresses = addrs
+}
+
+// Returns the list of KairosDB servers currently in use.
+func (hc *httpClient) ServerAddresses() []string {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+
+	addrs := make([]string, len(hc.serverAddresses))
+	copy(addrs, hc.serverAddresses)
+	return addrs
+}
+
+// Changes the credentials used for basic authentication. Passing an empty
+// username disables authentication. It takes effect for all the requests
+// issued after the call.
+func (hc *httpClient) SetCredentials(username, password string) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.username = username
+	hc.password = password
+}
+
+// Returns the path of the endpoint, with the base path applied.
+func (hc *httpClient) endpointPath(endpoint string) string {
+	if hc.basePathSet {
+		return hc.basePath + strings.TrimPrefix(endpoint, api_version)
+	}
+	return endpoint
+}
+
+// Creates a request for the endpoint using the current server address and
+// credentials.
+func (hc *httpClient) newRequest(ctx context.Context, method, endpoint string, body io.Reader) (*http.Request, error) {
+	// Read together, so that a reload never pairs a server with the
+	// credentials of another configuration.
+	hc.mu.RLock()
+	idx := atomic.AddUint32(&hc.next, 1) - 1
+	addr := hc.serverAddresses[idx%uint32(len(hc.serverAddresses))]
+	username, password := hc.username, hc.password
+	hc.mu.RUnlock()
+
+	// Retries of the request go to the next server, see rewind.
+	ctx = context.WithValue(ctx, endpointKey{}, endpoint)
+	return hc.buildRequest(ctx, addr, username, password, method, endpoint, body)
+}
+
+// Creates a request for the endpoint of the server at the given address
+// using the current credentials.
+func (hc *httpClient) newRequestTo(ctx context.Context, addr, method, endpoint string, body io.Reader) (*http.Request, error) {
+	hc.mu.RLock()
+	username, password := hc.username, hc.password
+	hc.mu.RUnlock()
+
+	return hc.buildRequest(ctx, addr, username, password, method, endpoint, body)
+}
+
+func (hc *httpClient) buildRequest(ctx context.Context, addr, username, password, method, endpoint string, body io.Reader) (*http.Request, error) {
+	ctx = withOperation(ctx, method, endpoint)
+	ctx = withRequestClass(ctx, method, endpoint)
+
+	req, err := http.NewRequestWithContext(ctx, method, addr+hc.endpointPath(endpoint), body)
+	if err != nil {
+		return nil, err
+	}
+
+	for k, vals := range hc.headers {
+		req.Header[k] = vals
+	}
+
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	if hc.correlationID != nil {
+		if id := hc.correlationID(ctx); id != "" {
+			req.Header.Set(hc.correlationHeader, id)
+		}
+	}
+
+	if hc.tenant != nil && hc.tenant.header != "" {
+		req.Header.Set(hc.tenant.header, hc.tenant.id)
+	}
+
+	// Per call headers come last so that they override the client ones.
+	for k, vals := range headersFromContext(ctx) {
+		req.Header[k] = vals
+	}
+
+	return req, nil
+}
+
+// Applies the compatibility profile and the tenant scope to an encoded query.
+func (hc *httpClient) prepareQuery(data []byte) ([]byte, error) {
+	if hc.profile != nil {
+		if err := hc.profile.checkQuery(data); err != nil {
+			return nil, err
+		}
+	}
+
+	if hc.tenant != nil {
+		return hc.tenant.scopeQuery(data)
+	}
+
+	return data, nil
 }
 
-func (hc *httpClient) sendRequest(url, method string) (*http.Response, error) {
-	req, err := http.NewRequest(method, url, nil)
+// Applies the compatibility profile, the default tags and the tenant scope
+// to encoded metrics.
+func (hc *httpClient) prepareMetrics(data []byte) ([]byte, error) {
+	if hc.profile != nil {
+		if err := hc.profile.checkMetrics(data); err != nil {
+			return nil, err
+		}
+	}
+
+	hc.mu.RLock()
+	defaultTags := hc.defaultTags
+	hc.mu.RUnlock()
+
+	if len(defaultTags) > 0 {
+		var err error
+		if data, err = addDefaultTags(data, defaultTags); err != nil {
+			return nil, err
+		}
+	}
+
+	if hc.tenant != nil {
+		return hc.tenant.scopeMetrics(data)
+	}
+
+	return data, nil
+}
+
+func (hc *httpClient) sendRequest(ctx context.Context, endpoint, method string) (*http.Response, error) {
+	req, err := hc.newRequest(ctx, method, endpoint, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Add("accept", "application/json")
-	cli := &http.Client{}
 
-	return cli.Do(req)
+	return hc.do(req)
 }
 
 func (hc *httpClient) httpRespToResponse(httpResp *http.Response) (*response.Response, error) {
@@ -145,13 +604,17 @@ func (hc *httpClient) httpRespToResponse(httpResp *http.Response) (*response.Res
 		defer httpResp.Body.Close()
 		switch httpResp.Header.Get("Content-Encoding") {
 		case "gzip":
-			reader, _ := gzip.NewReader(httpResp.Body)
+			var reader *gzip.Reader
+			reader, err = gzip.NewReader(httpResp.Body)
+			if err != nil {
+				return nil, err
+			}
 			contents, err = ioutil.ReadAll(reader)
 			if err != nil {
 				return nil, err
 			} else {
 				// Unmarshal the contents into Response object.
-				err = json.Unmarshal(contents, resp)
+				err = hc.unmarshal(contents, resp)
 				if err != nil {
 					return nil, err
 				}
@@ -162,7 +625,7 @@ func (hc *httpClient) httpRespToResponse(httpResp *http.Response) (*response.Res
 				return nil, err
 			} else {
 				// Unmarshal the contents into Response object.
-				err = json.Unmarshal(contents, resp)
+				err = hc.unmarshal(contents, resp)
 				if err != nil {
 					return nil, err
 				}
@@ -180,13 +643,17 @@ func (hc *httpClient) httpRespToQueryResponse(httpResp *http.Response) (*respons
 	defer httpResp.Body.Close()
 	switch httpResp.Header.Get("Content-Encoding") {
 	case "gzip":
-		reader, _ := gzip.NewReader(httpResp.Body)
-		contents, err = ioutil.ReadAll(reader)
+		var reader *gzip.Reader
+		reader, err = gzip.NewReader(httpResp.Body)
+		if err != nil {
+			return nil, err
+		}
+		contents, err = hc.readQueryBody(reader)
 		if err != nil {
 			return nil, err
 		}
 	default:
-		contents, err = ioutil.ReadAll(httpResp.Body)
+		contents, err = hc.readQueryBody(httpResp.Body)
 		if err != nil {
 			return nil, err
 		}
@@ -195,16 +662,28 @@ func (hc *httpClient) httpRespToQueryResponse(httpResp *http.Response) (*respons
 	qr := response.NewQueryResponse(httpResp.StatusCode)
 
 	// Unmarshal the contents into QueryResponse object.
-	err = json.Unmarshal(contents, qr)
+	if hc.lenientDecoding {
+		err = response.DecodeQueryResponseLenient(contents, qr, hc.onMalformed)
+	} else {
+		err = hc.unmarshal(contents, qr)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	if hc.numericStrings {
+		qr.CoerceNumericStrings()
+	}
+
+	if hc.sortDataPoints {
+		qr.SortDataPoints()
+	}
+
 	return qr, nil
 }
 
-func (hc *httpClient) get(url string) (*response.GetResponse, error) {
-	resp, err := hc.sendRequest(url, "GET")
+func (hc *httpClient) get(ctx context.Context, endpoint string) (*response.GetResponse, error) {
+	resp, err := hc.sendRequest(ctx, endpoint, "GET")
 	if err != nil {
 		return nil, err
 	}
@@ -212,63 +691,99 @@ func (hc *httpClient) get(url string) (*response.GetResponse, error) {
 	defer resp.Body.Close()
 	contents, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, responseError(resp, err)
 	} else {
 		gr := response.NewGetResponse(resp.StatusCode)
 
-		err = json.Unmarshal(contents, gr)
+		err = hc.unmarshal(contents, gr)
 		if err != nil {
-			return nil, err
+			return nil, responseError(resp, err)
 		}
 
 		return gr, nil
 	}
 }
 
-func (hc *httpClient) postData(url string, data []byte) (*response.Response, error) {
-	//var zBuf bytes.Buffer
-	//wzip := gzip.NewWriter(&zBuf)
-	//if _, err := wzip.Write(data); err != nil { }
-	//defer wzip.Close()
-	//resp, err := http.Post(url, "application/json; Accept-Encoding=gzip, deflate", &zBuf)
-	c := http.Client{}
-	resp, err := http.NewRequest("POST", url, bytes.NewReader(data))
+func (hc *httpClient) postData(ctx context.Context, endpoint string, data []byte) (*response.Response, error) {
+	return hc.postBody(ctx, endpoint, data, "application/json", "")
+}
+
+func (hc *httpClient) postBody(ctx context.Context, endpoint string, data []byte, contentType, contentEncoding string) (*response.Response, error) {
+	resp, err := hc.newRequest(ctx, "POST", endpoint, bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
-	resp.Header.Set("Content-Type", "application/json")
-	resp.Header.Set("Accept-Encoding", "gzip, deflate")
-	respDo, err := c.Do(resp)
+	resp.Header.Set("Content-Type", contentType)
+	if contentEncoding != "" {
+		resp.Header.Set("Content-Encoding", contentEncoding)
+	}
+	hc.setAcceptEncoding(resp)
+	respDo, err := hc.do(resp)
 	if err != nil {
 		return nil, err
 	}
 	defer respDo.Body.Close()
 
-	return hc.httpRespToResponse(respDo)
+	r, err := hc.httpRespToResponse(respDo)
+	if err != nil {
+		return nil, responseError(respDo, err)
+	}
+	return r, nil
 }
 
-func (hc *httpClient) postQuery(url string, data []byte) (*response.QueryResponse, error) {
-	c := http.Client{}
-	resp, err := http.NewRequest("POST", url, bytes.NewReader(data))
+func (hc *httpClient) postQuery(ctx context.Context, endpoint string, data []byte) (*response.QueryResponse, error) {
+	if hc.debugLog != nil {
+		var buf bytes.Buffer
+		if json.Indent(&buf, data, "", "  ") == nil {
+			hc.debugLog("kairosdb: POST %s\n%s", hc.endpointPath(endpoint), buf.String())
+		}
+	}
+
+	resp, err := hc.newRequest(ctx, "POST", endpoint, bytes.NewReader(data))
 	if err != nil {
 		return nil, err
 	}
 	resp.Header.Set("Content-Type", "application/json")
-	resp.Header.Set("Accept-Encoding", "gzip, deflate")
-	respDo, err := c.Do(resp)
+	hc.setAcceptEncoding(resp)
+
+	start := time.Now()
+	var size atomic.Int64
+	respDo, err := hc.do(resp)
 	if err != nil {
+		hc.logSlowQuery(endpoint, data, start, &size, 0, err)
 		return nil, err
 	}
 	defer respDo.Body.Close()
 
-	return hc.httpRespToQueryResponse(respDo)
+	if hc.slowQueryHook != nil {
+		respDo.Body = &countingBody{ReadCloser: respDo.Body, n: &size}
+	}
+
+	if hc.autoSplit != nil && respDo.StatusCode == http.StatusGatewayTimeout {
+		// Gateways answer with error pages that are not JSON, the status is
+		// all the splitting needs.
+		io.Copy(ioutil.Discard, respDo.Body)
+		hc.logSlowQuery(endpoint, data, start, &size, respDo.StatusCode, nil)
+		return response.NewQueryResponse(respDo.StatusCode), nil
+	}
+
+	qr, err := hc.httpRespToQueryResponse(respDo)
+	if err != nil {
+		err = responseError(respDo, err)
+	}
+	hc.logSlowQuery(endpoint, data, start, &size, respDo.StatusCode, err)
+	return qr, err
 }
 
-func (hc *httpClient) delete(url string) (*response.Response, error) {
-	resp, err := hc.sendRequest(url, "DELETE")
+func (hc *httpClient) delete(ctx context.Context, endpoint string) (*response.Response, error) {
+	resp, err := hc.sendRequest(ctx, endpoint, "DELETE")
 	if err != nil {
 		return nil, err
 	}
 
-	return hc.httpRespToResponse(resp)
+	dr, err := hc.httpRespToResponse(resp)
+	if err != nil {
+		return nil, responseError(resp, err)
+	}
+	return dr, nil
 }
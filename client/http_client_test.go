@@ -0,0 +1,183 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/response"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestSetServerAddress(t *testing.T) {
+	hits := make(map[string]int)
+	var mu sync.Mutex
+	handler := func(name string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			hits[name]++
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+
+	blue := httptest.NewServer(handler("blue"))
+	defer blue.Close()
+	green := httptest.NewServer(handler("green"))
+	defer green.Close()
+
+	cli := NewHttpClient(blue.URL)
+	_, err := cli.HealthCheck()
+	assert.Nil(t, err, "No error expected")
+
+	cli.SetServerAddress(green.URL)
+	_, err = cli.HealthCheck()
+	assert.Nil(t, err, "No error expected")
+
+	assert.Equal(t, 1, hits["blue"], "First request must go to the initial server")
+	assert.Equal(t, 1, hits["green"], "Second request must go to the new server")
+}
+
+// Success test.
+func TestSetCredentials(t *testing.T) {
+	var user, pass string
+	var authSet bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, authSet = r.BasicAuth()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClient(srv.URL)
+	cli.HealthCheck()
+	assert.False(t, authSet, "No credentials expected by default")
+
+	cli.SetCredentials("admin", "secret")
+	cli.HealthCheck()
+	assert.True(t, authSet, "Credentials must be sent once set")
+	assert.Equal(t, "admin", user, "Username must match")
+	assert.Equal(t, "secret", pass, "Password must match")
+
+	cli.SetCredentials("admin", "rotated")
+	cli.HealthCheck()
+	assert.Equal(t, "rotated", pass, "Rotated password must be used")
+
+	cli.SetCredentials("", "")
+	cli.HealthCheck()
+	assert.False(t, authSet, "Empty username must disable authentication")
+}
+
+// Success test.
+func TestWithBasePath(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	NewHttpClientWithOptions(srv.URL, WithBasePath("kairos/api/v1/")).HealthCheck()
+	NewHttpClientWithOptions(srv.URL, WithBasePath("")).DeleteMetric("m1")
+	NewHttpClient(srv.URL).HealthCheck()
+
+	assert.Equal(t, []string{"/kairos/api/v1/health/check", "/metric/m1", "/api/v1/health/check"}, paths,
+		"Base path must replace the API prefix")
+}
+
+// Success test.
+func TestDeleteMetric(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.EscapedPath())
+		switch r.URL.Path {
+		case "/api/v1/metric/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte("<html>Not Found</html>"))
+		case "/api/v1/metric/bad name":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":["Invalid metric name"]}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClient(srv.URL)
+
+	resp, err := cli.DeleteMetric("a/b?c")
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode())
+	assert.Equal(t, "/api/v1/metric/a%2Fb%3Fc", paths[0], "Metric name must be escaped")
+
+	// Failures are reported with typed errors.
+	resp, err = cli.DeleteMetric("missing")
+	assert.True(t, errors.Is(err, ErrorMetricNotFound), "Metric not found expected")
+	assert.Equal(t, http.StatusNotFound, resp.GetStatusCode())
+
+	resp, err = cli.DeleteMetric("bad name")
+	assert.True(t, errors.Is(err, ErrorMetricNameRejected), "Metric name rejected expected")
+	assert.Contains(t, err.Error(), "Invalid metric name", "Server message expected")
+
+	_, err = cli.DeleteMetric("")
+	assert.Equal(t, builder.ErrorMetricNameInvalid, err, "Empty name must be rejected")
+	assert.Len(t, paths, 3, "Empty name must not be sent")
+}
+
+// Success test.
+func TestHealthCheckStatus(t *testing.T) {
+	status := `["JVM-Thread-Deadlock: OK","Datastore-Query: OK"]`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, healthstatus_ep, r.URL.Path, "Status endpoint expected")
+		w.Write([]byte(status))
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithHealthStatus())
+
+	resp, err := cli.HealthCheck()
+	assert.Nil(t, err, "No error expected")
+	assert.True(t, resp.IsHealthy(), "Server must be healthy")
+	assert.Len(t, resp.Components, 2, "Two components expected")
+	assert.Equal(t, "Datastore-Query", resp.Components[1].Name)
+	assert.True(t, resp.Latency > 0, "Latency must be measured")
+
+	// A failing component makes the server unhealthy.
+	status = `["JVM-Thread-Deadlock: OK","Datastore-Query: FAIL"]`
+	resp, err = cli.HealthCheck()
+	assert.Nil(t, err, "No error expected")
+	assert.False(t, resp.IsHealthy(), "Server must be unhealthy")
+	assert.Equal(t, []response.ComponentStatus{{Name: "Datastore-Query", Status: "FAIL"}}, resp.UnhealthyComponents())
+}
+
+// Success test.
+func TestQueryAliases(t *testing.T) {
+	srv := newNamesServer(http.StatusOK, `{"queries":[{"results":[{"name":"m1"}]},{"results":[{"name":"m1"}]}]}`, 0)
+	defer srv.Close()
+
+	qb := hedgeQuery()
+	qb.Metrics()[0].SetAlias("first")
+	qb.AddMetric("m1").SetAlias("second")
+
+	qr, err := NewHttpClient(srv.URL).Query(qb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, "first", qr.QueriesArr[0].ResultsArr[0].Alias, "Alias of the first metric expected")
+	assert.Equal(t, "second", qr.QueriesArr[1].ResultsArr[0].Alias, "Alias of the second metric expected")
+}
@@ -0,0 +1,126 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/retoool/go-kairosdb/clock"
+)
+
+// Options of the IdlePinger.
+type IdlePingOptions struct {
+	// How long the client may stay without any request before it is
+	// pinged. Defaults to 30 seconds, below the idle timeout of most NAT
+	// gateways and load balancers.
+	Interval time.Duration
+
+	// Invoked when a ping fails or reports the server unhealthy. May be
+	// nil.
+	OnError func(error)
+
+	// Paces the pings. Defaults to the wall clock.
+	Clock clock.Clock
+}
+
+// Sends health checks on a client that has been idle for a while, so that
+// NAT and proxy sessions are kept alive and a dead server is noticed before
+// the next real request fails. The pings go through the client, i.e. to the
+// next server of its round robin, and count in its Stats.
+type IdlePinger struct {
+	client  Admin
+	opts    IdlePingOptions
+	healthy atomic.Bool
+	cancel  context.CancelFunc
+	done    chan struct{}
+	once    sync.Once
+}
+
+// Starts pinging the client whenever it has sent no request for the
+// interval, until Stop is called.
+func StartIdlePings(c Admin, opts IdlePingOptions) *IdlePinger {
+	if opts.Interval <= 0 {
+		opts.Interval = 30 * time.Second
+	}
+	opts.Clock = clock.OrReal(opts.Clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ip := &IdlePinger{
+		client: c,
+		opts:   opts,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	ip.healthy.Store(true)
+
+	go ip.run(ctx)
+	return ip
+}
+
+// Returns whether the last ping succeeded. True until the first ping.
+func (ip *IdlePinger) Healthy() bool {
+	return ip.healthy.Load()
+}
+
+// Sends a health check right away.
+func (ip *IdlePinger) Ping() error {
+	hr, err := ip.client.HealthCheck()
+	if err == nil && !hr.IsHealthy() {
+		err = fmt.Errorf("%w: status %d", ErrorPingUnhealthy, hr.GetStatusCode())
+	}
+
+	ip.healthy.Store(err == nil)
+	if err != nil && ip.opts.OnError != nil {
+		ip.opts.OnError(err)
+	}
+	return err
+}
+
+// Stops the pings and waits for the one in flight, if any.
+func (ip *IdlePinger) Stop() {
+	ip.once.Do(func() {
+		ip.cancel()
+		<-ip.done
+	})
+}
+
+func (ip *IdlePinger) run(ctx context.Context) {
+	defer close(ip.done)
+
+	// The client was idle over the last interval when its request counter
+	// did not move.
+	last := ip.client.Stats().Requests
+	for {
+		timer := ip.opts.Clock.NewTimer(ip.opts.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		if requests := ip.client.Stats().Requests; requests != last {
+			last = requests
+			continue
+		}
+
+		ip.Ping()
+		last = ip.client.Stats().Requests
+	}
+}
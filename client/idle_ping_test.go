@@ -0,0 +1,107 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPingServer(status int, pings *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == health_ep {
+			atomic.AddInt32(pings, 1)
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"version": "KairosDB 1.3.0"}`))
+	}))
+}
+
+// Success test.
+func TestIdlePinger(t *testing.T) {
+	var pings int32
+	srv := newPingServer(http.StatusNoContent, &pings)
+	defer srv.Close()
+
+	ip := StartIdlePings(NewHttpClient(srv.URL), IdlePingOptions{Interval: 10 * time.Millisecond})
+	defer ip.Stop()
+
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&pings) >= 2 }, time.Second, 5*time.Millisecond,
+		"Idle client must be pinged")
+	assert.True(t, ip.Healthy())
+}
+
+// Success test.
+func TestIdlePingerBusyClient(t *testing.T) {
+	var pings int32
+	srv := newPingServer(http.StatusNoContent, &pings)
+	defer srv.Close()
+
+	cli := NewHttpClient(srv.URL)
+	ip := StartIdlePings(cli, IdlePingOptions{Interval: 100 * time.Millisecond})
+
+	for deadline := time.Now().Add(300 * time.Millisecond); time.Now().Before(deadline); {
+		cli.GetVersion()
+		time.Sleep(5 * time.Millisecond)
+	}
+	ip.Stop()
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&pings), "Busy client must not be pinged")
+}
+
+// Failure test.
+func TestIdlePingerUnhealthy(t *testing.T) {
+	var pings int32
+	srv := newPingServer(http.StatusInternalServerError, &pings)
+	defer srv.Close()
+
+	errs := make(chan error, 10)
+	ip := StartIdlePings(NewHttpClient(srv.URL), IdlePingOptions{
+		Interval: time.Hour,
+		OnError: func(err error) {
+			errs <- err
+		},
+	})
+	defer ip.Stop()
+
+	assert.ErrorIs(t, ip.Ping(), ErrorPingUnhealthy)
+	assert.ErrorIs(t, <-errs, ErrorPingUnhealthy)
+	assert.False(t, ip.Healthy())
+}
+
+// Success test.
+func TestIdlePingerClock(t *testing.T) {
+	var pings int32
+	srv := newPingServer(http.StatusNoContent, &pings)
+	defer srv.Close()
+
+	clk := clock.NewFake(time.Now())
+	ip := StartIdlePings(NewHttpClient(srv.URL), IdlePingOptions{Interval: time.Minute, Clock: clk})
+	defer ip.Stop()
+
+	clk.BlockUntil(1)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&pings), "No ping before the interval expected")
+
+	clk.Advance(time.Minute)
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&pings) == 1 }, time.Second, 5*time.Millisecond,
+		"Ping after the interval expected")
+}
@@ -0,0 +1,84 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Returns the newest n data points of every series of the metric matching
+// the tags, i.e. its current values, newest first. There is one result per
+// series, carrying its tags. The series are found with a tags query and
+// grouped by all their tags, so that the descending order and the limit
+// apply to each of them.
+func GetLatest(ctx context.Context, r MetricReader, metric string, tags map[string]string, n int) ([]response.Results, error) {
+	if n <= 0 {
+		return nil, ErrorLatestCount
+	}
+
+	// The whole history: KairosDB reads from the end in descending order
+	// and stops at the limit.
+	tr := builder.TimeRange{Start: time.UnixMilli(1), End: clockOf(r).Now()}
+	names, err := NewTagLister(r).ListTags(ctx, metric, tr)
+	if err != nil {
+		return nil, err
+	}
+
+	qb := builder.NewQueryBuilder().SetAbsoluteStart(tr.Start)
+	qm := qb.AddMetric(metric).SetOrder(builder.DESCENDING).SetLimit(n)
+	for k, v := range tags {
+		qm.AddTag(k, []string{v})
+	}
+
+	if len(names) > 0 {
+		groupBy := make([]string, 0, len(names))
+		for name := range names {
+			groupBy = append(groupBy, name)
+		}
+		sort.Strings(groupBy)
+		qm.AddGrouper(builder.CreateTagsGroupBy(groupBy))
+	}
+
+	qr, err := r.QueryContext(ctx, qb)
+	if err != nil {
+		return nil, err
+	}
+
+	if qr.GetStatusCode() >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("%w: status %d: %v", ErrorLatestQuery, qr.GetStatusCode(), qr.GetErrors())
+	}
+
+	var latest []response.Results
+	for _, q := range qr.QueriesArr {
+		for _, res := range q.ResultsArr {
+			if len(res.DataPoints) == 0 {
+				continue
+			}
+			if len(res.DataPoints) > n {
+				res.DataPoints = res.DataPoints[:n]
+			}
+			latest = append(latest, res)
+		}
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestGetLatest(t *testing.T) {
+	var query string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case querytags_ep:
+			w.Write([]byte(`{"queries":[{"results":[{"name":"cpu","tags":{"host":["h1","h2"],"dc":["eu"]}}]}]}`))
+		case query_ep:
+			body, _ := ioutil.ReadAll(r.Body)
+			query = string(body)
+			w.Write([]byte(`{"queries":[{"results":[
+				{"name":"cpu","tags":{"host":["h1"],"dc":["eu"]},"values":[[3,3],[2,2],[1,1]]},
+				{"name":"cpu","tags":{"host":["h2"],"dc":["eu"]},"values":[]}
+			]}]}`))
+		}
+	}))
+	defer srv.Close()
+
+	latest, err := GetLatest(context.Background(), NewHttpClient(srv.URL), "cpu", map[string]string{"dc": "eu"}, 2)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"start_absolute":1,"metrics":[{"name":"cpu","tags":{"dc":["eu"]},"limit":2,"order":"desc",
+		"group_by":[{"name":"tag","tags":["dc","host"]}]}]}`, query)
+
+	assert.Len(t, latest, 1, "Series without data points must be left out")
+	assert.Len(t, latest[0].DataPoints, 2, "The results must be trimmed to n")
+	assert.Equal(t, int64(3), latest[0].DataPoints[0].Timestamp())
+}
+
+// Failure test.
+func TestGetLatestErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == querytags_ep {
+			w.Write([]byte(`{"queries":[]}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors":["bad"]}`))
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClient(srv.URL)
+	_, err := GetLatest(context.Background(), cli, "cpu", nil, 0)
+	assert.ErrorIs(t, err, ErrorLatestCount)
+
+	_, err = GetLatest(context.Background(), cli, "cpu", nil, 1)
+	assert.ErrorIs(t, err, ErrorLatestQuery)
+}
@@ -0,0 +1,92 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The prefixes the metric names are listed by when no partitions are given:
+// the lower and upper case letters and the digits. Names starting with any
+// other character are not listed.
+var DefaultNamePartitions = namePartitions()
+
+func namePartitions() []string {
+	var ps []string
+	for _, r := range [][2]rune{{'a', 'z'}, {'A', 'Z'}, {'0', '9'}} {
+		for c := r[0]; c <= r[1]; c++ {
+			ps = append(ps, string(c))
+		}
+	}
+	return ps
+}
+
+// Options of ListMetricNames.
+type NameListOptions struct {
+	// Prefixes the names are retrieved by, one request each. Defaults to
+	// DefaultNamePartitions. Overlapping prefixes list the same names
+	// several times.
+	Partitions []string
+}
+
+// Lists the metric names in batches, one per prefix partition, since a
+// single GetMetricNames on a large install can exceed the response size
+// limits of proxies. fn is called with the names of every partition as they
+// are retrieved, empty partitions are skipped. An error returned by fn stops
+// the listing and is returned as is.
+//
+// Names not starting with the prefix are dropped, in case the server ignores
+// the prefix.
+func ListMetricNames(ctx context.Context, c MetricReader, opts NameListOptions, fn func(prefix string, names []string) error) error {
+	partitions := opts.Partitions
+	if len(partitions) == 0 {
+		partitions = DefaultNamePartitions
+	}
+
+	for _, prefix := range partitions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		resp, err := c.GetMetricNamesWithPrefix(prefix)
+		if err != nil {
+			return err
+		}
+
+		if resp.GetStatusCode() >= http.StatusMultipleChoices {
+			return fmt.Errorf("%w: prefix %q: status %d: %v", ErrorMetricNamesQuery, prefix, resp.GetStatusCode(), resp.GetErrors())
+		}
+
+		var names []string
+		for _, name := range resp.GetResults() {
+			if strings.HasPrefix(name, prefix) {
+				names = append(names, name)
+			}
+		}
+
+		if len(names) == 0 {
+			continue
+		}
+
+		if err := fn(prefix, names); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Serves the metric names starting with the prefix parameter, or all of them
+// when ignorePrefix is set, like servers predating the parameter.
+func newPrefixServer(names []string, ignorePrefix bool, prefixes *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := r.URL.Query().Get("prefix")
+		*prefixes = append(*prefixes, prefix)
+
+		results := []string{}
+		for _, n := range names {
+			if ignorePrefix || strings.HasPrefix(n, prefix) {
+				results = append(results, n)
+			}
+		}
+		json.NewEncoder(w).Encode(map[string][]string{"results": results})
+	}))
+}
+
+// Success test.
+func TestListMetricNames(t *testing.T) {
+	names := []string{"cpu.idle", "cpu.user", "Disk", "9p", "mem", "_internal"}
+
+	for _, ignorePrefix := range []bool{false, true} {
+		var prefixes []string
+		srv := newPrefixServer(names, ignorePrefix, &prefixes)
+
+		got := map[string][]string{}
+		err := ListMetricNames(context.Background(), NewHttpClient(srv.URL), NameListOptions{}, func(prefix string, ns []string) error {
+			got[prefix] = ns
+			return nil
+		})
+		srv.Close()
+
+		assert.Nil(t, err, "No error expected")
+		assert.Equal(t, DefaultNamePartitions, prefixes, "One request per partition expected")
+		assert.Equal(t, map[string][]string{
+			"c": {"cpu.idle", "cpu.user"},
+			"D": {"Disk"},
+			"9": {"9p"},
+			"m": {"mem"},
+		}, got, "Names by partition expected")
+	}
+}
+
+// Success test.
+func TestListMetricNamesPartitions(t *testing.T) {
+	var prefixes []string
+	srv := newPrefixServer([]string{"app.a", "app.b", "sys.a"}, false, &prefixes)
+	defer srv.Close()
+
+	var got []string
+	err := ListMetricNames(context.Background(), NewHttpClient(srv.URL), NameListOptions{Partitions: []string{"app.", "sys."}}, func(prefix string, ns []string) error {
+		got = append(got, ns...)
+		return nil
+	})
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, []string{"app.", "sys."}, prefixes, "Custom partitions expected")
+	assert.Equal(t, []string{"app.a", "app.b", "sys.a"}, got, "All names expected")
+}
+
+// Failure test.
+func TestListMetricNamesErrors(t *testing.T) {
+	errSrv := newNamesServer(http.StatusInternalServerError, `{"errors":["boom"]}`, 0)
+	defer errSrv.Close()
+
+	err := ListMetricNames(context.Background(), NewHttpClient(errSrv.URL), NameListOptions{}, func(string, []string) error { return nil })
+	assert.True(t, errors.Is(err, ErrorMetricNamesQuery), "Status error expected")
+
+	var prefixes []string
+	srv := newPrefixServer([]string{"a", "b"}, false, &prefixes)
+	defer srv.Close()
+
+	stop := errors.New("stop")
+	err = ListMetricNames(context.Background(), NewHttpClient(srv.URL), NameListOptions{}, func(string, []string) error { return stop })
+	assert.Equal(t, stop, err, "Callback error expected")
+	assert.Equal(t, []string{"a"}, prefixes, "Listing must stop at the callback error")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = ListMetricNames(ctx, NewHttpClient(srv.URL), NameListOptions{}, func(string, []string) error { return nil })
+	assert.Equal(t, context.Canceled, err, "Context error expected")
+}
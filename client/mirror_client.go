@@ -0,0 +1,178 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Options controlling how batches are replicated to the mirror cluster.
+type MirrorOptions struct {
+	// Number of batches that can wait for the mirror. When the queue is full
+	// new batches are dead-lettered right away. Defaults to 1000.
+	QueueSize int
+
+	// Number of times a batch is sent to the mirror before giving up.
+	// Defaults to 3.
+	MaxAttempts int
+
+	// Delay before the first retry, doubled for every subsequent one.
+	// Defaults to 100ms.
+	Backoff time.Duration
+
+	// Invoked with every batch the mirror could not store. May be nil.
+	DeadLetter func(mb builder.MetricBuilder, err error)
+
+	// Paces the retries. Defaults to the wall clock.
+	Clock clock.Clock
+}
+
+// A Client that writes every batch to a primary and a mirror cluster. The
+// primary is written synchronously and decides the outcome of PushMetrics;
+// the mirror is written asynchronously with its own retries. All the other
+// operations only go to the primary.
+type MirrorClient struct {
+	Client
+	mirror  Client
+	opts    MirrorOptions
+	queue   chan builder.MetricBuilder
+	done    chan struct{}
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+// Creates a mirroring client and starts replicating to the mirror. Close
+// must be called to flush the mirror queue.
+func NewMirrorClient(primary, mirror Client, opts MirrorOptions) *MirrorClient {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1000
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+	if opts.Backoff <= 0 {
+		opts.Backoff = 100 * time.Millisecond
+	}
+	opts.Clock = clock.OrReal(opts.Clock)
+
+	mc := &MirrorClient{
+		Client: primary,
+		mirror: mirror,
+		opts:   opts,
+		queue:  make(chan builder.MetricBuilder, opts.QueueSize),
+		done:   make(chan struct{}),
+	}
+	go mc.run()
+
+	return mc
+}
+
+// Sends the metrics to the primary and queues them for the mirror. The
+// builder must not be modified after the call since the mirror reads it
+// asynchronously.
+func (mc *MirrorClient) PushMetrics(mb builder.MetricBuilder) (*response.Response, error) {
+	return mc.PushMetricsContext(context.Background(), mb)
+}
+
+// Same as PushMetrics. The context only applies to the primary, the mirror
+// is written in the background regardless.
+func (mc *MirrorClient) PushMetricsContext(ctx context.Context, mb builder.MetricBuilder) (*response.Response, error) {
+	resp, err := mc.Client.PushMetricsContext(ctx, mb)
+
+	mc.closeMu.RLock()
+	defer mc.closeMu.RUnlock()
+
+	if mc.closed {
+		mc.deadLetter(mb, ErrorMirrorClosed)
+		return resp, err
+	}
+
+	select {
+	case mc.queue <- mb:
+	default:
+		mc.deadLetter(mb, ErrorMirrorQueueFull)
+	}
+
+	return resp, err
+}
+
+// Stops accepting batches for the mirror and waits until the queued ones
+// have been written or dead-lettered.
+func (mc *MirrorClient) Close() {
+	mc.closeMu.Lock()
+	if mc.closed {
+		mc.closeMu.Unlock()
+		return
+	}
+	mc.closed = true
+	close(mc.queue)
+	mc.closeMu.Unlock()
+
+	<-mc.done
+}
+
+func (mc *MirrorClient) run() {
+	defer close(mc.done)
+
+	for mb := range mc.queue {
+		if err := mc.pushMirror(mb); err != nil {
+			mc.deadLetter(mb, err)
+		}
+	}
+}
+
+func (mc *MirrorClient) pushMirror(mb builder.MetricBuilder) error {
+	backoff := mc.opts.Backoff
+
+	var err error
+	for attempt := 1; attempt <= mc.opts.MaxAttempts; attempt++ {
+		var resp *response.Response
+		resp, err = mc.mirror.PushMetrics(mb)
+		if err == nil {
+			code := resp.GetStatusCode()
+			if code < http.StatusMultipleChoices {
+				return nil
+			}
+
+			err = fmt.Errorf("mirror returned status %d: %s", code, strings.Join(resp.GetErrors(), "; "))
+			if code < http.StatusInternalServerError {
+				// The request itself is bad, retrying will not help.
+				return err
+			}
+		}
+
+		if attempt < mc.opts.MaxAttempts {
+			<-mc.opts.Clock.NewTimer(backoff).C()
+			backoff *= 2
+		}
+	}
+
+	return err
+}
+
+func (mc *MirrorClient) deadLetter(mb builder.MetricBuilder, err error) {
+	if mc.opts.DeadLetter != nil {
+		mc.opts.DeadLetter(mb, err)
+	}
+}
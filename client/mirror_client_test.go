@@ -0,0 +1,71 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestMirrorClient(t *testing.T) {
+	primary := newPushRecorder(http.StatusNoContent)
+	defer primary.srv.Close()
+	mirror := newPushRecorder(http.StatusNoContent)
+	defer mirror.srv.Close()
+
+	mc := NewMirrorClient(NewHttpClient(primary.srv.URL), NewHttpClient(mirror.srv.URL), MirrorOptions{})
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddDataPoint(1, 10)
+	resp, err := mc.PushMetrics(mb)
+	mc.Close()
+
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode(), "Primary status expected")
+	assert.Equal(t, primary.Bodies(), mirror.Bodies(), "Mirror must receive the same batch")
+	assert.Len(t, mirror.Bodies(), 1, "Mirror must receive one batch")
+}
+
+// Failure test.
+func TestMirrorClientDeadLetter(t *testing.T) {
+	primary := newPushRecorder(http.StatusNoContent)
+	defer primary.srv.Close()
+	mirror := newPushRecorder(http.StatusInternalServerError)
+	defer mirror.srv.Close()
+
+	var dead []error
+	mc := NewMirrorClient(NewHttpClient(primary.srv.URL), NewHttpClient(mirror.srv.URL), MirrorOptions{
+		MaxAttempts: 3,
+		Backoff:     time.Millisecond,
+		DeadLetter:  func(mb builder.MetricBuilder, err error) { dead = append(dead, err) },
+	})
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddDataPoint(1, 10)
+	_, err := mc.PushMetrics(mb)
+	mc.Close()
+
+	assert.Nil(t, err, "Mirror failures must not fail the push")
+	assert.Len(t, mirror.Bodies(), 3, "Mirror must be retried")
+	assert.Len(t, dead, 1, "Batch must be dead-lettered once")
+
+	mc.PushMetrics(mb)
+	assert.Equal(t, ErrorMirrorClosed, dead[1], "Pushes after close must be dead-lettered")
+}
@@ -0,0 +1,246 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/retoool/go-kairosdb/clock"
+)
+
+// Configures the client created by NewHttpClientWithOptions.
+type Option func(hc *httpClient)
+
+// Captures the DNS, connect, TLS handshake and time to first byte timings of
+// every request and hands them to hook once the response headers have been
+// received or the request failed. The hook is called synchronously, so it
+// should be fast.
+func WithRequestTrace(hook func(RequestTrace)) Option {
+	return func(hc *httpClient) {
+		hc.traceHook = hook
+	}
+}
+
+// Logs the JSON of every query and tag query before it is sent, indented the
+// way QueryBuilder.DumpIndent prints it, e.g. with log.Printf. It shows what
+// KairosDB actually receives, after the tenant scoping and the profile of
+// the client. Meant for troubleshooting, queries may be large.
+func WithDebugLog(logf func(format string, args ...interface{})) Option {
+	return func(hc *httpClient) {
+		hc.debugLog = logf
+	}
+}
+
+// Uses the clock for the retry backoff instead of the wall clock, e.g. a
+// clock.Fake in tests. The helpers given the client, such as
+// DeleteOlderThan, WatchDiscovery and NewHedgedClient, follow it as well.
+func WithClock(c clock.Clock) Option {
+	return func(hc *httpClient) {
+		hc.clock = clock.OrReal(c)
+	}
+}
+
+// Returns the clock of a client created by this package, see WithClock, and
+// the wall clock for other implementations.
+func clockOf(c interface{}) clock.Clock {
+	switch v := c.(type) {
+	case *httpClient:
+		return v.clock
+	case *readerTagLister:
+		return clockOf(v.c)
+	}
+	return clock.Real
+}
+
+// Bounds every request to KairosDB, including reading the response body.
+// Zero means no timeout. A context deadline still applies on top of it.
+func WithTimeout(d time.Duration) Option {
+	return func(hc *httpClient) {
+		hc.httpCli.Timeout = d
+	}
+}
+
+// Authenticates the requests with the basic authentication credentials, see
+// SetCredentials.
+func WithBasicAuth(username, password string) Option {
+	return func(hc *httpClient) {
+		hc.username = username
+		hc.password = password
+	}
+}
+
+// Adds the headers to every request, e.g. an API key expected by a gateway.
+// The headers set by the client itself and those of ContextWithHeaders take
+// precedence.
+func WithHeaders(h http.Header) Option {
+	return func(hc *httpClient) {
+		if hc.headers == nil {
+			hc.headers = make(http.Header)
+		}
+		for k, vals := range h {
+			hc.headers[http.CanonicalHeaderKey(k)] = append([]string(nil), vals...)
+		}
+	}
+}
+
+// Sends the requests with a copy of the HTTP client, e.g. one whose
+// transport is instrumented by tracing middleware. The timeout, redirect
+// policy and transport set by other options are kept where the client has
+// none of its own. The options tuning the transport, such as WithTLSConfig,
+// modify a copy of the transport of the client when it is an
+// *http.Transport, leaving the caller's untouched, and replace it otherwise.
+func WithHTTPClient(c *http.Client) Option {
+	return func(hc *httpClient) {
+		cli := *c
+		if cli.Timeout == 0 {
+			cli.Timeout = hc.httpCli.Timeout
+		}
+		if cli.CheckRedirect == nil {
+			cli.CheckRedirect = hc.httpCli.CheckRedirect
+		}
+		if cli.Transport == nil {
+			cli.Transport = hc.httpCli.Transport
+		} else if t, ok := cli.Transport.(*http.Transport); ok {
+			// The transport may be shared, e.g. http.DefaultTransport.
+			cli.Transport = t.Clone()
+		}
+		hc.httpCli = &cli
+	}
+}
+
+// Replaces the /api/v1 prefix of the KairosDB endpoints, e.g. with
+// /kairos/api/v1 when a gateway mounts KairosDB under a path. An empty
+// prefix serves the endpoints from the root of the server.
+func WithBasePath(prefix string) Option {
+	return func(hc *httpClient) {
+		hc.basePath = strings.TrimRight(prefix, "/")
+		if hc.basePath != "" && !strings.HasPrefix(hc.basePath, "/") {
+			hc.basePath = "/" + hc.basePath
+		}
+		hc.basePathSet = true
+	}
+}
+
+// Makes HealthCheck query /health/status instead of /health/check, so that
+// the response holds the status of every server component. The server is
+// only reported healthy when all of them are.
+func WithHealthStatus() Option {
+	return func(hc *httpClient) {
+		hc.healthStatus = true
+	}
+}
+
+// Uses the TLS configuration for the connections to KairosDB.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(hc *httpClient) {
+		hc.transport().TLSClientConfig = cfg
+	}
+}
+
+// Opens the connections to KairosDB with the given dial function instead of
+// a plain TCP dialer, e.g. to go through a sidecar proxy.
+func WithDialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) Option {
+	return func(hc *httpClient) {
+		hc.transport().DialContext = dial
+	}
+}
+
+// Connects to KairosDB, or to a sidecar proxy in front of it, over the Unix
+// domain socket at path. The server address still determines the scheme and
+// the Host header of the requests, e.g. "http://localhost".
+func WithUnixSocket(path string) Option {
+	var d net.Dialer
+	return WithDialContext(func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return d.DialContext(ctx, "unix", path)
+	})
+}
+
+// Tuning of the connections to KairosDB. Zero values keep the defaults of
+// http.DefaultTransport.
+type TransportOptions struct {
+	// Maximum number of idle connections across all the servers.
+	MaxIdleConns int
+
+	// Maximum number of idle connections kept per server. The Go default
+	// of 2 is far too low for writers pushing from many goroutines.
+	MaxIdleConnsPerHost int
+
+	// Maximum number of connections per server, including those in use.
+	MaxConnsPerHost int
+
+	// How long an idle connection is kept open.
+	IdleConnTimeout time.Duration
+
+	// Maximum time to establish a connection. It bounds the dial function in
+	// place when the option is applied, so it must come after
+	// WithDialContext or WithUnixSocket.
+	DialTimeout time.Duration
+
+	// Maximum time for the TLS handshake.
+	TLSHandshakeTimeout time.Duration
+}
+
+// Tunes the connection pool and the timeouts of the transport.
+func WithTransportOptions(opts TransportOptions) Option {
+	return func(hc *httpClient) {
+		t := hc.transport()
+		if opts.MaxIdleConns > 0 {
+			t.MaxIdleConns = opts.MaxIdleConns
+		}
+		if opts.MaxIdleConnsPerHost > 0 {
+			t.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+		}
+		if opts.MaxConnsPerHost > 0 {
+			t.MaxConnsPerHost = opts.MaxConnsPerHost
+		}
+		if opts.IdleConnTimeout > 0 {
+			t.IdleConnTimeout = opts.IdleConnTimeout
+		}
+		if opts.TLSHandshakeTimeout > 0 {
+			t.TLSHandshakeTimeout = opts.TLSHandshakeTimeout
+		}
+
+		if opts.DialTimeout > 0 {
+			dial := t.DialContext
+			if dial == nil {
+				dial = (&net.Dialer{}).DialContext
+			}
+
+			t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+				ctx, cancel := context.WithTimeout(ctx, opts.DialTimeout)
+				defer cancel()
+				return dial(ctx, network, addr)
+			}
+		}
+	}
+}
+
+// Returns the transport of the client, replacing the default one by a
+// private copy on first use so that options never alter
+// http.DefaultTransport.
+func (hc *httpClient) transport() *http.Transport {
+	if t, ok := hc.httpCli.Transport.(*http.Transport); ok {
+		return t
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	hc.httpCli.Transport = t
+	return t
+}
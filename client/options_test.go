@@ -0,0 +1,212 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestWithUnixSocket(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "kairosdb.sock")
+	l, err := net.Listen("unix", sock)
+	assert.Nil(t, err, "No error expected")
+
+	var host string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.Listener = l
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := NewHttpClientWithOptions("http://kairosdb", WithUnixSocket(sock)).HealthCheck()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode(), "Health check must go through the socket")
+	assert.Equal(t, "kairosdb", host, "Host must come from the server address")
+}
+
+// Failure test.
+func TestWithUnixSocketMissing(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "missing.sock")
+
+	_, err := NewHttpClientWithOptions("http://kairosdb", WithUnixSocket(sock)).HealthCheck()
+	assert.NotNil(t, err, "Dial error expected")
+}
+
+// Success test.
+func TestWithTransportOptions(t *testing.T) {
+	blockingDial := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	cli := NewHttpClientWithOptions("http://kairosdb", WithDialContext(blockingDial), WithTransportOptions(TransportOptions{
+		MaxIdleConnsPerHost: 64,
+		MaxConnsPerHost:     128,
+		IdleConnTimeout:     time.Minute,
+		DialTimeout:         50 * time.Millisecond,
+	}))
+
+	tr := cli.(*httpClient).transport()
+	assert.Equal(t, 64, tr.MaxIdleConnsPerHost)
+	assert.Equal(t, 128, tr.MaxConnsPerHost)
+	assert.Equal(t, time.Minute, tr.IdleConnTimeout)
+	assert.Equal(t, http.DefaultTransport.(*http.Transport).MaxIdleConns, tr.MaxIdleConns, "Unset values must keep the default")
+
+	start := time.Now()
+	_, err := cli.HealthCheck()
+	assert.True(t, errors.Is(err, context.DeadlineExceeded), "Dial timeout expected")
+	assert.Less(t, time.Since(start), 5*time.Second, "Dial must be bounded")
+}
+
+// Success test.
+func TestWithDebugLog(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"queries":[]}`))
+	}))
+	defer srv.Close()
+
+	var logged []string
+	cli := NewHttpClientWithOptions(srv.URL, WithDebugLog(func(format string, args ...interface{}) {
+		logged = append(logged, fmt.Sprintf(format, args...))
+	}))
+
+	qb := builder.NewQueryBuilder().SetAbsoluteStart(time.Unix(1600000000, 0))
+	qb.AddMetric("cpu")
+	_, err := cli.Query(qb)
+	assert.Nil(t, err, "No error expected")
+
+	assert.Equal(t, []string{"kairosdb: POST /api/v1/datapoints/query\n" + qb.String()}, logged,
+		"The log must show the query as dumped by the builder")
+}
+
+// Success test.
+func TestWithBasicAuthAndHeaders(t *testing.T) {
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL,
+		WithBasicAuth("user", "secret"),
+		WithHeaders(http.Header{"x-api-key": {"k1"}, "X-Priority": {"low"}}))
+
+	ctx := ContextWithHeaders(context.Background(), http.Header{"X-Priority": {"high"}})
+	_, err := cli.HealthCheckContext(ctx)
+	assert.Nil(t, err, "No error expected")
+
+	username, password, ok := (&http.Request{Header: header}).BasicAuth()
+	assert.True(t, ok, "Basic authentication expected")
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "secret", password)
+	assert.Equal(t, "k1", header.Get("X-Api-Key"), "Client header expected")
+	assert.Equal(t, "high", header.Get("X-Priority"), "Per call header must take precedence")
+}
+
+type roundTripperFunc func(r *http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// Success test.
+func TestWithHTTPClient(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	var requests int
+	custom := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		requests++
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+
+	cli := NewHttpClientWithOptions(srv.URL, WithTimeout(time.Second), WithHTTPClient(custom))
+	_, err := cli.HealthCheck()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 1, requests, "Custom transport expected")
+	assert.Equal(t, time.Second, cli.(*httpClient).httpCli.Timeout, "Timeout of the options kept expected")
+	assert.Zero(t, custom.Timeout, "Client of the caller left as is expected")
+}
+
+// Success test.
+func TestWithHTTPClientSharedTransport(t *testing.T) {
+	shared := &http.Transport{}
+	custom := &http.Client{Transport: shared}
+
+	cli := NewHttpClientWithOptions("http://localhost:8080", WithHTTPClient(custom),
+		WithTLSConfig(&tls.Config{ServerName: "kairos"}), WithTransportOptions(TransportOptions{MaxIdleConnsPerHost: 7}))
+	transport := cli.(*httpClient).httpCli.Transport.(*http.Transport)
+	assert.Equal(t, "kairos", transport.TLSClientConfig.ServerName, "TLS config expected")
+	assert.NotEqual(t, "kairos", shared.TLSClientConfig.ServerName, "Transport of the caller left as is expected")
+	assert.Zero(t, shared.MaxIdleConnsPerHost, "Transport of the caller left as is expected")
+	assert.Same(t, shared, custom.Transport)
+
+	NewHttpClientWithOptions("http://localhost:8080", WithHTTPClient(http.DefaultClient), WithTLSConfig(&tls.Config{ServerName: "kairos"}))
+	defaultTLS := http.DefaultTransport.(*http.Transport).TLSClientConfig
+	assert.True(t, defaultTLS == nil || defaultTLS.ServerName == "", "http.DefaultTransport left as is expected")
+}
+
+// Failure test.
+func TestContextVariants(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The server only notices the client going away once the body is read.
+		ioutil.ReadAll(r.Body)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClient(srv.URL)
+	qb := builder.NewQueryBuilder().SetRelativeStart(1, "hours")
+	qb.AddMetric("m1")
+
+	for name, call := range map[string]func(ctx context.Context) error{
+		"GetMetricNames": func(ctx context.Context) error { _, err := cli.GetMetricNamesContext(ctx); return err },
+		"GetMetricNamesWithPrefix": func(ctx context.Context) error {
+			_, err := cli.GetMetricNamesWithPrefixContext(ctx, "m")
+			return err
+		},
+		"GetTagNames":  func(ctx context.Context) error { _, err := cli.GetTagNamesContext(ctx); return err },
+		"GetTagValues": func(ctx context.Context) error { _, err := cli.GetTagValuesContext(ctx); return err },
+		"QueryTags":    func(ctx context.Context) error { _, err := cli.QueryTagsContext(ctx, qb); return err },
+		"DeleteMetric": func(ctx context.Context) error { _, err := cli.DeleteMetricContext(ctx, "m1"); return err },
+		"Delete":       func(ctx context.Context) error { _, err := cli.DeleteContext(ctx, qb); return err },
+		"HealthCheck":  func(ctx context.Context) error { _, err := cli.HealthCheckContext(ctx); return err },
+		"GetVersion":   func(ctx context.Context) error { _, err := cli.GetVersionContext(ctx); return err },
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		err := call(ctx)
+		cancel()
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "%s: deadline error expected, got %v", name, err)
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Options of a paged query.
+type PageOptions struct {
+	// Maximum number of data points asked for per page, see
+	// QueryMetric.SetLimit. Defaults to 10000.
+	Limit int
+
+	// Resolves the relative times of the query. Defaults to the wall clock.
+	Clock clock.Clock
+}
+
+// Iterates over the data points of a query page by page, so that series of
+// any size can be read with bounded memory. Every query metric is paged on
+// its own, in ascending time order: a page is queried with the metric's
+// limit set and the next one starts after the last timestamp received.
+//
+// Since KairosDB applies the limit before the aggregators, paging is meant
+// for raw data points. The data points of a series sharing a millisecond
+// must fit in one page.
+//
+//	it, err := client.QueryPages(cli, qb, client.PageOptions{})
+//	for it.Next(ctx) {
+//		process(it.Metric(), it.Page())
+//	}
+//	err = it.Err()
+type PageIterator struct {
+	c     MetricReader
+	qb    builder.QueryBuilder
+	limit int
+	tr    builder.TimeRange
+
+	metric     int
+	start      time.Time
+	page       *response.QueryResponse
+	pageMetric int
+	err        error
+}
+
+// Creates an iterator over the pages of the query. Relative times are
+// resolved once, against the current time.
+func QueryPages(c MetricReader, qb builder.QueryBuilder, opts PageOptions) (*PageIterator, error) {
+	if _, err := qb.Build(); err != nil {
+		return nil, err
+	}
+
+	tr, err := builder.ResolveTimeRange(qb, clock.OrReal(opts.Clock).Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Limit <= 0 {
+		opts.Limit = 10000
+	}
+
+	return &PageIterator{
+		c:     c,
+		qb:    qb,
+		limit: opts.Limit,
+		tr:    tr,
+		start: tr.Start,
+	}, nil
+}
+
+// Fetches the next page. Returns false when all the pages were read or on
+// error, see Err. Empty pages are skipped.
+func (it *PageIterator) Next(ctx context.Context) bool {
+	it.page = nil
+	if it.err != nil {
+		return false
+	}
+
+	metrics := len(it.qb.Metrics())
+	for it.metric < metrics {
+		if it.start.After(it.tr.End) {
+			it.nextMetric()
+			continue
+		}
+
+		qb, err := builder.PageQuery(it.qb, it.metric, builder.TimeRange{Start: it.start, End: it.tr.End}, it.limit)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		resp, err := it.c.QueryContext(ctx, qb)
+		if err != nil {
+			it.err = err
+			return false
+		}
+
+		if resp.GetStatusCode() >= http.StatusMultipleChoices {
+			it.err = fmt.Errorf("%w: status %d: %v", ErrorPageQuery, resp.GetStatusCode(), resp.GetErrors())
+			return false
+		}
+
+		count, cut := pageCut(resp)
+		if count == 0 {
+			it.nextMetric()
+			continue
+		}
+
+		it.page, it.pageMetric = resp, it.metric
+		if count < it.limit {
+			// The remainder of the range fit in the page.
+			it.nextMetric()
+		} else {
+			it.start = truncatePage(resp, cut)
+		}
+
+		return true
+	}
+
+	return false
+}
+
+// Returns the page read by the last call to Next.
+func (it *PageIterator) Page() *response.QueryResponse {
+	return it.page
+}
+
+// Returns the index of the query metric the current page belongs to.
+func (it *PageIterator) Metric() int {
+	return it.pageMetric
+}
+
+// Returns the error that stopped the iteration, if any.
+func (it *PageIterator) Err() error {
+	return it.err
+}
+
+func (it *PageIterator) nextMetric() {
+	it.metric++
+	it.start = it.tr.Start
+}
+
+// Returns the number of data points of the page and the timestamp up to
+// which all the series are complete: the earliest of the last timestamps of
+// the series. Later data points may have been cut off by the limit, whether
+// the server applies it per series or to the metric as a whole.
+func pageCut(resp *response.QueryResponse) (int, int64) {
+	count := 0
+	var cut int64
+	found := false
+	for _, q := range resp.QueriesArr {
+		for _, r := range q.ResultsArr {
+			n := len(r.DataPoints)
+			if n == 0 {
+				continue
+			}
+
+			count += n
+			if last := r.DataPoints[n-1].Timestamp(); !found || last < cut {
+				cut, found = last, true
+			}
+		}
+	}
+
+	return count, cut
+}
+
+// Drops the data points at or after cut, which the next page reads again,
+// and returns where the next page starts. When that would leave the page
+// empty, the data points at cut are kept and the next page starts after it.
+func truncatePage(resp *response.QueryResponse, cut int64) time.Time {
+	kept := 0
+	for _, q := range resp.QueriesArr {
+		for _, r := range q.ResultsArr {
+			for _, dp := range r.DataPoints {
+				if dp.Timestamp() < cut {
+					kept++
+				}
+			}
+		}
+	}
+
+	next := cut
+	if kept == 0 {
+		next = cut + 1
+	}
+
+	for i := range resp.QueriesArr {
+		results := resp.QueriesArr[i].ResultsArr
+		for j := range results {
+			dps := results[j].DataPoints[:0]
+			for _, dp := range results[j].DataPoints {
+				if dp.Timestamp() < next {
+					dps = append(dps, dp)
+				}
+			}
+			results[j].DataPoints = dps
+		}
+	}
+
+	return time.UnixMilli(next)
+}
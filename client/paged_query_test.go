@@ -0,0 +1,136 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+// Serves two series of the queried metric, applying the time range and the
+// limit of the query to each series the way KairosDB does.
+func newPageServer(series map[string][]int64, queries *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*queries++
+
+		var q struct {
+			Start   int64 `json:"start_absolute"`
+			End     int64 `json:"end_absolute"`
+			Metrics []struct {
+				Name  string `json:"name"`
+				Limit int    `json:"limit"`
+				Order string `json:"order"`
+			} `json:"metrics"`
+		}
+		json.NewDecoder(r.Body).Decode(&q)
+
+		if len(q.Metrics) != 1 || q.Metrics[0].Order != "asc" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":["unexpected query"]}`))
+			return
+		}
+
+		type result struct {
+			Name   string              `json:"name"`
+			Tags   map[string][]string `json:"tags"`
+			Values [][2]int64          `json:"values"`
+		}
+		var results []result
+		for host, points := range series {
+			res := result{Name: q.Metrics[0].Name, Tags: map[string][]string{"host": {host}}}
+			for _, ts := range points {
+				if ts >= q.Start && ts <= q.End && len(res.Values) < q.Metrics[0].Limit {
+					res.Values = append(res.Values, [2]int64{ts, 1})
+				}
+			}
+			results = append(results, res)
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"queries": []interface{}{map[string]interface{}{"results": results}},
+		})
+	}))
+}
+
+// Success test.
+func TestQueryPages(t *testing.T) {
+	series := map[string][]int64{
+		"h1": {1000, 1001, 1002, 1003, 1004, 1005, 1006},
+		"h2": {1000, 1003, 1003, 1009},
+	}
+	var queries int
+	srv := newPageServer(series, &queries)
+	defer srv.Close()
+
+	qb := builder.NewQueryBuilder().SetTimeRange(time.UnixMilli(1000), time.UnixMilli(2000))
+	qb.AddMetric("m1")
+	qb.AddMetric("m2")
+
+	it, err := QueryPages(NewHttpClient(srv.URL), qb, PageOptions{Limit: 3})
+	assert.Nil(t, err, "No error expected")
+
+	got := map[int]map[string][]int64{0: {}, 1: {}}
+	for it.Next(context.Background()) {
+		for _, r := range it.Page().QueriesArr[0].ResultsArr {
+			host := r.Tags["host"][0]
+			for _, dp := range r.DataPoints {
+				got[it.Metric()][host] = append(got[it.Metric()][host], dp.Timestamp())
+			}
+		}
+	}
+	assert.Nil(t, it.Err(), "No error expected")
+	assert.Greater(t, queries, 4, "Several pages per metric expected")
+	assert.Equal(t, series, got[0], "Every data point of the first metric must be read once")
+	assert.Equal(t, series, got[1], "Every data point of the second metric must be read once")
+	assert.Len(t, qb.Metrics(), 2, "Original query must not be modified")
+
+	j, _ := qb.Build()
+	assert.NotContains(t, string(j), "limit", "Original metrics must not be modified")
+}
+
+// Failure test.
+func TestQueryPagesError(t *testing.T) {
+	var queries int
+	srv := newPageServer(nil, &queries)
+	defer srv.Close()
+
+	qb := builder.NewQueryBuilder().SetLookback(time.Hour)
+	qb.AddMetric("m1").SetOrder(builder.DESCENDING)
+
+	// The page query overrides the order of the metric.
+	it, err := QueryPages(NewHttpClient(srv.URL), qb, PageOptions{})
+	assert.Nil(t, err, "No error expected")
+	assert.False(t, it.Next(context.Background()), "No pages expected")
+	assert.Nil(t, it.Err(), "No error expected")
+
+	srv.Close()
+	it, _ = QueryPages(NewHttpClient(srv.URL), qb, PageOptions{})
+	assert.False(t, it.Next(context.Background()), "No pages expected")
+	assert.NotNil(t, it.Err(), "Request error expected")
+
+	errSrv := newNamesServer(http.StatusInternalServerError, `{"errors":["boom"]}`, 0)
+	defer errSrv.Close()
+	it, _ = QueryPages(NewHttpClient(errSrv.URL), qb, PageOptions{})
+	assert.False(t, it.Next(context.Background()), "No pages expected")
+	assert.True(t, errors.Is(it.Err(), ErrorPageQuery), "Page error expected")
+}
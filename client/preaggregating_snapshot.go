@@ -0,0 +1,122 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+type preAggSnapshot struct {
+	IntervalMs int64                  `json:"interval_ms"`
+	Series     []preAggSnapshotSeries `json:"series"`
+
+	// Data points pushed as is, held after a failed push.
+	Passthrough []builder.DataPointSet `json:"passthrough,omitempty"`
+}
+
+type preAggSnapshotSeries struct {
+	Name    string                 `json:"name"`
+	Tags    map[string]string      `json:"tags,omitempty"`
+	TTL     int64                  `json:"ttl,omitempty"`
+	Buckets []preAggSnapshotBucket `json:"buckets"`
+}
+
+type preAggSnapshotBucket struct {
+	Start int64   `json:"start"`
+	Sum   float64 `json:"sum"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Count int64   `json:"count"`
+}
+
+// Pushes the intervals that are over, like a push would, and saves the
+// others to the snapshot file instead of pushing them half filled. Intervals
+// and data points that fail to be pushed are saved as well. Without SnapshotPath, Close is
+// the same as Flush. The writer must not be used afterwards.
+func (pw *PreAggregatingWriter) Close(ctx context.Context) (*response.Response, error) {
+	if pw.opts.SnapshotPath == "" {
+		return pw.Flush(ctx)
+	}
+
+	pw.mu.Lock()
+	closed := pw.take(pw.opts.Clock.Now().Add(-pw.opts.Grace))
+	pw.mu.Unlock()
+
+	resp, err := pw.push(ctx, builder.NewMetricBuilder(), closed)
+
+	pw.mu.Lock()
+	open := pw.take(time.Time{})
+	held := pw.held
+	pw.held = nil
+	pw.mu.Unlock()
+
+	if serr := pw.saveSnapshot(open, held); serr != nil {
+		pw.restore(open, held)
+		return resp, serr
+	}
+
+	return resp, err
+}
+
+// Restores the intervals saved by Close before a restart and removes the
+// snapshot file. A missing file is not an error. The intervals are merged
+// with the data points pushed in the meantime, if any.
+func (pw *PreAggregatingWriter) RestoreSnapshot() error {
+	if pw.opts.SnapshotPath == "" {
+		return nil
+	}
+
+	var snap preAggSnapshot
+	if ok, err := readSnapshot(pw.opts.SnapshotPath, &snap); !ok || err != nil {
+		return err
+	}
+
+	// Buckets are keyed by the start of their interval, they cannot be
+	// split or merged into intervals of another size.
+	if snap.IntervalMs != pw.opts.Interval.Milliseconds() {
+		return fmt.Errorf("%w: %dms instead of %dms", ErrorSnapshotInterval, snap.IntervalMs, pw.opts.Interval.Milliseconds())
+	}
+
+	taken := make(map[string]*preAggSeries, len(snap.Series))
+	for _, ss := range snap.Series {
+		t := &preAggSeries{name: ss.Name, tags: ss.Tags, ttl: ss.TTL, buckets: make(map[int64]*preAggBucket, len(ss.Buckets))}
+		for _, b := range ss.Buckets {
+			t.buckets[b.Start] = &preAggBucket{sum: b.Sum, min: b.Min, max: b.Max, count: b.Count}
+		}
+		taken[preAggKey(ss.Name, ss.Tags)] = t
+	}
+	pw.restore(taken, snap.Passthrough)
+
+	return os.Remove(pw.opts.SnapshotPath)
+}
+
+func (pw *PreAggregatingWriter) saveSnapshot(taken map[string]*preAggSeries, held []builder.DataPointSet) error {
+	snap := preAggSnapshot{IntervalMs: pw.opts.Interval.Milliseconds(), Passthrough: held}
+	for _, t := range taken {
+		ss := preAggSnapshotSeries{Name: t.name, Tags: t.tags, TTL: t.ttl}
+		for start, b := range t.buckets {
+			ss.Buckets = append(ss.Buckets, preAggSnapshotBucket{Start: start, Sum: b.sum, Min: b.min, Max: b.max, Count: b.count})
+		}
+		snap.Series = append(snap.Series, ss)
+	}
+
+	return writeSnapshot(pw.opts.SnapshotPath, snap)
+}
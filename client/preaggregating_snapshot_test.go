@@ -0,0 +1,120 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestPreAggregatingWriterSnapshot(t *testing.T) {
+	var bodies []string
+	srv := newValidatingServer(&bodies)
+	defer srv.Close()
+
+	opts := PreAggregateOptions{
+		Interval:     time.Hour,
+		Aggregation:  PreAggregateSum,
+		SnapshotPath: filepath.Join(t.TempDir(), "preagg.json"),
+	}
+
+	// The interval of the previous hour is over, the current one is not.
+	now := time.Now().Truncate(time.Hour).UnixNano() / int64(time.Millisecond)
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddTTL(60).AddDataPoint(now-1000, 1).AddDataPoint(now+1000, 2)
+
+	pw := NewPreAggregatingWriter(NewHttpClient(srv.URL), opts)
+	assert.Nil(t, pw.RestoreSnapshot(), "A missing snapshot is not an error")
+
+	// Pushes the previous hour.
+	_, err := pw.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+	assert.Len(t, bodies, 1, "One request expected")
+
+	_, err = pw.Close(context.Background())
+	assert.Nil(t, err, "No error expected")
+	assert.Len(t, bodies, 1, "The current interval must not be pushed")
+	_, err = os.Stat(opts.SnapshotPath)
+	assert.Nil(t, err, "Snapshot expected")
+
+	// After the restart the data points of the current interval add up.
+	pw = NewPreAggregatingWriter(NewHttpClient(srv.URL), opts)
+	assert.Nil(t, pw.RestoreSnapshot(), "No error expected")
+	assert.Equal(t, 1, pw.Pending(), "The current interval must be restored")
+	_, err = os.Stat(opts.SnapshotPath)
+	assert.True(t, os.IsNotExist(err), "The snapshot must be removed once restored")
+
+	mb = builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddTTL(60).AddDataPoint(now+2000, 3)
+	pw.PushMetrics(mb)
+
+	_, err = pw.Flush(context.Background())
+	assert.Nil(t, err, "No error expected")
+	assert.Len(t, bodies, 2, "Flush must push the current interval")
+	assert.JSONEq(t, `[{"name":"m1","tags":{"host":"h1"},"ttl":60,"datapoints":[[`+strconv.FormatInt(now, 10)+`,5]]}]`, bodies[1],
+		"Restored and new data points must be aggregated together")
+}
+
+// Failure test.
+func TestPreAggregatingWriterSnapshotInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "preagg.json")
+	assert.Nil(t, ioutil.WriteFile(path, []byte(`{"interval_ms":60000,"series":[]}`), 0600), "No error expected")
+
+	pw := NewPreAggregatingWriter(NewHttpClient("http://localhost:1"), PreAggregateOptions{Interval: time.Hour, SnapshotPath: path})
+	err := pw.RestoreSnapshot()
+	assert.True(t, errors.Is(err, ErrorSnapshotInterval), "Interval mismatch expected")
+
+	_, err = os.Stat(path)
+	assert.Nil(t, err, "The snapshot must be kept")
+}
+
+// Success test.
+func TestPreAggregatingWriterSnapshotPassthrough(t *testing.T) {
+	unavailable := newPushRecorder(http.StatusServiceUnavailable)
+	defer unavailable.srv.Close()
+	ok := newPushRecorder(http.StatusNoContent)
+	defer ok.srv.Close()
+
+	opts := PreAggregateOptions{Interval: time.Hour, SnapshotPath: filepath.Join(t.TempDir(), "preagg.json")}
+	pw := NewPreAggregatingWriter(NewHttpClient(unavailable.srv.URL), opts)
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m2").AddTag("host", "h1").AddDataPoint(1000, "on")
+	_, err := pw.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 1, pw.Pending(), "The passed through point must be kept")
+
+	_, err = pw.Close(context.Background())
+	assert.Nil(t, err, "No error expected")
+
+	pw = NewPreAggregatingWriter(NewHttpClient(ok.srv.URL), opts)
+	assert.Nil(t, pw.RestoreSnapshot(), "No error expected")
+	assert.Equal(t, 1, pw.Pending(), "The passed through point must be restored")
+
+	_, err = pw.Flush(context.Background())
+	assert.Nil(t, err, "No error expected")
+	assert.JSONEq(t, `[{"name":"m2","tags":{"host":"h1"},"datapoints":[[1000,"on"]]}]`, ok.Bodies()[0])
+}
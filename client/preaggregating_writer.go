@@ -0,0 +1,398 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// How the data points of an interval are combined into one.
+type PreAggregation string
+
+const (
+	PreAggregateAvg   PreAggregation = "avg"
+	PreAggregateSum   PreAggregation = "sum"
+	PreAggregateMin   PreAggregation = "min"
+	PreAggregateMax   PreAggregation = "max"
+	PreAggregateCount PreAggregation = "count"
+)
+
+// Options of the PreAggregatingWriter.
+type PreAggregateOptions struct {
+	// Size of the intervals, aligned on the epoch. Defaults to 10 seconds.
+	Interval time.Duration
+
+	// Defaults to PreAggregateAvg.
+	Aggregation PreAggregation
+
+	// How long after the end of an interval late data points are still
+	// accepted before the interval is pushed.
+	Grace time.Duration
+
+	// File the intervals that are not over yet are saved to by Close, to be
+	// restored by RestoreSnapshot after a restart. Empty disables snapshots.
+	SnapshotPath string
+
+	// Tells when intervals are over. Defaults to the wall clock.
+	Clock clock.Clock
+
+	// Receives the aggregated data points of a push abandoned because its
+	// context is done, e.g. to write them aside when a shutdown flush runs
+	// out of time. Nil keeps them to be pushed again later.
+	DeadLetter func(mb builder.MetricBuilder, err error)
+}
+
+type preAggBucket struct {
+	sum, min, max float64
+	count         int64
+}
+
+func (b *preAggBucket) add(v float64) {
+	b.sum += v
+	b.min = math.Min(b.min, v)
+	b.max = math.Max(b.max, v)
+	b.count++
+}
+
+func (b *preAggBucket) merge(o *preAggBucket) {
+	b.sum += o.sum
+	b.min = math.Min(b.min, o.min)
+	b.max = math.Max(b.max, o.max)
+	b.count += o.count
+}
+
+type preAggSeries struct {
+	name    string
+	tags    map[string]string
+	ttl     int64
+	buckets map[int64]*preAggBucket
+}
+
+// A MetricWriter that combines the numeric data points of every series, i.e.
+// metric name and tags, into a single data point per interval stamped with
+// the start of the interval. An interval is pushed with the first push after
+// it is over, or by Flush. Data points of custom types and non numeric values
+// are pushed as is.
+//
+// Pushes return a 204 response without contacting KairosDB when no interval
+// is over yet. Once taken into the intervals, data points belong to the
+// writer: when KairosDB cannot be reached or answers 502, 503 or 504, the
+// intervals and the data points pushed as is are kept and pushed again with
+// the next push or Flush, and PushMetrics returns a 202 response instead of
+// the error, so that retrying callers do not count them twice.
+type PreAggregatingWriter struct {
+	MetricWriter
+	opts PreAggregateOptions
+
+	mu     sync.Mutex // Guards series and held.
+	series map[string]*preAggSeries
+	held   []builder.DataPointSet // Passed through by failed pushes.
+}
+
+func NewPreAggregatingWriter(w MetricWriter, opts PreAggregateOptions) *PreAggregatingWriter {
+	if opts.Interval <= 0 {
+		opts.Interval = 10 * time.Second
+	}
+
+	if opts.Aggregation == "" {
+		opts.Aggregation = PreAggregateAvg
+	}
+	opts.Clock = clock.OrReal(opts.Clock)
+
+	return &PreAggregatingWriter{
+		MetricWriter: w,
+		opts:         opts,
+		series:       make(map[string]*preAggSeries),
+	}
+}
+
+// Sends metrics from the builder to the KairosDB server.
+func (pw *PreAggregatingWriter) PushMetrics(mb builder.MetricBuilder) (*response.Response, error) {
+	return pw.PushMetricsContext(context.Background(), mb)
+}
+
+// Same as PushMetrics, but the request is aborted when the context is done.
+func (pw *PreAggregatingWriter) PushMetricsContext(ctx context.Context, mb builder.MetricBuilder) (*response.Response, error) {
+	passthrough := builder.NewMetricBuilder().SetNonFinitePolicy(mb.GetNonFinitePolicy())
+
+	pw.mu.Lock()
+	// The non finite policy is applied before the values are aggregated.
+	for _, m := range builder.DropDataPoints(mb, nil).GetMetrics() {
+		pw.add(m, passthrough)
+	}
+	closed := pw.take(pw.opts.Clock.Now().Add(-pw.opts.Grace))
+	pw.mu.Unlock()
+
+	resp, err := pw.push(ctx, passthrough, closed)
+	if pushRetryable(ctx, resp, err) {
+		// Kept, or handed to DeadLetter, by push.
+		resp, err = &response.Response{}, nil
+		resp.SetStatusCode(http.StatusAccepted)
+	}
+
+	return resp, err
+}
+
+// Pushes all the intervals, including the ones that are not over yet, e.g.
+// before shutting down. The context bounds how long the flush takes: once it
+// is done the push is abandoned and its intervals are kept, or handed to
+// DeadLetter. Nothing is taken when the context is done already.
+func (pw *PreAggregatingWriter) Flush(ctx context.Context) (*response.Response, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	pw.mu.Lock()
+	all := pw.take(time.Time{})
+	pw.mu.Unlock()
+
+	return pw.push(ctx, builder.NewMetricBuilder(), all)
+}
+
+// Returns the number of intervals waiting to be pushed, each of them
+// standing for one data point, plus the data points pushed as is that are
+// kept after a failed push.
+func (pw *PreAggregatingWriter) Pending() int {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	n := 0
+	for _, s := range pw.series {
+		n += len(s.buckets)
+	}
+	for _, set := range pw.held {
+		n += len(set.DataPoints)
+	}
+	return n
+}
+
+// The writer has no limit, see Backlog.
+func (pw *PreAggregatingWriter) Capacity() int {
+	return 0
+}
+
+func (pw *PreAggregatingWriter) add(m builder.Metric, passthrough builder.MetricBuilder) {
+	if m.GetType() != "" {
+		passthrough.AppendMetrics(m)
+		return
+	}
+
+	var other builder.Metric
+	s := pw.seriesOf(m.GetName(), m.GetTags(), m.GetTTL())
+	intervalMs := pw.opts.Interval.Milliseconds()
+	for _, dp := range m.GetDataPoints() {
+		v, ok := numericValue(dp.Value())
+		if !ok {
+			if other == nil {
+				other = passthrough.AddMetric(m.GetName()).AddTags(m.GetTags()).AddTTL(m.GetTTL())
+			}
+			other.AddDataPoint(dp.Timestamp(), dp.Value())
+			continue
+		}
+
+		start := dp.Timestamp() - dp.Timestamp()%intervalMs
+		if start > dp.Timestamp() {
+			// Before the epoch, the remainder is negative.
+			start -= intervalMs
+		}
+
+		b, ok := s.buckets[start]
+		if !ok {
+			b = &preAggBucket{min: math.Inf(1), max: math.Inf(-1)}
+			s.buckets[start] = b
+		}
+		b.add(v)
+	}
+}
+
+func (pw *PreAggregatingWriter) seriesOf(name string, tags map[string]string, ttl int64) *preAggSeries {
+	key := preAggKey(name, tags)
+	s, ok := pw.series[key]
+	if !ok {
+		s = &preAggSeries{
+			name:    name,
+			tags:    make(map[string]string, len(tags)),
+			buckets: make(map[int64]*preAggBucket),
+		}
+		for k, v := range tags {
+			s.tags[k] = v
+		}
+		pw.series[key] = s
+	}
+
+	// The TTL of the latest data points wins.
+	s.ttl = ttl
+	return s
+}
+
+// Removes the intervals over before the deadline, all of them for a zero
+// deadline, and returns them by series.
+func (pw *PreAggregatingWriter) take(deadline time.Time) map[string]*preAggSeries {
+	intervalMs := pw.opts.Interval.Milliseconds()
+	deadlineMs := deadline.UnixNano() / int64(time.Millisecond)
+
+	taken := make(map[string]*preAggSeries)
+	for key, s := range pw.series {
+		for start, b := range s.buckets {
+			if !deadline.IsZero() && start+intervalMs > deadlineMs {
+				continue
+			}
+
+			t, ok := taken[key]
+			if !ok {
+				t = &preAggSeries{name: s.name, tags: s.tags, ttl: s.ttl, buckets: make(map[int64]*preAggBucket)}
+				taken[key] = t
+			}
+			t.buckets[start] = b
+			delete(s.buckets, start)
+		}
+
+		if len(s.buckets) == 0 {
+			delete(pw.series, key)
+		}
+	}
+
+	return taken
+}
+
+// Puts back intervals and data points pushed as is that could not be pushed.
+func (pw *PreAggregatingWriter) restore(taken map[string]*preAggSeries, sets []builder.DataPointSet) {
+	pw.mu.Lock()
+	defer pw.mu.Unlock()
+
+	pw.held = append(pw.held, sets...)
+
+	for _, t := range taken {
+		s := pw.seriesOf(t.name, t.tags, t.ttl)
+		for start, b := range t.buckets {
+			if cur, ok := s.buckets[start]; ok {
+				cur.merge(b)
+			} else {
+				s.buckets[start] = b
+			}
+		}
+	}
+}
+
+// Pushes the intervals along with the data points passed through and the
+// ones held from failed pushes.
+func (pw *PreAggregatingWriter) push(ctx context.Context, passthrough builder.MetricBuilder, taken map[string]*preAggSeries) (*response.Response, error) {
+	pw.mu.Lock()
+	sets := append(pw.held, dataPointSetsOf(passthrough)...)
+	pw.held = nil
+	pw.mu.Unlock()
+
+	mb := metricBuilderOf(sets).SetNonFinitePolicy(passthrough.GetNonFinitePolicy())
+
+	keys := make([]string, 0, len(taken))
+	for key := range taken {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		t := taken[key]
+		m := mb.AddMetric(t.name).AddTags(t.tags)
+		if t.ttl > 0 {
+			m.AddTTL(t.ttl)
+		}
+
+		starts := make([]int64, 0, len(t.buckets))
+		for start := range t.buckets {
+			starts = append(starts, start)
+		}
+		sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+		for _, start := range starts {
+			m.AddDataPoint(start, pw.value(t.buckets[start]))
+		}
+	}
+
+	if len(mb.GetMetrics()) == 0 {
+		resp := &response.Response{}
+		resp.SetStatusCode(http.StatusNoContent)
+		return resp, nil
+	}
+
+	resp, err := pw.MetricWriter.PushMetricsContext(ctx, mb)
+	if pushRetryable(ctx, resp, err) {
+		if err != nil && ctx.Err() != nil && pw.opts.DeadLetter != nil {
+			pw.opts.DeadLetter(mb, err)
+		} else {
+			pw.restore(taken, sets)
+		}
+	}
+
+	return resp, err
+}
+
+// Tells whether a push failed in a way worth trying again: KairosDB could not
+// be reached or was unavailable, or the push was abandoned.
+func pushRetryable(ctx context.Context, resp *response.Response, err error) bool {
+	return unreachable(resp, err) || (err != nil && ctx.Err() != nil)
+}
+
+func (pw *PreAggregatingWriter) value(b *preAggBucket) interface{} {
+	switch pw.opts.Aggregation {
+	case PreAggregateSum:
+		return b.sum
+	case PreAggregateMin:
+		return b.min
+	case PreAggregateMax:
+		return b.max
+	case PreAggregateCount:
+		return b.count
+	default:
+		return b.sum / float64(b.count)
+	}
+}
+
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func preAggKey(name string, tags map[string]string) string {
+	names := make([]string, 0, len(tags))
+	for k := range tags {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString(name)
+	for _, k := range names {
+		sb.WriteByte(0)
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(tags[k])
+	}
+	return sb.String()
+}
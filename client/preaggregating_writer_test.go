@@ -0,0 +1,181 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestPreAggregatingWriter(t *testing.T) {
+	var bodies []string
+	srv := newValidatingServer(&bodies)
+	defer srv.Close()
+
+	pw := NewPreAggregatingWriter(NewHttpClient(srv.URL), PreAggregateOptions{Aggregation: PreAggregateSum})
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1000, 1).AddDataPoint(9000, 2.5).AddDataPoint(12000, 3)
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(5000, 4).AddDataPoint(now, 5)
+	mb.AddMetric("m2").AddTag("host", "h1").AddDataPoint(1000, "on")
+
+	resp, err := pw.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode(), "Push expected")
+	assert.Len(t, bodies, 1, "One request expected")
+	assert.JSONEq(t, `[
+		{"name":"m2","tags":{"host":"h1"},"datapoints":[[1000,"on"]]},
+		{"name":"m1","tags":{"host":"h1"},"datapoints":[[0,7.5],[10000,3]]}
+	]`, bodies[0], "Closed intervals must be aggregated")
+	assert.Equal(t, 1, pw.Pending(), "The current interval must be pending")
+
+	resp, err = pw.PushMetrics(builder.NewMetricBuilder())
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode(), "Empty push expected")
+	assert.Len(t, bodies, 1, "Nothing to push")
+
+	_, err = pw.Flush(context.Background())
+	assert.Nil(t, err, "No error expected")
+	assert.Len(t, bodies, 2, "Flush must push the current interval")
+	assert.Equal(t, 0, pw.Pending(), "Nothing pending after a flush")
+}
+
+// Failure test.
+func TestPreAggregatingWriterRequestError(t *testing.T) {
+	var bodies []string
+	srv := newValidatingServer(&bodies)
+	url := srv.URL
+	srv.Close()
+
+	pw := NewPreAggregatingWriter(NewHttpClient(url), PreAggregateOptions{
+		Interval:    time.Second,
+		Aggregation: PreAggregateCount,
+	})
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1000, 1).AddDataPoint(1500, 2)
+
+	resp, err := pw.PushMetrics(mb)
+	assert.Nil(t, err, "Accepted points must not be pushed again by the caller")
+	assert.Equal(t, http.StatusAccepted, resp.GetStatusCode())
+	assert.Equal(t, 1, pw.Pending(), "Intervals must be kept for the next push")
+
+	_, err = pw.Flush(context.Background())
+	assert.NotNil(t, err, "Request error expected")
+	assert.Equal(t, 1, pw.Pending(), "Intervals must be kept after a failed flush")
+}
+
+// Failure test.
+func TestPreAggregatingWriterUnavailable(t *testing.T) {
+	unavailable := newPushRecorder(http.StatusServiceUnavailable)
+	defer unavailable.srv.Close()
+	ok := newPushRecorder(http.StatusNoContent)
+	defer ok.srv.Close()
+
+	pw := NewPreAggregatingWriter(NewHttpClient(unavailable.srv.URL), PreAggregateOptions{
+		Interval:    time.Second,
+		Aggregation: PreAggregateCount,
+	})
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1000, 1).AddDataPoint(1500, 2)
+	mb.AddMetric("m2").AddTag("host", "h1").AddDataPoint(1000, "on")
+
+	resp, err := pw.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusAccepted, resp.GetStatusCode())
+	assert.Len(t, unavailable.Bodies(), 1, "One request expected")
+	assert.Equal(t, 2, pw.Pending(), "The interval and the passed through point must be kept")
+
+	pw.MetricWriter = NewHttpClient(ok.srv.URL)
+	resp, err = pw.PushMetrics(builder.NewMetricBuilder())
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode())
+	assert.Equal(t, 0, pw.Pending(), "Nothing pending after the retry")
+	assert.JSONEq(t, `[
+		{"name":"m2","tags":{"host":"h1"},"datapoints":[[1000,"on"]]},
+		{"name":"m1","tags":{"host":"h1"},"datapoints":[[1000,2]]}
+	]`, ok.Bodies()[0], "Kept points must be pushed once")
+}
+
+// Failure test.
+func TestPreAggregatingWriterRejected(t *testing.T) {
+	rejected := newPushRecorder(http.StatusBadRequest)
+	defer rejected.srv.Close()
+
+	pw := NewPreAggregatingWriter(NewHttpClient(rejected.srv.URL), PreAggregateOptions{Interval: time.Second})
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1000, 1)
+
+	resp, err := pw.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusBadRequest, resp.GetStatusCode(), "The rejection must be returned")
+	assert.Equal(t, 0, pw.Pending(), "Rejected points must not be kept")
+}
+
+// Failure test.
+func TestPreAggregatingWriterFlushCancelled(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	var dead []builder.MetricBuilder
+	opts := PreAggregateOptions{Aggregation: PreAggregateSum}
+	pw := NewPreAggregatingWriter(NewHttpClient(srv.URL), opts)
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(time.Now().UnixMilli(), 1)
+	pw.PushMetrics(mb)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := pw.Flush(ctx)
+	assert.ErrorIs(t, err, context.Canceled, "A done context must not flush")
+	assert.Equal(t, 1, pw.Pending(), "Nothing must be taken")
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = pw.Flush(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "The flush must be abandoned")
+	assert.Equal(t, 1, pw.Pending(), "An abandoned flush must be re-queued")
+
+	opts.DeadLetter = func(mb builder.MetricBuilder, err error) { dead = append(dead, mb) }
+	pw = NewPreAggregatingWriter(NewHttpClient(srv.URL), opts)
+	pw.PushMetrics(mb)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err = pw.Flush(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded, "The flush must be abandoned")
+	assert.Equal(t, 0, pw.Pending(), "A dead-lettered flush must not be re-queued")
+	assert.Len(t, dead, 1, "The abandoned data points must be dead-lettered")
+	assert.Equal(t, 1, len(dead[0].GetMetrics()[0].GetDataPoints()))
+}
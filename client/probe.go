@@ -0,0 +1,97 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Backlog is implemented by buffered writers so that the health probes can
+// report on the data points that are still waiting to be sent.
+type Backlog interface {
+	// Returns the number of data points waiting to be flushed.
+	Pending() int
+
+	// Returns the number of pending data points at which the writer is
+	// considered saturated. A value <= 0 means there is no limit.
+	Capacity() int
+}
+
+// Returns an http.Handler suitable for a readiness probe. It responds with
+// 200 when the KairosDB health check succeeds and none of the backlogs are
+// saturated, and with 503 otherwise. The health check is aborted along with
+// the probe request, e.g. when the prober gives up on it.
+func ReadinessHandler(c Admin, backlogs ...Backlog) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp, err := c.HealthCheckContext(r.Context())
+		if err != nil {
+			probeFailed(w, fmt.Sprintf("kairosdb unreachable: %v", err))
+			return
+		}
+
+		if !resp.IsHealthy() {
+			msg := fmt.Sprintf("kairosdb unhealthy: status %d", resp.GetStatusCode())
+			for _, c := range resp.UnhealthyComponents() {
+				msg += fmt.Sprintf(", %s: %s", c.Name, c.Status)
+			}
+			probeFailed(w, msg)
+			return
+		}
+
+		if msg := saturatedBacklog(backlogs); msg != "" {
+			probeFailed(w, msg)
+			return
+		}
+
+		probeOK(w)
+	})
+}
+
+// Returns an http.Handler suitable for a liveness probe. It does not contact
+// KairosDB, since an unreachable server is no reason to restart the embedding
+// service; it only fails with 503 when one of the backlogs is saturated.
+func LivenessHandler(backlogs ...Backlog) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if msg := saturatedBacklog(backlogs); msg != "" {
+			probeFailed(w, msg)
+			return
+		}
+
+		probeOK(w)
+	})
+}
+
+func saturatedBacklog(backlogs []Backlog) string {
+	for _, b := range backlogs {
+		if b.Capacity() > 0 && b.Pending() >= b.Capacity() {
+			return fmt.Sprintf("writer backlog saturated: %d/%d data points pending", b.Pending(), b.Capacity())
+		}
+	}
+
+	return ""
+}
+
+func probeOK(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintln(w, "ok")
+}
+
+func probeFailed(w http.ResponseWriter, msg string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	fmt.Fprintln(w, msg)
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeBacklog struct {
+	pending, capacity int
+}
+
+func (fb *fakeBacklog) Pending() int  { return fb.pending }
+func (fb *fakeBacklog) Capacity() int { return fb.capacity }
+
+func newHealthServer(code int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(code)
+	}))
+}
+
+func probe(h http.Handler) int {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	return rec.Code
+}
+
+// Success test.
+func TestReadinessHandler(t *testing.T) {
+	srv := newHealthServer(http.StatusNoContent)
+	defer srv.Close()
+
+	h := ReadinessHandler(NewHttpClient(srv.URL), &fakeBacklog{pending: 10, capacity: 100})
+	assert.Equal(t, http.StatusOK, probe(h), "Probe must succeed when KairosDB is healthy")
+}
+
+// Failure test.
+func TestReadinessHandlerUnhealthy(t *testing.T) {
+	srv := newHealthServer(http.StatusInternalServerError)
+	defer srv.Close()
+
+	h := ReadinessHandler(NewHttpClient(srv.URL))
+	assert.Equal(t, http.StatusServiceUnavailable, probe(h), "Probe must fail when KairosDB is unhealthy")
+}
+
+// Failure test.
+func TestReadinessHandlerCanceled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	rec := httptest.NewRecorder()
+	ReadinessHandler(NewHttpClient(srv.URL)).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code, "Probe must fail once its request is done")
+}
+
+// Failure test.
+func TestReadinessHandlerSaturated(t *testing.T) {
+	srv := newHealthServer(http.StatusNoContent)
+	defer srv.Close()
+
+	h := ReadinessHandler(NewHttpClient(srv.URL), &fakeBacklog{pending: 100, capacity: 100})
+	assert.Equal(t, http.StatusServiceUnavailable, probe(h), "Probe must fail when the backlog is saturated")
+}
+
+// Success test.
+func TestLivenessHandler(t *testing.T) {
+	assert.Equal(t, http.StatusOK, probe(LivenessHandler(&fakeBacklog{pending: 100})), "No capacity means no limit")
+	assert.Equal(t, http.StatusServiceUnavailable, probe(LivenessHandler(&fakeBacklog{pending: 5, capacity: 5})),
+		"Probe must fail when the backlog is saturated")
+}
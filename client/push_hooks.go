@@ -0,0 +1,98 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Metadata of a push attempt, handed to the push hooks.
+type PushInfo struct {
+	// Number of metrics and data points of the batch.
+	Metrics    int
+	DataPoints int
+
+	// Size of the request body as sent, i.e. after compression.
+	Bytes int
+
+	// Size of the request body before compression, the same as Bytes when
+	// the batch was not compressed.
+	RawBytes int
+
+	// Time taken by the request.
+	Latency time.Duration
+
+	// Status code of the response, zero when the request failed.
+	StatusCode int
+
+	// Error of the request, or nil when the server answered with an error
+	// status.
+	Err error
+}
+
+// Callbacks invoked after every push attempt. A push fails when the request
+// fails or the server answers with an error status. Either callback may be
+// nil. They are called synchronously, so they should be fast.
+type PushHooks struct {
+	OnSuccess func(PushInfo)
+	OnFailure func(PushInfo)
+}
+
+// Registers hooks called after every push, e.g. for custom accounting or
+// alerting. The option may be given several times, the hooks are called in
+// registration order.
+func WithPushHooks(hooks PushHooks) Option {
+	return func(hc *httpClient) {
+		hc.pushHooks = append(hc.pushHooks, hooks)
+	}
+}
+
+// Returns the raw size of the batch over its size as sent, 1 for a batch
+// that was not compressed.
+func (pi PushInfo) CompressionRatio() float64 {
+	if pi.Bytes == 0 {
+		return 1
+	}
+	return float64(pi.RawBytes) / float64(pi.Bytes)
+}
+
+func (hc *httpClient) notifyPush(info PushInfo, resp *response.Response) {
+	failed := info.Err != nil
+	if resp != nil {
+		info.StatusCode = resp.GetStatusCode()
+		failed = failed || info.StatusCode >= http.StatusMultipleChoices
+	}
+
+	for _, h := range hc.pushHooks {
+		if failed && h.OnFailure != nil {
+			h.OnFailure(info)
+		} else if !failed && h.OnSuccess != nil {
+			h.OnSuccess(info)
+		}
+	}
+}
+
+// Returns the number of metrics and data points of the builder.
+func countDataPoints(mb builder.MetricBuilder) (metrics, dataPoints int) {
+	for _, m := range mb.GetMetrics() {
+		metrics++
+		dataPoints += len(m.GetDataPoints())
+	}
+	return metrics, dataPoints
+}
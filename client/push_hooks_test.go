@@ -0,0 +1,68 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestWithPushHooks(t *testing.T) {
+	status := http.StatusNoContent
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		if status != http.StatusNoContent {
+			w.Write([]byte(`{"errors":["busy"]}`))
+		}
+	}))
+	defer srv.Close()
+
+	var ok, failed []PushInfo
+	cli := NewHttpClientWithOptions(srv.URL, WithPushHooks(PushHooks{
+		OnSuccess: func(info PushInfo) { ok = append(ok, info) },
+		OnFailure: func(info PushInfo) { failed = append(failed, info) },
+	}))
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1, 2).AddDataPoint(2, 3)
+	mb.AddMetric("m2").AddTag("host", "h1").AddDataPoint(1, 2)
+	data, _ := mb.Build()
+
+	_, err := cli.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+
+	// Error statuses are failures.
+	status = http.StatusServiceUnavailable
+	_, err = cli.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+
+	assert.Len(t, ok, 1, "One success expected")
+	assert.Equal(t, 2, ok[0].Metrics)
+	assert.Equal(t, 3, ok[0].DataPoints)
+	assert.Equal(t, len(data), ok[0].Bytes)
+	assert.Equal(t, len(data), ok[0].RawBytes)
+	assert.Equal(t, 1.0, ok[0].CompressionRatio())
+	assert.Equal(t, http.StatusNoContent, ok[0].StatusCode)
+	assert.True(t, ok[0].Latency > 0, "Latency must be measured")
+
+	assert.Len(t, failed, 1, "One failure expected")
+	assert.Equal(t, http.StatusServiceUnavailable, failed[0].StatusCode)
+	assert.Nil(t, failed[0].Err, "Error status is not a request error")
+}
@@ -0,0 +1,168 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Implemented by the writers able to push a single data point without
+// going through a MetricBuilder, such as the client of NewHttpClient.
+type PointWriter interface {
+	PushOne(ctx context.Context, metric string, tags map[string]string, ts int64, value float64) (*response.Response, error)
+}
+
+// Pushes a single data point, the timestamp in milliseconds. With a
+// PointWriter the payload is formatted without the builder machinery.
+// Other writers receive the data point through a MetricBuilder.
+func PushOne(ctx context.Context, w MetricWriter, metric string, tags map[string]string, ts int64, value float64) (*response.Response, error) {
+	if pw, ok := w.(PointWriter); ok {
+		return pw.PushOne(ctx, metric, tags, ts, value)
+	}
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric(metric).AddTags(tags).AddDataPoint(ts, value)
+	return w.PushMetricsContext(ctx, mb)
+}
+
+type pushOneBuffer struct {
+	data []byte
+	keys []string
+}
+
+var pushOneBuffers = sync.Pool{
+	New: func() interface{} { return &pushOneBuffer{data: make([]byte, 0, 256)} },
+}
+
+// Pushes a single data point. The payload is formatted into a pooled
+// buffer, so that the encoding does not allocate.
+func (hc *httpClient) PushOne(ctx context.Context, metric string, tags map[string]string, ts int64, value float64) (*response.Response, error) {
+	buf := pushOneBuffers.Get().(*pushOneBuffer)
+	defer pushOneBuffers.Put(buf)
+
+	var err error
+	if buf.data, buf.keys, err = appendPushOne(buf.data[:0], buf.keys[:0], metric, tags, ts, value); err != nil {
+		return nil, err
+	}
+
+	return hc.push(ctx, buf.data, 1, 1)
+}
+
+// Appends the JSON payload of a single data point to dst, using keys as
+// scratch space to sort the tag names. Returns both slices for reuse.
+func appendPushOne(dst []byte, keys []string, metric string, tags map[string]string, ts int64, value float64) ([]byte, []string, error) {
+	if metric == "" {
+		return dst, keys, builder.ErrorMetricNameInvalid
+	}
+
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return dst, keys, builder.ErrorDataPointNonFinite
+	}
+
+	for k, v := range tags {
+		if k == "" {
+			return dst, keys, builder.ErrorTagNameInvalid
+		} else if v == "" {
+			return dst, keys, builder.ErrorTagValueInvalid
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	dst = append(dst, `[{"name":`...)
+	dst = appendJSONString(dst, metric)
+	if len(keys) > 0 {
+		dst = append(dst, `,"tags":{`...)
+		for i, k := range keys {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendJSONString(dst, k)
+			dst = append(dst, ':')
+			dst = appendJSONString(dst, tags[k])
+		}
+		dst = append(dst, '}')
+	}
+	dst = append(dst, `,"datapoints":[[`...)
+	dst = strconv.AppendInt(dst, ts, 10)
+	dst = append(dst, ',')
+	dst = appendJSONFloat(dst, value)
+	dst = append(dst, "]]}]"...)
+
+	return dst, keys, nil
+}
+
+// Appends s as a JSON string, escaped the way encoding/json does.
+func appendJSONString(dst []byte, s string) []byte {
+	if !utf8.ValidString(s) {
+		// Rare enough to take the slow path.
+		data, _ := json.Marshal(s)
+		return append(dst, data...)
+	}
+
+	const hex = "0123456789abcdef"
+	dst = append(dst, '"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			dst = append(dst, '\\', c)
+		case c == '\n':
+			dst = append(dst, '\\', 'n')
+		case c == '\r':
+			dst = append(dst, '\\', 'r')
+		case c == '\t':
+			dst = append(dst, '\\', 't')
+		case c < 0x20 || c == '<' || c == '>' || c == '&':
+			dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		case c == 0xe2 && i+2 < len(s) && s[i+1] == 0x80 && s[i+2]&^1 == 0xa8:
+			// U+2028 and U+2029.
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[s[i+2]&0xf])
+			i += 2
+		default:
+			dst = append(dst, c)
+		}
+	}
+	return append(dst, '"')
+}
+
+// Appends f formatted the way encoding/json does.
+func appendJSONFloat(dst []byte, f float64) []byte {
+	abs := math.Abs(f)
+	format := byte('f')
+	if abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+
+	dst = strconv.AppendFloat(dst, f, format, -1, 64)
+	if format == 'e' {
+		// Clean up e-09 to e-9.
+		n := len(dst)
+		if n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+	return dst
+}
@@ -0,0 +1,92 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestPushOne(t *testing.T) {
+	var bodies []string
+	srv := newValidatingServer(&bodies)
+	defer srv.Close()
+
+	cli := NewHttpClient(srv.URL)
+	_, err := PushOne(context.Background(), cli, "cpu", map[string]string{"host": "h1", "dc": "eu"}, 1600000000000, 0.5)
+	assert.Nil(t, err, "No error expected")
+
+	// Goes through a MetricBuilder.
+	qw := NewQuarantineWriter(cli, QuarantineOptions{})
+	_, err = PushOne(context.Background(), qw, "cpu", map[string]string{"host": "h1", "dc": "eu"}, 1600000000000, 0.5)
+	assert.Nil(t, err, "No error expected")
+
+	assert.Len(t, bodies, 2)
+	assert.Equal(t, bodies[1], bodies[0], "The fast path must send what the builder sends")
+}
+
+// Success test.
+func TestAppendPushOneMatchesBuilder(t *testing.T) {
+	cases := []struct {
+		metric string
+		tags   map[string]string
+		value  float64
+	}{
+		{"cpu", map[string]string{"host": "h1"}, 1},
+		{`we"ird\name`, map[string]string{"a<b>&": "tab\there", "line": " \n\u2028"}, -0.000000125},
+		{"big", map[string]string{"k": "é"}, 1.5e21},
+		{"zero", nil, 0},
+	}
+
+	for _, c := range cases {
+		data, _, err := appendPushOne(nil, nil, c.metric, c.tags, 42, c.value)
+		assert.Nil(t, err, "No error expected")
+
+		mb := builder.NewMetricBuilder()
+		mb.AddMetric(c.metric).AddTags(c.tags).AddDataPoint(42, c.value)
+		expected, err := mb.Build()
+		assert.Nil(t, err, "No error expected")
+		assert.Equal(t, string(expected), string(data))
+	}
+}
+
+// Success test.
+func TestAppendPushOneAllocs(t *testing.T) {
+	tags := map[string]string{"host": "h1", "dc": "eu", "rack": "r1"}
+	buf := make([]byte, 0, 256)
+	keys := make([]string, 0, 8)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		buf, keys, _ = appendPushOne(buf[:0], keys[:0], "cpu.load", tags, 1600000000000, 0.42)
+	})
+	assert.Equal(t, 0.0, allocs, "Encoding must not allocate")
+}
+
+// Failure test.
+func TestAppendPushOneInvalid(t *testing.T) {
+	_, _, err := appendPushOne(nil, nil, "", nil, 1, 1)
+	assert.Equal(t, builder.ErrorMetricNameInvalid, err)
+
+	_, _, err = appendPushOne(nil, nil, "cpu", nil, 1, math.Inf(1))
+	assert.Equal(t, builder.ErrorDataPointNonFinite, err)
+
+	_, _, err = appendPushOne(nil, nil, "cpu", map[string]string{"host": ""}, 1, 1)
+	assert.Equal(t, builder.ErrorTagValueInvalid, err)
+}
@@ -0,0 +1,169 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// A data point left out of a batch after KairosDB rejected it.
+type RejectedDataPoint struct {
+	Metric    string
+	Tags      map[string]string
+	DataPoint builder.DataPoint
+
+	// The validation error of the server.
+	Reason string
+}
+
+// Options of the QuarantineWriter.
+type QuarantineOptions struct {
+	// Maximum number of pushes of a batch, including the first one.
+	// Defaults to 3.
+	MaxAttempts int
+
+	// Invoked with the data points left out after each rejection, e.g. to
+	// log them or store them for inspection. May be nil.
+	OnReject func(rejected []RejectedDataPoint)
+}
+
+// A MetricWriter that re-sends a batch rejected with a 400 without the data
+// points named by the validation errors of the server, so that one bad
+// value does not discard a whole batch. An error naming a metric but no
+// data point, e.g. an empty tag value, leaves out the whole metric.
+type QuarantineWriter struct {
+	MetricWriter
+	opts QuarantineOptions
+}
+
+func NewQuarantineWriter(w MetricWriter, opts QuarantineOptions) *QuarantineWriter {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 3
+	}
+
+	return &QuarantineWriter{
+		MetricWriter: w,
+		opts:         opts,
+	}
+}
+
+// Sends metrics from the builder to the KairosDB server.
+func (qw *QuarantineWriter) PushMetrics(mb builder.MetricBuilder) (*response.Response, error) {
+	return qw.PushMetricsContext(context.Background(), mb)
+}
+
+// Same as PushMetrics, but the requests are aborted when the context is
+// done. The response of the last push is returned.
+func (qw *QuarantineWriter) PushMetricsContext(ctx context.Context, mb builder.MetricBuilder) (*response.Response, error) {
+	// Work on the metrics as encoded, since the server errors refer to them
+	// by index.
+	batch := builder.DropDataPoints(mb, nil)
+
+	for attempt := 1; ; attempt++ {
+		resp, err := qw.MetricWriter.PushMetricsContext(ctx, batch)
+		if err != nil || resp.GetStatusCode() != http.StatusBadRequest || attempt == qw.opts.MaxAttempts {
+			return resp, err
+		}
+
+		bad := rejectedIndexes(resp.GetErrors())
+		if len(bad) == 0 {
+			// Nothing to leave out, the batch would be rejected again.
+			return resp, nil
+		}
+
+		if qw.opts.OnReject != nil {
+			qw.opts.OnReject(collectRejected(batch, bad))
+		}
+
+		batch = builder.DropDataPoints(batch, bad.contains)
+		if len(batch.GetMetrics()) == 0 {
+			return resp, nil
+		}
+	}
+}
+
+// Matches the index of the metric and, when the error is about a data
+// point, its index, e.g. in
+// "metric[0](name=cpu).datapoints[3].value cannot be null or empty".
+var rejectedRegexp = regexp.MustCompile(`metric\[(\d+)\](?:\([^)]*\))?(?:\.datapoints\[(\d+)\])?`)
+
+// The data points to leave out by metric index, with the reason. A point
+// index of -1 stands for the whole metric.
+type rejectedSet map[int]map[int]string
+
+func (rs rejectedSet) contains(metric, point int) bool {
+	points, ok := rs[metric]
+	if !ok {
+		return false
+	}
+
+	_, whole := points[-1]
+	_, found := points[point]
+	return whole || found
+}
+
+func rejectedIndexes(errs []string) rejectedSet {
+	rs := make(rejectedSet)
+	for _, e := range errs {
+		m := rejectedRegexp.FindStringSubmatch(e)
+		if m == nil {
+			continue
+		}
+
+		metric, _ := strconv.Atoi(m[1])
+		point := -1
+		if m[2] != "" {
+			point, _ = strconv.Atoi(m[2])
+		}
+
+		if rs[metric] == nil {
+			rs[metric] = make(map[int]string)
+		}
+		rs[metric][point] = e
+	}
+
+	return rs
+}
+
+func collectRejected(batch builder.MetricBuilder, rs rejectedSet) []RejectedDataPoint {
+	var rejected []RejectedDataPoint
+	for i, m := range batch.GetMetrics() {
+		for j, dp := range m.GetDataPoints() {
+			if !rs.contains(i, j) {
+				continue
+			}
+
+			reason, ok := rs[i][j]
+			if !ok {
+				reason = rs[i][-1]
+			}
+
+			rejected = append(rejected, RejectedDataPoint{
+				Metric:    m.GetName(),
+				Tags:      m.GetTags(),
+				DataPoint: dp,
+				Reason:    reason,
+			})
+		}
+	}
+
+	return rejected
+}
@@ -0,0 +1,112 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+// Rejects data points with a negative value and metrics without tags, the
+// way KairosDB reports validation errors.
+func newValidatingServer(bodies *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		*bodies = append(*bodies, string(body))
+
+		var metrics []struct {
+			Name       string            `json:"name"`
+			Tags       map[string]string `json:"tags"`
+			DataPoints [][2]float64      `json:"datapoints"`
+		}
+		json.Unmarshal(body, &metrics)
+
+		var errs []string
+		for i, m := range metrics {
+			if len(m.Tags) == 0 {
+				errs = append(errs, fmt.Sprintf("metric[%d](name=%s).tags count must be greater than or equal to 1.", i, m.Name))
+			}
+			for j, dp := range m.DataPoints {
+				if dp[1] < 0 {
+					errs = append(errs, fmt.Sprintf("metric[%d](name=%s).datapoints[%d].value must be positive.", i, m.Name, j))
+				}
+			}
+		}
+
+		if len(errs) > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string][]string{"errors": errs})
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+// Success test.
+func TestQuarantineWriter(t *testing.T) {
+	var bodies []string
+	srv := newValidatingServer(&bodies)
+	defer srv.Close()
+
+	var rejected []RejectedDataPoint
+	qw := NewQuarantineWriter(NewHttpClient(srv.URL), QuarantineOptions{
+		OnReject: func(r []RejectedDataPoint) { rejected = append(rejected, r...) },
+	})
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1, 1).AddDataPoint(2, -1).AddDataPoint(3, 3)
+	mb.AddMetric("m2").AddDataPoint(1, 1)
+	mb.AddMetric("m3").AddTag("host", "h1").AddDataPoint(1, 4)
+
+	resp, err := qw.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode(), "Remainder must be accepted")
+	assert.Len(t, bodies, 2, "One retry expected")
+	assert.Equal(t, `[{"name":"m1","tags":{"host":"h1"},"datapoints":[[1,1],[3,3]]},{"name":"m3","tags":{"host":"h1"},"datapoints":[[1,4]]}]`,
+		bodies[1], "Bad data points must be left out")
+
+	assert.Len(t, rejected, 2, "Two rejected data points expected")
+	assert.Equal(t, "m1", rejected[0].Metric)
+	assert.Equal(t, int64(2), rejected[0].DataPoint.Timestamp())
+	assert.Equal(t, "metric[0](name=m1).datapoints[1].value must be positive.", rejected[0].Reason)
+	assert.Equal(t, "m2", rejected[1].Metric, "Whole metric must be rejected")
+	assert.Len(t, mb.GetMetrics()[0].GetDataPoints(), 3, "Original batch must not be modified")
+}
+
+// Failure test.
+func TestQuarantineWriterUnattributed(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bodies = append(bodies, "")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"errors":["Invalid json"]}`))
+	}))
+	defer srv.Close()
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1, 1)
+
+	resp, err := NewQuarantineWriter(NewHttpClient(srv.URL), QuarantineOptions{}).PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusBadRequest, resp.GetStatusCode(), "Rejection must be returned")
+	assert.Len(t, bodies, 1, "No retry expected")
+}
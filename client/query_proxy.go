@@ -0,0 +1,181 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Implemented by clients able to send a query already encoded as JSON,
+// such as the one returned by NewHttpClient.
+type RawQuerier interface {
+	// Sends the encoded query as is, apart from the tenant scoping of the
+	// client.
+	QueryRaw(ctx context.Context, query []byte) (*response.QueryResponse, error)
+}
+
+func (hc *httpClient) QueryRaw(ctx context.Context, query []byte) (*response.QueryResponse, error) {
+	data, err := hc.prepareQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	hc.stats.queries.Add(1)
+	return hc.postQuery(ctx, query_ep, data)
+}
+
+// Options of the query proxy.
+type ProxyOptions struct {
+	// Authenticates the incoming request and returns the tenant it belongs
+	// to, empty for none. An error is answered with 401. Nil lets every
+	// request through.
+	Authorize func(r *http.Request) (tenant string, err error)
+
+	// Tag restricting the queries to the tenant returned by Authorize, the
+	// way TenantOptions.Tag does. Queries for another tenant are answered
+	// with 403. Empty disables the restriction.
+	TenantTag string
+
+	// Maximum size of a query in bytes. Defaults to 1 MiB.
+	MaxQuerySize int64
+}
+
+// Returns an http.Handler exposing the query and metric names endpoints of
+// KairosDB, and nothing else, through the client: web applications can
+// query time series without access to the database, which the client
+// authenticates against. The handler serves
+//
+//	POST /api/v1/datapoints/query
+//	GET  /api/v1/metricnames[?prefix=<prefix>]
+//
+// and can be mounted under a prefix with http.StripPrefix. The metric names
+// are not tenant scoped. The client must implement RawQuerier.
+func NewQueryProxy(c Client, opts ProxyOptions) (http.Handler, error) {
+	rq, ok := c.(RawQuerier)
+	if !ok {
+		return nil, ErrorRawQueryUnsupported
+	}
+
+	if opts.MaxQuerySize <= 0 {
+		opts.MaxQuerySize = 1 << 20
+	}
+
+	p := &queryProxy{client: c, raw: rq, opts: opts}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(query_ep, p.query)
+	mux.HandleFunc(metricnames_ep, p.metricNames)
+	return mux, nil
+}
+
+type queryProxy struct {
+	client Client
+	raw    RawQuerier
+	opts   ProxyOptions
+}
+
+func (p *queryProxy) query(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		proxyError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	tenant, ok := p.authorize(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, p.opts.MaxQuerySize))
+	if err != nil {
+		proxyError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	}
+
+	if p.opts.TenantTag != "" {
+		ts := &tenantScope{id: tenant, tag: p.opts.TenantTag}
+		if data, err = ts.scopeQuery(data); err != nil {
+			if errors.Is(err, ErrorTenantMismatch) {
+				proxyError(w, http.StatusForbidden, err.Error())
+			} else {
+				proxyError(w, http.StatusBadRequest, err.Error())
+			}
+			return
+		}
+	}
+
+	qr, err := p.raw.QueryRaw(r.Context(), data)
+	if err != nil {
+		proxyError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	proxyJSON(w, qr.GetStatusCode(), qr)
+}
+
+func (p *queryProxy) metricNames(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		proxyError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	if _, ok := p.authorize(w, r); !ok {
+		return
+	}
+
+	gr, err := p.client.GetMetricNamesWithPrefixContext(r.Context(), r.URL.Query().Get("prefix"))
+	if err != nil {
+		proxyError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	proxyJSON(w, gr.GetStatusCode(), gr)
+}
+
+// Authenticates the request, answering it when it is refused.
+func (p *queryProxy) authorize(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if p.opts.Authorize == nil {
+		return "", true
+	}
+
+	tenant, err := p.opts.Authorize(r)
+	if err != nil {
+		proxyError(w, http.StatusUnauthorized, err.Error())
+		return "", false
+	}
+
+	if tenant == "" && p.opts.TenantTag != "" {
+		proxyError(w, http.StatusForbidden, ErrorTenantMismatch.Error())
+		return "", false
+	}
+
+	return tenant, true
+}
+
+func proxyJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// Answers with an error in the format of KairosDB.
+func proxyError(w http.ResponseWriter, code int, msg string) {
+	proxyJSON(w, code, response.Response{Errors: []string{msg}})
+}
@@ -0,0 +1,130 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newProxy(t *testing.T, queries *[]string) (*httptest.Server, *httptest.Server) {
+	kairos := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case query_ep:
+			body, _ := ioutil.ReadAll(r.Body)
+			*queries = append(*queries, string(body))
+			w.Write([]byte(`{"queries":[{"sample_size":1,"results":[{"name":"m1","values":[[1,2]]}]}]}`))
+		case metricnames_ep:
+			w.Write([]byte(`{"results":["m1"]}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+
+	proxy, err := NewQueryProxy(NewHttpClient(kairos.URL), ProxyOptions{
+		Authorize: func(r *http.Request) (string, error) {
+			if r.Header.Get("X-Tenant") == "" {
+				return "", errors.New("unknown user")
+			}
+			return r.Header.Get("X-Tenant"), nil
+		},
+		TenantTag: "tenant",
+	})
+	assert.NoError(t, err)
+
+	return kairos, httptest.NewServer(proxy)
+}
+
+func proxyRequest(method, url, tenant, body string) (int, string) {
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	if tenant != "" {
+		req.Header.Set("X-Tenant", tenant)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err.Error()
+	}
+	defer resp.Body.Close()
+
+	data, _ := ioutil.ReadAll(resp.Body)
+	return resp.StatusCode, strings.TrimSpace(string(data))
+}
+
+// Success test.
+func TestQueryProxy(t *testing.T) {
+	var queries []string
+	kairos, proxy := newProxy(t, &queries)
+	defer kairos.Close()
+	defer proxy.Close()
+
+	code, body := proxyRequest("POST", proxy.URL+query_ep, "acme", `{"start_relative":{"value":1,"unit":"hours"},"metrics":[{"name":"m1"}]}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `{"queries":[{"sample_size":1,"results":[{"name":"m1","values":[[1,2]]}]}]}`, body)
+	assert.Equal(t, []string{`{"metrics":[{"name":"m1","tags":{"tenant":["acme"]}}],"start_relative":{"value":1,"unit":"hours"}}`}, queries)
+
+	// Null tags are scoped like missing ones.
+	queries = nil
+	code, _ = proxyRequest("POST", proxy.URL+query_ep, "acme", `{"metrics":[{"name":"x","tags":null}]}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{`{"metrics":[{"name":"x","tags":{"tenant":["acme"]}}]}`}, queries)
+
+	code, body = proxyRequest("GET", proxy.URL+metricnames_ep+"?prefix=m", "acme", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `{"results":["m1"]}`, body)
+}
+
+// Failure test.
+func TestQueryProxyRefused(t *testing.T) {
+	var queries []string
+	kairos, proxy := newProxy(t, &queries)
+	defer kairos.Close()
+	defer proxy.Close()
+
+	query := `{"metrics":[{"name":"m1","tags":{"tenant":["other"]}}]}`
+
+	code, _ := proxyRequest("POST", proxy.URL+query_ep, "", query)
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	code, body := proxyRequest("POST", proxy.URL+query_ep, "acme", query)
+	assert.Equal(t, http.StatusForbidden, code)
+	assert.Equal(t, `{"errors":["`+ErrorTenantMismatch.Error()+`"]}`, body)
+
+	code, _ = proxyRequest("GET", proxy.URL+query_ep, "acme", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+
+	code, _ = proxyRequest("POST", proxy.URL+datapoints_ep, "acme", `[]`)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	code, _ = proxyRequest("POST", proxy.URL+deldatapoints_ep, "acme", query)
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Empty(t, queries)
+
+	// Malformed queries are refused instead of crashing the handler.
+	for _, query := range []string{`{"metrics":[null]}`, `null`, `{"metrics":{}}`} {
+		code, _ = proxyRequest("POST", proxy.URL+query_ep, "acme", query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+	}
+	assert.Empty(t, queries)
+
+	_, err := NewQueryProxy(NewCachingClient(NewHttpClient(kairos.URL), CacheOptions{}), ProxyOptions{})
+	assert.ErrorIs(t, err, ErrorRawQueryUnsupported)
+}
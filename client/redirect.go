@@ -0,0 +1,92 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Controls how redirects issued by load balancers in front of KairosDB are
+// followed.
+type RedirectPolicy struct {
+	// Maximum number of redirects followed for a single request. Zero means
+	// redirects are not followed and the redirect response is returned.
+	MaxHops int
+
+	// Whether redirects to another host than the one of the original
+	// request are followed.
+	AllowCrossHost bool
+
+	// Whether the Authorization header is sent along to the redirect
+	// target. When false it is always dropped, when true it is kept even
+	// across hosts.
+	PreserveAuth bool
+
+	// Whether 301 and 302 redirects repeat the original method and body.
+	// By default they turn a POST into a body-less GET, which KairosDB
+	// rejects. 307 and 308 redirects always keep the method.
+	PreserveMethod bool
+}
+
+// Follows redirects according to the policy instead of Go's default of up
+// to 10 redirects to any host.
+func WithRedirectPolicy(p RedirectPolicy) Option {
+	return func(hc *httpClient) {
+		hc.httpCli.CheckRedirect = p.checkRedirect
+	}
+}
+
+func (p RedirectPolicy) checkRedirect(req *http.Request, via []*http.Request) error {
+	if p.MaxHops <= 0 {
+		return http.ErrUseLastResponse
+	}
+
+	if len(via) > p.MaxHops {
+		return fmt.Errorf("stopped after %d redirects", p.MaxHops)
+	}
+
+	orig := via[0]
+	if !p.AllowCrossHost && req.URL.Host != orig.URL.Host {
+		return fmt.Errorf("redirect to another host %q not allowed", req.URL.Host)
+	}
+
+	if p.PreserveAuth {
+		if auth := orig.Header.Get("Authorization"); auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+	} else {
+		req.Header.Del("Authorization")
+	}
+
+	if p.PreserveMethod && req.Method != orig.Method {
+		req.Method = orig.Method
+		if orig.GetBody != nil {
+			body, err := orig.GetBody()
+			if err != nil {
+				return err
+			}
+			req.Body = body
+			req.GetBody = orig.GetBody
+			req.ContentLength = orig.ContentLength
+		}
+
+		if ct := orig.Header.Get("Content-Type"); ct != "" {
+			req.Header.Set("Content-Type", ct)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+type redirectCapture struct {
+	method, body, auth string
+}
+
+func newRedirectServer(code int, capture *redirectCapture) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/moved") {
+			http.Redirect(w, r, "/moved"+r.URL.Path, code)
+			return
+		}
+
+		b, _ := ioutil.ReadAll(r.Body)
+		capture.method, capture.body, capture.auth = r.Method, string(b), r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func redirectBatch() builder.MetricBuilder {
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddDataPoint(1, 10)
+	return mb
+}
+
+// Success test.
+func TestRedirectPolicyPreserveMethod(t *testing.T) {
+	var capture redirectCapture
+	srv := newRedirectServer(http.StatusFound, &capture)
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithRedirectPolicy(RedirectPolicy{MaxHops: 2, PreserveMethod: true, PreserveAuth: true}))
+	cli.SetCredentials("user", "pass")
+	resp, err := cli.PushMetrics(redirectBatch())
+
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode(), "Redirect must be followed")
+	assert.Equal(t, "POST", capture.method, "Method must be preserved")
+	assert.Equal(t, `[{"name":"m1","datapoints":[[1,10]]}]`, capture.body, "Body must be preserved")
+	assert.NotEmpty(t, capture.auth, "Authorization must be preserved")
+}
+
+// Success test.
+func TestRedirectPolicyDropAuth(t *testing.T) {
+	var capture redirectCapture
+	srv := newRedirectServer(http.StatusTemporaryRedirect, &capture)
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithRedirectPolicy(RedirectPolicy{MaxHops: 1}))
+	cli.SetCredentials("user", "pass")
+	cli.PushMetrics(redirectBatch())
+
+	assert.Equal(t, "POST", capture.method, "307 must keep the method")
+	assert.Empty(t, capture.auth, "Authorization must be dropped")
+}
+
+// Failure test.
+func TestRedirectPolicyNoFollow(t *testing.T) {
+	var capture redirectCapture
+	srv := newRedirectServer(http.StatusFound, &capture)
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithRedirectPolicy(RedirectPolicy{}))
+	resp, err := cli.HealthCheck()
+
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusFound, resp.GetStatusCode(), "Redirect response must be returned")
+	assert.Empty(t, capture.method, "Redirect must not be followed")
+}
+
+// Failure test.
+func TestRedirectPolicyCrossHost(t *testing.T) {
+	var capture redirectCapture
+	target := newRedirectServer(http.StatusFound, &capture)
+	defer target.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL+"/moved", http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithRedirectPolicy(RedirectPolicy{MaxHops: 3}))
+	_, err := cli.HealthCheck()
+
+	assert.NotNil(t, err, "Cross host redirect must be refused")
+	assert.Empty(t, capture.method, "Other host must not be contacted")
+}
@@ -0,0 +1,110 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The error of a request sent to KairosDB, along with the operation, the
+// server and the attempt it failed on. The cause can be tested with
+// errors.Is and errors.As.
+type RequestError struct {
+	Op      string // E.g. "pushing datapoints".
+	Server  string // Address of the server, e.g. "http://localhost:8080".
+	Attempt int    // Number of the attempt that failed, starting at 1.
+	Err     error
+}
+
+func (e *RequestError) Error() string {
+	if e.Attempt > 1 {
+		return fmt.Sprintf("%s to %s (attempt %d): %v", e.Op, e.Server, e.Attempt, e.Err)
+	}
+	return fmt.Sprintf("%s to %s: %v", e.Op, e.Server, e.Err)
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+type operationKey struct{}
+
+// Returns a context carrying the description of the request made to the
+// endpoint, used to report its errors.
+func withOperation(ctx context.Context, method, endpoint string) context.Context {
+	return context.WithValue(ctx, operationKey{}, operation(method, endpoint))
+}
+
+// Describes the request made to the endpoint.
+func operation(method, endpoint string) string {
+	endpoint, _, _ = strings.Cut(endpoint, "?")
+
+	switch {
+	case endpoint == datapoints_ep:
+		return "pushing datapoints"
+	case endpoint == query_ep:
+		return "sending query"
+	case endpoint == querytags_ep:
+		return "sending tags query"
+	case endpoint == deldatapoints_ep:
+		return "sending delete query"
+	case strings.HasPrefix(endpoint, delmetric_ep):
+		return "sending delete of metric " + strings.TrimPrefix(endpoint, delmetric_ep)
+	case endpoint == metricnames_ep:
+		return "requesting metric names"
+	case endpoint == tagnames_ep:
+		return "requesting tag names"
+	case endpoint == tagvalues_ep:
+		return "requesting tag values"
+	case endpoint == version_ep:
+		return "requesting version"
+	case endpoint == health_ep, endpoint == healthstatus_ep:
+		return "sending health check"
+	case strings.HasPrefix(endpoint, rollups_ep):
+		return "sending rollup request"
+	}
+
+	return "sending " + method + " " + endpoint
+}
+
+// Wraps the error of the request, unless it is already wrapped.
+func requestError(req *http.Request, attempt int, err error) error {
+	if _, ok := err.(*RequestError); ok {
+		return err
+	}
+
+	op, _ := req.Context().Value(operationKey{}).(string)
+	if op == "" {
+		op = "sending " + req.Method + " " + req.URL.Path
+	}
+
+	return &RequestError{
+		Op:      op,
+		Server:  req.URL.Scheme + "://" + req.URL.Host,
+		Attempt: attempt,
+		Err:     err,
+	}
+}
+
+// Wraps an error met while reading the response to a request.
+func responseError(resp *http.Response, err error) error {
+	if resp.Request == nil {
+		return err
+	}
+	return requestError(resp.Request, 1, err)
+}
@@ -0,0 +1,73 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// Failure test.
+func TestRequestErrorPush(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithRetry(RetryOptions{WriteAttempts: 3, Backoff: time.Millisecond}))
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("cpu").AddDataPoint(1, 1.0).AddTag("host", "h1")
+
+	_, err := cli.PushMetrics(mb)
+	var re *RequestError
+	assert.True(t, errors.As(err, &re), "RequestError expected")
+	assert.Equal(t, "pushing datapoints", re.Op)
+	assert.Equal(t, srv.URL, re.Server)
+	assert.Equal(t, 3, re.Attempt)
+	assert.True(t, strings.HasPrefix(err.Error(), "pushing datapoints to "+srv.URL+" (attempt 3): "), err.Error())
+	assert.ErrorIs(t, err, syscall.ECONNREFUSED)
+}
+
+// Failure test.
+func TestRequestErrorDecode(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"queries":`))
+	}))
+	defer srv.Close()
+
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("cpu")
+
+	_, err := NewHttpClient(srv.URL).Query(qb)
+	var re *RequestError
+	assert.True(t, errors.As(err, &re), "RequestError expected")
+	assert.Equal(t, "sending query", re.Op)
+	assert.Equal(t, 1, re.Attempt)
+	assert.True(t, strings.HasPrefix(err.Error(), "sending query to "+srv.URL+": "), err.Error())
+
+	_, err = NewHttpClient(srv.URL).DeleteMetric("cpu")
+	var se *json.SyntaxError
+	assert.True(t, errors.As(err, &se), "Decoding error expected")
+	assert.True(t, errors.As(err, &re), "RequestError expected")
+	assert.Equal(t, "sending delete of metric cpu", re.Op)
+}
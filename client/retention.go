@@ -0,0 +1,55 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Deletes the data points of the metric older than age, i.e. with a
+// timestamp before now minus age, now read from the clock of the client, e.g. to enforce a retention period the
+// application manages itself. Only the series matching all the tags are
+// affected, every series of the metric with nil tags.
+func DeleteOlderThan(c Admin, metric string, tags map[string]string, age time.Duration) (*response.Response, error) {
+	if age <= 0 {
+		return nil, ErrorRetentionAgeInvalid
+	}
+
+	return DeleteBefore(c, metric, tags, clockOf(c).Now().Add(-age))
+}
+
+// Same as DeleteOlderThan, with the cutoff given as a time, e.g. computed
+// from a clock other than the wall clock.
+func DeleteBefore(c Admin, metric string, tags map[string]string, cutoff time.Time) (*response.Response, error) {
+	return c.Delete(deleteOlderThanQuery(metric, tags, cutoff))
+}
+
+func deleteOlderThanQuery(metric string, tags map[string]string, cutoff time.Time) builder.QueryBuilder {
+	// A zero start time means none to the builder, hence the millisecond.
+	// Both ends are inclusive, data points at the cutoff are kept.
+	qb := builder.NewQueryBuilder().
+		SetAbsoluteStart(time.Unix(0, int64(time.Millisecond))).
+		SetAbsoluteEnd(cutoff.Truncate(time.Millisecond).Add(-time.Millisecond))
+
+	qm := qb.AddMetric(metric)
+	for k, v := range tags {
+		qm.AddTag(k, []string{v})
+	}
+
+	return qb
+}
@@ -0,0 +1,85 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestDeleteOlderThan(t *testing.T) {
+	var path string
+	var query struct {
+		StartAbs int64 `json:"start_absolute"`
+		EndAbs   int64 `json:"end_absolute"`
+		Metrics  []struct {
+			Name string              `json:"name"`
+			Tags map[string][]string `json:"tags"`
+		} `json:"metrics"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &query)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	before := time.Now()
+	resp, err := DeleteOlderThan(NewHttpClient(srv.URL), "m1", map[string]string{"host": "h1"}, 24*time.Hour)
+	after := time.Now()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode(), "Delete expected")
+
+	assert.Equal(t, "/api/v1/datapoints/delete", path, "Delete endpoint expected")
+	assert.Equal(t, int64(1), query.StartAbs, "Start at the epoch expected")
+	assert.True(t, query.EndAbs >= before.Add(-24*time.Hour).UnixNano()/int64(time.Millisecond)-1, "End before the cutoff expected")
+	assert.True(t, query.EndAbs < after.Add(-24*time.Hour).UnixNano()/int64(time.Millisecond), "Data points at the cutoff must be kept")
+	assert.Equal(t, "m1", query.Metrics[0].Name, "Metric expected")
+	assert.Equal(t, map[string][]string{"host": {"h1"}}, query.Metrics[0].Tags, "Tags expected")
+}
+
+// Success test.
+func TestDeleteOlderThanClock(t *testing.T) {
+	var query struct {
+		EndAbs int64 `json:"end_absolute"`
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(body, &query)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	now := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	cli := NewHttpClientWithOptions(srv.URL, WithClock(clock.NewFake(now)))
+	_, err := DeleteOlderThan(cli, "m1", nil, 24*time.Hour)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, now.Add(-24*time.Hour).UnixMilli()-1, query.EndAbs, "Cutoff from the client clock expected")
+}
+
+// Failure test.
+func TestDeleteOlderThanAgeInvalid(t *testing.T) {
+	_, err := DeleteOlderThan(NewHttpClient("http://localhost:1"), "m1", nil, 0)
+	assert.Equal(t, ErrorRetentionAgeInvalid, err, "Age error expected")
+}
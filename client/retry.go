@@ -0,0 +1,207 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/retoool/go-kairosdb/clock"
+)
+
+// Retries of the requests sent to KairosDB.
+type RetryOptions struct {
+	// Number of attempts of the idempotent requests, including the first
+	// one: the queries, the deletions and the other reads. Less than two
+	// disables their retries.
+	Attempts int
+
+	// Number of attempts of the requests that are not idempotent: the
+	// pushes of data points, and the creation and triggering of roll-up
+	// tasks. A push that reached the server before failing, e.g. whose
+	// response timed out, is stored again when retried. Defaults to a
+	// single attempt, writes are only retried when asked to.
+	WriteAttempts int
+
+	// Wait before the first retry, doubled after every further attempt.
+	// Defaults to 100 milliseconds.
+	Backoff time.Duration
+
+	// Header carrying a key identifying every push, the same for all its
+	// attempts, so that a deduplicating proxy in front of KairosDB can drop
+	// the replays, e.g. "Idempotency-Key". A key set by the caller with
+	// ContextWithHeaders is kept, which makes it stable across processes.
+	// Defaults to none.
+	WriteDedupHeader string
+}
+
+// Retries the requests failing with a network error or answered with 502,
+// 503 or 504, e.g. while a server behind a load balancer restarts. Every
+// retry is made again for the next server address of the round robin, so
+// with several servers it goes to another one. Requests whose context is
+// done are not retried.
+func WithRetry(opts RetryOptions) Option {
+	return func(hc *httpClient) {
+		if opts.Attempts < 2 && opts.WriteAttempts < 2 && opts.WriteDedupHeader == "" {
+			hc.retry = nil
+			return
+		}
+
+		if opts.Backoff <= 0 {
+			opts.Backoff = 100 * time.Millisecond
+		}
+		hc.retry = &retrier{opts: opts}
+	}
+}
+
+type retrier struct {
+	opts RetryOptions
+}
+
+// Sends the request until it succeeds or the attempts are exhausted, with
+// rewind making the request of every further attempt. Returns the number of
+// attempts made along with the last response or error.
+func (r *retrier) do(req *http.Request, send func(*http.Request) (*http.Response, error),
+	rewind func(*http.Request) (*http.Request, error), clk clock.Clock) (*http.Response, int, error) {
+	// A body that cannot be replayed can only be sent once.
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	attempts := r.opts.Attempts
+	if isWrite(req.Context()) {
+		attempts = r.opts.WriteAttempts
+	}
+
+	backoff := r.opts.Backoff
+	for attempt := 1; ; attempt++ {
+		resp, err := send(req)
+		if attempt >= attempts || !replayable || req.Context().Err() != nil || !retryable(resp, err) {
+			return resp, attempt, err
+		}
+
+		if resp != nil {
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := clk.NewTimer(backoff)
+		select {
+		case <-timer.C():
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, attempt, req.Context().Err()
+		}
+		backoff *= 2
+
+		if req, err = rewind(req); err != nil {
+			return nil, attempt, err
+		}
+	}
+}
+
+// Returns the request to send for the next attempt: the same request with a
+// fresh body, made again by newRequest so that it goes to the next server
+// address with the current credentials. The headers set on the request
+// after it was made, e.g. its Content-Type, are kept.
+func (hc *httpClient) rewind(req *http.Request) (*http.Request, error) {
+	var body io.ReadCloser
+	if req.GetBody != nil {
+		var err error
+		if body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	}
+
+	endpoint, ok := req.Context().Value(endpointKey{}).(string)
+	if !ok {
+		// Made for a given server, e.g. by the warm-up, or not made by
+		// newRequest at all, e.g. by a wrapper of the client.
+		next := req.Clone(req.Context())
+		if body != nil {
+			next.Body = body
+		}
+		return next, nil
+	}
+
+	next, err := hc.newRequest(req.Context(), req.Method, endpoint, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, vals := range req.Header {
+		if _, ok := next.Header[k]; !ok && k != "Authorization" {
+			next.Header[k] = vals
+		}
+	}
+	next.ContentLength = req.ContentLength
+	next.GetBody = req.GetBody
+	return next, nil
+}
+
+// Returns a copy of the context carrying a dedup key for the push, unless
+// the caller set one.
+func (r *retrier) withDedupKey(ctx context.Context) context.Context {
+	header := r.opts.WriteDedupHeader
+	if header == "" || headersFromContext(ctx).Get(header) != "" {
+		return ctx
+	}
+
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return ctx
+	}
+
+	return ContextWithHeaders(ctx, http.Header{header: {hex.EncodeToString(key)}})
+}
+
+type writeKey struct{}
+
+type endpointKey struct{}
+
+// Returns a copy of the context marking the request sent with it as a
+// write, when sending it twice does not have the same effect as sending it
+// once. The queries are posted but only read, and deleting the data points
+// of a query twice deletes the same ones.
+func withRequestClass(ctx context.Context, method, endpoint string) context.Context {
+	if method != http.MethodPost {
+		return ctx
+	}
+
+	switch endpoint {
+	case query_ep, querytags_ep, deldatapoints_ep:
+		return ctx
+	}
+	return context.WithValue(ctx, writeKey{}, true)
+}
+
+func isWrite(ctx context.Context) bool {
+	write, _ := ctx.Value(writeKey{}).(bool)
+	return write
+}
+
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestWithRetry(t *testing.T) {
+	var hits int32
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := make([]byte, 512)
+		n, _ := r.Body.Read(buf)
+		bodies = append(bodies, string(buf[:n]))
+		if atomic.AddInt32(&hits, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithRetry(RetryOptions{WriteAttempts: 3, Backoff: time.Millisecond}))
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1, 2)
+	resp, err := cli.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode())
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+	for _, body := range bodies {
+		assert.Equal(t, bodies[0], body, "Every attempt must send the whole body")
+	}
+}
+
+// Success test.
+func TestWithRetryNextServer(t *testing.T) {
+	var hits []string
+	handler := func(name string, code int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			hits = append(hits, name+" "+r.Header.Get("Content-Type")+" "+string(body))
+			w.WriteHeader(code)
+			w.Write([]byte(`{"queries":[]}`))
+		}
+	}
+	a := httptest.NewServer(handler("a", http.StatusServiceUnavailable))
+	defer a.Close()
+	b := httptest.NewServer(handler("b", http.StatusOK))
+	defer b.Close()
+
+	cli := NewHttpClientWithOptions(a.URL, WithRetry(RetryOptions{Attempts: 2, Backoff: time.Millisecond}))
+	cli.SetServerAddresses([]string{a.URL, b.URL})
+
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("m1")
+	resp, err := cli.Query(qb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusOK, resp.GetStatusCode())
+
+	assert.Len(t, hits, 2, "One retry expected")
+	assert.Equal(t, "a application/json", hits[0][:len("a application/json")])
+	assert.Equal(t, "b"+hits[0][1:], hits[1], "Same request retried on the next server expected")
+}
+
+// Success test.
+func TestWithRetryClock(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	clk := clock.NewFake(time.Now())
+	cli := NewHttpClientWithOptions(srv.URL,
+		WithRetry(RetryOptions{Attempts: 3, Backoff: time.Hour}),
+		WithClock(clk))
+
+	done := make(chan int)
+	go func() {
+		resp, err := cli.HealthCheck()
+		assert.Nil(t, err, "No error expected")
+		done <- resp.GetStatusCode()
+	}()
+
+	clk.BlockUntil(1)
+	clk.Advance(time.Hour)
+	clk.BlockUntil(1)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	clk.Advance(2 * time.Hour)
+	assert.Equal(t, http.StatusNoContent, <-done, "The backoff must follow the clock")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits))
+}
+
+// Failure test.
+func TestWithRetryGivesUp(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithRetry(RetryOptions{Attempts: 2, Backoff: time.Millisecond}))
+
+	resp, err := cli.HealthCheck()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusBadGateway, resp.GetStatusCode(), "The last response must be returned")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+}
+
+// Failure test.
+func TestWithRetryClientError(t *testing.T) {
+	var hits int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithRetry(RetryOptions{Attempts: 3, Backoff: time.Millisecond}))
+
+	_, err := cli.HealthCheck()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits), "Client errors must not be retried")
+}
+
+// Failure test.
+func TestWithRetryWritesOptIn(t *testing.T) {
+	var pushes, queries int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == datapoints_ep {
+			atomic.AddInt32(&pushes, 1)
+		} else {
+			atomic.AddInt32(&queries, 1)
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithRetry(RetryOptions{Attempts: 3, Backoff: time.Millisecond}))
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1, 2)
+	cli.PushMetrics(mb)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&pushes), "Pushes must not be retried by default")
+
+	qb := builder.NewQueryBuilder().SetRelativeStart(1, "hours")
+	qb.AddMetric("m1")
+	cli.Query(qb)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&queries), "Queries must be retried")
+}
+
+// Success test.
+func TestWithRetryDedupKey(t *testing.T) {
+	var hits int32
+	var keys []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if atomic.AddInt32(&hits, 1)%2 == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithRetry(RetryOptions{
+		WriteAttempts:    2,
+		Backoff:          time.Millisecond,
+		WriteDedupHeader: "Idempotency-Key",
+	}))
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1, 2)
+	_, err := cli.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+	_, err = cli.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+
+	assert.Len(t, keys, 4, "Two attempts per push expected")
+	assert.NotEmpty(t, keys[0], "Dedup key expected")
+	assert.Equal(t, keys[0], keys[1], "Same key for all the attempts expected")
+	assert.Equal(t, keys[2], keys[3], "Same key for all the attempts expected")
+	assert.NotEqual(t, keys[0], keys[2], "A key per push expected")
+
+	ctx := ContextWithHeaders(context.Background(), http.Header{"Idempotency-Key": {"batch-42"}})
+	_, err = cli.PushMetricsContext(ctx, mb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, []string{"batch-42", "batch-42"}, keys[4:], "Caller key expected")
+}
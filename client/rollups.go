@@ -0,0 +1,181 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Creates a roll-up task. The response holds the created task with its ID.
+func (hc *httpClient) CreateRollup(rb builder.RollupBuilder) (*response.RollupResponse, error) {
+	data, err := hc.buildRollup(rb)
+	if err != nil {
+		return nil, err
+	}
+
+	return hc.rollupRequest("POST", rollups_ep, data)
+}
+
+// Returns all the roll-up tasks.
+func (hc *httpClient) GetRollups() (*response.RollupResponse, error) {
+	return hc.rollupRequest("GET", rollups_ep, nil)
+}
+
+// Returns the roll-up task with the given ID.
+func (hc *httpClient) GetRollup(id string) (*response.RollupResponse, error) {
+	return hc.rollupRequest("GET", rollups_ep+"/"+id, nil)
+}
+
+// Replaces the definition of the roll-up task with the given ID.
+func (hc *httpClient) UpdateRollup(id string, rb builder.RollupBuilder) (*response.RollupResponse, error) {
+	data, err := hc.buildRollup(rb)
+	if err != nil {
+		return nil, err
+	}
+
+	return hc.rollupRequest("PUT", rollups_ep+"/"+id, data)
+}
+
+// Deletes the roll-up task with the given ID.
+func (hc *httpClient) DeleteRollup(id string) (*response.Response, error) {
+	return hc.delete(context.Background(), rollups_ep+"/"+id)
+}
+
+// Returns the execution status of the roll-up task with the given ID.
+func (hc *httpClient) GetRollupStatus(id string) (*response.RollupStatusResponse, error) {
+	resp, err := hc.sendRequest(context.Background(), rollups_ep+"/status/"+id, "GET")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, responseError(resp, err)
+	}
+
+	rr := response.NewRollupStatusResponse(resp.StatusCode)
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		err = hc.unmarshal(contents, rr.Response)
+	} else {
+		rr.Status = &response.RollupStatus{}
+		err = hc.unmarshal(contents, rr.Status)
+	}
+	if err != nil {
+		return nil, responseError(resp, err)
+	}
+
+	return rr, nil
+}
+
+// Asks the server to run the roll-up task with the given ID right away.
+// Servers without a trigger endpoint answer 404, 405 or 501; the last two
+// are reported as ErrorNotSupported.
+func (hc *httpClient) TriggerRollup(id string) (*response.Response, error) {
+	resp, err := hc.postData(context.Background(), rollups_ep+"/trigger/"+id, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.GetStatusCode() {
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return resp, fmt.Errorf("%w: roll-up trigger", ErrorNotSupported)
+	}
+
+	return resp, nil
+}
+
+// Encodes the task and applies the compatibility profile and the tenant
+// scope to its queries.
+func (hc *httpClient) buildRollup(rb builder.RollupBuilder) ([]byte, error) {
+	data, err := rb.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	if hc.profile == nil && hc.tenant == nil {
+		return data, nil
+	}
+
+	var task map[string]json.RawMessage
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, err
+	}
+
+	var rollups []map[string]json.RawMessage
+	if err := json.Unmarshal(task["rollups"], &rollups); err != nil {
+		return nil, err
+	}
+
+	for _, r := range rollups {
+		if r["query"], err = hc.prepareQuery(r["query"]); err != nil {
+			return nil, err
+		}
+	}
+
+	if task["rollups"], err = json.Marshal(rollups); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(task)
+}
+
+func (hc *httpClient) rollupRequest(method, endpoint string, data []byte) (*response.RollupResponse, error) {
+	req, err := hc.newRequest(context.Background(), method, endpoint, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("accept", "application/json")
+	if data != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := hc.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	contents, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, responseError(resp, err)
+	}
+
+	rr := response.NewRollupResponse(resp.StatusCode)
+	switch {
+	case len(bytes.TrimSpace(contents)) == 0:
+		// Nothing to decode, e.g. 204 No Content.
+	case resp.StatusCode >= http.StatusMultipleChoices:
+		err = hc.unmarshal(contents, rr.Response)
+	case bytes.HasPrefix(bytes.TrimSpace(contents), []byte("[")):
+		err = hc.unmarshal(contents, &rr.Tasks)
+	default:
+		rr.Tasks = make([]response.RollupTask, 1)
+		err = hc.unmarshal(contents, &rr.Tasks[0])
+	}
+	if err != nil {
+		return nil, responseError(resp, err)
+	}
+
+	return rr, nil
+}
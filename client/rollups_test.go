@@ -0,0 +1,135 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRollupServer(bodies *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		*bodies = append(*bodies, r.Method+" "+r.URL.Path+" "+string(body))
+
+		switch {
+		case r.Method == "POST":
+			w.Write([]byte(`{"id":"t1","name":"hourly","attributes":{"url":"/api/v1/rollups/t1"}}`))
+		case r.Method == "GET" && r.URL.Path == rollups_ep:
+			w.Write([]byte(`[{"id":"t1","name":"hourly","execution_interval":{"value":1,"unit":"hours"},
+				"rollups":[{"save_as":"cpu.hourly","query":{"metrics":[{"name":"cpu"}]}}],"lastModified":1500}]`))
+		case r.Method == "GET":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":["Resource not found for id missing"]}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+func hourlyRollup() builder.RollupBuilder {
+	rb := builder.NewRollupBuilder("hourly").SetExecutionInterval(1, utils.HOURS)
+	rb.AddRollup("cpu.hourly").SetRelativeStart(1, utils.HOURS).AddMetric("cpu")
+	return rb
+}
+
+// Success test.
+func TestRollups(t *testing.T) {
+	var bodies []string
+	srv := newRollupServer(&bodies)
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithTenant("acme", TenantOptions{Tag: "tenant"}))
+
+	rr, err := cli.CreateRollup(hourlyRollup())
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, "t1", rr.GetTasks()[0].ID, "Created task ID expected")
+	assert.Equal(t, `POST /api/v1/rollups {"execution_interval":{"value":1,"unit":"hours"},"name":"hourly",`+
+		`"rollups":[{"query":{"metrics":[{"name":"cpu","tags":{"tenant":["acme"]}}],"start_relative":{"value":1,"unit":"hours"}},"save_as":"cpu.hourly"}]}`,
+		bodies[0], "Roll-up queries must be scoped to the tenant")
+
+	rr, err = cli.GetRollups()
+	assert.Nil(t, err, "No error expected")
+	assert.Len(t, rr.GetTasks(), 1, "One task expected")
+	assert.Equal(t, "cpu.hourly", rr.GetTasks()[0].Rollups[0].SaveAs)
+	assert.Equal(t, 1, rr.GetTasks()[0].ExecutionInterval.Value())
+
+	resp, err := cli.DeleteRollup("t1")
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode())
+	assert.Equal(t, "DELETE /api/v1/rollups/t1 ", bodies[2])
+}
+
+// Failure test.
+func TestRollupsNotFound(t *testing.T) {
+	var bodies []string
+	srv := newRollupServer(&bodies)
+	defer srv.Close()
+
+	rr, err := NewHttpClient(srv.URL).GetRollup("missing")
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNotFound, rr.GetStatusCode())
+	assert.Equal(t, []string{"Resource not found for id missing"}, rr.GetErrors())
+	assert.Empty(t, rr.GetTasks(), "No task expected")
+
+	_, err = NewHttpClient(srv.URL).CreateRollup(builder.NewRollupBuilder(""))
+	assert.Equal(t, builder.ErrorRollupNameInvalid, err, "Builder errors must be reported")
+}
+
+// Success test.
+func TestRollupStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case rollups_ep + "/status/t1":
+			w.Write([]byte(`{"executingHost":"kairos1","lastExecuted":1500000000000,"statuses":[
+				{"metricName":"cpu.hourly","lastExecuted":"2017-07-14T02:40:00Z","dataPointCount":"42","executionLength":"250"},
+				{"metricName":"mem.hourly","errorMessage":"query timed out"}]}`))
+		case rollups_ep + "/trigger/t1":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			w.Write([]byte(`{"errors":["Method not allowed"]}`))
+		}
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithStrictDecoding())
+
+	rr, err := cli.GetRollupStatus("t1")
+	assert.Nil(t, err, "No error expected")
+	status := rr.GetStatus()
+	assert.Equal(t, "kairos1", status.ExecutingHost)
+	assert.Equal(t, int64(1500000000000), status.LastExecuted.UnixNano()/int64(time.Millisecond))
+	assert.Equal(t, int64(42), status.Statuses[0].DataPointCount)
+	assert.Equal(t, 250*time.Millisecond, status.Statuses[0].ExecutionLength)
+	assert.Equal(t, time.Date(2017, 7, 14, 2, 40, 0, 0, time.UTC), status.Statuses[0].LastExecuted.UTC())
+	assert.Equal(t, []string{"mem.hourly: query timed out"}, status.Errors())
+
+	resp, err := cli.TriggerRollup("t1")
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode())
+
+	// Stock servers have no trigger endpoint.
+	_, err = cli.TriggerRollup("t2")
+	assert.True(t, errors.Is(err, ErrorNotSupported), "Trigger must be reported as not supported")
+}
@@ -0,0 +1,173 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Chooses which of the shards a metric is written to. It must return an
+// index in the [0, shards) range.
+type ShardFunc func(m builder.Metric, shards int) int
+
+// Shards metrics by the FNV-1a hash of their name, so that all the data
+// points of a metric always end up on the same cluster.
+func HashShard(m builder.Metric, shards int) int {
+	h := fnv.New32a()
+	h.Write([]byte(m.GetName()))
+	return int(h.Sum32() % uint32(shards))
+}
+
+// Returns a ShardFunc that pins the metrics named in rules to the given
+// shard and shards all the other metrics using fallback. A nil fallback
+// defaults to HashShard.
+func RuleShard(rules map[string]int, fallback ShardFunc) ShardFunc {
+	if fallback == nil {
+		fallback = HashShard
+	}
+
+	return func(m builder.Metric, shards int) int {
+		if shard, ok := rules[m.GetName()]; ok {
+			return shard
+		}
+		return fallback(m, shards)
+	}
+}
+
+// Routes the metrics whose name matches to a shard. Prefix and Pattern may
+// be combined, a metric must then match both.
+type RouteRule struct {
+	// Prefix of the metric names, e.g. "debug.". Empty matches all.
+	Prefix string
+
+	// Pattern of the metric names. Nil matches all.
+	Pattern *regexp.Regexp
+
+	// Index of the shard the matching metrics are written to.
+	Shard int
+}
+
+// Returns a ShardFunc routing the metrics by name, e.g. "debug." metrics to
+// a short retention cluster and "sla." ones to the durable one. The rules
+// are tried in order and the first match wins; the metrics matching none
+// are sharded using fallback. A nil fallback defaults to HashShard.
+func RouteShard(rules []RouteRule, fallback ShardFunc) ShardFunc {
+	if fallback == nil {
+		fallback = HashShard
+	}
+
+	return func(m builder.Metric, shards int) int {
+		name := m.GetName()
+		for _, r := range rules {
+			if strings.HasPrefix(name, r.Prefix) && (r.Pattern == nil || r.Pattern.MatchString(name)) {
+				return r.Shard
+			}
+		}
+		return fallback(m, shards)
+	}
+}
+
+// Writes metrics to one of several KairosDB clusters.
+type ShardedWriter struct {
+	shards  []Client
+	shardFn ShardFunc
+}
+
+// Creates a writer that routes every metric to one of the shards using
+// shardFn. A nil shardFn defaults to HashShard.
+func NewShardedWriter(shards []Client, shardFn ShardFunc) (*ShardedWriter, error) {
+	if len(shards) == 0 {
+		return nil, ErrorNoShards
+	}
+
+	if shardFn == nil {
+		shardFn = HashShard
+	}
+
+	return &ShardedWriter{
+		shards:  shards,
+		shardFn: shardFn,
+	}, nil
+}
+
+// Splits the metrics of the builder by shard and sends them to the shards
+// concurrently. The returned response carries the highest status code and
+// the errors reported by all the shards. The first request error, if any,
+// is returned annotated with the index of the shard.
+func (sw *ShardedWriter) PushMetrics(mb builder.MetricBuilder) (*response.Response, error) {
+	return sw.PushMetricsContext(context.Background(), mb)
+}
+
+// Same as PushMetrics, but the requests are aborted when the context is
+// done.
+func (sw *ShardedWriter) PushMetricsContext(ctx context.Context, mb builder.MetricBuilder) (*response.Response, error) {
+	batches := make([]builder.MetricBuilder, len(sw.shards))
+	for _, m := range mb.GetMetrics() {
+		shard := sw.shardFn(m, len(sw.shards))
+		if shard < 0 || shard >= len(sw.shards) {
+			return nil, ErrorShardOutOfRange
+		}
+
+		if batches[shard] == nil {
+			batches[shard] = builder.NewMetricBuilder().SetNonFinitePolicy(mb.GetNonFinitePolicy())
+		}
+		batches[shard].AppendMetrics(m)
+	}
+
+	resps := make([]*response.Response, len(sw.shards))
+	errs := make([]error, len(sw.shards))
+
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		if batch == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, batch builder.MetricBuilder) {
+			defer wg.Done()
+			resps[i], errs[i] = sw.shards[i].PushMetricsContext(ctx, batch)
+		}(i, batch)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("shard %d: %w", i, err)
+		}
+	}
+
+	merged := &response.Response{}
+	for _, r := range resps {
+		if r == nil {
+			continue
+		}
+
+		if r.GetStatusCode() > merged.GetStatusCode() {
+			merged.SetStatusCode(r.GetStatusCode())
+		}
+		merged.Errors = append(merged.Errors, r.GetErrors()...)
+	}
+
+	return merged, nil
+}
@@ -0,0 +1,138 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+type pushRecorder struct {
+	mu     sync.Mutex
+	bodies []string
+	srv    *httptest.Server
+}
+
+func newPushRecorder(code int) *pushRecorder {
+	pr := &pushRecorder{}
+	pr.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		pr.mu.Lock()
+		pr.bodies = append(pr.bodies, string(b))
+		pr.mu.Unlock()
+		w.WriteHeader(code)
+		if code != http.StatusNoContent {
+			w.Write([]byte(`{"errors":["failed"]}`))
+		}
+	}))
+	return pr
+}
+
+func (pr *pushRecorder) Bodies() []string {
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+	return append([]string(nil), pr.bodies...)
+}
+
+// Success test.
+func TestShardedWriterRules(t *testing.T) {
+	s0 := newPushRecorder(http.StatusNoContent)
+	defer s0.srv.Close()
+	s1 := newPushRecorder(http.StatusNoContent)
+	defer s1.srv.Close()
+
+	shardFn := RuleShard(map[string]int{"m1": 1}, func(m builder.Metric, shards int) int { return 0 })
+	sw, err := NewShardedWriter([]Client{NewHttpClient(s0.srv.URL), NewHttpClient(s1.srv.URL)}, shardFn)
+	assert.Nil(t, err, "No error expected")
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddDataPoint(1, 10)
+	mb.AddMetric("m2").AddDataPoint(2, 20)
+	mb.AddMetric("m3").AddDataPoint(3, 30)
+
+	resp, err := sw.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode(), "Status code must match")
+	assert.Equal(t, []string{`[{"name":"m2","datapoints":[[2,20]]},{"name":"m3","datapoints":[[3,30]]}]`}, s0.Bodies(),
+		"Unpinned metrics must go to the fallback shard")
+	assert.Equal(t, []string{`[{"name":"m1","datapoints":[[1,10]]}]`}, s1.Bodies(), "Pinned metric must go to its shard")
+}
+
+// Success test.
+func TestShardedWriterRoutes(t *testing.T) {
+	s0 := newPushRecorder(http.StatusNoContent)
+	defer s0.srv.Close()
+	s1 := newPushRecorder(http.StatusNoContent)
+	defer s1.srv.Close()
+
+	shardFn := RouteShard([]RouteRule{
+		{Prefix: "debug.", Shard: 1},
+		{Prefix: "sla.", Pattern: regexp.MustCompile(`\.latency$`), Shard: 0},
+		{Pattern: regexp.MustCompile(`^tmp\d+$`), Shard: 1},
+	}, func(m builder.Metric, shards int) int { return 0 })
+	sw, err := NewShardedWriter([]Client{NewHttpClient(s0.srv.URL), NewHttpClient(s1.srv.URL)}, shardFn)
+	assert.Nil(t, err, "No error expected")
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("debug.gc").AddDataPoint(1, 10)
+	mb.AddMetric("sla.latency").AddDataPoint(2, 20)
+	mb.AddMetric("tmp42").AddDataPoint(3, 30)
+	mb.AddMetric("other").AddDataPoint(4, 40)
+
+	_, err = sw.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, []string{`[{"name":"sla.latency","datapoints":[[2,20]]},{"name":"other","datapoints":[[4,40]]}]`}, s0.Bodies(),
+		"Durable metrics and the unmatched ones must go to shard 0")
+	assert.Equal(t, []string{`[{"name":"debug.gc","datapoints":[[1,10]]},{"name":"tmp42","datapoints":[[3,30]]}]`}, s1.Bodies(),
+		"Matched metrics must go to their shard")
+}
+
+// Success test.
+func TestShardedWriterHashIsStable(t *testing.T) {
+	m := builder.NewMetric("some.metric")
+	first := HashShard(m, 7)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, first, HashShard(m, 7), "Hash shard must be stable")
+	}
+}
+
+// Failure test.
+func TestShardedWriterErrors(t *testing.T) {
+	_, err := NewShardedWriter(nil, nil)
+	assert.Equal(t, ErrorNoShards, err, "Shards must be required")
+
+	s0 := newPushRecorder(http.StatusBadRequest)
+	defer s0.srv.Close()
+
+	sw, _ := NewShardedWriter([]Client{NewHttpClient(s0.srv.URL)}, nil)
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddDataPoint(1, 10)
+
+	resp, err := sw.PushMetrics(mb)
+	assert.Nil(t, err, "No request error expected")
+	assert.Equal(t, http.StatusBadRequest, resp.GetStatusCode(), "Highest status code expected")
+	assert.Equal(t, []string{"failed"}, resp.GetErrors(), "Shard errors must be merged")
+
+	bad, _ := NewShardedWriter([]Client{NewHttpClient(s0.srv.URL)}, func(m builder.Metric, shards int) int { return 3 })
+	_, err = bad.PushMetrics(mb)
+	assert.Equal(t, ErrorShardOutOfRange, err, "Out of range shard must be rejected")
+}
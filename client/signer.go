@@ -0,0 +1,43 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import "net/http"
+
+// Signs the requests sent to KairosDB, for HMAC schemes or gateway specific
+// signatures. The signer runs last, after the authentication and the
+// built-in signing, over the final headers and body: the body, compressed
+// when the client compresses it, can be read with req.GetBody without
+// consuming it.
+type Signer interface {
+	// Adds the signature to the request, usually as a header. An error
+	// aborts the request.
+	Sign(req *http.Request) error
+}
+
+// Adapts a function to the Signer interface.
+type SignerFunc func(req *http.Request) error
+
+func (f SignerFunc) Sign(req *http.Request) error {
+	return f(req)
+}
+
+// Signs every request with the given signer. Every attempt of a retried
+// request is signed again, so time based signatures stay fresh.
+func WithSigner(s Signer) Option {
+	return func(hc *httpClient) {
+		hc.signer = s
+	}
+}
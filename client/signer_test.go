@@ -0,0 +1,86 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+func hmacHex(key, data []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Success test.
+func TestWithSigner(t *testing.T) {
+	key := []byte("secret")
+	var verified bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		payload := append([]byte(r.Header.Get("Authorization")+"\n"), body...)
+		verified = r.Header.Get("X-Signature") == hmacHex(key, payload)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL,
+		WithAuthProvider(AuthProviderFunc(func(*http.Request) (string, error) {
+			return "Bearer token", nil
+		})),
+		WithSigner(SignerFunc(func(req *http.Request) error {
+			body, err := req.GetBody()
+			if err != nil {
+				return err
+			}
+			data, _ := ioutil.ReadAll(body)
+
+			// Runs after the auth provider, so the header is signed too.
+			payload := append([]byte(req.Header.Get("Authorization")+"\n"), data...)
+			req.Header.Set("X-Signature", hmacHex(key, payload))
+			return nil
+		})),
+	)
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1, 1)
+	_, err := cli.PushMetrics(mb)
+	assert.NoError(t, err)
+	assert.True(t, verified, "Signature over the final headers and body expected")
+}
+
+// Failure test.
+func TestWithSignerError(t *testing.T) {
+	srv := newHealthServer(http.StatusNoContent)
+	defer srv.Close()
+
+	signErr := errors.New("no key")
+	cli := NewHttpClientWithOptions(srv.URL, WithSigner(SignerFunc(func(*http.Request) error {
+		return signErr
+	})))
+
+	_, err := cli.HealthCheck()
+	assert.ErrorIs(t, err, signErr, "Signer error expected")
+}
@@ -0,0 +1,94 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Signs a request of the AWS Signature Version 4 test suite.
+func signVanilla(t *testing.T, method string) string {
+	s := &sigV4Signer{
+		opts: SigV4Options{
+			Region:      "us-east-1",
+			Service:     "service",
+			Credentials: StaticCredentials("AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", ""),
+		},
+		now: func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) },
+	}
+
+	req, _ := http.NewRequest(method, "https://example.amazonaws.com/", nil)
+	err := s.sign(req)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"), "Date header expected")
+	return req.Header.Get("Authorization")
+}
+
+// Success test.
+func TestSigV4TestSuite(t *testing.T) {
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		signVanilla(t, "GET"), "get-vanilla signature expected")
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b",
+		signVanilla(t, "POST"), "post-vanilla signature expected")
+}
+
+// Success test.
+func TestWithSigV4(t *testing.T) {
+	var auth, token string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		token = r.Header.Get("X-Amz-Security-Token")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithSigV4(SigV4Options{
+		Region:      "eu-west-1",
+		Service:     "execute-api",
+		Credentials: StaticCredentials("AKID", "secret", "session"),
+	}))
+
+	_, err := cli.DeleteMetric("m1")
+	assert.Nil(t, err, "No error expected")
+	assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/"), "Request must be signed")
+	assert.Contains(t, auth, "/eu-west-1/execute-api/aws4_request", "Scope must name the region and service")
+	assert.Contains(t, auth, "SignedHeaders=host;x-amz-date;x-amz-security-token", "Session token must be signed")
+	assert.Equal(t, "session", token, "Session token expected")
+}
+
+// Failure test.
+func TestWithSigV4NoCredentials(t *testing.T) {
+	srv := newHealthServer(http.StatusNoContent)
+	defer srv.Close()
+
+	credsErr := errors.New("no credentials")
+	cli := NewHttpClientWithOptions(srv.URL, WithSigV4(SigV4Options{
+		Region:      "eu-west-1",
+		Service:     "execute-api",
+		Credentials: func() (AWSCredentials, error) { return AWSCredentials{}, credsErr },
+	}))
+
+	_, err := cli.HealthCheck()
+	assert.ErrorIs(t, err, credsErr, "Credentials error expected")
+}
@@ -0,0 +1,66 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// A query that took longer than the slow query threshold.
+type SlowQuery struct {
+	// Path of the endpoint, i.e. the data point or the tag query one.
+	Endpoint string
+
+	// JSON of the query as sent to KairosDB.
+	Query []byte
+
+	// Time from sending the request to decoding the response.
+	Duration time.Duration
+
+	// Response body bytes read, before decompression by the client.
+	ResponseBytes int64
+
+	StatusCode int   // Zero when the request failed.
+	Err        error // The request or decoding error, if any.
+}
+
+// Hands the queries taking at least threshold to hook, so that operators can
+// find the dashboards that hurt the cluster. Queries failing after the
+// threshold, e.g. timing out, are reported as well. The hook is called
+// synchronously, so it should be fast.
+func WithSlowQueryLog(threshold time.Duration, hook func(SlowQuery)) Option {
+	return func(hc *httpClient) {
+		hc.slowQueryThreshold = threshold
+		hc.slowQueryHook = hook
+	}
+}
+
+// Reports the query to the slow query hook when it took too long.
+func (hc *httpClient) logSlowQuery(endpoint string, data []byte, start time.Time, size *atomic.Int64, status int, err error) {
+	d := time.Since(start)
+	if hc.slowQueryHook == nil || d < hc.slowQueryThreshold {
+		return
+	}
+
+	hc.slowQueryHook(SlowQuery{
+		Endpoint:      endpoint,
+		Query:         data,
+		Duration:      d,
+		ResponseBytes: size.Load(),
+		StatusCode:    status,
+		Err:           err,
+	})
+}
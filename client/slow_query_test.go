@@ -0,0 +1,99 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+const slowQueryBody = `{"queries":[{"sample_size":0,"results":[]}]}`
+
+func newSlowServer(delay time.Duration) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		w.Write([]byte(slowQueryBody))
+	}))
+}
+
+// Success test.
+func TestWithSlowQueryLog(t *testing.T) {
+	srv := newSlowServer(20 * time.Millisecond)
+	defer srv.Close()
+
+	var logged []SlowQuery
+	cli := NewHttpClientWithOptions(srv.URL, WithSlowQueryLog(10*time.Millisecond, func(sq SlowQuery) {
+		logged = append(logged, sq)
+	}))
+
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("m1")
+	_, err := cli.Query(qb)
+	assert.Nil(t, err, "No error expected")
+
+	assert.Len(t, logged, 1, "Slow query must be logged")
+	assert.Equal(t, query_ep, logged[0].Endpoint)
+	assert.Equal(t, `{"start_relative":{"value":1,"unit":"hours"},"metrics":[{"name":"m1"}]}`, string(logged[0].Query))
+	assert.Equal(t, int64(len(slowQueryBody)), logged[0].ResponseBytes)
+	assert.Equal(t, http.StatusOK, logged[0].StatusCode)
+	assert.GreaterOrEqual(t, logged[0].Duration, 10*time.Millisecond)
+}
+
+// Success test.
+func TestWithSlowQueryLogFastQuery(t *testing.T) {
+	srv := newSlowServer(0)
+	defer srv.Close()
+
+	var logged []SlowQuery
+	cli := NewHttpClientWithOptions(srv.URL, WithSlowQueryLog(time.Second, func(sq SlowQuery) {
+		logged = append(logged, sq)
+	}))
+
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("m1")
+	_, err := cli.Query(qb)
+	assert.Nil(t, err, "No error expected")
+	assert.Empty(t, logged, "Fast query must not be logged")
+}
+
+// Failure test.
+func TestWithSlowQueryLogTimeout(t *testing.T) {
+	srv := newSlowServer(100 * time.Millisecond)
+	defer srv.Close()
+
+	var logged []SlowQuery
+	cli := NewHttpClientWithOptions(srv.URL, WithSlowQueryLog(10*time.Millisecond, func(sq SlowQuery) {
+		logged = append(logged, sq)
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("m1")
+	_, err := cli.QueryContext(ctx, qb)
+	assert.NotNil(t, err, "Error expected")
+
+	assert.Len(t, logged, 1, "Timed out query must be logged")
+	assert.Equal(t, 0, logged[0].StatusCode)
+	assert.ErrorIs(t, logged[0].Err, context.DeadlineExceeded)
+}
@@ -0,0 +1,92 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/retoool/go-kairosdb/builder"
+)
+
+// Reads the snapshot file into v. Returns false, without error, when there
+// is no snapshot.
+func readSnapshot(path string, v interface{}) (bool, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return true, json.Unmarshal(data, v)
+}
+
+// Replaces the snapshot file atomically, so a crash never leaves it half
+// written.
+func writeSnapshot(path string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// Returns a builder holding the data point sets.
+func metricBuilderOf(sets []builder.DataPointSet) builder.MetricBuilder {
+	mb := builder.NewMetricBuilder()
+	for _, s := range sets {
+		m := mb.AddMetric(s.Name).AddTags(s.Tags).AddType(s.Type).AddTTL(s.TTL)
+		for _, dp := range s.DataPoints {
+			m.AddDataPoint(dp.Timestamp(), dp.Value())
+		}
+	}
+	return mb
+}
+
+// Returns the metrics of the builder as data point sets, which outlive the
+// builder and can be saved.
+func dataPointSetsOf(mb builder.MetricBuilder) []builder.DataPointSet {
+	var sets []builder.DataPointSet
+	for _, m := range mb.GetMetrics() {
+		sets = append(sets, builder.DataPointSet{
+			Name:       m.GetName(),
+			Type:       m.GetType(),
+			Tags:       m.GetTags(),
+			DataPoints: m.GetDataPoints(),
+			TTL:        m.GetTTL(),
+		})
+	}
+	return sets
+}
@@ -0,0 +1,386 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// What the SpoolingWriter does when the spool is full.
+type SpoolPolicy int
+
+const (
+	// Evicts the oldest batches to make room for the new one.
+	SpoolDropOldest SpoolPolicy = iota
+
+	// Rejects the new batch with ErrorSpoolFull.
+	SpoolRejectNew
+)
+
+// Options of the SpoolingWriter.
+type SpoolOptions struct {
+	// Directory the batches are spooled to, one file per batch. Batches
+	// left by a previous process are replayed as well.
+	Dir string
+
+	// Maximum size of the spool in bytes. Defaults to 256 MiB.
+	MaxBytes int64
+
+	// Defaults to SpoolDropOldest.
+	Policy SpoolPolicy
+
+	// Time between two replay attempts of Run. Defaults to 10 seconds.
+	RetryInterval time.Duration
+
+	// Invoked with the number of data points of every batch dropped, either
+	// evicted with ErrorSpoolFull, rejected by the server on replay or set
+	// aside with ErrorSpoolCorrupt. May be nil.
+	OnDrop func(dataPoints int, err error)
+
+	// Paces Run. Defaults to the wall clock.
+	Clock clock.Clock
+}
+
+type spoolFile struct {
+	name       string
+	size       int64
+	dataPoints int
+}
+
+// A MetricWriter that keeps working while KairosDB is unreachable: batches
+// failing with a network error or a 502, 503 or 504 are spooled to disk and
+// replayed in order once the server answers again. While batches are
+// spooled, new batches are spooled behind them, so that the data points
+// reach the server in the order they were pushed.
+//
+// Spooled batches are answered with a 202 response. They are replayed as
+// DataPointSets: metric extensions are not kept, and integer values beyond
+// 2^53 lose precision unless the wrapped writer is a DataPointSetWriter.
+type SpoolingWriter struct {
+	MetricWriter
+	opts SpoolOptions
+
+	mu    sync.Mutex // Guards the fields below and serializes replays.
+	files []spoolFile
+	size  int64
+	next  uint64
+}
+
+// Creates a spooling writer, picking up the batches spooled to the
+// directory by a previous process.
+func NewSpoolingWriter(w MetricWriter, opts SpoolOptions) (*SpoolingWriter, error) {
+	if opts.Dir == "" {
+		return nil, ErrorSpoolDir
+	}
+
+	if opts.MaxBytes <= 0 {
+		opts.MaxBytes = 256 << 20
+	}
+
+	if opts.RetryInterval <= 0 {
+		opts.RetryInterval = 10 * time.Second
+	}
+	opts.Clock = clock.OrReal(opts.Clock)
+
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	sw := &SpoolingWriter{
+		MetricWriter: w,
+		opts:         opts,
+	}
+
+	if err := sw.load(); err != nil {
+		return nil, err
+	}
+
+	return sw, nil
+}
+
+// Sends metrics from the builder to the KairosDB server.
+func (sw *SpoolingWriter) PushMetrics(mb builder.MetricBuilder) (*response.Response, error) {
+	return sw.PushMetricsContext(context.Background(), mb)
+}
+
+// Same as PushMetrics, but the requests are aborted when the context is
+// done. The spooled batches are replayed first.
+func (sw *SpoolingWriter) PushMetricsContext(ctx context.Context, mb builder.MetricBuilder) (*response.Response, error) {
+	data, err := mb.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	if sw.Offline() {
+		if err := sw.Replay(ctx); err != nil {
+			return sw.spool(data, mb)
+		}
+	}
+
+	resp, err := sw.MetricWriter.PushMetricsContext(ctx, mb)
+	if ctx.Err() == nil && unreachable(resp, err) {
+		return sw.spool(data, mb)
+	}
+
+	return resp, err
+}
+
+// Tells whether batches are waiting in the spool.
+func (sw *SpoolingWriter) Offline() bool {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	return len(sw.files) > 0
+}
+
+// Returns the number of data points waiting in the spool.
+func (sw *SpoolingWriter) Pending() int {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	n := 0
+	for _, f := range sw.files {
+		n += f.dataPoints
+	}
+	return n
+}
+
+// The spool is bounded in bytes, not in data points.
+func (sw *SpoolingWriter) Capacity() int {
+	return 0
+}
+
+// Replays the spooled batches in order, stopping at the first one the
+// server cannot be reached for. A batch rejected by the server is dropped,
+// since it would be rejected again, and reported through OnDrop. So is a
+// batch that cannot be read or parsed, whose file is renamed with a .bad
+// suffix to be looked into.
+func (sw *SpoolingWriter) Replay(ctx context.Context) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	for len(sw.files) > 0 {
+		f := sw.files[0]
+		data, err := os.ReadFile(filepath.Join(sw.opts.Dir, f.name))
+
+		var sets []builder.DataPointSet
+		if err == nil {
+			err = json.Unmarshal(data, &sets)
+		}
+		if err != nil {
+			sw.setAside(fmt.Errorf("%w: %s: %v", ErrorSpoolCorrupt, f.name, err))
+			continue
+		}
+
+		resp, err := PushDataPointSets(ctx, sw.MetricWriter, sets)
+		if unreachable(resp, err) || (err != nil && ctx.Err() != nil) {
+			if err == nil {
+				err = fmt.Errorf("%w: status %d", ErrorSpoolReplay, resp.GetStatusCode())
+			}
+			return err
+		}
+
+		if err == nil && resp.GetStatusCode() >= http.StatusMultipleChoices {
+			err = fmt.Errorf("%w: status %d: %v", ErrorSpoolReplay, resp.GetStatusCode(), resp.GetErrors())
+		}
+		if err != nil && sw.opts.OnDrop != nil {
+			sw.opts.OnDrop(f.dataPoints, err)
+		}
+
+		sw.remove()
+	}
+
+	return nil
+}
+
+// Replays the spool every retry interval until the context is done, so that
+// spooled batches are sent even when nothing new is pushed.
+func (sw *SpoolingWriter) Run(ctx context.Context) error {
+	for {
+		timer := sw.opts.Clock.NewTimer(sw.opts.RetryInterval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+		}
+
+		if sw.Offline() {
+			sw.Replay(ctx)
+		}
+	}
+}
+
+// Tells whether a push failed for the server being out of reach, as
+// opposed to the batch being rejected.
+func unreachable(resp *response.Response, err error) bool {
+	if err != nil {
+		var re *RequestError
+		var ne net.Error
+		return errors.As(err, &re) || errors.As(err, &ne)
+	}
+
+	switch resp.GetStatusCode() {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func (sw *SpoolingWriter) spool(data []byte, mb builder.MetricBuilder) (*response.Response, error) {
+	_, dataPoints := countDataPoints(mb)
+
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	size := int64(len(data))
+	if size > sw.opts.MaxBytes {
+		return nil, ErrorSpoolFull
+	}
+
+	for sw.size+size > sw.opts.MaxBytes {
+		if sw.opts.Policy == SpoolRejectNew {
+			return nil, ErrorSpoolFull
+		}
+
+		if sw.opts.OnDrop != nil {
+			sw.opts.OnDrop(sw.files[0].dataPoints, ErrorSpoolFull)
+		}
+		sw.remove()
+	}
+
+	f := spoolFile{
+		name:       fmt.Sprintf("%020d-%d.json", sw.next, dataPoints),
+		size:       size,
+		dataPoints: dataPoints,
+	}
+
+	// Written aside and renamed, so that a crash never leaves half a batch.
+	path := filepath.Join(sw.opts.Dir, f.name)
+	if err := os.WriteFile(path+".tmp", data, 0o644); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return nil, err
+	}
+
+	sw.next++
+	sw.files = append(sw.files, f)
+	sw.size += size
+
+	resp := &response.Response{}
+	resp.SetStatusCode(http.StatusAccepted)
+	return resp, nil
+}
+
+// Removes the oldest batch of the spool.
+func (sw *SpoolingWriter) remove() {
+	f := sw.files[0]
+	os.Remove(filepath.Join(sw.opts.Dir, f.name))
+	sw.files = sw.files[1:]
+	sw.size -= f.size
+}
+
+// Moves the oldest batch of the spool out of the way and reports it.
+func (sw *SpoolingWriter) setAside(err error) {
+	f := sw.files[0]
+	path := filepath.Join(sw.opts.Dir, f.name)
+	if os.Rename(path, path+".bad") != nil {
+		os.Remove(path)
+	}
+	sw.files = sw.files[1:]
+	sw.size -= f.size
+
+	if sw.opts.OnDrop != nil {
+		sw.opts.OnDrop(f.dataPoints, err)
+	}
+}
+
+// Picks up the batches spooled to the directory, removing the ones a crash
+// left half written.
+func (sw *SpoolingWriter) load() error {
+	entries, err := os.ReadDir(sw.opts.Dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".tmp") {
+			if err := os.Remove(filepath.Join(sw.opts.Dir, e.Name())); err != nil {
+				return err
+			}
+			continue
+		}
+
+		seq, dataPoints, ok := parseSpoolName(e.Name())
+		if !ok {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			return err
+		}
+
+		sw.files = append(sw.files, spoolFile{name: e.Name(), size: info.Size(), dataPoints: dataPoints})
+		sw.size += info.Size()
+		if seq >= sw.next {
+			sw.next = seq + 1
+		}
+	}
+
+	sort.Slice(sw.files, func(i, j int) bool { return sw.files[i].name < sw.files[j].name })
+	return nil
+}
+
+// Parses "<sequence>-<data points>.json".
+func parseSpoolName(name string) (uint64, int, bool) {
+	base := strings.TrimSuffix(name, ".json")
+	if base == name {
+		return 0, 0, false
+	}
+
+	seqStr, dpStr, ok := strings.Cut(base, "-")
+	if !ok {
+		return 0, 0, false
+	}
+
+	seq, err := strconv.ParseUint(seqStr, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	dataPoints, err := strconv.Atoi(dpStr)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return seq, dataPoints, true
+}
@@ -0,0 +1,183 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+// Answers 503 while down is set.
+func newFlakyServer(down *int32, bodies *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		*bodies = append(*bodies, string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+func spoolBatch(ts int64) builder.MetricBuilder {
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(ts, ts)
+	return mb
+}
+
+// Success test.
+func TestSpoolingWriter(t *testing.T) {
+	down := int32(1)
+	var bodies []string
+	srv := newFlakyServer(&down, &bodies)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	sw, err := NewSpoolingWriter(NewHttpClient(srv.URL), SpoolOptions{Dir: dir})
+	assert.NoError(t, err)
+
+	resp, err := sw.PushMetrics(spoolBatch(1))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusAccepted, resp.GetStatusCode())
+
+	_, err = sw.PushMetrics(spoolBatch(2))
+	assert.NoError(t, err)
+	assert.True(t, sw.Offline())
+	assert.Equal(t, 2, sw.Pending())
+
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 2)
+
+	// A new writer resumes the spool of the previous one.
+	sw, err = NewSpoolingWriter(NewHttpClient(srv.URL), SpoolOptions{Dir: dir})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sw.Pending())
+
+	atomic.StoreInt32(&down, 0)
+	resp, err = sw.PushMetrics(spoolBatch(3))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode())
+	assert.False(t, sw.Offline())
+
+	assert.Equal(t, []string{
+		`[{"name":"m1","tags":{"host":"h1"},"datapoints":[[1,1]]}]`,
+		`[{"name":"m1","tags":{"host":"h1"},"datapoints":[[2,2]]}]`,
+		`[{"name":"m1","tags":{"host":"h1"},"datapoints":[[3,3]]}]`,
+	}, bodies)
+
+	entries, _ = os.ReadDir(dir)
+	assert.Empty(t, entries)
+}
+
+// Success test.
+func TestSpoolingWriterDropOldest(t *testing.T) {
+	down := int32(1)
+	var bodies []string
+	srv := newFlakyServer(&down, &bodies)
+	defer srv.Close()
+
+	batch, _ := spoolBatch(1).Build()
+	var dropped int
+	sw, err := NewSpoolingWriter(NewHttpClient(srv.URL), SpoolOptions{
+		Dir:      t.TempDir(),
+		MaxBytes: int64(2 * len(batch)),
+		OnDrop:   func(dataPoints int, err error) { dropped += dataPoints },
+	})
+	assert.NoError(t, err)
+
+	for ts := int64(1); ts <= 3; ts++ {
+		_, err = sw.PushMetrics(spoolBatch(ts))
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, dropped)
+	assert.Equal(t, 2, sw.Pending())
+
+	atomic.StoreInt32(&down, 0)
+	assert.NoError(t, sw.Replay(context.Background()))
+	assert.Equal(t, []string{
+		`[{"name":"m1","tags":{"host":"h1"},"datapoints":[[2,2]]}]`,
+		`[{"name":"m1","tags":{"host":"h1"},"datapoints":[[3,3]]}]`,
+	}, bodies)
+}
+
+// Failure test.
+func TestSpoolingWriterRejectNew(t *testing.T) {
+	down := int32(1)
+	var bodies []string
+	srv := newFlakyServer(&down, &bodies)
+	defer srv.Close()
+
+	batch, _ := spoolBatch(1).Build()
+	sw, err := NewSpoolingWriter(NewHttpClient(srv.URL), SpoolOptions{
+		Dir:      t.TempDir(),
+		MaxBytes: int64(len(batch)),
+		Policy:   SpoolRejectNew,
+	})
+	assert.NoError(t, err)
+
+	_, err = sw.PushMetrics(spoolBatch(1))
+	assert.NoError(t, err)
+	_, err = sw.PushMetrics(spoolBatch(2))
+	assert.ErrorIs(t, err, ErrorSpoolFull)
+	assert.Equal(t, 1, sw.Pending())
+
+	_, err = NewSpoolingWriter(NewHttpClient(srv.URL), SpoolOptions{})
+	assert.ErrorIs(t, err, ErrorSpoolDir)
+}
+
+// Failure test.
+func TestSpoolingWriterCorrupt(t *testing.T) {
+	down := int32(0)
+	var bodies []string
+	srv := newFlakyServer(&down, &bodies)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"00000000000000000000-1.json":     `[{"name":"m1","datapoints":[[1,`,
+		"00000000000000000001-1.json":     `[{"name":"m1","tags":{"host":"h1"},"datapoints":[[2,2]]}]`,
+		"00000000000000000002-1.json.tmp": `[{"name":"m1"`,
+	} {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0o644))
+	}
+
+	var dropped []error
+	sw, err := NewSpoolingWriter(NewHttpClient(srv.URL), SpoolOptions{
+		Dir:    dir,
+		OnDrop: func(dataPoints int, err error) { dropped = append(dropped, err) },
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, sw.Pending(), "Half written batches must not be picked up")
+
+	assert.NoError(t, sw.Replay(context.Background()), "An unparsable batch must not stop the replay")
+	assert.False(t, sw.Offline())
+	assert.Len(t, dropped, 1)
+	assert.ErrorIs(t, dropped[0], ErrorSpoolCorrupt)
+	assert.Equal(t, []string{`[{"name":"m1","tags":{"host":"h1"},"datapoints":[[2,2]]}]`}, bodies)
+
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "00000000000000000000-1.json.bad", entries[0].Name(), "The unparsable batch must be set aside")
+}
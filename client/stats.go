@@ -0,0 +1,130 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// A snapshot of the counters of a client, since its creation.
+type Stats struct {
+	// HTTP requests sent, and those that failed or were answered with an
+	// error status.
+	Requests int64
+	Failures int64
+
+	// Queries run, including tag queries.
+	Queries int64
+
+	// Successful pushes and the data points they carried.
+	Pushes            int64
+	DataPointsWritten int64
+
+	// Request body bytes sent, after compression, and response body bytes
+	// read.
+	BytesSent     int64
+	BytesReceived int64
+
+	// Body bytes of the successful pushes before and after compression,
+	// and the time they took.
+	PushRawBytes int64
+	PushBytes    int64
+	PushLatency  time.Duration
+}
+
+// Returns the average number of data points of a successful push.
+func (s Stats) DataPointsPerPush() float64 {
+	if s.Pushes == 0 {
+		return 0
+	}
+	return float64(s.DataPointsWritten) / float64(s.Pushes)
+}
+
+// Returns the raw size of the pushes over their size as sent, 1 when
+// nothing was compressed.
+func (s Stats) CompressionRatio() float64 {
+	if s.PushBytes == 0 {
+		return 1
+	}
+	return float64(s.PushRawBytes) / float64(s.PushBytes)
+}
+
+// Returns the average time taken by a successful push.
+func (s Stats) AvgPushLatency() time.Duration {
+	if s.Pushes == 0 {
+		return 0
+	}
+	return s.PushLatency / time.Duration(s.Pushes)
+}
+
+type clientStats struct {
+	requests      atomic.Int64
+	failures      atomic.Int64
+	queries       atomic.Int64
+	pushes        atomic.Int64
+	dataPoints    atomic.Int64
+	bytesSent     atomic.Int64
+	bytesReceived atomic.Int64
+	pushRawBytes  atomic.Int64
+	pushBytes     atomic.Int64
+	pushLatency   atomic.Int64 // In nanoseconds.
+}
+
+// Returns a snapshot of the counters of the client.
+func (hc *httpClient) Stats() Stats {
+	s := &hc.stats
+	return Stats{
+		Requests:          s.requests.Load(),
+		Failures:          s.failures.Load(),
+		Queries:           s.queries.Load(),
+		Pushes:            s.pushes.Load(),
+		DataPointsWritten: s.dataPoints.Load(),
+		BytesSent:         s.bytesSent.Load(),
+		BytesReceived:     s.bytesReceived.Load(),
+		PushRawBytes:      s.pushRawBytes.Load(),
+		PushBytes:         s.pushBytes.Load(),
+		PushLatency:       time.Duration(s.pushLatency.Load()),
+	}
+}
+
+func (s *clientStats) recordRequest(req *http.Request, resp *http.Response, err error) {
+	s.requests.Add(1)
+	if req.ContentLength > 0 {
+		s.bytesSent.Add(req.ContentLength)
+	}
+
+	if err != nil || resp.StatusCode >= http.StatusMultipleChoices {
+		s.failures.Add(1)
+	}
+
+	if resp != nil && resp.Body != nil {
+		resp.Body = &countingBody{ReadCloser: resp.Body, n: &s.bytesReceived}
+	}
+}
+
+// Counts the bytes read from a response body.
+type countingBody struct {
+	io.ReadCloser
+	n *atomic.Int64
+}
+
+func (cb *countingBody) Read(p []byte) (int, error) {
+	n, err := cb.ReadCloser.Read(p)
+	cb.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,113 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestStats(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case query_ep:
+			w.Write([]byte(`{"queries":[]}`))
+		case datapoints_ep:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	cli := NewHttpClient(srv.URL)
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1, 2).AddDataPoint(2, 3)
+	data, _ := mb.Build()
+	_, err := cli.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("m1")
+	query, _ := qb.Build()
+	_, err = cli.Query(qb)
+	assert.Nil(t, err, "No error expected")
+
+	cli.HealthCheck()
+
+	stats := cli.Stats()
+	assert.True(t, stats.PushLatency > 0, "Push latency must be measured")
+	assert.Equal(t, stats.PushLatency, stats.AvgPushLatency())
+	stats.PushLatency = 0
+
+	assert.Equal(t, Stats{
+		Requests:          3,
+		Failures:          1,
+		Queries:           1,
+		Pushes:            1,
+		DataPointsWritten: 2,
+		BytesSent:         int64(len(data) + len(query)),
+		BytesReceived:     int64(len(`{"queries":[]}`)),
+		PushRawBytes:      int64(len(data)),
+		PushBytes:         int64(len(data)),
+	}, stats)
+	assert.Equal(t, 2.0, stats.DataPointsPerPush())
+	assert.Equal(t, 1.0, stats.CompressionRatio())
+}
+
+// Success test.
+func TestStatsCompression(t *testing.T) {
+	var sent int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		sent = len(body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	var infos []PushInfo
+	cli := NewHttpClientWithOptions(srv.URL, WithGzip(0, gzip.BestCompression), WithPushHooks(PushHooks{
+		OnSuccess: func(info PushInfo) { infos = append(infos, info) },
+	}))
+
+	mb := builder.NewMetricBuilder()
+	m := mb.AddMetric("m1").AddTag("host", "h1")
+	for i := int64(0); i < 100; i++ {
+		m.AddDataPoint(i, 1)
+	}
+	data, _ := mb.Build()
+	_, err := cli.PushMetrics(mb)
+	assert.NoError(t, err)
+
+	stats := cli.Stats()
+	assert.Equal(t, int64(len(data)), stats.PushRawBytes)
+	assert.Equal(t, int64(sent), stats.PushBytes)
+	assert.True(t, stats.CompressionRatio() > 2, "The batch must compress")
+	assert.Equal(t, 100.0, stats.DataPointsPerPush())
+
+	assert.Len(t, infos, 1)
+	assert.Equal(t, len(data), infos[0].RawBytes)
+	assert.Equal(t, sent, infos[0].Bytes)
+	assert.Equal(t, stats.CompressionRatio(), infos[0].CompressionRatio())
+}
@@ -0,0 +1,214 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/clock"
+)
+
+// Options of the TagCache.
+type TagCacheOptions struct {
+	// How long the tags of a metric are served from the cache. Defaults to
+	// a minute.
+	TTL time.Duration
+
+	// Time range looked up by TagKeys and TagValues, ending now. Defaults to
+	// 24 hours.
+	Lookback time.Duration
+
+	// Tells the age of the cached tags and the end of the lookback window.
+	// Defaults to the wall clock.
+	Clock clock.Clock
+}
+
+type tagCacheEntry struct {
+	start    time.Time
+	tags     map[string][]string
+	err      error
+	storedAt time.Time
+	ready    chan struct{} // Closed once the lookup is done.
+}
+
+// A TagLister caching the tags of every metric for a TTL, e.g. to expand tag
+// patterns or to autocomplete tag filters without running a tags query each
+// time. Concurrent lookups of the same metric share a single tags query,
+// which is not canceled when the caller that started it gives up: the
+// others may still be waiting for it.
+//
+// A cached lookup is reused for any time range starting no earlier than the
+// range it was made for. Tags which first appeared after the lookup are
+// therefore missed until the entry expires or is invalidated.
+type TagCache struct {
+	lister TagLister
+	opts   TagCacheOptions
+
+	mu      sync.Mutex // Guards entries.
+	entries map[string]*tagCacheEntry
+}
+
+func NewTagCache(tl TagLister, opts TagCacheOptions) *TagCache {
+	if opts.TTL <= 0 {
+		opts.TTL = time.Minute
+	}
+	if opts.Lookback <= 0 {
+		opts.Lookback = 24 * time.Hour
+	}
+	opts.Clock = clock.OrReal(opts.Clock)
+
+	return &TagCache{
+		lister:  tl,
+		opts:    opts,
+		entries: make(map[string]*tagCacheEntry),
+	}
+}
+
+// Returns the values of every tag of the metric over the time range. The
+// returned map is shared with the cache and must not be modified.
+func (tc *TagCache) ListTags(ctx context.Context, metric string, tr builder.TimeRange) (map[string][]string, error) {
+	tc.mu.Lock()
+	entry, ok := tc.entries[metric]
+	if ok && !tc.covers(entry, tr) {
+		delete(tc.entries, metric)
+		ok = false
+	}
+
+	if !ok {
+		entry = &tagCacheEntry{
+			start: tr.Start,
+			ready: make(chan struct{}),
+		}
+		tc.entries[metric] = entry
+		go tc.load(detachedContext{ctx}, metric, tr, entry)
+	}
+	tc.mu.Unlock()
+
+	select {
+	case <-entry.ready:
+		return entry.tags, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Returns the sorted names of the tags of the metric starting with the
+// prefix, over the lookback window.
+func (tc *TagCache) TagKeys(ctx context.Context, metric string, prefix string) ([]string, error) {
+	tags, err := tc.ListTags(ctx, metric, tc.lookback())
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	for k := range tags {
+		if strings.HasPrefix(k, prefix) {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Returns the sorted values of a tag of the metric starting with the prefix,
+// over the lookback window.
+func (tc *TagCache) TagValues(ctx context.Context, metric string, tag string, prefix string) ([]string, error) {
+	tags, err := tc.ListTags(ctx, metric, tc.lookback())
+	if err != nil {
+		return nil, err
+	}
+
+	var vals []string
+	for _, v := range tags[tag] {
+		if strings.HasPrefix(v, prefix) {
+			vals = append(vals, v)
+		}
+	}
+
+	sort.Strings(vals)
+	return vals, nil
+}
+
+// Drops the cached tags of the metrics, e.g. after new series were pushed.
+func (tc *TagCache) Invalidate(metrics ...string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+
+	for _, m := range metrics {
+		delete(tc.entries, m)
+	}
+}
+
+// Drops the cached tags of all the metrics.
+func (tc *TagCache) Purge() {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	tc.entries = make(map[string]*tagCacheEntry)
+}
+
+// Tells whether the entry can serve a lookup over the time range. Entries
+// still loading a range that covers it are shared.
+func (tc *TagCache) covers(entry *tagCacheEntry, tr builder.TimeRange) bool {
+	if tr.Start.Before(entry.start) {
+		return false
+	}
+
+	select {
+	case <-entry.ready:
+	default:
+		return true
+	}
+
+	return entry.err == nil && tc.opts.Clock.Now().Sub(entry.storedAt) < tc.opts.TTL
+}
+
+func (tc *TagCache) load(ctx context.Context, metric string, tr builder.TimeRange, entry *tagCacheEntry) {
+	tags, err := tc.lister.ListTags(ctx, metric, tr)
+
+	tc.mu.Lock()
+	entry.tags, entry.err, entry.storedAt = tags, err, tc.opts.Clock.Now()
+	if err != nil && tc.entries[metric] == entry {
+		// Failures are not cached, the next lookup tries again.
+		delete(tc.entries, metric)
+	}
+	tc.mu.Unlock()
+
+	close(entry.ready)
+}
+
+// A context carrying the values of its parent, e.g. its trace, but neither
+// its deadline nor its cancelation.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (dc detachedContext) Value(key interface{}) interface{} {
+	return dc.parent.Value(key)
+}
+
+func (tc *TagCache) lookback() builder.TimeRange {
+	now := tc.opts.Clock.Now()
+	return builder.TimeRange{Start: now.Add(-tc.opts.Lookback), End: now}
+}
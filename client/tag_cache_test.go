@@ -0,0 +1,222 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingTagLister struct {
+	mu    sync.Mutex
+	calls int
+	err   error
+}
+
+func (tl *countingTagLister) ListTags(ctx context.Context, metric string, tr builder.TimeRange) (map[string][]string, error) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	tl.calls++
+	if tl.err != nil {
+		return nil, tl.err
+	}
+	return map[string][]string{"host": {"web-2", "web-1", "db-1"}, "dc": {"eu"}}, nil
+}
+
+// Success test.
+func TestTagCache(t *testing.T) {
+	tl := &countingTagLister{}
+	tc := NewTagCache(tl, TagCacheOptions{TTL: time.Minute})
+	ctx := context.Background()
+
+	keys, err := tc.TagKeys(ctx, "cpu", "")
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, []string{"dc", "host"}, keys)
+
+	vals, err := tc.TagValues(ctx, "cpu", "host", "web")
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, []string{"web-1", "web-2"}, vals)
+	assert.Equal(t, 1, tl.calls, "Tags must be cached")
+
+	// A range starting before the cached lookup is not covered.
+	now := time.Now()
+	_, err = tc.ListTags(ctx, "cpu", builder.TimeRange{Start: now.Add(-48 * time.Hour), End: now})
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 2, tl.calls)
+
+	tc.Invalidate("cpu")
+	_, err = tc.TagKeys(ctx, "cpu", "")
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 3, tl.calls, "Invalidated tags must be looked up again")
+
+	_, err = tc.TagKeys(ctx, "mem", "")
+	assert.Nil(t, err, "No error expected")
+	tc.Purge()
+	_, err = tc.TagKeys(ctx, "mem", "")
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 5, tl.calls)
+}
+
+// Success test.
+func TestTagCacheExpiry(t *testing.T) {
+	tl := &countingTagLister{}
+	clk := clock.NewFake(time.Now())
+	tc := NewTagCache(tl, TagCacheOptions{TTL: time.Minute, Clock: clk})
+
+	_, err := tc.TagKeys(context.Background(), "cpu", "")
+	assert.Nil(t, err, "No error expected")
+	clk.Advance(time.Minute)
+	_, err = tc.TagKeys(context.Background(), "cpu", "")
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 2, tl.calls, "Expired tags must be looked up again")
+}
+
+// Success test.
+func TestTagCacheExpandPatterns(t *testing.T) {
+	var tagQueries int
+	var queries []string
+	srv := newTagsServer(&tagQueries, &queries)
+	defer srv.Close()
+
+	cli := NewHttpClient(srv.URL)
+	tc := NewTagCache(NewTagLister(cli), TagCacheOptions{TTL: time.Minute})
+	glob, _ := GlobMatcher("web-*")
+
+	for i := 0; i < 3; i++ {
+		qb := builder.NewQueryBuilder()
+		qb.SetRelativeStart(1, utils.HOURS).AddMetric("cpu")
+		err := ExpandTagPatterns(context.Background(), tc, qb, TagPattern{Metric: 0, Tag: "host", Match: glob})
+		assert.Nil(t, err, "No error expected")
+		data, _ := qb.Build()
+		assert.Contains(t, string(data), `"tags":{"host":["web-1","web-2"]}`)
+	}
+
+	assert.Equal(t, 1, tagQueries, "Tags must be queried once")
+}
+
+// Failure test.
+func TestTagCacheError(t *testing.T) {
+	tl := &countingTagLister{err: errors.New("unavailable")}
+	tc := NewTagCache(tl, TagCacheOptions{TTL: time.Minute})
+
+	_, err := tc.TagKeys(context.Background(), "cpu", "")
+	assert.Equal(t, tl.err, err)
+	_, err = tc.TagKeys(context.Background(), "cpu", "")
+	assert.Equal(t, tl.err, err)
+	assert.Equal(t, 2, tl.calls, "Failures must not be cached")
+}
+
+type blockingTagLister struct {
+	countingTagLister
+	release chan struct{}
+}
+
+func (tl *blockingTagLister) ListTags(ctx context.Context, metric string, tr builder.TimeRange) (map[string][]string, error) {
+	select {
+	case <-tl.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return tl.countingTagLister.ListTags(ctx, metric, tr)
+}
+
+// Success test.
+func TestTagCacheSharedLoad(t *testing.T) {
+	tl := &blockingTagLister{release: make(chan struct{})}
+	tc := NewTagCache(tl, TagCacheOptions{})
+	now := time.Now()
+	day := builder.TimeRange{Start: now.Add(-24 * time.Hour), End: now}
+
+	// The caller starting the lookup gives up, the one sharing it does not.
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := tc.ListTags(ctx, "cpu", day)
+		first <- err
+	}()
+	assert.Eventually(t, func() bool {
+		tc.mu.Lock()
+		defer tc.mu.Unlock()
+		return tc.entries["cpu"] != nil
+	}, time.Second, time.Millisecond)
+
+	second := make(chan error, 1)
+	go func() {
+		_, err := tc.ListTags(context.Background(), "cpu", builder.TimeRange{Start: now.Add(-time.Hour), End: now})
+		second <- err
+	}()
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-first, "Caller's own cancelation expected")
+	close(tl.release)
+	assert.Nil(t, <-second, "Shared lookup must survive the cancelation of its starter")
+
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+	assert.Equal(t, 1, tl.calls, "Lookup must be shared")
+}
+
+// Success test.
+func TestTagCacheLoadingRange(t *testing.T) {
+	tl := &blockingTagLister{release: make(chan struct{})}
+	tc := NewTagCache(tl, TagCacheOptions{})
+	now := time.Now()
+	entry := func() *tagCacheEntry {
+		tc.mu.Lock()
+		defer tc.mu.Unlock()
+		return tc.entries["cpu"]
+	}
+
+	var wg sync.WaitGroup
+	lookup := func(d time.Duration) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := tc.ListTags(context.Background(), "cpu", builder.TimeRange{Start: now.Add(-d), End: now})
+			assert.Nil(t, err, "No error expected")
+		}()
+	}
+
+	lookup(time.Hour)
+	assert.Eventually(t, func() bool { return entry() != nil }, time.Second, time.Millisecond)
+	hour := entry()
+
+	// A loading entry for a shorter range must not serve a longer one.
+	lookup(24 * time.Hour)
+	assert.Eventually(t, func() bool { return entry() != hour }, time.Second, time.Millisecond,
+		"Longer range must be looked up")
+
+	close(tl.release)
+	wg.Wait()
+	assert.Equal(t, 2, tl.calls)
+}
+
+// Success test.
+func TestTagCacheDefaultTTL(t *testing.T) {
+	tl := &countingTagLister{}
+	tc := NewTagCache(tl, TagCacheOptions{})
+
+	tc.TagKeys(context.Background(), "cpu", "")
+	tc.TagKeys(context.Background(), "cpu", "")
+	assert.Equal(t, 1, tl.calls, "Tags must be cached without a TTL")
+}
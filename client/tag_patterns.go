@@ -0,0 +1,173 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Reports whether a tag value matches, see TagPattern.
+type TagMatcher func(value string) bool
+
+// Returns a matcher of the values matching the shell pattern, see
+// path.Match, e.g. "web-*" or "eu-?".
+func GlobMatcher(pattern string) (TagMatcher, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("%w: %q: %v", ErrorTagPatternInvalid, pattern, err)
+	}
+
+	return func(value string) bool {
+		ok, _ := path.Match(pattern, value)
+		return ok
+	}, nil
+}
+
+// Returns a matcher of the values matching the regular expression. Like
+// regexp.MatchString, it matches anywhere in the value unless anchored.
+func RegexpMatcher(expr string) (TagMatcher, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %v", ErrorTagPatternInvalid, expr, err)
+	}
+
+	return re.MatchString, nil
+}
+
+// A tag of a query metric filtered by a pattern rather than by concrete
+// values, which KairosDB does not support.
+type TagPattern struct {
+	// Index of the metric in the query.
+	Metric int
+
+	Tag   string
+	Match TagMatcher
+}
+
+// Looks up the tags of a metric along with their values.
+type TagLister interface {
+	// Returns the values of every tag of the metric over the time range.
+	ListTags(ctx context.Context, metric string, tr builder.TimeRange) (map[string][]string, error)
+}
+
+type readerTagLister struct {
+	c MetricReader
+}
+
+// Returns a TagLister running a tags query for every lookup.
+func NewTagLister(c MetricReader) TagLister {
+	return &readerTagLister{c: c}
+}
+
+func (tl *readerTagLister) ListTags(ctx context.Context, metric string, tr builder.TimeRange) (map[string][]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	qb := builder.NewQueryBuilder()
+	qb.SetTimeRange(tr.Start, tr.End).AddMetric(metric)
+
+	resp, err := tl.c.QueryTags(qb)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.GetStatusCode() >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("%w: status %d: %v", ErrorTagsQuery, resp.GetStatusCode(), resp.GetErrors())
+	}
+
+	tags := make(map[string][]string)
+	for _, q := range resp.QueriesArr {
+		for _, r := range q.ResultsArr {
+			for name, vals := range r.Tags {
+				tags[name] = appendMissing(tags[name], vals)
+			}
+		}
+	}
+
+	return tags, nil
+}
+
+// Replaces the tag patterns with the values they match, looked up over the
+// time range of the query. The tag filters of the query metrics are set in
+// place, so the query must not be frozen. A pattern matching no value fails
+// with an error wrapping ErrorNoTagMatch, since KairosDB would read every
+// series of the metric when given an empty filter.
+func ExpandTagPatterns(ctx context.Context, tl TagLister, qb builder.QueryBuilder, patterns ...TagPattern) error {
+	tr, err := builder.ResolveTimeRange(qb, clockOf(tl).Now())
+	if err != nil {
+		return err
+	}
+
+	metrics := qb.Metrics()
+	listed := make(map[string]map[string][]string)
+	for _, p := range patterns {
+		if p.Metric < 0 || p.Metric >= len(metrics) {
+			return builder.ErrorMetricIndexInvalid
+		}
+
+		qm := metrics[p.Metric]
+		tags, ok := listed[qm.GetName()]
+		if !ok {
+			if tags, err = tl.ListTags(ctx, qm.GetName(), tr); err != nil {
+				return err
+			}
+			listed[qm.GetName()] = tags
+		}
+
+		var matched []string
+		for _, v := range tags[p.Tag] {
+			if p.Match(v) {
+				matched = append(matched, v)
+			}
+		}
+
+		if len(matched) == 0 {
+			return fmt.Errorf("%w: metric %s, tag %s", ErrorNoTagMatch, qm.GetName(), p.Tag)
+		}
+
+		sort.Strings(matched)
+		qm.AddTag(p.Tag, matched)
+	}
+
+	return nil
+}
+
+// Expands the tag patterns with tags queries, see ExpandTagPatterns, then
+// runs the query.
+func QueryTagPatterns(ctx context.Context, c MetricReader, qb builder.QueryBuilder, patterns ...TagPattern) (*response.QueryResponse, error) {
+	if err := ExpandTagPatterns(ctx, NewTagLister(c), qb, patterns...); err != nil {
+		return nil, err
+	}
+
+	return c.QueryContext(ctx, qb)
+}
+
+// Appends the values not in vals yet.
+func appendMissing(vals []string, add []string) []string {
+	for _, v := range add {
+		if !containsString(vals, v) {
+			vals = append(vals, v)
+		}
+	}
+	return vals
+}
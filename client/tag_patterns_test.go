@@ -0,0 +1,94 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTagsServer(tagQueries *int, queries *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		switch r.URL.Path {
+		case querytags_ep:
+			*tagQueries++
+			w.Write([]byte(`{"queries":[{"results":[{"name":"cpu","tags":{"host":["web-1","web-2","db-1"],"dc":["eu"]}}]}]}`))
+		case query_ep:
+			*queries = append(*queries, string(body))
+			w.Write([]byte(`{"queries":[{"results":[]}]}`))
+		}
+	}))
+}
+
+// Success test.
+func TestQueryTagPatterns(t *testing.T) {
+	var tagQueries int
+	var queries []string
+	srv := newTagsServer(&tagQueries, &queries)
+	defer srv.Close()
+
+	glob, err := GlobMatcher("web-*")
+	assert.Nil(t, err, "No error expected")
+	re, err := RegexpMatcher("^e")
+	assert.Nil(t, err, "No error expected")
+
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("cpu")
+
+	_, err = QueryTagPatterns(context.Background(), NewHttpClient(srv.URL), qb,
+		TagPattern{Metric: 0, Tag: "host", Match: glob},
+		TagPattern{Metric: 0, Tag: "dc", Match: re})
+	assert.Nil(t, err, "No error expected")
+
+	assert.Equal(t, 1, tagQueries, "Tags must be listed once per metric")
+	assert.Equal(t, []string{`{"start_relative":{"value":1,"unit":"hours"},"metrics":[{"tags":{"dc":["eu"],"host":["web-1","web-2"]},"name":"cpu"}]}`},
+		queries, "Patterns must be replaced with the matching values")
+}
+
+// Failure test.
+func TestQueryTagPatternsNoMatch(t *testing.T) {
+	var tagQueries int
+	var queries []string
+	srv := newTagsServer(&tagQueries, &queries)
+	defer srv.Close()
+
+	glob, _ := GlobMatcher("cache-*")
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("cpu")
+
+	_, err := QueryTagPatterns(context.Background(), NewHttpClient(srv.URL), qb, TagPattern{Metric: 0, Tag: "host", Match: glob})
+	assert.ErrorIs(t, err, ErrorNoTagMatch)
+	assert.Empty(t, queries, "Query must not be sent")
+
+	_, err = QueryTagPatterns(context.Background(), NewHttpClient(srv.URL), qb, TagPattern{Metric: 1, Tag: "host", Match: glob})
+	assert.Equal(t, builder.ErrorMetricIndexInvalid, err)
+}
+
+// Failure test.
+func TestTagMatcherInvalid(t *testing.T) {
+	_, err := GlobMatcher("web-[")
+	assert.ErrorIs(t, err, ErrorTagPatternInvalid)
+
+	_, err = RegexpMatcher("web-(")
+	assert.ErrorIs(t, err, ErrorTagPatternInvalid)
+}
@@ -0,0 +1,148 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import "encoding/json"
+
+// How the tenant of a client is conveyed to KairosDB. At least one of the
+// fields should be set.
+type TenantOptions struct {
+	// Header carrying the tenant ID on every request, for gateways that
+	// route or authorize by tenant. Empty sends no header.
+	Header string
+
+	// Tag holding the tenant ID. It is added to every pushed metric and to
+	// the filter of every query and deletion, so that a tenant can neither
+	// read nor delete the data of another. Writing or querying with a
+	// different value for the tag fails with ErrorTenantMismatch. Empty
+	// disables the enforcement.
+	Tag string
+}
+
+// Scopes the client to a tenant. Serving several tenants over one KairosDB
+// takes one client per tenant. The metric, tag name and tag value listings
+// are not tenant scoped, and DeleteMetric is refused when the tenant tag is
+// enforced since it would delete the data of all the tenants.
+func WithTenant(id string, opts TenantOptions) Option {
+	return func(hc *httpClient) {
+		hc.tenant = &tenantScope{
+			id:     id,
+			header: opts.Header,
+			tag:    opts.Tag,
+		}
+	}
+}
+
+type tenantScope struct {
+	id     string
+	header string
+	tag    string
+}
+
+// Restricts every metric of an encoded query to the tenant.
+func (ts *tenantScope) scopeQuery(data []byte) ([]byte, error) {
+	if ts.tag == "" {
+		return data, nil
+	}
+
+	var q map[string]json.RawMessage
+	if err := json.Unmarshal(data, &q); err != nil {
+		return nil, err
+	}
+
+	var metrics []map[string]json.RawMessage
+	if err := json.Unmarshal(q["metrics"], &metrics); err != nil {
+		return nil, err
+	}
+
+	for _, m := range metrics {
+		if m == nil {
+			return nil, ErrorTenantMetricNull
+		}
+
+		var tags map[string][]string
+		if raw, ok := m["tags"]; ok {
+			if err := json.Unmarshal(raw, &tags); err != nil {
+				return nil, err
+			}
+		}
+		if tags == nil {
+			// No tags at all, or "tags": null.
+			tags = make(map[string][]string)
+		}
+
+		for _, v := range tags[ts.tag] {
+			if v != ts.id {
+				return nil, ErrorTenantMismatch
+			}
+		}
+		tags[ts.tag] = []string{ts.id}
+
+		raw, err := json.Marshal(tags)
+		if err != nil {
+			return nil, err
+		}
+		m["tags"] = raw
+	}
+
+	raw, err := json.Marshal(metrics)
+	if err != nil {
+		return nil, err
+	}
+	q["metrics"] = raw
+
+	return json.Marshal(q)
+}
+
+// Tags every metric of an encoded metric list with the tenant.
+func (ts *tenantScope) scopeMetrics(data []byte) ([]byte, error) {
+	if ts.tag == "" {
+		return data, nil
+	}
+
+	var metrics []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &metrics); err != nil {
+		return nil, err
+	}
+
+	for _, m := range metrics {
+		if m == nil {
+			return nil, ErrorTenantMetricNull
+		}
+
+		var tags map[string]string
+		if raw, ok := m["tags"]; ok {
+			if err := json.Unmarshal(raw, &tags); err != nil {
+				return nil, err
+			}
+		}
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+
+		if v, ok := tags[ts.tag]; ok && v != ts.id {
+			return nil, ErrorTenantMismatch
+		}
+		tags[ts.tag] = ts.id
+
+		raw, err := json.Marshal(tags)
+		if err != nil {
+			return nil, err
+		}
+		m["tags"] = raw
+	}
+
+	return json.Marshal(metrics)
+}
@@ -0,0 +1,109 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type tenantCapture struct {
+	header, body string
+}
+
+func newTenantServer(captured *[]tenantCapture) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		*captured = append(*captured, tenantCapture{header: r.Header.Get("X-Tenant"), body: string(body)})
+		if r.URL.Path == query_ep {
+			w.Write([]byte(`{"queries":[]}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+}
+
+// Success test.
+func TestWithTenant(t *testing.T) {
+	var captured []tenantCapture
+	srv := newTenantServer(&captured)
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithTenant("acme", TenantOptions{Header: "X-Tenant", Tag: "tenant"}))
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("host", "h1").AddDataPoint(1, 2)
+	_, err := cli.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("m1")
+	_, err = cli.Query(qb)
+	assert.Nil(t, err, "No error expected")
+
+	assert.Equal(t, "acme", captured[0].header, "Tenant header expected on writes")
+	assert.Equal(t, `[{"datapoints":[[1,2]],"name":"m1","tags":{"host":"h1","tenant":"acme"}}]`, captured[0].body,
+		"Pushed metrics must be tagged with the tenant")
+	assert.Equal(t, "acme", captured[1].header, "Tenant header expected on queries")
+	assert.Equal(t, `{"metrics":[{"name":"m1","tags":{"tenant":["acme"]}}],"start_relative":{"value":1,"unit":"hours"}}`,
+		captured[1].body, "Queries must be filtered on the tenant")
+}
+
+// Failure test.
+func TestWithTenantMismatch(t *testing.T) {
+	var captured []tenantCapture
+	srv := newTenantServer(&captured)
+	defer srv.Close()
+
+	cli := NewHttpClientWithOptions(srv.URL, WithTenant("acme", TenantOptions{Tag: "tenant"}))
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("m1").AddTag("tenant", "other").AddDataPoint(1, 2)
+	_, err := cli.PushMetrics(mb)
+	assert.Equal(t, ErrorTenantMismatch, err, "Writing for another tenant must fail")
+
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("m1").AddTag("tenant", []string{"acme", "other"})
+	_, err = cli.Query(qb)
+	assert.Equal(t, ErrorTenantMismatch, err, "Querying another tenant must fail")
+
+	_, err = cli.DeleteMetric("m1")
+	assert.Equal(t, ErrorTenantDeleteMetric, err, "Deleting a whole metric must fail")
+	assert.Empty(t, captured, "Nothing must be sent")
+}
+
+// Failure test.
+func TestTenantScopeNull(t *testing.T) {
+	ts := &tenantScope{tag: "tenant", id: "acme"}
+
+	data, err := ts.scopeMetrics([]byte(`[{"name":"m1","tags":null}]`))
+	assert.Nil(t, err, "Null tags must be scoped like missing ones")
+	assert.Equal(t, `[{"name":"m1","tags":{"tenant":"acme"}}]`, string(data))
+
+	data, err = ts.scopeQuery([]byte(`{"metrics":[{"name":"m1","tags":null}]}`))
+	assert.Nil(t, err, "Null tags must be scoped like missing ones")
+	assert.Equal(t, `{"metrics":[{"name":"m1","tags":{"tenant":["acme"]}}]}`, string(data))
+
+	_, err = ts.scopeMetrics([]byte(`[null]`))
+	assert.Equal(t, ErrorTenantMetricNull, err)
+	_, err = ts.scopeQuery([]byte(`{"metrics":[null]}`))
+	assert.Equal(t, ErrorTenantMetricNull, err)
+}
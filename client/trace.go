@@ -0,0 +1,122 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+)
+
+// Connection level timings of a single request. Phases that did not happen,
+// e.g. DNS and connect on a reused connection, are zero.
+type RequestTrace struct {
+	Method     string
+	URL        string
+	StatusCode int   // Zero when the request failed.
+	Err        error // The request error, if any.
+
+	ConnReused      bool          // Whether an idle connection was reused.
+	DNS             time.Duration // Time spent resolving the host name.
+	Connect         time.Duration // Time spent establishing the TCP connection.
+	TLSHandshake    time.Duration // Time spent in the TLS handshake.
+	TimeToFirstByte time.Duration // Time from the start of the request to the first response byte.
+	Total           time.Duration // Time until the response headers were received.
+}
+
+// Sends the request, retrying it when configured to. Errors are wrapped in a
+// *RequestError.
+func (hc *httpClient) do(req *http.Request) (*http.Response, error) {
+	var resp *http.Response
+	var err error
+	attempt := 1
+	if hc.retry != nil {
+		resp, attempt, err = hc.retry.do(req, hc.send, hc.rewind, hc.clock)
+	} else {
+		resp, err = hc.send(req)
+	}
+
+	if err != nil {
+		return nil, requestError(req, attempt, err)
+	}
+	return resp, nil
+}
+
+// Sends the request once, authenticating, signing and tracing it when
+// configured to.
+func (hc *httpClient) send(req *http.Request) (*http.Response, error) {
+	if err := hc.authorize(req); err != nil {
+		return nil, err
+	}
+
+	if hc.sigV4 != nil {
+		if err := hc.sigV4.sign(req); err != nil {
+			return nil, err
+		}
+	}
+
+	if hc.signer != nil {
+		if err := hc.signer.Sign(req); err != nil {
+			return nil, err
+		}
+	}
+
+	resp, err := hc.traced(req)
+	hc.stats.recordRequest(req, resp, err)
+	return resp, err
+}
+
+func (hc *httpClient) traced(req *http.Request) (*http.Response, error) {
+	if hc.traceHook == nil {
+		return hc.httpCli.Do(req)
+	}
+
+	rt := RequestTrace{
+		Method: req.Method,
+		URL:    req.URL.String(),
+	}
+
+	var dnsStart, connectStart, tlsStart time.Time
+	start := time.Now()
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:  func(httptrace.DNSDoneInfo) { rt.DNS = time.Since(dnsStart) },
+		ConnectStart: func(network, addr string) {
+			connectStart = time.Now()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			rt.Connect = time.Since(connectStart)
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			rt.TLSHandshake = time.Since(tlsStart)
+		},
+		GotConn: func(info httptrace.GotConnInfo) { rt.ConnReused = info.Reused },
+		GotFirstResponseByte: func() {
+			rt.TimeToFirstByte = time.Since(start)
+		},
+	}
+
+	resp, err := hc.httpCli.Do(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	rt.Total = time.Since(start)
+	rt.Err = err
+	if resp != nil {
+		rt.StatusCode = resp.StatusCode
+	}
+
+	hc.traceHook(rt)
+	return resp, err
+}
@@ -0,0 +1,60 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestRequestTrace(t *testing.T) {
+	srv := newHealthServer(http.StatusNoContent)
+	defer srv.Close()
+
+	var traces []RequestTrace
+	cli := NewHttpClientWithOptions(srv.URL, WithRequestTrace(func(rt RequestTrace) {
+		traces = append(traces, rt)
+	}))
+
+	cli.HealthCheck()
+	cli.HealthCheck()
+
+	assert.Len(t, traces, 2, "One trace per request expected")
+	assert.Equal(t, "GET", traces[0].Method, "Method must be traced")
+	assert.Equal(t, srv.URL+health_ep, traces[0].URL, "URL must be traced")
+	assert.Equal(t, http.StatusNoContent, traces[0].StatusCode, "Status code must be traced")
+	assert.False(t, traces[0].ConnReused, "First request needs a new connection")
+	assert.True(t, traces[0].Connect > 0, "Connect time must be traced")
+	assert.True(t, traces[1].ConnReused, "Second request must reuse the connection")
+	assert.True(t, traces[1].TimeToFirstByte > 0, "Time to first byte must be traced")
+	assert.True(t, traces[1].Total >= traces[1].TimeToFirstByte, "Total must include time to first byte")
+}
+
+// Failure test.
+func TestRequestTraceError(t *testing.T) {
+	var traces []RequestTrace
+	cli := NewHttpClientWithOptions("http://127.0.0.1:1", WithRequestTrace(func(rt RequestTrace) {
+		traces = append(traces, rt)
+	}))
+
+	_, err := cli.HealthCheck()
+	assert.NotNil(t, err, "Connection error expected")
+	assert.Len(t, traces, 1, "Failed requests must be traced")
+	assert.NotNil(t, traces[0].Err, "Error must be traced")
+	assert.Equal(t, 0, traces[0].StatusCode, "No status code expected")
+}
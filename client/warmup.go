@@ -0,0 +1,110 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Options of the connection warm-up.
+type WarmUpOptions struct {
+	// Number of connections opened to every server. Defaults to 1. Keeping
+	// more than 2 per server open takes raising MaxIdleConnsPerHost with
+	// WithTransportOptions, the extra ones are closed right away otherwise.
+	Connections int
+
+	// Sends /health/check over the new connections instead of a HEAD
+	// request, and reports the servers that are not healthy to OnError.
+	HealthCheck bool
+
+	// Bounds the whole warm-up. Defaults to 5 seconds.
+	Timeout time.Duration
+
+	// Invoked for every connection that could not be opened and for every
+	// unhealthy server. May be nil.
+	OnError func(serverAddress string, err error)
+}
+
+// Opens connections to every server when the client is created, including
+// the TLS handshake, so that the first writes and queries do not pay for
+// them. The client is only returned once the warm-up is done or timed out.
+// Failures do not prevent the creation of the client, they are reported to
+// OnError.
+func WithWarmUp(opts WarmUpOptions) Option {
+	return func(hc *httpClient) {
+		hc.warmUpOpts = &opts
+	}
+}
+
+func (hc *httpClient) warmUp(opts WarmUpOptions) {
+	if opts.Connections <= 0 {
+		opts.Connections = 1
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	defer cancel()
+
+	// The requests run concurrently so that none of them can reuse the
+	// connection of another.
+	var wg sync.WaitGroup
+	for _, addr := range hc.ServerAddresses() {
+		for i := 0; i < opts.Connections; i++ {
+			wg.Add(1)
+			go func(addr string) {
+				defer wg.Done()
+
+				if err := hc.warmUpConn(ctx, addr, opts.HealthCheck); err != nil && opts.OnError != nil {
+					opts.OnError(addr, err)
+				}
+			}(addr)
+		}
+	}
+	wg.Wait()
+}
+
+func (hc *httpClient) warmUpConn(ctx context.Context, addr string, healthCheck bool) error {
+	method, endpoint := http.MethodHead, "/"
+	if healthCheck {
+		method, endpoint = http.MethodGet, health_ep
+	}
+
+	req, err := hc.newRequestTo(ctx, addr, method, endpoint, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := hc.do(req)
+	if err != nil {
+		return err
+	}
+
+	// Reading the body to the end returns the connection to the pool.
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if healthCheck && resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: status %d", ErrorWarmUpUnhealthy, resp.StatusCode)
+	}
+	return nil
+}
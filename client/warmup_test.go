@@ -0,0 +1,122 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestWithWarmUp(t *testing.T) {
+	var conns int32
+	var mu sync.Mutex
+	var paths []string
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	var traces []RequestTrace
+	var errs []error
+	cli := NewHttpClientWithOptions(srv.URL,
+		WithTLSConfig(srv.Client().Transport.(*http.Transport).TLSClientConfig),
+		WithTransportOptions(TransportOptions{MaxIdleConnsPerHost: 3}),
+		WithWarmUp(WarmUpOptions{
+			Connections: 3,
+			OnError:     func(_ string, err error) { errs = append(errs, err) },
+		}),
+		WithRequestTrace(func(rt RequestTrace) {
+			mu.Lock()
+			defer mu.Unlock()
+			traces = append(traces, rt)
+		}))
+
+	assert.Empty(t, errs, "No error expected")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&conns), "Connections must be opened up front")
+	mu.Lock()
+	assert.Equal(t, "HEAD /", paths[0])
+	traces = nil
+	mu.Unlock()
+
+	_, err := cli.HealthCheck()
+	assert.Nil(t, err, "No error expected")
+	assert.True(t, traces[0].ConnReused, "First request must reuse a warm connection")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&conns))
+}
+
+// Failure test.
+func TestWithWarmUpUnhealthy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, health_ep, r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var errs []error
+	NewHttpClientWithOptions(srv.URL, WithWarmUp(WarmUpOptions{
+		Connections: 2,
+		HealthCheck: true,
+		OnError: func(addr string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, srv.URL, addr)
+			errs = append(errs, err)
+		},
+	}))
+
+	assert.Len(t, errs, 2)
+	for _, err := range errs {
+		assert.ErrorIs(t, err, ErrorWarmUpUnhealthy)
+	}
+}
+
+// Success test.
+func TestWithWarmUpRequests(t *testing.T) {
+	var mu sync.Mutex
+	var seen []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, _, _ := r.BasicAuth()
+		mu.Lock()
+		seen = append(seen, user+" "+r.Header.Get("X-Api-Key"))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	// The warm-up runs once all the options are applied, whatever their order.
+	NewHttpClientWithOptions(srv.URL,
+		WithWarmUp(WarmUpOptions{HealthCheck: true}),
+		WithBasicAuth("reader", "secret"),
+		WithHeaders(http.Header{"X-Api-Key": {"key"}}))
+
+	assert.Equal(t, []string{"reader key"}, seen, "Warm-up requests must be made like the other ones")
+}
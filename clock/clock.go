@@ -0,0 +1,175 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clock abstracts the passing of time, so that the time dependent
+// behavior of the other packages, such as retries, cache expiry and
+// schedules, can be driven by a fake clock in tests.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Tells the time and creates timers.
+type Clock interface {
+	Now() time.Time
+
+	// Creates a timer firing once after the duration.
+	NewTimer(d time.Duration) Timer
+}
+
+// A single event, see time.Timer.
+type Timer interface {
+	// Returns the channel the time is sent on when the timer fires.
+	C() <-chan time.Time
+
+	// Prevents the timer from firing. Returns false if it already fired or
+	// was stopped.
+	Stop() bool
+}
+
+// The wall clock, backed by the time package.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (rt realTimer) C() <-chan time.Time {
+	return rt.t.C
+}
+
+func (rt realTimer) Stop() bool {
+	return rt.t.Stop()
+}
+
+// Returns c, or the real clock when c is nil.
+func OrReal(c Clock) Clock {
+	if c == nil {
+		return Real
+	}
+	return c
+}
+
+// A Clock whose time only moves when told to. Timers fire when the clock is
+// advanced past their deadline.
+type Fake struct {
+	mu      sync.Mutex
+	cond    *sync.Cond // Signaled when timers are added.
+	now     time.Time
+	pending []*fakeTimer
+}
+
+// Creates a fake clock set to the given time.
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mu)
+	return f
+}
+
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	ft := &fakeTimer{f: f, deadline: f.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		ft.c <- f.now
+		return ft
+	}
+
+	f.pending = append(f.pending, ft)
+	f.cond.Broadcast()
+	return ft
+}
+
+// Moves the clock forward, firing the timers due in deadline order.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.now = f.now.Add(d)
+
+	sort.SliceStable(f.pending, func(i, j int) bool {
+		return f.pending[i].deadline.Before(f.pending[j].deadline)
+	})
+
+	var kept []*fakeTimer
+	for _, ft := range f.pending {
+		if ft.deadline.After(f.now) {
+			kept = append(kept, ft)
+			continue
+		}
+		ft.c <- ft.deadline
+	}
+	f.pending = kept
+}
+
+// Blocks until at least n timers are pending, e.g. until the code under test
+// waits on the clock before advancing it.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for len(f.pending) < n {
+		f.cond.Wait()
+	}
+}
+
+// Returns the number of timers that have neither fired nor been stopped.
+func (f *Fake) Pending() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.pending)
+}
+
+type fakeTimer struct {
+	f        *Fake
+	deadline time.Time
+	c        chan time.Time
+}
+
+func (ft *fakeTimer) C() <-chan time.Time {
+	return ft.c
+}
+
+func (ft *fakeTimer) Stop() bool {
+	ft.f.mu.Lock()
+	defer ft.f.mu.Unlock()
+
+	for i, p := range ft.f.pending {
+		if p == ft {
+			ft.f.pending = append(ft.f.pending[:i], ft.f.pending[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestFake(t *testing.T) {
+	start := time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC)
+	f := NewFake(start)
+
+	t1 := f.NewTimer(time.Minute)
+	t2 := f.NewTimer(2 * time.Minute)
+	t3 := f.NewTimer(3 * time.Minute)
+	assert.Equal(t, 3, f.Pending())
+	assert.True(t, t3.Stop(), "Pending timer must stop")
+	assert.False(t, t3.Stop(), "Stopped timer must not stop twice")
+
+	f.Advance(90 * time.Second)
+	assert.Equal(t, start.Add(90*time.Second), f.Now())
+	assert.Equal(t, start.Add(time.Minute), <-t1.C(), "Timer must fire at its deadline")
+	assert.False(t, t1.Stop(), "Fired timer must not stop")
+
+	select {
+	case <-t2.C():
+		t.Fatal("Timer fired early")
+	default:
+	}
+
+	f.Advance(time.Minute)
+	assert.Equal(t, start.Add(2*time.Minute), <-t2.C())
+	assert.Equal(t, 0, f.Pending())
+
+	now := f.NewTimer(0)
+	assert.Equal(t, f.Now(), <-now.C(), "Expired timer must fire right away")
+}
+
+// Success test.
+func TestFakeBlockUntil(t *testing.T) {
+	f := NewFake(time.Unix(0, 0))
+
+	done := make(chan time.Time)
+	go func() {
+		done <- <-f.NewTimer(time.Second).C()
+	}()
+
+	f.BlockUntil(1)
+	f.Advance(time.Second)
+	assert.Equal(t, time.Unix(1, 0), <-done)
+}
+
+// Success test.
+func TestOrReal(t *testing.T) {
+	assert.Equal(t, Real, OrReal(nil))
+
+	f := NewFake(time.Unix(0, 0))
+	assert.Equal(t, Clock(f), OrReal(f))
+}
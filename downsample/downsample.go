@@ -0,0 +1,240 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package downsample periodically queries raw metrics, aggregates them to a
+// coarser resolution and writes the result to other metrics, a client side
+// alternative to KairosDB roll-ups.
+package downsample
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/aggregator"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/retoool/go-kairosdb/client"
+	"github.com/retoool/go-kairosdb/response"
+	"github.com/retoool/go-kairosdb/scheduler"
+)
+
+// Tag added to the downsampled series, naming the raw metric, as KairosDB
+// roll-ups do.
+const SavedFromTag = "saved_from"
+
+// The aggregations a rule can use, all KairosDB sampling aggregators.
+var aggregations = map[string]bool{
+	"avg": true, "sum": true, "min": true, "max": true,
+	"count": true, "first": true, "last": true, "dev": true,
+}
+
+// Declares how a raw metric is downsampled.
+type Rule struct {
+	// The raw metric and, optionally, the tags narrowing its series.
+	Metric string
+	Tags   map[string][]string
+
+	// Tags of the raw series kept on the downsampled ones. The series
+	// sharing the values of these tags are aggregated together.
+	GroupBy []string
+
+	// One of avg, sum, min, max, count, first, last and dev. Defaults to avg.
+	Aggregation string
+
+	// Time covered by a downsampled data point. The rule runs once per
+	// resolution, aligned on the epoch.
+	Resolution time.Duration
+
+	// Number of past intervals downsampled again on every run, so that late
+	// data points are taken into account. Defaults to 1, the interval that
+	// just ended.
+	Windows int
+
+	// Name of the metric the downsampled data points are written to.
+	SaveAs string
+}
+
+// Outcome of a run of a rule.
+type Run struct {
+	SaveAs string
+
+	// Time range downsampled.
+	Range builder.TimeRange
+
+	// Number of data points written.
+	DataPoints int
+
+	Err error
+}
+
+// Options of the Pipeline.
+type Options struct {
+	// Called after every run. May be nil.
+	OnRun func(Run)
+}
+
+// Downsamples raw metrics according to rules. Every rule is a job of a
+// scheduler.Runner, so a rule never overlaps with itself.
+type Pipeline struct {
+	runner *scheduler.Runner
+	w      client.MetricWriter
+	opts   Options
+}
+
+func NewPipeline(r client.MetricReader, w client.MetricWriter, opts Options) *Pipeline {
+	return &Pipeline{
+		runner: scheduler.NewRunner(r),
+		w:      w,
+		opts:   opts,
+	}
+}
+
+// Registers a rule. Rules must be added before Run is called and their
+// target metrics must be unique.
+func (p *Pipeline) Add(rule Rule) error {
+	switch {
+	case rule.Metric == "":
+		return ErrorRuleMetric
+	case rule.SaveAs == "":
+		return ErrorRuleSaveAs
+	case rule.Resolution < time.Millisecond:
+		return ErrorRuleResolution
+	}
+
+	if rule.Aggregation == "" {
+		rule.Aggregation = "avg"
+	}
+	if !aggregations[rule.Aggregation] {
+		return fmt.Errorf("%w: %q", ErrorRuleAggregation, rule.Aggregation)
+	}
+
+	if rule.Windows <= 0 {
+		rule.Windows = 1
+	}
+
+	return p.runner.Register(scheduler.Job{
+		Name:       rule.SaveAs,
+		Schedule:   scheduler.Every(rule.Resolution),
+		BuildQuery: func(scheduled time.Time) builder.QueryBuilder { return rule.query(rule.window(scheduled)) },
+		Handler: func(ctx context.Context, res scheduler.Result) {
+			p.handle(ctx, rule, res)
+		},
+	})
+}
+
+// Runs the rules until the context is done.
+func (p *Pipeline) Run(ctx context.Context) error {
+	return p.runner.Run(ctx)
+}
+
+// Returns the time range downsampled by the run scheduled at the given
+// time: the windows ending right before it.
+func (rule Rule) window(scheduled time.Time) builder.TimeRange {
+	return builder.TimeRange{
+		Start: scheduled.Add(-time.Duration(rule.Windows) * rule.Resolution),
+		End:   scheduled.Add(-time.Millisecond),
+	}
+}
+
+func (rule Rule) query(tr builder.TimeRange) builder.QueryBuilder {
+	value, unit := utils.SamplingFromDuration(rule.Resolution)
+	// The range starts on a multiple of the resolution, aligning the
+	// samples on it puts them on the epoch multiples as well.
+	aggr := aggregator.NewSamplingAggregator(rule.Aggregation, value, unit).SetStartTimeAlignmentOnly()
+
+	qb := builder.NewQueryBuilder().SetTimeRange(tr.Start, tr.End)
+	qm := qb.AddMetric(rule.Metric).AddTags(rule.Tags).AddAggregator(aggr)
+	if len(rule.GroupBy) > 0 {
+		qm.AddGrouper(builder.CreateTagsGroupBy(rule.GroupBy))
+	}
+
+	return qb
+}
+
+func (p *Pipeline) handle(ctx context.Context, rule Rule, res scheduler.Result) {
+	run := Run{SaveAs: rule.SaveAs, Range: rule.window(res.Scheduled), Err: res.Err}
+
+	if run.Err == nil {
+		mb := rule.writeback(res.Response)
+		run.DataPoints = countDataPoints(mb)
+		if run.DataPoints > 0 {
+			run.Err = p.push(ctx, mb)
+		}
+	}
+
+	if p.opts.OnRun != nil {
+		p.opts.OnRun(run)
+	}
+}
+
+func (p *Pipeline) push(ctx context.Context, mb builder.MetricBuilder) error {
+	resp, err := p.w.PushMetricsContext(ctx, mb)
+	if err != nil {
+		return err
+	}
+
+	if resp.GetStatusCode() >= http.StatusMultipleChoices {
+		return fmt.Errorf("%w: status %d: %v", ErrorWriteFailed, resp.GetStatusCode(), resp.GetErrors())
+	}
+
+	return nil
+}
+
+// Turns the aggregated series of the response into metrics named after the
+// target, tagged with the group by tags and the raw metric.
+func (rule Rule) writeback(qr *response.QueryResponse) builder.MetricBuilder {
+	mb := builder.NewMetricBuilder()
+	for _, q := range qr.QueriesArr {
+		for _, r := range q.ResultsArr {
+			if !hasValues(r) {
+				continue
+			}
+
+			m := mb.AddMetric(rule.SaveAs).AddTag(SavedFromTag, rule.Metric)
+			for _, tag := range rule.GroupBy {
+				// Grouped tags have a single value per series.
+				if vals := r.Tags[tag]; len(vals) == 1 {
+					m.AddTag(tag, vals[0])
+				}
+			}
+
+			for _, dp := range r.DataPoints {
+				if dp.Value() != nil {
+					m.AddDataPoint(dp.Timestamp(), dp.Value())
+				}
+			}
+		}
+	}
+
+	return mb
+}
+
+func hasValues(r response.Results) bool {
+	for _, dp := range r.DataPoints {
+		if dp.Value() != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func countDataPoints(mb builder.MetricBuilder) int {
+	n := 0
+	for _, m := range mb.GetMetrics() {
+		n += len(m.GetDataPoints())
+	}
+	return n
+}
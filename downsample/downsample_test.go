@@ -0,0 +1,133 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package downsample
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/client"
+	"github.com/retoool/go-kairosdb/response"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestRuleQuery(t *testing.T) {
+	rule := Rule{Metric: "cpu", Tags: map[string][]string{"dc": {"eu"}}, GroupBy: []string{"host"},
+		Aggregation: "max", Resolution: 5 * time.Minute, Windows: 2}
+
+	scheduled := time.Unix(3600, 0)
+	tr := rule.window(scheduled)
+	assert.Equal(t, time.Unix(3000, 0), tr.Start, "Two windows expected")
+	assert.Equal(t, scheduled.Add(-time.Millisecond), tr.End, "End before the scheduled time expected")
+
+	data, err := rule.query(tr).Build()
+	assert.Nil(t, err, "No error expected")
+	assert.JSONEq(t, `{
+		"start_absolute": 3000000,
+		"end_absolute": 3599999,
+		"metrics": [{
+			"name": "cpu",
+			"tags": {"dc": ["eu"]},
+			"group_by": [{"name": "tag", "tags": ["host"]}],
+			"aggregators": [{"name": "max", "sampling": {"value": 5, "unit": "minutes"}, "align_start_time": true}]
+		}]
+	}`, string(data), "Query expected")
+}
+
+// Success test.
+func TestRuleWriteback(t *testing.T) {
+	rule := Rule{Metric: "cpu", GroupBy: []string{"host"}, SaveAs: "cpu.5m"}
+
+	qr := response.NewQueryResponse(http.StatusOK)
+	err := json.Unmarshal([]byte(`{"queries":[{"results":[
+		{"name":"cpu","tags":{"host":["h1"],"dc":["eu","us"]},"values":[[0,1.5],[300000,2]]},
+		{"name":"cpu","tags":{"host":["h2"]},"values":[[0,null]]},
+		{"name":"cpu","tags":{"host":["h3"]},"values":[]}]}]}`), qr)
+	assert.Nil(t, err, "No error expected")
+
+	mb := rule.writeback(qr)
+	assert.Len(t, mb.GetMetrics(), 1, "Series without values must be left out")
+
+	m := mb.GetMetrics()[0]
+	assert.Equal(t, "cpu.5m", m.GetName(), "Target metric expected")
+	assert.Equal(t, map[string]string{"host": "h1", SavedFromTag: "cpu"}, m.GetTags(), "Grouped tags and origin expected")
+	assert.Equal(t, 2, len(m.GetDataPoints()), "Data points expected")
+}
+
+// Success test.
+func TestPipeline(t *testing.T) {
+	var mu sync.Mutex
+	var pushed []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/datapoints/query":
+			w.Write([]byte(`{"queries":[{"results":[{"name":"cpu","tags":{"host":["h1"]},"values":[[0,1]]}]}]}`))
+		case "/api/v1/datapoints":
+			body, _ := ioutil.ReadAll(r.Body)
+			mu.Lock()
+			pushed = append(pushed, string(body))
+			mu.Unlock()
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	var runs []Run
+	cli := client.NewHttpClient(srv.URL)
+	p := NewPipeline(cli, cli, Options{OnRun: func(r Run) {
+		mu.Lock()
+		runs = append(runs, r)
+		mu.Unlock()
+	}})
+	err := p.Add(Rule{Metric: "cpu", GroupBy: []string{"host"}, Resolution: 20 * time.Millisecond, SaveAs: "cpu.20ms"})
+	assert.Nil(t, err, "No error expected")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 70*time.Millisecond)
+	defer cancel()
+	p.Run(ctx)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.True(t, len(runs) >= 2, "Several runs expected")
+	assert.Nil(t, runs[0].Err, "No error expected")
+	assert.Equal(t, 1, runs[0].DataPoints, "One data point written per run")
+	assert.Equal(t, 20*time.Millisecond-time.Millisecond, runs[0].Range.End.Sub(runs[0].Range.Start), "One window expected")
+	// The push of the last run may be cut short by the context.
+	assert.True(t, len(pushed) >= len(runs)-1, "One push per run expected")
+	assert.JSONEq(t, `[{"name":"cpu.20ms","tags":{"host":"h1","saved_from":"cpu"},"datapoints":[[0,1]]}]`, pushed[0], "Downsampled metric expected")
+}
+
+// Failure test.
+func TestPipelineAdd(t *testing.T) {
+	p := NewPipeline(nil, nil, Options{})
+
+	assert.Equal(t, ErrorRuleMetric, p.Add(Rule{SaveAs: "b", Resolution: time.Minute}), "Metric error expected")
+	assert.Equal(t, ErrorRuleSaveAs, p.Add(Rule{Metric: "a", Resolution: time.Minute}), "Target error expected")
+	assert.Equal(t, ErrorRuleResolution, p.Add(Rule{Metric: "a", SaveAs: "b"}), "Resolution error expected")
+
+	err := p.Add(Rule{Metric: "a", SaveAs: "b", Resolution: time.Minute, Aggregation: "median"})
+	assert.True(t, errors.Is(err, ErrorRuleAggregation), "Aggregation error expected")
+
+	assert.Nil(t, p.Add(Rule{Metric: "a", SaveAs: "b", Resolution: time.Minute}), "No error expected")
+	assert.NotNil(t, p.Add(Rule{Metric: "c", SaveAs: "b", Resolution: time.Minute}), "Target metrics must be unique")
+}
@@ -0,0 +1,25 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package downsample
+
+import "errors"
+
+var (
+	ErrorRuleMetric      = errors.New("Downsampling rule without metric")
+	ErrorRuleSaveAs      = errors.New("Downsampling rule without target metric")
+	ErrorRuleResolution  = errors.New("Downsampling resolution must be at least a millisecond")
+	ErrorRuleAggregation = errors.New("Downsampling aggregation not supported")
+	ErrorWriteFailed     = errors.New("Downsampled data points push returned an error status")
+)
@@ -0,0 +1,571 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dsl compiles a compact, SQL like textual query language to query
+// builders, so that command line tools and configuration driven dashboards
+// can express queries as strings:
+//
+//	SELECT avg(cpu.load) WHERE host = 'a' GROUP BY dc SAMPLE 1m LAST 6h
+//
+// A query selects one or more metrics, optionally through an aggregator,
+// followed by clauses in any order:
+//
+//	SELECT metric | agg(metric) [AS alias], ...
+//	WHERE tag = 'value' [AND tag IN ('v1', 'v2') ...]
+//	GROUP BY tag, ...
+//	SAMPLE <duration>
+//	LAST <duration> | FROM '<RFC 3339 time>' [TO '<RFC 3339 time>']
+//	LIMIT <n>
+//	ORDER [BY TIME] ASC | DESC
+//
+// Keywords are case insensitive. Durations are a number and a unit among
+// ms, s, m, h, d, w, mo and y, e.g. 30s or 6h. The aggregators are avg,
+// sum, min, max, count, first, last, dev and pNN for the NNth percentile,
+// e.g. p99; they take the sampling of the SAMPLE clause. The filters,
+// grouping and sampling apply to all the selected metrics. Metric and tag
+// names may be double quoted when they contain other characters than
+// letters, digits and _ . - : /.
+package dsl
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+)
+
+type tokenKind int
+
+const (
+	tkEOF tokenKind = iota
+	tkIdent
+	tkString
+	tkNumber
+	tkDuration
+	tkPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int // Byte offset in the query.
+}
+
+func (t token) String() string {
+	if t.kind == tkEOF {
+		return "end of query"
+	}
+	return strconv.Quote(t.text)
+}
+
+// Splits the query into tokens.
+func lex(src string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+
+		case strings.ContainsRune("(),=", c):
+			tokens = append(tokens, token{tkPunct, string(c), i})
+			i++
+
+		case c == '\'' || c == '"':
+			// Quotes are escaped by doubling them, as in SQL.
+			var sb strings.Builder
+			start := i
+			for i++; ; i++ {
+				if i >= len(src) {
+					return nil, fmt.Errorf("%w: at %d: unterminated string", ErrorSyntax, start)
+				}
+				if rune(src[i]) == c {
+					if i+1 < len(src) && rune(src[i+1]) == c {
+						i++
+					} else {
+						break
+					}
+				}
+				sb.WriteByte(src[i])
+			}
+			i++
+
+			kind := tkString
+			if c == '"' {
+				kind = tkIdent
+			}
+			tokens = append(tokens, token{kind, sb.String(), start})
+
+		case unicode.IsDigit(c):
+			start := i
+			for i < len(src) && unicode.IsDigit(rune(src[i])) {
+				i++
+			}
+			kind := tkNumber
+			for i < len(src) && unicode.IsLetter(rune(src[i])) {
+				kind = tkDuration
+				i++
+			}
+			tokens = append(tokens, token{kind, src[start:i], start})
+
+		case isIdentChar(c):
+			start := i
+			for i < len(src) && isIdentChar(rune(src[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tkIdent, src[start:i], start})
+
+		default:
+			return nil, fmt.Errorf("%w: at %d: unexpected character %q", ErrorSyntax, i, c)
+		}
+	}
+
+	return append(tokens, token{kind: tkEOF, pos: len(src)}), nil
+}
+
+func isIdentChar(c rune) bool {
+	return unicode.IsLetter(c) || unicode.IsDigit(c) || strings.ContainsRune("_.-:/", c)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tkEOF {
+		p.pos++
+	}
+	return t
+}
+
+// Tells whether the next token is the keyword, consuming it if so.
+func (p *parser) keyword(kw string) bool {
+	t := p.peek()
+	if t.kind == tkIdent && strings.EqualFold(t.text, kw) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) punct(s string) bool {
+	t := p.peek()
+	if t.kind == tkPunct && t.text == s {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) errorf(t token, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: at %d: %s, got %s", ErrorSyntax, t.pos, fmt.Sprintf(format, args...), t)
+}
+
+func (p *parser) expectKeyword(kw string) error {
+	if !p.keyword(kw) {
+		return p.errorf(p.peek(), "expected %s", kw)
+	}
+	return nil
+}
+
+func (p *parser) expectPunct(s string) error {
+	if !p.punct(s) {
+		return p.errorf(p.peek(), "expected %q", s)
+	}
+	return nil
+}
+
+func (p *parser) expect(kind tokenKind, what string) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, p.errorf(t, "expected %s", what)
+	}
+	return t, nil
+}
+
+// A metric of the SELECT clause.
+type selected struct {
+	metric string
+	agg    string
+	alias  string
+}
+
+// The clauses of a query.
+type query struct {
+	selected []selected
+	tags     map[string][]string
+	groupBy  []string
+	sample   *utils.RelativeTime
+	last     *utils.RelativeTime
+	from, to time.Time
+	limit    int
+	order    builder.OrderType
+}
+
+// Compiles the query to a query builder. Errors wrap ErrorSyntax, with the
+// byte offset of the offending token, or one of the other errors of the
+// package.
+func Compile(src string) (builder.QueryBuilder, error) {
+	tokens, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	q, err := p.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return q.build()
+}
+
+func (p *parser) parse() (*query, error) {
+	q := &query{tags: make(map[string][]string)}
+
+	if err := p.expectKeyword("SELECT"); err != nil {
+		return nil, err
+	}
+
+	for {
+		s, err := p.parseSelected()
+		if err != nil {
+			return nil, err
+		}
+		q.selected = append(q.selected, s)
+
+		if !p.punct(",") {
+			break
+		}
+	}
+
+	seen := make(map[string]bool)
+	for p.peek().kind != tkEOF {
+		t := p.next()
+		clause := strings.ToUpper(t.text)
+		if t.kind != tkIdent {
+			return nil, p.errorf(t, "expected a clause")
+		}
+		if seen[clause] {
+			return nil, p.errorf(t, "%s clause given twice", clause)
+		}
+		seen[clause] = true
+
+		var err error
+		switch clause {
+		case "WHERE":
+			err = p.parseWhere(q)
+		case "GROUP":
+			err = p.parseGroupBy(q)
+		case "SAMPLE":
+			q.sample, err = p.parseDuration()
+		case "LAST":
+			if seen["FROM"] {
+				return nil, p.errorf(t, "LAST and FROM are exclusive")
+			}
+			q.last, err = p.parseDuration()
+		case "FROM":
+			if seen["LAST"] {
+				return nil, p.errorf(t, "LAST and FROM are exclusive")
+			}
+			err = p.parseFrom(q)
+		case "LIMIT":
+			err = p.parseLimit(q)
+		case "ORDER":
+			err = p.parseOrder(q)
+		default:
+			return nil, p.errorf(t, "expected a clause")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return q, nil
+}
+
+// Parses "metric", "agg(metric)" and their optional alias.
+func (p *parser) parseSelected() (selected, error) {
+	var s selected
+	t, err := p.expect(tkIdent, "a metric or an aggregator")
+	if err != nil {
+		return s, err
+	}
+
+	if p.punct("(") {
+		s.agg = strings.ToLower(t.text)
+		m, err := p.expect(tkIdent, "a metric")
+		if err != nil {
+			return s, err
+		}
+		s.metric = m.text
+
+		if err := p.expectPunct(")"); err != nil {
+			return s, err
+		}
+	} else {
+		s.metric = t.text
+	}
+
+	if p.keyword("AS") {
+		a, err := p.expect(tkIdent, "an alias")
+		if err != nil {
+			return s, err
+		}
+		s.alias = a.text
+	}
+
+	return s, nil
+}
+
+// Parses the conditions following WHERE.
+func (p *parser) parseWhere(q *query) error {
+	for {
+		tag, err := p.expect(tkIdent, "a tag name")
+		if err != nil {
+			return err
+		}
+		if _, ok := q.tags[tag.text]; ok {
+			return p.errorf(tag, "tag filtered twice, use IN")
+		}
+
+		var vals []string
+		if p.punct("=") {
+			v, err := p.expect(tkString, "a quoted value")
+			if err != nil {
+				return err
+			}
+			vals = []string{v.text}
+		} else if p.keyword("IN") {
+			if err := p.expectPunct("("); err != nil {
+				return err
+			}
+			for {
+				v, err := p.expect(tkString, "a quoted value")
+				if err != nil {
+					return err
+				}
+				vals = append(vals, v.text)
+
+				if !p.punct(",") {
+					break
+				}
+			}
+			if err := p.expectPunct(")"); err != nil {
+				return err
+			}
+		} else {
+			return p.errorf(p.peek(), "expected = or IN")
+		}
+		q.tags[tag.text] = vals
+
+		if !p.keyword("AND") {
+			return nil
+		}
+	}
+}
+
+func (p *parser) parseGroupBy(q *query) error {
+	if err := p.expectKeyword("BY"); err != nil {
+		return err
+	}
+
+	for {
+		tag, err := p.expect(tkIdent, "a tag name")
+		if err != nil {
+			return err
+		}
+		q.groupBy = append(q.groupBy, tag.text)
+
+		if !p.punct(",") {
+			return nil
+		}
+	}
+}
+
+var durationUnits = map[string]utils.TimeUnit{
+	"ms": utils.MILLISECONDS,
+	"s":  utils.SECONDS,
+	"m":  utils.MINUTES,
+	"h":  utils.HOURS,
+	"d":  utils.DAYS,
+	"w":  utils.WEEKS,
+	"mo": utils.MONTHS,
+	"y":  utils.YEARS,
+}
+
+func (p *parser) parseDuration() (*utils.RelativeTime, error) {
+	t, err := p.expect(tkDuration, "a duration such as 5m")
+	if err != nil {
+		return nil, err
+	}
+
+	i := strings.IndexFunc(t.text, unicode.IsLetter)
+	value, err := strconv.Atoi(t.text[:i])
+	if err != nil || value <= 0 {
+		return nil, p.errorf(t, "expected a positive duration")
+	}
+
+	unit, ok := durationUnits[strings.ToLower(t.text[i:])]
+	if !ok {
+		return nil, p.errorf(t, "expected a unit among ms, s, m, h, d, w, mo and y")
+	}
+
+	return utils.NewRelativeTime(value, unit), nil
+}
+
+func (p *parser) parseFrom(q *query) error {
+	var err error
+	if q.from, err = p.parseTime(); err != nil {
+		return err
+	}
+
+	if p.keyword("TO") {
+		q.to, err = p.parseTime()
+	}
+	return err
+}
+
+func (p *parser) parseTime() (time.Time, error) {
+	t, err := p.expect(tkString, "a quoted RFC 3339 time")
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	ts, err := time.Parse(time.RFC3339, t.text)
+	if err != nil {
+		return time.Time{}, p.errorf(t, "expected an RFC 3339 time")
+	}
+	return ts, nil
+}
+
+func (p *parser) parseLimit(q *query) error {
+	t, err := p.expect(tkNumber, "a number")
+	if err != nil {
+		return err
+	}
+
+	q.limit, err = strconv.Atoi(t.text)
+	if err != nil || q.limit <= 0 {
+		return p.errorf(t, "expected a positive number")
+	}
+	return nil
+}
+
+func (p *parser) parseOrder(q *query) error {
+	if p.keyword("BY") {
+		if err := p.expectKeyword("TIME"); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case p.keyword("ASC"):
+		q.order = builder.ASCENDING
+	case p.keyword("DESC"):
+		q.order = builder.DESCENDING
+	default:
+		return p.errorf(p.peek(), "expected ASC or DESC")
+	}
+	return nil
+}
+
+// Returns the aggregator of the given name with the sampling.
+func aggregator(name string, sample *utils.RelativeTime) (builder.Aggregator, error) {
+	value, unit := sample.Value(), sample.Unit()
+
+	switch name {
+	case "avg":
+		return builder.CreateAverageAggregator(value, unit), nil
+	case "sum":
+		return builder.CreateSumAggregator(value, unit), nil
+	case "min":
+		return builder.CreateMinAggregator(value, unit), nil
+	case "max":
+		return builder.CreateMaxAggregator(value, unit), nil
+	case "count":
+		return builder.CreateCountAggregator(value, unit), nil
+	case "first":
+		return builder.CreateFirstAggregator(value, unit), nil
+	case "last":
+		return builder.CreateLastAggregator(value, unit), nil
+	case "dev":
+		return builder.CreateStandardDeviationAggregator(value, unit), nil
+	}
+
+	if strings.HasPrefix(name, "p") {
+		if pct, err := strconv.Atoi(name[1:]); err == nil && pct > 0 && pct < 100 {
+			return builder.CreatePercentileAggregator(float64(pct)/100, value, unit), nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %s", ErrorUnknownAggregator, name)
+}
+
+func (q *query) build() (builder.QueryBuilder, error) {
+	qb := builder.NewQueryBuilder()
+	switch {
+	case q.last != nil:
+		qb.SetRelativeStart(q.last.Value(), q.last.Unit())
+	case !q.from.IsZero():
+		qb.SetAbsoluteStart(q.from)
+		if !q.to.IsZero() {
+			qb.SetAbsoluteEnd(q.to)
+		}
+	default:
+		return nil, ErrorNoTimeRange
+	}
+
+	for _, s := range q.selected {
+		qm := qb.AddMetric(s.metric).AddTags(q.tags)
+
+		if len(q.groupBy) > 0 {
+			qm.AddGrouper(builder.CreateTagsGroupBy(q.groupBy))
+		}
+
+		if s.agg != "" {
+			if q.sample == nil {
+				return nil, fmt.Errorf("%w: %s(%s)", ErrorNoSampling, s.agg, s.metric)
+			}
+
+			aggr, err := aggregator(s.agg, q.sample)
+			if err != nil {
+				return nil, err
+			}
+			qm.AddAggregator(aggr)
+		}
+
+		if q.limit > 0 {
+			qm.SetLimit(q.limit)
+		}
+		if q.order != "" {
+			qm.SetOrder(q.order)
+		}
+		if s.alias != "" {
+			qm.SetAlias(s.alias)
+		}
+	}
+
+	return qb, nil
+}
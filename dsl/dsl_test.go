@@ -0,0 +1,104 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dsl
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestCompile(t *testing.T) {
+	qb, err := Compile("SELECT avg(cpu.load) WHERE host='a' GROUP BY dc SAMPLE 1m LAST 6h")
+	assert.Nil(t, err, "No error expected")
+
+	data, err := qb.Build()
+	assert.Nil(t, err, "No error expected")
+	assert.JSONEq(t, `{
+		"start_relative": {"value": 6, "unit": "hours"},
+		"metrics": [{
+			"name": "cpu.load",
+			"tags": {"host": ["a"]},
+			"group_by": [{"name": "tag", "tags": ["dc"]}],
+			"aggregators": [{"name": "avg", "sampling": {"value": 1, "unit": "minutes"}}]
+		}]
+	}`, string(data), "Query expected")
+}
+
+// Success test.
+func TestCompileClauses(t *testing.T) {
+	qb, err := Compile(`select "disk used" as disk, max(net.rx) from '1970-01-01T01:00:00Z' to '1970-01-01T02:00:00Z'
+		where host in ('a', 'b''s') and dc = 'eu' sample 5mo order by time desc limit 10`)
+	assert.Nil(t, err, "No error expected")
+
+	data, err := qb.Build()
+	assert.Nil(t, err, "No error expected")
+	assert.JSONEq(t, `{
+		"start_absolute": 3600000,
+		"end_absolute": 7200000,
+		"metrics": [{
+			"name": "disk used",
+			"tags": {"host": ["a", "b's"], "dc": ["eu"]},
+			"limit": 10,
+			"order": "desc"
+		}, {
+			"name": "net.rx",
+			"tags": {"host": ["a", "b's"], "dc": ["eu"]},
+			"aggregators": [{"name": "max", "sampling": {"value": 5, "unit": "months"}}],
+			"limit": 10,
+			"order": "desc"
+		}]
+	}`, string(data), "Query expected")
+	assert.Equal(t, "disk", qb.Metrics()[0].Alias(), "Alias expected")
+}
+
+// Success test.
+func TestAggregatorPercentile(t *testing.T) {
+	aggr, err := aggregator("p99", utils.NewRelativeTime(1, utils.HOURS))
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, "percentile", aggr.Name(), "Percentile aggregator expected")
+	assert.Equal(t, 0.99, aggr.(interface{ Percentile() float64 }).Percentile(), "99th percentile expected")
+
+	_, err = aggregator("p100", utils.NewRelativeTime(1, utils.HOURS))
+	assert.True(t, errors.Is(err, ErrorUnknownAggregator), "Unknown aggregator expected")
+}
+
+// Failure test.
+func TestCompileErrors(t *testing.T) {
+	for query, expected := range map[string]error{
+		"":                                         ErrorSyntax,
+		"SELECT":                                   ErrorSyntax,
+		"SELECT cpu LAST 6":                        ErrorSyntax,
+		"SELECT cpu LAST 6x":                       ErrorSyntax,
+		"SELECT cpu LAST 6h LAST 1h":               ErrorSyntax,
+		"SELECT cpu LAST 6h FROM '2020-01-01'":     ErrorSyntax,
+		"SELECT cpu WHERE host = a LAST 6h":        ErrorSyntax,
+		"SELECT cpu WHERE host = 'a LAST 6h":       ErrorSyntax,
+		"SELECT cpu WHERE h='a' AND h='b' LAST 1h": ErrorSyntax,
+		"SELECT cpu LAST 6h; DROP":                 ErrorSyntax,
+		"SELECT cpu":                               ErrorNoTimeRange,
+		"SELECT avg(cpu) LAST 6h":                  ErrorNoSampling,
+		"SELECT median(cpu) SAMPLE 1m LAST 6h":     ErrorUnknownAggregator,
+	} {
+		_, err := Compile(query)
+		assert.True(t, errors.Is(err, expected), "%q: %v expected, got %v", query, expected, err)
+	}
+
+	_, err := Compile("SELECT cpu WHERE host = 1 LAST 6h")
+	assert.EqualError(t, err, `Query syntax error: at 24: expected a quoted value, got "1"`, "Position expected")
+}
@@ -0,0 +1,255 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Estimated memory taken by a decoded data point: the timestamp, the value
+// interface and the float64 it points to, plus slice overhead.
+const dataPointSize = 48
+
+// Options of an Accumulator.
+type AccumulatorOptions struct {
+	// Approximate memory, in bytes, the results kept in memory may take
+	// before they are spilled to disk. Defaults to 64 MiB.
+	MemoryBudget int64
+
+	// Directory of the spill file. Defaults to the system temporary
+	// directory.
+	Dir string
+}
+
+// Collects the results of export queries without holding them all in
+// memory: once the results exceed the memory budget they are written to a
+// temporary file, and read back one series at a time by the iterator. The
+// memory use is estimated, not measured.
+//
+// An Accumulator must not be used concurrently. Close removes the spill
+// file.
+type Accumulator struct {
+	opts AccumulatorOptions
+
+	mem     []response.Results
+	memSize int64
+	count   int64
+
+	file    *os.File
+	w       *bufio.Writer
+	enc     *json.Encoder
+	spilled int64
+	closed  bool
+}
+
+// A series as written to the spill file. The alias is not part of the JSON
+// of Results.
+type spilledResults struct {
+	Results response.Results `json:"results"`
+	Alias   string           `json:"alias,omitempty"`
+}
+
+func NewAccumulator(opts AccumulatorOptions) *Accumulator {
+	if opts.MemoryBudget <= 0 {
+		opts.MemoryBudget = 64 << 20
+	}
+
+	return &Accumulator{opts: opts}
+}
+
+// Adds the results of a query, spilling to disk when the memory budget is
+// exceeded.
+func (a *Accumulator) Add(results ...response.Results) error {
+	if a.closed {
+		return ErrorAccumulatorClosed
+	}
+
+	for _, r := range results {
+		a.mem = append(a.mem, r)
+		a.memSize += resultsSize(r)
+		a.count++
+	}
+
+	if a.memSize > a.opts.MemoryBudget {
+		return a.spill()
+	}
+	return nil
+}
+
+// Adds the results of every query of the response.
+func (a *Accumulator) AddResponse(qr *response.QueryResponse) error {
+	for _, q := range qr.QueriesArr {
+		if err := a.Add(q.ResultsArr...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns the number of series added.
+func (a *Accumulator) Len() int64 {
+	return a.count
+}
+
+// Returns the number of series written to disk.
+func (a *Accumulator) Spilled() int64 {
+	return a.spilled
+}
+
+// Returns an iterator over the series in the order they were added, the
+// spilled ones first. No results may be added while iterating.
+func (a *Accumulator) Iterator() (*ResultsIterator, error) {
+	if a.closed {
+		return nil, ErrorAccumulatorClosed
+	}
+
+	it := &ResultsIterator{mem: a.mem}
+	if a.file == nil {
+		return it, nil
+	}
+
+	if err := a.w.Flush(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(a.file.Name())
+	if err != nil {
+		return nil, err
+	}
+	it.file = f
+	it.dec = json.NewDecoder(bufio.NewReader(f))
+
+	return it, nil
+}
+
+// Drops the results and removes the spill file.
+func (a *Accumulator) Close() error {
+	if a.closed {
+		return nil
+	}
+	a.closed = true
+	a.mem = nil
+
+	if a.file == nil {
+		return nil
+	}
+
+	err := a.file.Close()
+	if rmErr := os.Remove(a.file.Name()); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+func (a *Accumulator) spill() error {
+	if a.file == nil {
+		f, err := ioutil.TempFile(a.opts.Dir, "kairosdb-export-*.jsonl")
+		if err != nil {
+			return err
+		}
+		a.file = f
+		a.w = bufio.NewWriter(f)
+		a.enc = json.NewEncoder(a.w)
+	}
+
+	for _, r := range a.mem {
+		if err := a.enc.Encode(spilledResults{Results: r, Alias: r.Alias}); err != nil {
+			return err
+		}
+	}
+
+	a.spilled += int64(len(a.mem))
+	a.mem = nil
+	a.memSize = 0
+	return nil
+}
+
+func resultsSize(r response.Results) int64 {
+	size := int64(len(r.Name)) + int64(len(r.DataPoints))*dataPointSize
+	for k, vals := range r.Tags {
+		size += int64(len(k))
+		for _, v := range vals {
+			size += int64(len(v))
+		}
+	}
+	return size
+}
+
+// Iterates over the series of an Accumulator.
+type ResultsIterator struct {
+	file *os.File
+	dec  *json.Decoder
+	mem  []response.Results
+	cur  response.Results
+	err  error
+}
+
+// Advances to the next series. Returns false at the end or on error, see
+// Err.
+func (it *ResultsIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	if it.dec != nil {
+		var sr spilledResults
+		err := it.dec.Decode(&sr)
+		if err == nil {
+			it.cur = sr.Results
+			it.cur.Alias = sr.Alias
+			return true
+		}
+
+		it.dec = nil
+		if err != io.EOF {
+			it.err = err
+			return false
+		}
+	}
+
+	if len(it.mem) == 0 {
+		return false
+	}
+
+	it.cur, it.mem = it.mem[0], it.mem[1:]
+	return true
+}
+
+// Returns the current series.
+func (it *ResultsIterator) Results() response.Results {
+	return it.cur
+}
+
+// Returns the error that stopped the iteration, if any.
+func (it *ResultsIterator) Err() error {
+	return it.err
+}
+
+// Releases the spill file. Safe to call more than once.
+func (it *ResultsIterator) Close() error {
+	if it.file == nil {
+		return nil
+	}
+
+	err := it.file.Close()
+	it.file = nil
+	return err
+}
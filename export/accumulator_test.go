@@ -0,0 +1,99 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/response"
+	"github.com/stretchr/testify/assert"
+)
+
+func seriesOf(name string, points int) response.Results {
+	r := response.Results{Name: name, Tags: map[string][]string{"host": {"h1"}}, Alias: name + "-alias"}
+	for i := 0; i < points; i++ {
+		r.DataPoints = append(r.DataPoints, *builder.NewDataPoint(int64(i), float64(i)))
+	}
+	return r
+}
+
+// Success test.
+func TestAccumulatorSpill(t *testing.T) {
+	dir := t.TempDir()
+	acc := NewAccumulator(AccumulatorOptions{MemoryBudget: 1000, Dir: dir})
+
+	for _, name := range []string{"m1", "m2", "m3"} {
+		assert.Nil(t, acc.Add(seriesOf(name, 10)), "No error expected")
+	}
+	assert.Nil(t, acc.Add(seriesOf("m4", 1)), "No error expected")
+	assert.Equal(t, int64(4), acc.Len())
+	assert.Equal(t, int64(3), acc.Spilled(), "Series over the budget must be spilled")
+
+	it, err := acc.Iterator()
+	assert.Nil(t, err, "No error expected")
+	defer it.Close()
+
+	var got []response.Results
+	for it.Next() {
+		got = append(got, it.Results())
+	}
+	assert.Nil(t, it.Err(), "No error expected")
+
+	assert.Len(t, got, 4)
+	for i, name := range []string{"m1", "m2", "m3", "m4"} {
+		assert.Equal(t, name, got[i].Name, "Series must come back in insertion order")
+		assert.Equal(t, name+"-alias", got[i].Alias)
+	}
+	assert.Equal(t, []string{"h1"}, got[1].Tags["host"])
+	assert.Len(t, got[2].DataPoints, 10)
+	assert.Equal(t, int64(9), got[2].DataPoints[9].Timestamp())
+	v, _ := got[2].DataPoints[9].Float64Value()
+	assert.Equal(t, 9.0, v)
+
+	assert.Nil(t, acc.Close(), "No error expected")
+	files, _ := ioutil.ReadDir(dir)
+	assert.Empty(t, files, "Spill file must be removed")
+}
+
+// Success test.
+func TestAccumulatorInMemory(t *testing.T) {
+	acc := NewAccumulator(AccumulatorOptions{})
+	defer acc.Close()
+
+	qr := response.NewQueryResponse(200)
+	qr.QueriesArr = []response.Queries{{ResultsArr: []response.Results{seriesOf("m1", 3), seriesOf("m2", 3)}}}
+	assert.Nil(t, acc.AddResponse(qr), "No error expected")
+	assert.Equal(t, int64(0), acc.Spilled())
+
+	it, err := acc.Iterator()
+	assert.Nil(t, err, "No error expected")
+	n := 0
+	for it.Next() {
+		n++
+	}
+	assert.Equal(t, 2, n)
+}
+
+// Failure test.
+func TestAccumulatorClosed(t *testing.T) {
+	acc := NewAccumulator(AccumulatorOptions{})
+	assert.Nil(t, acc.Close(), "No error expected")
+
+	assert.Equal(t, ErrorAccumulatorClosed, acc.Add(seriesOf("m1", 1)))
+	_, err := acc.Iterator()
+	assert.Equal(t, ErrorAccumulatorClosed, err)
+}
@@ -0,0 +1,93 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Persists the progress of incremental exports: the timestamp, in
+// milliseconds, of the last exported data point of every metric.
+type CursorStore interface {
+	// Returns the stored cursors. A store that was never saved returns an
+	// empty map.
+	Load() (map[string]int64, error)
+
+	// Replaces the stored cursors.
+	Save(cursors map[string]int64) error
+}
+
+type fileCursorStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// Creates a CursorStore that keeps the cursors in a JSON file. The file is
+// replaced atomically on every save, so a crash never leaves it half
+// written.
+func NewFileCursorStore(path string) CursorStore {
+	return &fileCursorStore{path: path}
+}
+
+func (fs *fileCursorStore) Load() (map[string]int64, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	cursors := make(map[string]int64)
+	data, err := ioutil.ReadFile(fs.path)
+	if os.IsNotExist(err) {
+		return cursors, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(data, &cursors); err != nil {
+		return nil, err
+	}
+
+	return cursors, nil
+}
+
+func (fs *fileCursorStore) Save(cursors map[string]int64) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	data, err := json.Marshal(cursors)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(fs.path), filepath.Base(fs.path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), fs.path)
+}
@@ -0,0 +1,151 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/client"
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Receives the data exported for a metric. The cursor of the metric is only
+// advanced once the sink returned without error, so a failed write is
+// retried on the next run.
+type Sink func(metric string, results []response.Results) error
+
+// Options of an incremental export.
+type IncrementalOptions struct {
+	// Metrics to export.
+	Metrics []string
+
+	// Where the export of a metric without a cursor starts.
+	InitialStart time.Time
+
+	// Data points younger than Lag are left for the next run, so that late
+	// writes are not skipped once the cursor moved past them.
+	Lag time.Duration
+
+	// Tags restricting the exported series. May be nil.
+	Tags map[string][]string
+
+	// Tells the end of the export. Defaults to the wall clock.
+	Clock clock.Clock
+}
+
+// Runs one pass of an incremental export: every metric is queried from its
+// cursor up to now minus the lag, the data is handed to the sink and the
+// cursor is moved to the last exported data point. Cursors are saved after
+// every metric, so an interrupted run resumes where it stopped.
+func RunIncremental(ctx context.Context, c client.MetricReader, store CursorStore, opts IncrementalOptions, sink Sink) error {
+	if len(opts.Metrics) == 0 {
+		return ErrorNoMetrics
+	}
+
+	if opts.InitialStart.IsZero() {
+		return ErrorStartNotSet
+	}
+
+	cursors, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	end := clock.OrReal(opts.Clock).Now().Add(-opts.Lag)
+	for _, metric := range opts.Metrics {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		start := opts.InitialStart
+		if last, ok := cursors[metric]; ok {
+			start = msToTime(last + 1)
+		}
+
+		if start.After(end) {
+			continue
+		}
+
+		results, err := exportRange(ctx, c, metric, opts.Tags, start, end)
+		if err != nil {
+			return err
+		}
+
+		last, ok := lastTimestamp(results)
+		if !ok {
+			continue
+		}
+
+		if err := sink(metric, results); err != nil {
+			return err
+		}
+
+		cursors[metric] = last
+		if err := store.Save(cursors); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func exportRange(ctx context.Context, c client.MetricReader, metric string, tags map[string][]string, start, end time.Time) ([]response.Results, error) {
+	qb := builder.NewQueryBuilder()
+	qb.SetAbsoluteStart(start).SetAbsoluteEnd(end)
+
+	qm := qb.AddMetric(metric).SetOrder(builder.ASCENDING)
+	if tags != nil {
+		qm.AddTags(tags)
+	}
+
+	resp, err := c.QueryContext(ctx, qb)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.GetStatusCode() >= http.StatusMultipleChoices {
+		return nil, ErrorQueryFailed
+	}
+
+	var results []response.Results
+	for _, q := range resp.QueriesArr {
+		results = append(results, q.ResultsArr...)
+	}
+
+	return results, nil
+}
+
+// Returns the timestamp of the most recent data point of the results.
+func lastTimestamp(results []response.Results) (int64, bool) {
+	var last int64
+	found := false
+	for _, r := range results {
+		for i := range r.DataPoints {
+			if ts := r.DataPoints[i].Timestamp(); !found || ts > last {
+				last, found = ts, true
+			}
+		}
+	}
+
+	return last, found
+}
+
+func msToTime(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
@@ -0,0 +1,118 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/client"
+	"github.com/retoool/go-kairosdb/response"
+	"github.com/stretchr/testify/assert"
+)
+
+// Serves data points at 1000, 2000 and 3000 ms, limited to the requested
+// time range.
+func newExportServer(starts *[]int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var q struct {
+			Start   int64 `json:"start_absolute"`
+			End     int64 `json:"end_absolute"`
+			Metrics []struct {
+				Name string `json:"name"`
+			} `json:"metrics"`
+		}
+		json.NewDecoder(r.Body).Decode(&q)
+		*starts = append(*starts, q.Start)
+
+		var values []string
+		for _, ts := range []int64{1000, 2000, 3000} {
+			if ts >= q.Start && ts <= q.End {
+				values = append(values, fmt.Sprintf("[%d,1]", ts))
+			}
+		}
+
+		fmt.Fprintf(w, `{"queries":[{"results":[{"name":%q,"values":[%s]}]}]}`,
+			q.Metrics[0].Name, strings.Join(values, ","))
+	}))
+}
+
+// Success test.
+func TestRunIncremental(t *testing.T) {
+	var starts []int64
+	srv := newExportServer(&starts)
+	defer srv.Close()
+
+	store := NewFileCursorStore(filepath.Join(t.TempDir(), "cursors.json"))
+	opts := IncrementalOptions{
+		Metrics:      []string{"m1"},
+		InitialStart: time.Unix(0, 0).Add(time.Millisecond),
+	}
+
+	var exported int
+	sink := func(metric string, results []response.Results) error {
+		for _, r := range results {
+			exported += len(r.DataPoints)
+		}
+		return nil
+	}
+
+	err := RunIncremental(context.Background(), client.NewHttpClient(srv.URL), store, opts, sink)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 3, exported, "All data points expected on the first run")
+
+	cursors, err := store.Load()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, map[string]int64{"m1": 3000}, cursors, "Cursor must point to the last data point")
+
+	exported = 0
+	err = RunIncremental(context.Background(), client.NewHttpClient(srv.URL), store, opts, sink)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 0, exported, "No new data expected on the second run")
+	assert.Equal(t, []int64{1, 3001}, starts, "Second run must start after the cursor")
+}
+
+// Failure test.
+func TestRunIncrementalSinkError(t *testing.T) {
+	var starts []int64
+	srv := newExportServer(&starts)
+	defer srv.Close()
+
+	store := NewFileCursorStore(filepath.Join(t.TempDir(), "cursors.json"))
+	opts := IncrementalOptions{
+		Metrics:      []string{"m1"},
+		InitialStart: time.Unix(0, 0).Add(time.Millisecond),
+	}
+
+	sinkErr := errors.New("sink failed")
+	err := RunIncremental(context.Background(), client.NewHttpClient(srv.URL), store, opts,
+		func(string, []response.Results) error { return sinkErr })
+	assert.Equal(t, sinkErr, err, "Sink error expected")
+
+	cursors, err := store.Load()
+	assert.Nil(t, err, "No error expected")
+	assert.Empty(t, cursors, "Cursor must not move when the sink fails")
+
+	err = RunIncremental(context.Background(), client.NewHttpClient(srv.URL), store, IncrementalOptions{}, nil)
+	assert.Equal(t, ErrorNoMetrics, err, "Missing metrics must be rejected")
+}
@@ -0,0 +1,36 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.23
+
+package export
+
+import (
+	"iter"
+
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Returns an iterator over the remaining series, streamed from the spill
+// file. As with Next, the error that stopped the iteration is reported by
+// Err.
+func (it *ResultsIterator) All() iter.Seq[response.Results] {
+	return func(yield func(response.Results) bool) {
+		for it.Next() {
+			if !yield(it.Results()) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.23
+
+package export
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestResultsIteratorAll(t *testing.T) {
+	acc := NewAccumulator(AccumulatorOptions{MemoryBudget: 1000, Dir: t.TempDir()})
+	defer acc.Close()
+
+	for _, name := range []string{"m1", "m2", "m3"} {
+		assert.Nil(t, acc.Add(seriesOf(name, 10)), "No error expected")
+	}
+
+	it, err := acc.Iterator()
+	assert.Nil(t, err, "No error expected")
+	defer it.Close()
+
+	var names []string
+	for r := range it.All() {
+		names = append(names, r.Name)
+		if r.Name == "m2" {
+			break
+		}
+	}
+	assert.Equal(t, []string{"m1", "m2"}, names)
+
+	for r := range it.All() {
+		names = append(names, r.Name)
+	}
+	assert.Nil(t, it.Err(), "No error expected")
+	assert.Equal(t, []string{"m1", "m2", "m3"}, names, "Iteration must resume after a break")
+}
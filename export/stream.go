@@ -0,0 +1,108 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/client"
+	"github.com/retoool/go-kairosdb/clock"
+)
+
+// Options of a streamed export.
+type StreamOptions struct {
+	// Size of the time slices the query is split into, each one written out
+	// before the next one is queried. Defaults to a day.
+	ChunkSize time.Duration
+
+	// Resolves the relative times of the query. Defaults to the wall clock.
+	Clock clock.Clock
+}
+
+// A data point as written by Stream.
+type streamedPoint struct {
+	Metric    string              `json:"metric"`
+	Tags      map[string][]string `json:"tags,omitempty"`
+	Timestamp int64               `json:"ts"`
+	Value     interface{}         `json:"value"`
+}
+
+// Runs the query and writes its data points to w as newline delimited JSON,
+// one object per data point, e.g.
+//
+//	{"metric":"cpu","tags":{"host":["a"]},"ts":1500000000000,"value":0.5}
+//
+// The query is split into time slices of a day, written out as soon as they
+// are retrieved, so that the data can be piped into a file or a producer
+// without holding the whole export in memory. See StreamWithOptions.
+func Stream(ctx context.Context, c client.MetricReader, qb builder.QueryBuilder, w io.Writer) error {
+	return StreamWithOptions(ctx, c, qb, w, StreamOptions{})
+}
+
+// Same as Stream, with options. A slice answered with an error status stops
+// the export with an error wrapping ErrorQueryFailed; the slices before it
+// are already written.
+func StreamWithOptions(ctx context.Context, c client.MetricReader, qb builder.QueryBuilder, w io.Writer, opts StreamOptions) error {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = 24 * time.Hour
+	}
+
+	chunks, err := builder.SplitQuery(qb, opts.ChunkSize, clock.OrReal(opts.Clock).Now())
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for _, chunk := range chunks {
+		resp, err := c.QueryContext(ctx, chunk.Query)
+		if err != nil {
+			return err
+		}
+
+		if code := resp.GetStatusCode(); code >= http.StatusMultipleChoices {
+			return fmt.Errorf("%w: status %d: %v", ErrorQueryFailed, code, resp.GetErrors())
+		}
+
+		for _, q := range resp.QueriesArr {
+			for _, r := range q.ResultsArr {
+				for i := range r.DataPoints {
+					err := enc.Encode(streamedPoint{
+						Metric:    r.Name,
+						Tags:      r.Tags,
+						Timestamp: r.DataPoints[i].Timestamp(),
+						Value:     r.DataPoints[i].Value(),
+					})
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
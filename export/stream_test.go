@@ -0,0 +1,74 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package export
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func streamQuery() builder.QueryBuilder {
+	qb := builder.NewQueryBuilder().SetTimeRange(time.UnixMilli(1), time.UnixMilli(3500))
+	qb.AddMetric("m1")
+	return qb
+}
+
+// Success test.
+func TestStream(t *testing.T) {
+	var starts []int64
+	srv := newExportServer(&starts)
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	err := StreamWithOptions(context.Background(), client.NewHttpClient(srv.URL), streamQuery(), &buf,
+		StreamOptions{ChunkSize: time.Second})
+	assert.Nil(t, err, "No error expected")
+	assert.Len(t, starts, 4, "One query per slice expected")
+	assert.Equal(t, `{"metric":"m1","ts":1000,"value":1}
+{"metric":"m1","ts":2000,"value":1}
+{"metric":"m1","ts":3000,"value":1}
+`, buf.String(), "One line per data point expected")
+}
+
+// Failure test.
+func TestStreamQueryFailed(t *testing.T) {
+	queries := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if queries++; queries > 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"errors":["bad query"]}`))
+			return
+		}
+		w.Write([]byte(`{"queries":[{"results":[{"name":"m1","tags":{"host":["a"]},"values":[[1,0.5]]}]}]}`))
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	err := StreamWithOptions(context.Background(), client.NewHttpClient(srv.URL), streamQuery(), &buf,
+		StreamOptions{ChunkSize: time.Second})
+	assert.True(t, errors.Is(err, ErrorQueryFailed), "Query failure expected")
+	assert.EqualError(t, err, "Export query failed: status 400: [bad query]")
+	assert.Equal(t, `{"metric":"m1","tags":{"host":["a"]},"ts":1,"value":0.5}`+"\n", buf.String(),
+		"Slices before the failure written expected")
+}
@@ -0,0 +1,703 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kairostest
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/retoool/go-kairosdb/client"
+	"github.com/retoool/go-kairosdb/clock"
+)
+
+// Options of the Emulator.
+type EmulatorOptions struct {
+	// Time relative query ranges and TTLs are computed against. Defaults
+	// to the wall clock.
+	Clock clock.Clock
+}
+
+// An in-memory KairosDB, for end to end tests of query logic without
+// Docker. It stores the pushed data points by series, a metric name and
+// its tags, and evaluates queries the way the server does: tag filters,
+// absolute and relative time ranges, limit and order, grouping by tag and
+// the sum, avg, min, max, count, first and last aggregators with their
+// sampling and alignment. Tag queries, deletes, TTLs and the metric, tag
+// name and tag value listings are supported as well.
+//
+// Values are stored as float64, so only numeric data points are accepted.
+// Queries using another aggregator or grouper are answered with 400.
+type Emulator struct {
+	clock clock.Clock
+
+	mu     sync.RWMutex // Guards series.
+	series map[string]*emuSeries
+}
+
+type emuSeries struct {
+	name   string
+	tags   map[string]string
+	points map[int64]emuPoint // By timestamp, a push overwrites.
+}
+
+type emuPoint struct {
+	value   float64
+	expires int64 // Zero for no TTL.
+}
+
+// Creates an empty emulator.
+func NewEmulator(opts EmulatorOptions) *Emulator {
+	return &Emulator{
+		clock:  clock.OrReal(opts.Clock),
+		series: make(map[string]*emuSeries),
+	}
+}
+
+// Starts an emulator for the test and returns a client of it along with
+// the emulator. The server is closed when the test ends.
+func NewEmulated(t testing.TB, opts EmulatorOptions, clientOpts ...client.Option) (client.Client, *Emulator) {
+	t.Helper()
+
+	e := NewEmulator(opts)
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+
+	return client.NewHttpClientWithOptions(srv.URL, clientOpts...), e
+}
+
+// Drops all the stored data points.
+func (e *Emulator) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.series = make(map[string]*emuSeries)
+}
+
+// Serves the KairosDB REST API.
+func (e *Emulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/api/v1")
+
+	switch {
+	case path == "/datapoints" && r.Method == http.MethodPost:
+		e.push(w, r)
+	case path == "/datapoints/query" && r.Method == http.MethodPost:
+		e.query(w, r, false)
+	case path == "/datapoints/query/tags" && r.Method == http.MethodPost:
+		e.query(w, r, true)
+	case path == "/datapoints/delete" && r.Method == http.MethodPost:
+		e.deleteQuery(w, r)
+	case strings.HasPrefix(path, "/metric/") && r.Method == http.MethodDelete:
+		e.deleteMetric(strings.TrimPrefix(path, "/metric/"))
+		w.WriteHeader(http.StatusNoContent)
+	case path == "/metricnames" && r.Method == http.MethodGet:
+		e.list(w, r.URL.Query().Get("prefix"), func(s *emuSeries) []string { return []string{s.name} })
+	case path == "/tagnames" && r.Method == http.MethodGet:
+		e.list(w, "", func(s *emuSeries) []string { return sortedKeys(s.tags) })
+	case path == "/tagvalues" && r.Method == http.MethodGet:
+		e.list(w, "", func(s *emuSeries) []string {
+			var vals []string
+			for _, v := range s.tags {
+				vals = append(vals, v)
+			}
+			return vals
+		})
+	case path == "/health/check" && r.Method == http.MethodGet:
+		w.WriteHeader(http.StatusNoContent)
+	case path == "/health/status" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, []string{"JVM-Thread-Deadlock: OK", "Datastore-Query: OK"})
+	case path == "/version" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]string{"version": "KairosDB 1.3.0-emulator"})
+	default:
+		writeErrors(w, http.StatusNotFound, "not found")
+	}
+}
+
+// A metric of a push request, either with a list of data points or a
+// single one.
+type emuMetric struct {
+	Name       string            `json:"name"`
+	Tags       map[string]string `json:"tags"`
+	DataPoints [][2]interface{}  `json:"datapoints"`
+	Timestamp  *int64            `json:"timestamp"`
+	Value      interface{}       `json:"value"`
+	TTL        int64             `json:"ttl"`
+}
+
+func (e *Emulator) push(w http.ResponseWriter, r *http.Request) {
+	var metrics []emuMetric
+	if err := decodeBody(r, &metrics); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var errs []string
+	for i, m := range metrics {
+		if m.Name == "" {
+			errs = append(errs, fmt.Sprintf("metric[%d].name may not be empty.", i))
+		}
+		if len(m.Tags) == 0 {
+			errs = append(errs, fmt.Sprintf("metric[%d](name=%s).tags count must be greater than or equal to 1.", i, m.Name))
+		}
+		for j, dp := range m.DataPoints {
+			if _, ok := dp[1].(float64); !ok {
+				errs = append(errs, fmt.Sprintf("metric[%d](name=%s).datapoints[%d].value must be a number.", i, m.Name, j))
+			}
+		}
+		if m.Timestamp != nil {
+			if _, ok := m.Value.(float64); !ok {
+				errs = append(errs, fmt.Sprintf("metric[%d](name=%s).value must be a number.", i, m.Name))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		writeErrors(w, http.StatusBadRequest, errs...)
+		return
+	}
+
+	now := toMs(e.clock.Now())
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, m := range metrics {
+		key := seriesKey(m.Name, m.Tags)
+		s, ok := e.series[key]
+		if !ok {
+			s = &emuSeries{name: m.Name, tags: m.Tags, points: make(map[int64]emuPoint)}
+			e.series[key] = s
+		}
+
+		var expires int64
+		if m.TTL > 0 {
+			expires = now + m.TTL*1000
+		}
+
+		for _, dp := range m.DataPoints {
+			ts, _ := dp[0].(float64)
+			s.points[int64(ts)] = emuPoint{value: dp[1].(float64), expires: expires}
+		}
+		if m.Timestamp != nil {
+			s.points[*m.Timestamp] = emuPoint{value: m.Value.(float64), expires: expires}
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// A query as sent to KairosDB.
+type emuQuery struct {
+	StartAbs *int64              `json:"start_absolute"`
+	EndAbs   *int64              `json:"end_absolute"`
+	StartRel *utils.RelativeTime `json:"start_relative"`
+	EndRel   *utils.RelativeTime `json:"end_relative"`
+	Metrics  []emuQueryMetric    `json:"metrics"`
+}
+
+type emuQueryMetric struct {
+	Name        string              `json:"name"`
+	Tags        map[string][]string `json:"tags"`
+	Limit       int                 `json:"limit"`
+	Order       string              `json:"order"`
+	GroupBy     []emuGrouper        `json:"group_by"`
+	Aggregators []emuAggregator     `json:"aggregators"`
+}
+
+type emuGrouper struct {
+	Name string   `json:"name"`
+	Tags []string `json:"tags"`
+}
+
+type emuAggregator struct {
+	Name           string              `json:"name"`
+	Sampling       *utils.RelativeTime `json:"sampling"`
+	AlignSampling  bool                `json:"align_sampling"`
+	AlignStartTime bool                `json:"align_start_time"`
+	StartTime      int64               `json:"start_time"`
+}
+
+type emuResult struct {
+	Name    string              `json:"name"`
+	GroupBy []interface{}       `json:"group_by,omitempty"`
+	Tags    map[string][]string `json:"tags"`
+	Values  [][2]interface{}    `json:"values"`
+}
+
+type emuQueryResult struct {
+	SampleSize int         `json:"sample_size"`
+	Results    []emuResult `json:"results"`
+}
+
+func (e *Emulator) query(w http.ResponseWriter, r *http.Request, tagsOnly bool) {
+	q, start, end, ok := e.decodeQuery(w, r)
+	if !ok {
+		return
+	}
+
+	for i, qm := range q.Metrics {
+		if err := checkSupported(qm); err != "" {
+			writeErrors(w, http.StatusBadRequest, fmt.Sprintf("query.metric[%d].%s", i, err))
+			return
+		}
+	}
+
+	now := toMs(e.clock.Now())
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	queries := make([]emuQueryResult, 0, len(q.Metrics))
+	for _, qm := range q.Metrics {
+		var qr emuQueryResult
+		for _, g := range e.groups(qm, start, end, now) {
+			qr.SampleSize += len(g.points)
+
+			res := emuResult{Name: qm.Name, Tags: g.tags, Values: [][2]interface{}{}}
+			if !tagsOnly {
+				res.GroupBy = g.groupBy
+				points := g.points
+				if qm.Limit > 0 && len(points) > qm.Limit {
+					// The limit keeps the first data points in the
+					// order of the query.
+					if qm.Order == "desc" {
+						points = points[len(points)-qm.Limit:]
+					} else {
+						points = points[:qm.Limit]
+					}
+				}
+				for _, a := range qm.Aggregators {
+					points = aggregate(a, points, start)
+				}
+				if qm.Order == "desc" {
+					for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+						points[i], points[j] = points[j], points[i]
+					}
+				}
+				for _, p := range points {
+					res.Values = append(res.Values, [2]interface{}{p.ts, p.value})
+				}
+			}
+			qr.Results = append(qr.Results, res)
+		}
+
+		if len(qr.Results) == 0 {
+			qr.Results = []emuResult{{Name: qm.Name, Tags: map[string][]string{}, Values: [][2]interface{}{}}}
+		}
+		queries = append(queries, qr)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"queries": queries})
+}
+
+func (e *Emulator) deleteQuery(w http.ResponseWriter, r *http.Request) {
+	q, start, end, ok := e.decodeQuery(w, r)
+	if !ok {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, qm := range q.Metrics {
+		for key, s := range e.series {
+			if !s.matches(qm) {
+				continue
+			}
+
+			for ts := range s.points {
+				if ts >= start && ts <= end {
+					delete(s.points, ts)
+				}
+			}
+			if len(s.points) == 0 {
+				delete(e.series, key)
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Emulator) deleteMetric(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for key, s := range e.series {
+		if s.name == name {
+			delete(e.series, key)
+		}
+	}
+}
+
+// Answers with the sorted distinct strings returned by f for the series,
+// restricted to the prefix.
+func (e *Emulator) list(w http.ResponseWriter, prefix string, f func(s *emuSeries) []string) {
+	e.mu.RLock()
+	set := make(map[string]bool)
+	for _, s := range e.series {
+		for _, v := range f(s) {
+			if strings.HasPrefix(v, prefix) {
+				set[v] = true
+			}
+		}
+	}
+	e.mu.RUnlock()
+
+	results := make([]string, 0, len(set))
+	for v := range set {
+		results = append(results, v)
+	}
+	sort.Strings(results)
+
+	writeJSON(w, http.StatusOK, map[string][]string{"results": results})
+}
+
+// Decodes a query and resolves its time range, answering the request when
+// the query is invalid.
+func (e *Emulator) decodeQuery(w http.ResponseWriter, r *http.Request) (q emuQuery, start, end int64, ok bool) {
+	if err := decodeBody(r, &q); err != nil {
+		writeErrors(w, http.StatusBadRequest, err.Error())
+		return q, 0, 0, false
+	}
+
+	now := e.clock.Now()
+	switch {
+	case q.StartAbs != nil:
+		start = *q.StartAbs
+	case q.StartRel != nil:
+		start = toMs(q.StartRel.RelativeTimeTo(now))
+	default:
+		writeErrors(w, http.StatusBadRequest, "query.start_time relative or absolute time must be set")
+		return q, 0, 0, false
+	}
+
+	switch {
+	case q.EndAbs != nil:
+		end = *q.EndAbs
+	case q.EndRel != nil:
+		end = toMs(q.EndRel.RelativeTimeTo(now))
+	default:
+		end = toMs(now)
+	}
+
+	if end < start {
+		writeErrors(w, http.StatusBadRequest, "query.end_time must be greater than the start time")
+		return q, 0, 0, false
+	}
+
+	return q, start, end, true
+}
+
+// Returns the part of the query metric the emulator does not support, if
+// any.
+func checkSupported(qm emuQueryMetric) string {
+	for i, g := range qm.GroupBy {
+		if g.Name != "tag" {
+			return fmt.Sprintf("group_by[%d] %s is not supported by the emulator", i, g.Name)
+		}
+	}
+
+	for i, a := range qm.Aggregators {
+		switch a.Name {
+		case "sum", "avg", "min", "max", "count", "first", "last":
+		default:
+			return fmt.Sprintf("aggregators[%d] %s is not supported by the emulator", i, a.Name)
+		}
+
+		if a.Sampling != nil && (a.Sampling.Value() <= 0 || !a.Sampling.Unit().IsValid()) {
+			return fmt.Sprintf("aggregators[%d].sampling is invalid", i)
+		}
+	}
+
+	return ""
+}
+
+type emuDataPoint struct {
+	ts    int64
+	value float64
+}
+
+// The data points of the series of a result.
+type emuGroup struct {
+	key     string
+	tags    map[string][]string
+	groupBy []interface{}
+	points  []emuDataPoint
+}
+
+// Returns the data points of the series matching the query metric within
+// the time range, merged by tag group.
+func (e *Emulator) groups(qm emuQueryMetric, start, end, now int64) []*emuGroup {
+	var groupTags []string
+	for _, g := range qm.GroupBy {
+		groupTags = append(groupTags, g.Tags...)
+	}
+
+	byKey := make(map[string]*emuGroup)
+	for _, s := range e.series {
+		if !s.matches(qm) {
+			continue
+		}
+
+		var points []emuDataPoint
+		for ts, p := range s.points {
+			if ts >= start && ts <= end && (p.expires == 0 || p.expires > now) {
+				points = append(points, emuDataPoint{ts: ts, value: p.value})
+			}
+		}
+		if len(points) == 0 {
+			continue
+		}
+
+		group := make(map[string]string)
+		for _, t := range groupTags {
+			if v, ok := s.tags[t]; ok {
+				group[t] = v
+			}
+		}
+		key := seriesKey("", group)
+
+		g, ok := byKey[key]
+		if !ok {
+			g = &emuGroup{key: key, tags: make(map[string][]string)}
+			if len(groupTags) > 0 {
+				g.groupBy = append(g.groupBy, map[string]interface{}{"name": "tag", "tags": groupTags, "group": group})
+			}
+			g.groupBy = append(g.groupBy, map[string]string{"name": "type", "type": "number"})
+			byKey[key] = g
+		}
+
+		for k, v := range s.tags {
+			if !contains(g.tags[k], v) {
+				g.tags[k] = append(g.tags[k], v)
+			}
+		}
+		g.points = append(g.points, points...)
+	}
+
+	groups := make([]*emuGroup, 0, len(byKey))
+	for _, g := range byKey {
+		for _, vals := range g.tags {
+			sort.Strings(vals)
+		}
+		sort.SliceStable(g.points, func(i, j int) bool { return g.points[i].ts < g.points[j].ts })
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].key < groups[j].key })
+
+	return groups
+}
+
+// Tells whether the series is selected by the name and tag filter of the
+// query metric.
+func (s *emuSeries) matches(qm emuQueryMetric) bool {
+	if s.name != qm.Name {
+		return false
+	}
+
+	for k, vals := range qm.Tags {
+		if len(vals) > 0 && !contains(vals, s.tags[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Applies the aggregator to the sorted data points. Without sampling, the
+// whole range makes one sample.
+func aggregate(a emuAggregator, points []emuDataPoint, queryStart int64) []emuDataPoint {
+	if len(points) == 0 {
+		return points
+	}
+
+	value, unit := 0, utils.TimeUnit(utils.MILLISECONDS)
+	if a.Sampling != nil {
+		value, unit = a.Sampling.Value(), a.Sampling.Unit()
+	}
+
+	origin := time.UnixMilli(queryStart).UTC()
+	if a.StartTime > 0 {
+		origin = time.UnixMilli(a.StartTime).UTC()
+	} else if a.AlignSampling {
+		origin = truncate(origin, unit)
+	}
+
+	var out []emuDataPoint
+	var bucket []emuDataPoint
+	n := 0
+	rangeStart := origin
+	rangeEnd := addUnits(origin, value, unit)
+
+	flush := func() {
+		if len(bucket) == 0 {
+			return
+		}
+		ts := bucket[0].ts
+		if a.AlignStartTime || a.AlignSampling {
+			ts = toMs(rangeStart)
+		}
+		out = append(out, emuDataPoint{ts: ts, value: reduce(a.Name, bucket)})
+		bucket = bucket[:0]
+	}
+
+	for _, p := range points {
+		for value > 0 && p.ts >= toMs(rangeEnd) {
+			flush()
+			n++
+			rangeStart = addUnits(origin, n*value, unit)
+			rangeEnd = addUnits(origin, (n+1)*value, unit)
+		}
+		bucket = append(bucket, p)
+	}
+	flush()
+
+	return out
+}
+
+func reduce(name string, points []emuDataPoint) float64 {
+	switch name {
+	case "count":
+		return float64(len(points))
+	case "first":
+		return points[0].value
+	case "last":
+		return points[len(points)-1].value
+	}
+
+	sum, min, max := 0.0, math.Inf(1), math.Inf(-1)
+	for _, p := range points {
+		sum += p.value
+		min = math.Min(min, p.value)
+		max = math.Max(max, p.value)
+	}
+
+	switch name {
+	case "avg":
+		return sum / float64(len(points))
+	case "min":
+		return min
+	case "max":
+		return max
+	}
+	return sum
+}
+
+// Returns the time n units after t.
+func addUnits(t time.Time, n int, unit utils.TimeUnit) time.Time {
+	switch utils.TimeUnit(strings.ToLower(string(unit))) {
+	case utils.YEARS:
+		return t.AddDate(n, 0, 0)
+	case utils.MONTHS:
+		return t.AddDate(0, n, 0)
+	case utils.WEEKS:
+		return t.AddDate(0, 0, 7*n)
+	case utils.DAYS:
+		return t.AddDate(0, 0, n)
+	case utils.HOURS:
+		return t.Add(time.Duration(n) * time.Hour)
+	case utils.MINUTES:
+		return t.Add(time.Duration(n) * time.Minute)
+	case utils.SECONDS:
+		return t.Add(time.Duration(n) * time.Second)
+	}
+	return t.Add(time.Duration(n) * time.Millisecond)
+}
+
+// Aligns t on the unit, e.g. the top of the hour for hours.
+func truncate(t time.Time, unit utils.TimeUnit) time.Time {
+	switch utils.TimeUnit(strings.ToLower(string(unit))) {
+	case utils.YEARS:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+	case utils.MONTHS:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case utils.WEEKS:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -int(day.Weekday()))
+	case utils.DAYS:
+		return t.Truncate(24 * time.Hour)
+	case utils.HOURS:
+		return t.Truncate(time.Hour)
+	case utils.MINUTES:
+		return t.Truncate(time.Minute)
+	case utils.SECONDS:
+		return t.Truncate(time.Second)
+	}
+	return t
+}
+
+// Returns a key identifying a series.
+func seriesKey(name string, tags map[string]string) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range sortedKeys(tags) {
+		b.WriteString("\x00" + k + "=" + tags[k])
+	}
+	return b.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(vals []string, v string) bool {
+	for _, val := range vals {
+		if val == v {
+			return true
+		}
+	}
+	return false
+}
+
+func toMs(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
+
+func decodeBody(r *http.Request, v interface{}) error {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		body = gr
+	}
+
+	return json.NewDecoder(body).Decode(v)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeErrors(w http.ResponseWriter, code int, errs ...string) {
+	writeJSON(w, code, map[string][]string{"errors": errs})
+}
@@ -0,0 +1,186 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kairostest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/retoool/go-kairosdb/client"
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/retoool/go-kairosdb/response"
+	"github.com/stretchr/testify/assert"
+)
+
+var emuStart = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Pushes one point a minute for 10 minutes to h1 (values 0..9) and h2
+// (values 10..19).
+func seedEmulator(t *testing.T, clk clock.Clock) (client.Client, func(qb builder.QueryBuilder) *response.QueryResponse) {
+	cli, _ := NewEmulated(t, EmulatorOptions{Clock: clk})
+
+	mb := builder.NewMetricBuilder()
+	h1 := mb.AddMetric("cpu").AddTag("host", "h1").AddTag("dc", "eu")
+	h2 := mb.AddMetric("cpu").AddTag("host", "h2").AddTag("dc", "eu")
+	for i := 0; i < 10; i++ {
+		ts := emuStart.Add(time.Duration(i) * time.Minute).UnixMilli()
+		h1.AddDataPoint(ts, float64(i))
+		h2.AddDataPoint(ts, float64(10+i))
+	}
+	mb.AddMetric("mem").AddTag("host", "h1").AddDataPoint(emuStart.UnixMilli(), 1)
+
+	resp, err := cli.PushMetrics(mb)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode())
+
+	return cli, func(qb builder.QueryBuilder) *response.QueryResponse {
+		qr, err := cli.Query(qb)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, qr.GetStatusCode(), qr.GetErrors())
+		return qr
+	}
+}
+
+func values(r response.Results) [][2]float64 {
+	var vals [][2]float64
+	for _, dp := range r.DataPoints {
+		v, _ := dp.Float64Value()
+		vals = append(vals, [2]float64{float64(dp.Timestamp() - emuStart.UnixMilli()), v})
+	}
+	return vals
+}
+
+// Success test.
+func TestEmulatorQuery(t *testing.T) {
+	_, query := seedEmulator(t, clock.NewFake(emuStart.Add(time.Hour)))
+	minute := float64(time.Minute / time.Millisecond)
+
+	qb := builder.NewQueryBuilder().SetAbsoluteStart(emuStart)
+	qb.AddMetric("cpu").AddTag("host", []string{"h1"}).SetLimit(3)
+	res := query(qb).QueriesArr[0]
+	assert.Equal(t, int64(10), res.SampleSize)
+	assert.Equal(t, [][2]float64{{0, 0}, {minute, 1}, {2 * minute, 2}}, values(res.ResultsArr[0]))
+	assert.Equal(t, map[string][]string{"host": {"h1"}, "dc": {"eu"}}, res.ResultsArr[0].Tags)
+
+	// Both series merged, summed by 5 minutes.
+	qb = builder.NewQueryBuilder().SetAbsoluteStart(emuStart)
+	qb.AddMetric("cpu").AddAggregator(builder.CreateSumAggregator(5, utils.MINUTES))
+	res = query(qb).QueriesArr[0]
+	assert.Equal(t, [][2]float64{{0, 10 + 60}, {5 * minute, 35 + 85}}, values(res.ResultsArr[0]))
+	assert.Equal(t, map[string][]string{"host": {"h1", "h2"}, "dc": {"eu"}}, res.ResultsArr[0].Tags)
+
+	// Grouped by host, chained aggregators, relative range and order.
+	qb = builder.NewQueryBuilder().SetRelativeStart(1, utils.HOURS)
+	qb.AddMetric("cpu").
+		AddGrouper(builder.CreateTagsGroupBy([]string{"host"})).
+		AddAggregator(builder.CreateMaxAggregator(2, utils.MINUTES)).
+		AddAggregator(builder.CreateAverageAggregator(1, utils.HOURS)).
+		SetOrder(builder.DESCENDING)
+	res = query(qb).QueriesArr[0]
+	assert.Len(t, res.ResultsArr, 2)
+	assert.Equal(t, [][2]float64{{0, 5}}, values(res.ResultsArr[0]))
+	assert.Equal(t, [][2]float64{{0, 15}}, values(res.ResultsArr[1]))
+	assert.Equal(t, map[string]interface{}{"host": "h2"}, res.ResultsArr[1].Group[0].Group)
+
+	qb = builder.NewQueryBuilder().SetAbsoluteStart(emuStart)
+	qb.AddMetric("cpu").AddAggregator(builder.CreateMinAggregator(1, utils.HOURS))
+	qb.AddMetric("cpu").AddAggregator(builder.CreateCountAggregator(1, utils.HOURS))
+	qb.AddMetric("disk")
+	qr := query(qb)
+	assert.Equal(t, [][2]float64{{0, 0}}, values(qr.QueriesArr[0].ResultsArr[0]))
+	assert.Equal(t, [][2]float64{{0, 20}}, values(qr.QueriesArr[1].ResultsArr[0]))
+	assert.Empty(t, qr.QueriesArr[2].ResultsArr[0].DataPoints)
+}
+
+// Success test.
+func TestEmulatorTagsAndDelete(t *testing.T) {
+	clk := clock.NewFake(emuStart.Add(time.Hour))
+	cli, query := seedEmulator(t, clk)
+
+	qb := builder.NewQueryBuilder().SetAbsoluteStart(emuStart)
+	qb.AddMetric("cpu").AddTag("host", []string{"h2"})
+	qr, err := cli.QueryTags(qb)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"host": {"h2"}, "dc": {"eu"}}, qr.QueriesArr[0].ResultsArr[0].Tags)
+	assert.Empty(t, qr.QueriesArr[0].ResultsArr[0].DataPoints)
+
+	names, err := cli.GetMetricNames()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cpu", "mem"}, names.Results)
+	tagNames, _ := cli.GetTagNames()
+	assert.Equal(t, []string{"dc", "host"}, tagNames.Results)
+	tagValues, _ := cli.GetTagValues()
+	assert.Equal(t, []string{"eu", "h1", "h2"}, tagValues.Results)
+
+	del := builder.NewQueryBuilder().SetAbsoluteStart(emuStart).SetAbsoluteEnd(emuStart.Add(4 * time.Minute))
+	del.AddMetric("cpu").AddTag("host", []string{"h2"})
+	resp, err := cli.Delete(del)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode())
+	assert.Len(t, query(qb).QueriesArr[0].ResultsArr[0].DataPoints, 5)
+
+	_, err = cli.DeleteMetric("mem")
+	assert.NoError(t, err)
+	names, _ = cli.GetMetricNames()
+	assert.Equal(t, []string{"cpu"}, names.Results)
+
+	// Data points expire with their TTL.
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("tmp").AddTag("host", "h1").AddTTL(60).AddDataPoint(emuStart.UnixMilli(), 1)
+	_, err = cli.PushMetrics(mb)
+	assert.NoError(t, err)
+
+	tmp := builder.NewQueryBuilder().SetAbsoluteStart(emuStart)
+	tmp.AddMetric("tmp")
+	assert.Len(t, query(tmp).QueriesArr[0].ResultsArr[0].DataPoints, 1)
+	clk.Advance(time.Minute)
+	assert.Empty(t, query(tmp).QueriesArr[0].ResultsArr[0].DataPoints)
+}
+
+// Failure test.
+func TestEmulatorErrors(t *testing.T) {
+	cli, _ := seedEmulator(t, nil)
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("cpu").AddDataPoint(1, 1)
+	resp, err := cli.PushMetrics(mb)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.GetStatusCode())
+	assert.Equal(t, []string{"metric[0](name=cpu).tags count must be greater than or equal to 1."}, resp.GetErrors())
+
+	qb := builder.NewQueryBuilder().SetAbsoluteStart(emuStart)
+	qb.AddMetric("cpu").AddAggregator(builder.CreatePercentileAggregator(0.9, 1, utils.HOURS))
+	qr, err := cli.Query(qb)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, qr.GetStatusCode())
+	assert.Equal(t, []string{"query.metric[0].aggregators[0] percentile is not supported by the emulator"}, qr.GetErrors())
+}
+
+// Success test.
+func TestEmulatorGetLatest(t *testing.T) {
+	cli, _ := seedEmulator(t, nil)
+	minute := float64(time.Minute / time.Millisecond)
+
+	latest, err := client.GetLatest(context.Background(), cli, "cpu", map[string]string{"dc": "eu"}, 2)
+	assert.NoError(t, err)
+	assert.Len(t, latest, 2, "One result per series expected")
+	assert.Equal(t, [][2]float64{{9 * minute, 9}, {8 * minute, 8}}, values(latest[0]))
+	assert.Equal(t, [][2]float64{{9 * minute, 19}, {8 * minute, 18}}, values(latest[1]))
+	assert.Equal(t, map[string][]string{"host": {"h2"}, "dc": {"eu"}}, latest[1].Tags)
+}
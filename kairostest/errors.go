@@ -0,0 +1,24 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kairostest
+
+import "errors"
+
+var (
+	// Container Errors.
+	ErrorDockerNotFound = errors.New("Docker is not available")
+	ErrorNoPort         = errors.New("Container port is not published")
+	ErrorNotHealthy     = errors.New("KairosDB did not become healthy in time")
+)
@@ -0,0 +1,170 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kairostest starts a real KairosDB server in Docker for end to end
+// tests. It drives the docker command line rather than a Docker SDK so that
+// the module keeps no dependencies beyond the standard library. Tests that
+// do not need the real server can use the in-memory Emulator instead.
+package kairostest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/client"
+)
+
+// Image started when neither Options.Image nor the KAIROSDB_IMAGE environment
+// variable is set. It must run KairosDB with its default H2 datastore and
+// listen on port 8080.
+const DefaultImage = "kairosdb/kairosdb:latest"
+
+// Options of the KairosDB container.
+type Options struct {
+	// Docker image to run. Defaults to KAIROSDB_IMAGE, then DefaultImage.
+	Image string
+
+	// How long to wait for the server to report itself healthy. Defaults
+	// to two minutes, H2 takes a while to initialize.
+	StartupTimeout time.Duration
+}
+
+// A running KairosDB server.
+type Server struct {
+	// Base URL of the server, e.g. http://127.0.0.1:32768.
+	URL string
+
+	containerID string
+}
+
+// Runs the docker command and returns its trimmed standard output. A
+// variable so that tests can fake it.
+var docker = func(ctx context.Context, args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.Error); ok {
+			return "", ErrorDockerNotFound
+		}
+		return "", fmt.Errorf("docker %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// Starts a KairosDB container and waits until its health check passes. The
+// container is removed if it does not.
+func Start(ctx context.Context, opts Options) (*Server, error) {
+	if opts.Image == "" {
+		opts.Image = os.Getenv("KAIROSDB_IMAGE")
+	}
+	if opts.Image == "" {
+		opts.Image = DefaultImage
+	}
+	if opts.StartupTimeout <= 0 {
+		opts.StartupTimeout = 2 * time.Minute
+	}
+
+	id, err := docker(ctx, "run", "--detach", "--rm", "--publish", "127.0.0.1::8080", opts.Image)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{containerID: id}
+
+	if err := s.waitHealthy(ctx, opts.StartupTimeout); err != nil {
+		s.Terminate(context.Background())
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *Server) waitHealthy(ctx context.Context, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		// The port is published once the container runs, which may take a
+		// while when the image is pulled.
+		if s.URL == "" {
+			if out, err := docker(ctx, "port", s.containerID, "8080/tcp"); err == nil && out != "" {
+				// One line per address family, any of them will do.
+				s.URL = "http://" + strings.SplitN(out, "\n", 2)[0]
+			}
+		}
+
+		if s.URL != "" {
+			resp, err := s.Client().HealthCheck()
+			if err == nil && resp.GetStatusCode() == http.StatusNoContent {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if s.URL == "" {
+				return ErrorNoPort
+			}
+			return ErrorNotHealthy
+		case <-ticker.C:
+		}
+	}
+}
+
+// Returns a client of the server configured with the given options.
+func (s *Server) Client(opts ...client.Option) client.Client {
+	return client.NewHttpClientWithOptions(s.URL, opts...)
+}
+
+// Stops and removes the container.
+func (s *Server) Terminate(ctx context.Context) error {
+	_, err := docker(ctx, "rm", "--force", s.containerID)
+	return err
+}
+
+// Starts a server for the test and returns a client of it. The container is
+// removed when the test ends. The test is skipped when Docker is not
+// available, so that end to end tests do not fail on machines without it.
+func New(t testing.TB, opts Options, clientOpts ...client.Option) client.Client {
+	t.Helper()
+
+	if _, err := docker(context.Background(), "info", "--format", "{{.ServerVersion}}"); err != nil {
+		t.Skipf("kairostest: Docker not available: %v", err)
+	}
+
+	s, err := Start(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("kairostest: starting KairosDB: %v", err)
+	}
+
+	t.Cleanup(func() {
+		if err := s.Terminate(context.Background()); err != nil {
+			t.Logf("kairostest: removing KairosDB: %v", err)
+		}
+	})
+
+	return s.Client(clientOpts...)
+}
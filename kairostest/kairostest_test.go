@@ -0,0 +1,97 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kairostest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func fakeDocker(t *testing.T, port string) *[]string {
+	var calls []string
+	orig := docker
+	docker = func(ctx context.Context, args ...string) (string, error) {
+		calls = append(calls, strings.Join(args, " "))
+		switch args[0] {
+		case "run":
+			return "c1", nil
+		case "port":
+			return port, nil
+		}
+		return "", nil
+	}
+	t.Cleanup(func() { docker = orig })
+	return &calls
+}
+
+// Success test.
+func TestStart(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	calls := fakeDocker(t, strings.TrimPrefix(srv.URL, "http://")+"\n[::1]:1")
+
+	s, err := Start(context.Background(), Options{Image: "kairos:test"})
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, srv.URL, s.URL, "Published port expected")
+
+	assert.Nil(t, s.Terminate(context.Background()), "No error expected")
+	assert.Equal(t, []string{
+		"run --detach --rm --publish 127.0.0.1::8080 kairos:test",
+		"port c1 8080/tcp",
+		"rm --force c1",
+	}, *calls)
+}
+
+// Failure test.
+func TestStartNotHealthy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	calls := fakeDocker(t, strings.TrimPrefix(srv.URL, "http://"))
+
+	_, err := Start(context.Background(), Options{StartupTimeout: 100 * time.Millisecond})
+	assert.Equal(t, ErrorNotHealthy, err, "Unhealthy server expected")
+	assert.Equal(t, "rm --force c1", (*calls)[len(*calls)-1], "Container must be removed")
+}
+
+// End to end test, skipped without Docker.
+func TestNew(t *testing.T) {
+	cli := New(t, Options{})
+
+	mb := builder.NewMetricBuilder()
+	mb.AddMetric("kairostest").AddTag("host", "h1").AddDataPoint(time.Now().UnixNano()/int64(time.Millisecond), 1)
+	resp, err := cli.PushMetrics(mb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.GetStatusCode())
+
+	qb := builder.NewQueryBuilder()
+	qb.SetRelativeStart(1, utils.HOURS).AddMetric("kairostest")
+	qr, err := cli.Query(qb)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusOK, qr.GetStatusCode())
+}
@@ -0,0 +1,159 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadgen
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/client"
+)
+
+// Configuration of a load test.
+type Config struct {
+	// Names of the generated metrics.
+	Metrics []string
+
+	// Shape of the generated values.
+	Shape Shape
+
+	// Number of distinct values of every tag. Every metric gets one series
+	// per combination of tag values, e.g. {"host": 100, "cpu": 8} yields 800
+	// series per metric. Tag values are named after the tag, as in "host-42".
+	// A loadgen=true tag is always added, so that the generated data can be
+	// told apart and deleted afterwards.
+	Tags map[string]int
+
+	// Target number of data points per second.
+	Rate int
+
+	// Number of data points per push. Defaults to 1000.
+	BatchSize int
+}
+
+// Outcome of a load test.
+type Stats struct {
+	// Number of data points accepted by KairosDB.
+	Sent int64
+
+	// Number of data points whose push failed.
+	Failed int64
+
+	// Number of pushes.
+	Batches int64
+}
+
+type series struct {
+	metric string
+	tags   map[string]string
+	index  int
+}
+
+// Pushes synthetic data points to the writer at the target rate until the
+// context is done. Series are written to in a round robin fashion, so that
+// at low rates every series still gets data. Failed pushes are counted and
+// do not stop the run.
+func Run(ctx context.Context, w client.MetricWriter, cfg Config) (Stats, error) {
+	var stats Stats
+
+	if len(cfg.Metrics) == 0 {
+		return stats, ErrorNoMetrics
+	}
+
+	if cfg.Shape == nil {
+		return stats, ErrorNoShape
+	}
+
+	if cfg.Rate <= 0 {
+		return stats, ErrorRateInvalid
+	}
+
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+
+	all := expandSeries(cfg.Metrics, cfg.Tags)
+	interval := time.Duration(float64(cfg.BatchSize) / float64(cfg.Rate) * float64(time.Second))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	next := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return stats, nil
+		case now := <-ticker.C:
+			mb := builder.NewMetricBuilder()
+			ts := now.UnixNano() / int64(time.Millisecond)
+			for i := 0; i < cfg.BatchSize; i++ {
+				s := all[next]
+				next = (next + 1) % len(all)
+
+				m := mb.AddMetric(s.metric)
+				for k, v := range s.tags {
+					m.AddTag(k, v)
+				}
+				m.AddDataPoint(ts, cfg.Shape.Value(s.index, now))
+			}
+
+			stats.Batches++
+			resp, err := w.PushMetrics(mb)
+			if err != nil || resp.GetStatusCode() >= http.StatusMultipleChoices {
+				stats.Failed += int64(cfg.BatchSize)
+			} else {
+				stats.Sent += int64(cfg.BatchSize)
+			}
+		}
+	}
+}
+
+// Returns every metric crossed with every combination of tag values.
+func expandSeries(metrics []string, tags map[string]int) []series {
+	names := make([]string, 0, len(tags))
+	combinations := 1
+	for name, n := range tags {
+		if n > 0 {
+			names = append(names, name)
+			combinations *= n
+		}
+	}
+	sort.Strings(names)
+
+	var all []series
+	for _, metric := range metrics {
+		for c := 0; c < combinations; c++ {
+			s := series{
+				metric: metric,
+				tags:   map[string]string{"loadgen": "true"},
+				index:  len(all),
+			}
+
+			// Decompose the combination index into one value per tag.
+			rest := c
+			for _, name := range names {
+				s.tags[name] = fmt.Sprintf("%s-%d", name, rest%tags[name])
+				rest /= tags[name]
+			}
+			all = append(all, s)
+		}
+	}
+
+	return all
+}
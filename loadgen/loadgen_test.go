@@ -0,0 +1,84 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadgen
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/response"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingWriter struct {
+	mu     sync.Mutex
+	code   int
+	series map[string]bool
+}
+
+func (rw *recordingWriter) PushMetrics(mb builder.MetricBuilder) (*response.Response, error) {
+	return rw.PushMetricsContext(context.Background(), mb)
+}
+
+func (rw *recordingWriter) PushMetricsContext(ctx context.Context, mb builder.MetricBuilder) (*response.Response, error) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	for _, m := range mb.GetMetrics() {
+		rw.series[m.GetName()+"/"+m.GetTags()["host"]+"/"+m.GetTags()["cpu"]] = true
+	}
+
+	resp := &response.Response{}
+	resp.SetStatusCode(rw.code)
+	return resp, nil
+}
+
+// Success test.
+func TestRun(t *testing.T) {
+	w := &recordingWriter{code: 204, series: make(map[string]bool)}
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	stats, err := Run(ctx, w, Config{
+		Metrics:   []string{"m1", "m2"},
+		Shape:     Sine(1, 0, time.Minute),
+		Tags:      map[string]int{"host": 3, "cpu": 2},
+		Rate:      2000,
+		BatchSize: 10,
+	})
+	assert.Nil(t, err, "No error expected")
+	assert.True(t, stats.Batches >= 2, "Several batches expected")
+	assert.Equal(t, stats.Batches*10, stats.Sent, "All data points must be sent")
+	assert.Len(t, w.series, 12, "Every series must be written to")
+	assert.True(t, w.series["m2/host-2/cpu-1"], "Tag values must be named after the tag")
+}
+
+// Failure test.
+func TestRunFailures(t *testing.T) {
+	w := &recordingWriter{code: 500, series: make(map[string]bool)}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	stats, err := Run(ctx, w, Config{Metrics: []string{"m1"}, Shape: Spikes(1, 10, 0.1, 1), Rate: 1000, BatchSize: 5})
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, int64(0), stats.Sent, "Nothing must be counted as sent")
+	assert.Equal(t, stats.Batches*5, stats.Failed, "Failed data points must be counted")
+
+	_, err = Run(ctx, w, Config{Metrics: []string{"m1"}, Shape: RandomWalk(0, 1, 1)})
+	assert.Equal(t, ErrorRateInvalid, err, "Invalid rate must be rejected")
+}
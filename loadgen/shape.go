@@ -0,0 +1,108 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadgen
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Generates the values of the synthetic series. series identifies the
+// series, from 0 to the number of series - 1.
+type Shape interface {
+	Value(series int, t time.Time) float64
+}
+
+type sine struct {
+	amplitude float64
+	offset    float64
+	period    time.Duration
+}
+
+// Returns a sine wave oscillating around offset. The series are phase
+// shifted from one another so that they do not all peak at once.
+func Sine(amplitude, offset float64, period time.Duration) Shape {
+	return &sine{amplitude: amplitude, offset: offset, period: period}
+}
+
+func (s *sine) Value(series int, t time.Time) float64 {
+	phase := float64(t.UnixNano()%int64(s.period)) / float64(s.period)
+	return s.offset + s.amplitude*math.Sin(2*math.Pi*phase+float64(series))
+}
+
+type randomWalk struct {
+	start float64
+	step  float64
+
+	mu     sync.Mutex // Guards the fields below.
+	rnd    *rand.Rand
+	values map[int]float64
+}
+
+// Returns a random walk starting at start for every series and moving by at
+// most step on each data point.
+func RandomWalk(start, step float64, seed int64) Shape {
+	return &randomWalk{
+		start:  start,
+		step:   step,
+		rnd:    rand.New(rand.NewSource(seed)),
+		values: make(map[int]float64),
+	}
+}
+
+func (rw *randomWalk) Value(series int, t time.Time) float64 {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	v, ok := rw.values[series]
+	if !ok {
+		v = rw.start
+	}
+	v += (rw.rnd.Float64()*2 - 1) * rw.step
+	rw.values[series] = v
+	return v
+}
+
+type spikes struct {
+	base        float64
+	spike       float64
+	probability float64
+
+	mu  sync.Mutex // Guards rnd.
+	rnd *rand.Rand
+}
+
+// Returns a flat line at base with the given probability of a data point
+// being a spike at base + spike.
+func Spikes(base, spike, probability float64, seed int64) Shape {
+	return &spikes{
+		base:        base,
+		spike:       spike,
+		probability: probability,
+		rnd:         rand.New(rand.NewSource(seed)),
+	}
+}
+
+func (s *spikes) Value(series int, t time.Time) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.rnd.Float64() < s.probability {
+		return s.base + s.spike
+	}
+	return s.base
+}
@@ -0,0 +1,26 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentsdb
+
+import "errors"
+
+var (
+	// Import Errors.
+	ErrorPayloadInvalid   = errors.New("Payload is not an OpenTSDB data point or array of data points")
+	ErrorMetricMissing    = errors.New("Data point metric name empty")
+	ErrorTagsMissing      = errors.New("Data point must have at least one tag")
+	ErrorTimestampInvalid = errors.New("Data point timestamp invalid")
+	ErrorValueInvalid     = errors.New("Data point value not a number")
+)
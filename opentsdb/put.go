@@ -0,0 +1,171 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/client"
+)
+
+// A data point as accepted by the OpenTSDB /api/put endpoint.
+type PutDataPoint struct {
+	Metric    string            `json:"metric"`
+	Timestamp int64             `json:"timestamp"`
+	Value     json.Number       `json:"value"`
+	Tags      map[string]string `json:"tags"`
+}
+
+// Timestamps above this value are in milliseconds, below in seconds, as in
+// OpenTSDB.
+const maxSecondsTimestamp = 9999999999
+
+// Converts an OpenTSDB /api/put payload, either a single data point or an
+// array of them, into a MetricBuilder. Data points of the same series are
+// grouped into a single metric. Second timestamps are converted to
+// milliseconds and values given as strings are parsed like OpenTSDB does.
+func ConvertPut(data []byte) (builder.MetricBuilder, error) {
+	var dps []PutDataPoint
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	data = bytes.TrimSpace(data)
+	switch {
+	case len(data) > 0 && data[0] == '[':
+		if err := dec.Decode(&dps); err != nil {
+			return nil, ErrorPayloadInvalid
+		}
+	case len(data) > 0 && data[0] == '{':
+		var dp PutDataPoint
+		if err := dec.Decode(&dp); err != nil {
+			return nil, ErrorPayloadInvalid
+		}
+		dps = append(dps, dp)
+	default:
+		return nil, ErrorPayloadInvalid
+	}
+
+	mb := builder.NewMetricBuilder()
+	series := make(map[string]builder.Metric)
+	for _, dp := range dps {
+		if dp.Metric == "" {
+			return nil, ErrorMetricMissing
+		}
+
+		if len(dp.Tags) == 0 {
+			return nil, ErrorTagsMissing
+		}
+
+		if dp.Timestamp <= 0 {
+			return nil, ErrorTimestampInvalid
+		}
+
+		ts := dp.Timestamp
+		if ts <= maxSecondsTimestamp {
+			ts *= 1000
+		}
+
+		value, err := parseValue(dp.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		key := seriesKey(dp.Metric, dp.Tags)
+		m, ok := series[key]
+		if !ok {
+			m = mb.AddMetric(dp.Metric)
+			for k, v := range dp.Tags {
+				m.AddTag(k, v)
+			}
+			series[key] = m
+		}
+		m.AddDataPoint(ts, value)
+	}
+
+	return mb, nil
+}
+
+// Returns an http.Handler serving the OpenTSDB /api/put endpoint on top of
+// KairosDB, so that OpenTSDB agents can write to KairosDB unchanged. Like
+// OpenTSDB it answers 204 on success and 400 on invalid payloads. Failures
+// of KairosDB are passed on with their status code.
+func PutHandler(w client.MetricWriter) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		var buf bytes.Buffer
+		if _, err := buf.ReadFrom(r.Body); err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		mb, err := ConvertPut(buf.Bytes())
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		resp, err := w.PushMetrics(mb)
+		if err != nil {
+			http.Error(rw, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		if code := resp.GetStatusCode(); code >= http.StatusMultipleChoices {
+			http.Error(rw, strings.Join(resp.GetErrors(), "\n"), code)
+			return
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func parseValue(n json.Number) (interface{}, error) {
+	s := strings.TrimSpace(n.String())
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return i, nil
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, ErrorValueInvalid
+	}
+	return f, nil
+}
+
+func seriesKey(metric string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(metric)
+	for _, k := range keys {
+		sb.WriteString("\x00" + k + "=" + tags[k])
+	}
+	return sb.String()
+}
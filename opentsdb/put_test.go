@@ -0,0 +1,82 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentsdb
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/client"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestConvertPut(t *testing.T) {
+	payload := `[{"metric":"sys.cpu.user","timestamp":1346846400,"value":18,"tags":{"host":"web01","cpu":"0"}},` +
+		`{"metric":"sys.cpu.user","timestamp":1346846400500,"value":"9.5","tags":{"cpu":"0","host":"web01"}},` +
+		`{"metric":"sys.cpu.user","timestamp":1346846400,"value":4,"tags":{"host":"web02"}}]`
+
+	mb, err := ConvertPut([]byte(payload))
+	assert.Nil(t, err, "No error expected")
+
+	j, err := mb.Build()
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `[{"name":"sys.cpu.user","tags":{"cpu":"0","host":"web01"},"datapoints":[[1346846400000,18],[1346846400500,9.5]]},`+
+		`{"name":"sys.cpu.user","tags":{"host":"web02"},"datapoints":[[1346846400000,4]]}]`, string(j),
+		"Data points must be grouped by series")
+
+	mb, err = ConvertPut([]byte(`{"metric":"m","timestamp":1,"value":1,"tags":{"a":"b"}}`))
+	assert.Nil(t, err, "Single data point must be accepted")
+	assert.Len(t, mb.GetMetrics(), 1, "One metric expected")
+}
+
+// Failure test.
+func TestConvertPutInvalid(t *testing.T) {
+	_, err := ConvertPut([]byte(`"nope"`))
+	assert.Equal(t, ErrorPayloadInvalid, err, "Invalid payload error expected")
+
+	_, err = ConvertPut([]byte(`{"metric":"m","timestamp":1,"value":1}`))
+	assert.Equal(t, ErrorTagsMissing, err, "Missing tags error expected")
+
+	_, err = ConvertPut([]byte(`{"metric":"m","timestamp":1,"tags":{"a":"b"}}`))
+	assert.Equal(t, ErrorValueInvalid, err, "Missing value error expected")
+}
+
+// Success test.
+func TestPutHandler(t *testing.T) {
+	var pushed string
+	kairos := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		pushed = string(data)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer kairos.Close()
+
+	shim := httptest.NewServer(PutHandler(client.NewHttpClient(kairos.URL)))
+	defer shim.Close()
+
+	resp, err := http.Post(shim.URL, "application/json",
+		strings.NewReader(`{"metric":"m","timestamp":2,"value":3,"tags":{"a":"b"}}`))
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode, "Push must succeed")
+	assert.Equal(t, `[{"name":"m","tags":{"a":"b"},"datapoints":[[2000,3]]}]`, pushed, "Converted metrics expected")
+
+	resp, err = http.Post(shim.URL, "application/json", strings.NewReader(`{}`))
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode, "Invalid payload must be rejected")
+}
@@ -0,0 +1,89 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentsdb
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// A series in the shape of the OpenTSDB /api/query response.
+type QueryResult struct {
+	Metric        string                 `json:"metric"`
+	Tags          map[string]string      `json:"tags"`
+	AggregateTags []string               `json:"aggregateTags"`
+	DPs           map[string]interface{} `json:"dps"`
+}
+
+// Converts KairosDB query results to OpenTSDB series. Tags with a single
+// value become tags, tags with several values, i.e. aggregated over, become
+// aggregate tags. Data point timestamps are keyed in seconds unless
+// msResolution is set, in which case they are kept in milliseconds; with
+// second resolution the last data point of a second wins. Non numeric data
+// points are left out since OpenTSDB only stores numbers.
+func ConvertQueryResponse(qr *response.QueryResponse, msResolution bool) []QueryResult {
+	results := make([]QueryResult, 0)
+	for _, q := range qr.QueriesArr {
+		for _, r := range q.ResultsArr {
+			results = append(results, convertResult(r, msResolution))
+		}
+	}
+
+	return results
+}
+
+// Writes the query results as an OpenTSDB /api/query response body.
+func WriteQueryResponse(w io.Writer, qr *response.QueryResponse, msResolution bool) error {
+	return json.NewEncoder(w).Encode(ConvertQueryResponse(qr, msResolution))
+}
+
+func convertResult(r response.Results, msResolution bool) QueryResult {
+	res := QueryResult{
+		Metric:        r.Name,
+		Tags:          make(map[string]string),
+		AggregateTags: make([]string, 0),
+		DPs:           make(map[string]interface{}),
+	}
+
+	for k, vals := range r.Tags {
+		if len(vals) == 1 {
+			res.Tags[k] = vals[0]
+		} else {
+			res.AggregateTags = append(res.AggregateTags, k)
+		}
+	}
+	sort.Strings(res.AggregateTags)
+
+	for i := range r.DataPoints {
+		dp := &r.DataPoints[i]
+		switch dp.Value().(type) {
+		case float64, int64:
+		default:
+			continue
+		}
+
+		ts := dp.Timestamp()
+		if !msResolution {
+			ts /= 1000
+		}
+		res.DPs[strconv.FormatInt(ts, 10)] = dp.Value()
+	}
+
+	return res
+}
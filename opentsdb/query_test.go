@@ -0,0 +1,50 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package opentsdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/response"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestWriteQueryResponse(t *testing.T) {
+	qr := response.NewQueryResponse(200)
+	err := json.Unmarshal([]byte(`{"queries":[{"results":[{"name":"sys.cpu.user",`+
+		`"tags":{"host":["web01"],"cpu":["0","1"]},"values":[[1346846400000,18],[1346846460000,"n/a"],[1346846520500,5.5]]}]}]}`), qr)
+	assert.Nil(t, err, "No error expected")
+
+	var buf bytes.Buffer
+	err = WriteQueryResponse(&buf, qr, false)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, `[{"metric":"sys.cpu.user","tags":{"host":"web01"},"aggregateTags":["cpu"],`+
+		`"dps":{"1346846400":18,"1346846520":5.5}}]`+"\n", buf.String(), "OpenTSDB shape expected")
+
+	results := ConvertQueryResponse(qr, true)
+	assert.Equal(t, map[string]interface{}{"1346846400000": 18.0, "1346846520500": 5.5}, results[0].DPs,
+		"Millisecond timestamps expected")
+}
+
+// Success test.
+func TestConvertQueryResponseEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteQueryResponse(&buf, response.NewQueryResponse(200), false)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, "[]\n", buf.String(), "Empty array expected")
+}
@@ -0,0 +1,51 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import "github.com/retoool/go-kairosdb/builder"
+
+// Copies the aliases of the metrics of the query the response answers to
+// their results. KairosDB returns one query per metric, in the order of the
+// metrics of the query.
+func (qr *QueryResponse) ApplyAliases(qb builder.QueryBuilder) *QueryResponse {
+	metrics := qb.Metrics()
+	for i := range qr.QueriesArr {
+		if i >= len(metrics) {
+			break
+		}
+
+		alias := metrics[i].Alias()
+		for j := range qr.QueriesArr[i].ResultsArr {
+			qr.QueriesArr[i].ResultsArr[j].Alias = alias
+		}
+	}
+
+	return qr
+}
+
+// Returns the results of the response by alias. Results without alias are
+// left out.
+func (qr *QueryResponse) ResultsByAlias() map[string][]Results {
+	byAlias := make(map[string][]Results)
+	for _, q := range qr.QueriesArr {
+		for _, r := range q.ResultsArr {
+			if r.Alias != "" {
+				byAlias[r.Alias] = append(byAlias[r.Alias], r)
+			}
+		}
+	}
+
+	return byAlias
+}
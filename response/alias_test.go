@@ -0,0 +1,45 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestApplyAliases(t *testing.T) {
+	qb := builder.NewQueryBuilder().SetRelativeStart(1, utils.HOURS)
+	qb.AddMetric("cpu").AddTag("host", []string{"web1", "web2"}).SetAlias("web")
+	qb.AddMetric("cpu").AddTag("host", []string{"db1"}).SetAlias("db")
+	qb.AddMetric("mem")
+
+	qr := NewQueryResponse(200)
+	err := json.Unmarshal([]byte(`{"queries":[
+		{"results":[{"name":"cpu","tags":{"host":["web1"]}},{"name":"cpu","tags":{"host":["web2"]}}]},
+		{"results":[{"name":"cpu","tags":{"host":["db1"]}}]},
+		{"results":[{"name":"mem"}]}]}`), qr)
+	assert.Nil(t, err, "No error expected")
+
+	byAlias := qr.ApplyAliases(qb).ResultsByAlias()
+	assert.Equal(t, 2, len(byAlias), "Results without alias must be left out")
+	assert.Equal(t, 2, len(byAlias["web"]), "Both web series expected")
+	assert.Equal(t, []string{"db1"}, byAlias["db"][0].Tags["host"], "db series expected")
+	assert.Equal(t, "", qr.QueriesArr[2].ResultsArr[0].Alias, "No alias expected")
+}
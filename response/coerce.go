@@ -0,0 +1,53 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/retoool/go-kairosdb/builder"
+)
+
+// Replaces the data point values of the series that are strings holding a
+// finite number, as returned by some KairosDB plugins, with the number as a
+// float64. Other strings, e.g. of string metrics, are left as is.
+func (r Results) CoerceNumericStrings() {
+	for i, dp := range r.DataPoints {
+		s, ok := dp.Value().(string)
+		if !ok {
+			continue
+		}
+
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			continue
+		}
+
+		r.DataPoints[i] = *builder.NewDataPoint(dp.Timestamp(), f)
+	}
+}
+
+// Coerces the numeric string values of every series of the response, see
+// Results.CoerceNumericStrings.
+func (qr *QueryResponse) CoerceNumericStrings() *QueryResponse {
+	for i := range qr.QueriesArr {
+		for _, r := range qr.QueriesArr[i].ResultsArr {
+			r.CoerceNumericStrings()
+		}
+	}
+
+	return qr
+}
@@ -0,0 +1,44 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestCoerceNumericStrings(t *testing.T) {
+	qr := NewQueryResponse(200)
+	err := json.Unmarshal([]byte(`{"queries":[{"results":[
+		{"name":"m1","values":[[1,"1.5"],[2,"-7"],[3,4],[4,"1e3"]]},
+		{"name":"m2","values":[[1,"up"],[2,"NaN"],[3,"Inf"],[4,""],[5,null]]}]}]}`), qr)
+	assert.Nil(t, err, "No error expected")
+
+	qr.CoerceNumericStrings()
+
+	var values []interface{}
+	for _, r := range qr.QueriesArr[0].ResultsArr {
+		for _, dp := range r.DataPoints {
+			values = append(values, dp.Value())
+		}
+	}
+
+	assert.Equal(t, []interface{}{1.5, float64(-7), float64(4), float64(1000), "up", "NaN", "Inf", "", nil}, values,
+		"Only finite numeric strings must be coerced")
+	assert.Equal(t, int64(2), qr.QueriesArr[0].ResultsArr[0].DataPoints[1].Timestamp(), "Timestamps must be kept")
+}
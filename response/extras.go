@@ -0,0 +1,207 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Fields of a response object that this library does not know about, by
+// name, e.g. sent by a newer KairosDB version or a plugin. They are kept as
+// sent instead of being dropped, and written back when the object is
+// encoded. Known fields holding a value of an unexpected shape, such as the
+// group of a new group_by type, are kept there as well.
+type Extras map[string]json.RawMessage
+
+// Decodes the field of the given name into v. Returns false when there is
+// no such field.
+func (e Extras) Get(name string, v interface{}) (bool, error) {
+	raw, ok := e[name]
+	if !ok {
+		return false, nil
+	}
+	return true, json.Unmarshal(raw, v)
+}
+
+// Returns the sorted names of the fields.
+func (e Extras) Names() []string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// A field of a response object, decoded into ptr. A tolerant field whose
+// value does not fit is kept in the extras instead of failing the object.
+type field struct {
+	ptr      interface{}
+	tolerant bool
+}
+
+// Decodes a JSON object into the fields, by JSON name, and returns the other
+// ones. Returns nil extras when all the fields are known.
+func decodeObject(data []byte, fields map[string]field) (Extras, error) {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil, nil
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+
+	var extras Extras
+	for name, value := range raw {
+		f, ok := fields[name]
+		if ok {
+			err := json.Unmarshal(value, f.ptr)
+			if err == nil {
+				continue
+			}
+			if !f.tolerant {
+				return nil, err
+			}
+		}
+
+		if extras == nil {
+			extras = make(Extras)
+		}
+		extras[name] = value
+	}
+
+	return extras, nil
+}
+
+// Encodes the known fields of v along with the extras. Known fields take
+// precedence over extras of the same name.
+func encodeObject(v interface{}, extras Extras) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extras) == 0 {
+		return data, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	for name, value := range extras {
+		if _, ok := fields[name]; !ok {
+			fields[name] = value
+		}
+	}
+	return json.Marshal(fields)
+}
+
+func (g *GroupResult) UnmarshalJSON(data []byte) error {
+	var decoded GroupResult
+	extras, err := decodeObject(data, map[string]field{
+		"name":        {&decoded.Name, true},
+		"type":        {&decoded.Type, true},
+		"tags":        {&decoded.Tags, true},
+		"range_size":  {&decoded.RangeSize, true},
+		"group_count": {&decoded.GroupCount, true},
+		"bins":        {&decoded.Bins, true},
+		"group":       {&decoded.Group, true},
+	})
+	if err != nil {
+		return err
+	}
+
+	decoded.Extras = extras
+	*g = decoded
+	return nil
+}
+
+func (g GroupResult) MarshalJSON() ([]byte, error) {
+	type plain GroupResult
+	return encodeObject(plain(g), g.Extras)
+}
+
+func (r *Results) UnmarshalJSON(data []byte) error {
+	var decoded Results
+	extras, err := decodeObject(data, map[string]field{
+		"name":     {&decoded.Name, false},
+		"values":   {&decoded.DataPoints, false},
+		"tags":     {&decoded.Tags, true},
+		"group_by": {&decoded.Group, true},
+	})
+	if err != nil {
+		return err
+	}
+
+	decoded.Extras = extras
+	*r = decoded
+	return nil
+}
+
+func (r Results) MarshalJSON() ([]byte, error) {
+	type plain Results
+	return encodeObject(plain(r), r.Extras)
+}
+
+func (q *Queries) UnmarshalJSON(data []byte) error {
+	var decoded Queries
+	extras, err := decodeObject(data, map[string]field{
+		"sample_size": {&decoded.SampleSize, true},
+		"results":     {&decoded.ResultsArr, false},
+		"errors":      {&decoded.Errors, true},
+	})
+	if err != nil {
+		return err
+	}
+
+	decoded.Extras = extras
+	*q = decoded
+	return nil
+}
+
+func (q Queries) MarshalJSON() ([]byte, error) {
+	type plain Queries
+	return encodeObject(plain(q), q.Extras)
+}
+
+// Returns the paths of the fields of the response kept as extras, e.g.
+// "queries[0].results[1].group_by[0].labels", in response order. Empty when
+// the whole response matched the schema known to this library.
+func (qr *QueryResponse) UnknownFields() []string {
+	var paths []string
+	add := func(prefix string, extras Extras) {
+		for _, name := range extras.Names() {
+			paths = append(paths, prefix+name)
+		}
+	}
+
+	for qi, q := range qr.QueriesArr {
+		qp := fmt.Sprintf("queries[%d].", qi)
+		add(qp, q.Extras)
+
+		for ri, r := range q.ResultsArr {
+			rp := fmt.Sprintf("%sresults[%d].", qp, ri)
+			add(rp, r.Extras)
+
+			for gi, g := range r.Group {
+				add(fmt.Sprintf("%sgroup_by[%d].", rp, gi), g.Extras)
+			}
+		}
+	}
+
+	return paths
+}
@@ -0,0 +1,90 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A response of a future server version: unknown fields at every level and
+// a group_by whose tags are not a list.
+const futureResponse = `{"queries":[{"sample_size":1,"took_ms":12,"results":[{"name":"m1","tags":{"host":["a"]},` +
+	`"values":[[1,2]],"unit":"percent","group_by":[{"name":"tag","tags":["host"],"group":{"host":"a"}},` +
+	`{"name":"bin","tags":{"host":"a"},"labels":["low","high"],"group":{"bin_number":1}}]}]}]}`
+
+// Success test.
+func TestExtras(t *testing.T) {
+	qr := NewQueryResponse(http.StatusOK)
+	err := json.Unmarshal([]byte(futureResponse), qr)
+	assert.Nil(t, err, "No error expected")
+
+	q := qr.QueriesArr[0]
+	var took int
+	ok, err := q.Extras.Get("took_ms", &took)
+	assert.True(t, ok, "Extra field expected")
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 12, took)
+
+	r := q.ResultsArr[0]
+	assert.Len(t, r.DataPoints, 1, "Known fields decoded expected")
+	assert.Equal(t, Extras{"unit": json.RawMessage(`"percent"`)}, r.Extras)
+	assert.Nil(t, r.Group[0].Extras, "No extras expected for a known shape")
+
+	bin := r.Group[1]
+	assert.Equal(t, "bin", bin.Name)
+	assert.Nil(t, bin.Tags, "Tags of an unexpected shape left out of the field expected")
+	assert.Equal(t, map[string]interface{}{"bin_number": float64(1)}, bin.Group)
+	assert.Equal(t, []string{"labels", "tags"}, bin.Extras.Names())
+
+	ok, err = bin.Extras.Get("missing", &took)
+	assert.False(t, ok, "No extra field expected")
+	assert.Nil(t, err, "No error expected")
+
+	assert.Equal(t, []string{
+		"queries[0].took_ms",
+		"queries[0].results[0].unit",
+		"queries[0].results[0].group_by[1].labels",
+		"queries[0].results[0].group_by[1].tags",
+	}, qr.UnknownFields())
+}
+
+// Success test.
+func TestExtrasRoundTrip(t *testing.T) {
+	qr := NewQueryResponse(http.StatusOK)
+	assert.Nil(t, json.Unmarshal([]byte(futureResponse), qr), "No error expected")
+
+	data, err := json.Marshal(qr)
+	assert.Nil(t, err, "No error expected")
+	assert.JSONEq(t, futureResponse, string(data), "Extras written back expected")
+}
+
+// Success test.
+func TestExtrasLenient(t *testing.T) {
+	qr := NewQueryResponse(http.StatusOK)
+	err := DecodeQueryResponseLenient([]byte(futureResponse), qr, nil)
+	assert.Nil(t, err, "No error expected")
+	assert.Len(t, qr.UnknownFields(), 4, "Extras kept by the lenient decoder expected")
+}
+
+// Failure test.
+func TestExtrasMalformedValues(t *testing.T) {
+	qr := NewQueryResponse(http.StatusOK)
+	err := json.Unmarshal([]byte(`{"queries":[{"results":[{"name":"m1","values":{"1":2}}]}]}`), qr)
+	assert.NotNil(t, err, "Data points must not be tolerated as extras")
+}
@@ -0,0 +1,102 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import "regexp"
+
+// Decides whether a series is kept, given its tags. A tag of a series holds
+// several values when the series combines several tag values.
+type TagPredicate func(tags map[string][]string) bool
+
+// Keeps the series whose tag only has the given value.
+func TagEquals(name, value string) TagPredicate {
+	return TagIn(name, value)
+}
+
+// Keeps the series whose tag values are all among the given ones.
+func TagIn(name string, values ...string) TagPredicate {
+	set := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		set[v] = struct{}{}
+	}
+
+	return tagAll(name, func(v string) bool {
+		_, ok := set[v]
+		return ok
+	})
+}
+
+// Keeps the series whose tag values all match the regular expression.
+func TagMatches(name string, re *regexp.Regexp) TagPredicate {
+	return tagAll(name, re.MatchString)
+}
+
+// Keeps the series not kept by the predicate.
+func TagNot(pred TagPredicate) TagPredicate {
+	return func(tags map[string][]string) bool {
+		return !pred(tags)
+	}
+}
+
+// A series without the tag is not kept, since nothing is known about its
+// values.
+func tagAll(name string, match func(string) bool) TagPredicate {
+	return func(tags map[string][]string) bool {
+		vals := tags[name]
+		if len(vals) == 0 {
+			return false
+		}
+
+		for _, v := range vals {
+			if !match(v) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Returns the series kept by all the predicates.
+func (q Queries) FilterResults(preds ...TagPredicate) Queries {
+	out := q
+	out.ResultsArr = make([]Results, 0, len(q.ResultsArr))
+	for _, r := range q.ResultsArr {
+		if matchesAll(r.Tags, preds) {
+			out.ResultsArr = append(out.ResultsArr, r)
+		}
+	}
+
+	return out
+}
+
+// Drops the series of every query that are not kept by all the predicates,
+// e.g. to narrow down the response of a broad query shared through a cache.
+// The response is modified in place and returned.
+func (qr *QueryResponse) FilterResults(preds ...TagPredicate) *QueryResponse {
+	for i := range qr.QueriesArr {
+		qr.QueriesArr[i] = qr.QueriesArr[i].FilterResults(preds...)
+	}
+
+	return qr
+}
+
+func matchesAll(tags map[string][]string, preds []TagPredicate) bool {
+	for _, pred := range preds {
+		if !pred(tags) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,64 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func filterResponse(t *testing.T) *QueryResponse {
+	qr := NewQueryResponse(200)
+	err := json.Unmarshal([]byte(`{"queries":[{"results":[
+		{"name":"m1","tags":{"host":["web1"],"dc":["eu"]}},
+		{"name":"m1","tags":{"host":["web2"],"dc":["us"]}},
+		{"name":"m1","tags":{"host":["db1","web1"],"dc":["eu"]}},
+		{"name":"m1","tags":{"dc":["eu"]}}
+	]}]}`), qr)
+	assert.Nil(t, err, "No error expected")
+	return qr
+}
+
+func hostsOf(qr *QueryResponse) [][]string {
+	var hosts [][]string
+	for _, r := range qr.QueriesArr[0].ResultsArr {
+		hosts = append(hosts, r.Tags["host"])
+	}
+	return hosts
+}
+
+// Success test.
+func TestFilterResults(t *testing.T) {
+	tests := []struct {
+		name  string
+		preds []TagPredicate
+		hosts [][]string
+	}{
+		{"equals", []TagPredicate{TagEquals("host", "web1")}, [][]string{{"web1"}}},
+		{"in", []TagPredicate{TagIn("host", "web1", "db1")}, [][]string{{"web1"}, {"db1", "web1"}}},
+		{"regexp", []TagPredicate{TagMatches("host", regexp.MustCompile(`^web`))}, [][]string{{"web1"}, {"web2"}}},
+		{"and", []TagPredicate{TagMatches("host", regexp.MustCompile(`^web`)), TagEquals("dc", "us")}, [][]string{{"web2"}}},
+		{"not", []TagPredicate{TagNot(TagEquals("dc", "eu"))}, [][]string{{"web2"}}},
+		{"none", nil, [][]string{{"web1"}, {"web2"}, {"db1", "web1"}, nil}},
+	}
+
+	for _, test := range tests {
+		qr := filterResponse(t).FilterResults(test.preds...)
+		assert.Equal(t, test.hosts, hostsOf(qr), test.name)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// The range size of a group. Time groups have a unit, e.g. 1 DAYS, value
+// groups have none.
+type GroupRange struct {
+	Value int64
+	Unit  string
+}
+
+type groupRangeObject struct {
+	Value int64  `json:"value"`
+	Unit  string `json:"unit"`
+}
+
+func (gr *GroupRange) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		var obj groupRangeObject
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		*gr = GroupRange(obj)
+		return nil
+	}
+
+	*gr = GroupRange{}
+	return json.Unmarshal(data, &gr.Value)
+}
+
+func (gr GroupRange) MarshalJSON() ([]byte, error) {
+	if gr.Unit == "" {
+		return json.Marshal(gr.Value)
+	}
+	return json.Marshal(groupRangeObject(gr))
+}
+
+// Returns the tag values of a "tag" group by tag name, e.g. {"host": "a"}.
+// Returns nil for other groups.
+func (g GroupResult) TagValues() map[string]string {
+	if g.Name != "tag" {
+		return nil
+	}
+
+	values := make(map[string]string, len(g.Group))
+	for k, v := range g.Group {
+		if s, ok := v.(string); ok {
+			values[k] = s
+		}
+	}
+	return values
+}
+
+// Returns the number of the group the result belongs to: the group number
+// of a "time" or "value" group, the bin number of a "bin" group. Returns
+// false for other groups.
+func (g GroupResult) Number() (int64, bool) {
+	var key string
+	switch g.Name {
+	case "time", "value":
+		key = "group_number"
+	case "bin":
+		key = "bin_number"
+	default:
+		return 0, false
+	}
+
+	n, ok := toInt(g.Group[key], 0, 1<<53)
+	return n, ok
+}
+
+// Returns the first group of the result of the given name, e.g. "tag".
+func (r Results) GroupBy(name string) (GroupResult, bool) {
+	for _, g := range r.Group {
+		if g.Name == name {
+			return g, true
+		}
+	}
+	return GroupResult{}, false
+}
+
+// Returns the data type of the values of the result as reported by
+// KairosDB, e.g. "number" or "text". Empty when the response does not say.
+func (r Results) ValueType() string {
+	g, _ := r.GroupBy("type")
+	return g.Type
+}
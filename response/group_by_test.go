@@ -0,0 +1,82 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// A response to a query grouped by every group_by type KairosDB supports.
+const groupedResponse = `{"queries":[{"sample_size":2,"results":[` +
+	`{"name":"cpu","group_by":[{"name":"tag","tags":["host"],"group":{"host":"a"}},` +
+	`{"name":"time","range_size":{"value":1,"unit":"DAYS"},"group_count":7,"group":{"group_number":3}},` +
+	`{"name":"type","type":"number"}],"tags":{"host":["a"]},"values":[[1,2]]},` +
+	`{"name":"cpu","group_by":[{"name":"value","range_size":100,"group":{"group_number":2}},` +
+	`{"name":"bin","bins":[10,20.5],"group":{"bin_number":1}}],"tags":{"host":["b"]},"values":[[1,250]]}]}]}`
+
+// Success test.
+func TestGroupBy(t *testing.T) {
+	qr := NewQueryResponse(http.StatusOK)
+	assert.Nil(t, json.Unmarshal([]byte(groupedResponse), qr), "No error expected")
+	assert.Empty(t, qr.UnknownFields(), "All the group_by fields must be known")
+
+	r := qr.QueriesArr[0].ResultsArr[0]
+	assert.Equal(t, "number", r.ValueType())
+
+	tag, ok := r.GroupBy("tag")
+	assert.True(t, ok, "Tag group expected")
+	assert.Equal(t, []string{"host"}, tag.Tags)
+	assert.Equal(t, map[string]string{"host": "a"}, tag.TagValues())
+	_, ok = tag.Number()
+	assert.False(t, ok, "No number for a tag group expected")
+
+	tm, _ := r.GroupBy("time")
+	assert.Equal(t, &GroupRange{Value: 1, Unit: "DAYS"}, tm.RangeSize)
+	assert.Equal(t, int64(7), tm.GroupCount)
+	n, ok := tm.Number()
+	assert.True(t, ok, "Group number expected")
+	assert.Equal(t, int64(3), n)
+	assert.Nil(t, tm.TagValues(), "No tag values for a time group expected")
+
+	r = qr.QueriesArr[0].ResultsArr[1]
+	assert.Equal(t, "", r.ValueType(), "No type group expected")
+
+	val, _ := r.GroupBy("value")
+	assert.Equal(t, &GroupRange{Value: 100}, val.RangeSize)
+	n, _ = val.Number()
+	assert.Equal(t, int64(2), n)
+
+	bin, _ := r.GroupBy("bin")
+	assert.Equal(t, []float64{10, 20.5}, bin.Bins)
+	n, _ = bin.Number()
+	assert.Equal(t, int64(1), n)
+
+	_, ok = r.GroupBy("tag")
+	assert.False(t, ok, "No tag group expected")
+}
+
+// Success test.
+func TestGroupByRoundTrip(t *testing.T) {
+	qr := NewQueryResponse(http.StatusOK)
+	assert.Nil(t, json.Unmarshal([]byte(groupedResponse), qr), "No error expected")
+
+	data, err := json.Marshal(qr)
+	assert.Nil(t, err, "No error expected")
+	assert.JSONEq(t, groupedResponse, string(data), "Group metadata written back expected")
+}
@@ -0,0 +1,89 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// The status of a component checked by KairosDB, e.g. the datastore.
+type ComponentStatus struct {
+	Name    string
+	Status  string
+	Healthy bool
+}
+
+type HealthResponse struct {
+	*Response
+
+	// Whether the server answered with a success status and all the
+	// components are healthy.
+	Healthy bool
+
+	// The statuses of the components, only filled when the detailed status
+	// was requested.
+	Components []ComponentStatus
+
+	// Time taken by the server to answer.
+	Latency time.Duration
+}
+
+func NewHealthResponse(code int, latency time.Duration) *HealthResponse {
+	hr := &HealthResponse{
+		Response: &Response{},
+		Healthy:  code < http.StatusMultipleChoices,
+		Latency:  latency,
+	}
+
+	hr.SetStatusCode(code)
+	return hr
+}
+
+// Returns whether the server is healthy.
+func (hr *HealthResponse) IsHealthy() bool {
+	return hr.Healthy
+}
+
+// Fills the components from the body of /health/status, a list of strings
+// such as "Datastore-Query: OK". Any status other than OK makes the server
+// unhealthy.
+func (hr *HealthResponse) SetComponents(statuses []string) {
+	hr.Components = make([]ComponentStatus, 0, len(statuses))
+	for _, s := range statuses {
+		name, status, _ := strings.Cut(s, ":")
+		c := ComponentStatus{
+			Name:   strings.TrimSpace(name),
+			Status: strings.TrimSpace(status),
+		}
+		c.Healthy = c.Status == "OK"
+		if !c.Healthy {
+			hr.Healthy = false
+		}
+		hr.Components = append(hr.Components, c)
+	}
+}
+
+// Returns the components that are not healthy.
+func (hr *HealthResponse) UnhealthyComponents() []ComponentStatus {
+	var out []ComponentStatus
+	for _, c := range hr.Components {
+		if !c.Healthy {
+			out = append(out, c)
+		}
+	}
+	return out
+}
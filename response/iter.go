@@ -0,0 +1,62 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.23
+
+package response
+
+import (
+	"iter"
+
+	"github.com/retoool/go-kairosdb/builder"
+)
+
+// Returns an iterator over the data points of the series.
+func (r Results) Points() iter.Seq[builder.DataPoint] {
+	return func(yield func(builder.DataPoint) bool) {
+		for _, dp := range r.DataPoints {
+			if !yield(dp) {
+				return
+			}
+		}
+	}
+}
+
+// Returns an iterator over the series of the response along with the index
+// of the query they answer, in response order.
+func (qr *QueryResponse) All() iter.Seq2[int, Results] {
+	return func(yield func(int, Results) bool) {
+		for i, q := range qr.QueriesArr {
+			for _, r := range q.ResultsArr {
+				if !yield(i, r) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Returns an iterator over the data points of every series of the response,
+// along with the series they belong to.
+func (qr *QueryResponse) Points() iter.Seq2[Results, builder.DataPoint] {
+	return func(yield func(Results, builder.DataPoint) bool) {
+		for _, r := range qr.All() {
+			for dp := range r.Points() {
+				if !yield(r, dp) {
+					return
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build go1.23
+
+package response
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestIterators(t *testing.T) {
+	data := `{"queries":[
+		{"results":[{"name":"cpu","values":[[1,1],[2,2]]},{"name":"mem","values":[[1,3]]}]},
+		{"results":[{"name":"disk","values":[[1,4]]}]}
+	]}`
+
+	qr := NewQueryResponse(200)
+	assert.Nil(t, json.Unmarshal([]byte(data), qr), "No error expected")
+
+	var names []string
+	var queries []int
+	for i, r := range qr.All() {
+		queries = append(queries, i)
+		names = append(names, r.Name)
+	}
+	assert.Equal(t, []int{0, 0, 1}, queries)
+	assert.Equal(t, []string{"cpu", "mem", "disk"}, names)
+
+	var ts []int64
+	for dp := range qr.QueriesArr[0].ResultsArr[0].Points() {
+		ts = append(ts, dp.Timestamp())
+	}
+	assert.Equal(t, []int64{1, 2}, ts)
+
+	count := 0
+	for r, dp := range qr.Points() {
+		count++
+		if r.Name == "mem" {
+			assert.Equal(t, int64(1), dp.Timestamp())
+			break
+		}
+	}
+	assert.Equal(t, 3, count, "Iteration must stop on break")
+}
@@ -0,0 +1,135 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+
+	"github.com/retoool/go-kairosdb/builder"
+)
+
+// A data point of a query response that could not be decoded and was left
+// out by DecodeQueryResponseLenient.
+type MalformedDataPoint struct {
+	Query  int             // Index of the query in the response.
+	Result int             // Index of the result in the query.
+	Metric string          // Name of the result's metric.
+	Index  int             // Index of the data point in the result's values.
+	Raw    json.RawMessage // The data point as sent by KairosDB.
+	Err    error
+}
+
+type lenientResults struct {
+	Name       string
+	DataPoints []json.RawMessage
+	Tags       map[string][]string
+	Group      []GroupResult
+	Extras     Extras
+}
+
+func (r *lenientResults) UnmarshalJSON(data []byte) error {
+	var err error
+	r.Extras, err = decodeObject(data, map[string]field{
+		"name":     {&r.Name, false},
+		"values":   {&r.DataPoints, false},
+		"tags":     {&r.Tags, true},
+		"group_by": {&r.Group, true},
+	})
+	return err
+}
+
+type lenientQueries struct {
+	SampleSize flexInt
+	ResultsArr []lenientResults
+	Errors     []string
+	Extras     Extras
+}
+
+func (q *lenientQueries) UnmarshalJSON(data []byte) error {
+	var err error
+	q.Extras, err = decodeObject(data, map[string]field{
+		"sample_size": {&q.SampleSize, true},
+		"results":     {&q.ResultsArr, false},
+		"errors":      {&q.Errors, true},
+	})
+	return err
+}
+
+type lenientQueryResponse struct {
+	Errors     []string         `json:"errors,omitempty"`
+	QueriesArr []lenientQueries `json:"queries,omitempty"`
+}
+
+// Decodes a query response body into qr like json.Unmarshal, except that data
+// points that cannot be decoded are skipped instead of failing the whole
+// response. Each skipped data point is reported to onMalformed, which may be
+// nil. A sample size sent as a string is accepted as well.
+//
+// An error is only returned when the body as a whole is not a query
+// response.
+func DecodeQueryResponseLenient(data []byte, qr *QueryResponse, onMalformed func(MalformedDataPoint)) error {
+	var raw lenientQueryResponse
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if qr.Response == nil {
+		qr.Response = &Response{}
+	}
+	qr.Errors = append(qr.Errors, raw.Errors...)
+
+	for qi, rq := range raw.QueriesArr {
+		q := Queries{
+			SampleSize: int64(rq.SampleSize),
+			ResultsArr: make([]Results, 0, len(rq.ResultsArr)),
+			Errors:     rq.Errors,
+			Extras:     rq.Extras,
+		}
+
+		for ri, rr := range rq.ResultsArr {
+			r := Results{
+				Name:       rr.Name,
+				DataPoints: make([]builder.DataPoint, 0, len(rr.DataPoints)),
+				Tags:       rr.Tags,
+				Group:      rr.Group,
+				Extras:     rr.Extras,
+			}
+
+			for i, rdp := range rr.DataPoints {
+				var dp builder.DataPoint
+				if err := dp.UnmarshalJSON(rdp); err != nil {
+					if onMalformed != nil {
+						onMalformed(MalformedDataPoint{
+							Query:  qi,
+							Result: ri,
+							Metric: rr.Name,
+							Index:  i,
+							Raw:    rdp,
+							Err:    err,
+						})
+					}
+					continue
+				}
+				r.DataPoints = append(r.DataPoints, dp)
+			}
+
+			q.ResultsArr = append(q.ResultsArr, r)
+		}
+
+		qr.QueriesArr = append(qr.QueriesArr, q)
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestDecodeQueryResponseLenient(t *testing.T) {
+	body := `{"queries":[{"sample_size":"4","results":[{"name":"m1","tags":{"host":["a"]},` +
+		`"values":[[1,1.5],["2",2],[null,3],[4],[5,null]]}]}]}`
+
+	var malformed []MalformedDataPoint
+	qr := NewQueryResponse(http.StatusOK)
+	err := DecodeQueryResponseLenient([]byte(body), qr, func(m MalformedDataPoint) {
+		malformed = append(malformed, m)
+	})
+	assert.Nil(t, err, "No error expected")
+
+	q := qr.QueriesArr[0]
+	assert.Equal(t, int64(4), q.SampleSize, "String sample size must be accepted")
+	assert.Equal(t, "m1", q.ResultsArr[0].Name, "Name expected")
+	assert.Equal(t, []string{"a"}, q.ResultsArr[0].Tags["host"], "Tags expected")
+
+	dps := q.ResultsArr[0].DataPoints
+	assert.Equal(t, 3, len(dps), "Malformed data points must be skipped")
+	assert.Equal(t, int64(2), dps[1].Timestamp(), "String timestamp must be accepted")
+	assert.Nil(t, dps[2].Value(), "Null value must be kept")
+
+	assert.Equal(t, 2, len(malformed), "Malformed data points must be reported")
+	assert.Equal(t, 2, malformed[0].Index, "Index of the data point expected")
+	assert.Equal(t, "m1", malformed[0].Metric, "Metric expected")
+	assert.Equal(t, builder.ErrorDataPointTimestampInvalid, malformed[0].Err, "Timestamp error expected")
+	assert.Equal(t, json.RawMessage(`[4]`), malformed[1].Raw, "Raw data point expected")
+	assert.Equal(t, builder.ErrorDataPointMalformed, malformed[1].Err, "Malformed error expected")
+
+	// The same body fails as a whole when decoded strictly.
+	assert.NotNil(t, json.Unmarshal([]byte(body), NewQueryResponse(http.StatusOK)), "Error expected")
+}
+
+// Failure test.
+func TestDecodeQueryResponseLenientInvalid(t *testing.T) {
+	qr := NewQueryResponse(http.StatusOK)
+	assert.NotNil(t, DecodeQueryResponseLenient([]byte(`{"queries":{}}`), qr, nil), "Error expected")
+	assert.NotNil(t, DecodeQueryResponseLenient([]byte(`[`), qr, nil), "Error expected")
+}
+
+func FuzzDecodeQueryResponse(f *testing.F) {
+	for _, seed := range []string{
+		`{"queries":[{"sample_size":2,"results":[{"name":"m1","values":[[1,1.5],[2,"a"]]}]}]}`,
+		`{"queries":[{"sample_size":"2","results":[{"name":"m1","values":[[null,1],[2],[]]}]}]}`,
+		`{"queries":[{"results":[{"name":"m1","group_by":[{"name":"type","type":"number"}],"tags":{"h":["a"]}}]}]}`,
+		`{"errors":["query.metric[0].name may not be empty"]}`,
+	} {
+		f.Add([]byte(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		strict := NewQueryResponse(http.StatusOK)
+		strictErr := json.Unmarshal(data, strict)
+
+		lenient := NewQueryResponse(http.StatusOK)
+		if err := DecodeQueryResponseLenient(data, lenient, nil); err != nil {
+			return
+		}
+
+		// What decodes strictly must decode to the same data points leniently.
+		if strictErr == nil {
+			assert.Equal(t, countDataPoints(strict), countDataPoints(lenient), "Data point count")
+		}
+	})
+}
+
+func countDataPoints(qr *QueryResponse) int {
+	n := 0
+	for _, q := range qr.QueriesArr {
+		for _, r := range q.ResultsArr {
+			n += len(r.DataPoints)
+		}
+	}
+	return n
+}
@@ -0,0 +1,282 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+)
+
+// Combines the values falling into the same bucket into a single value.
+type Reducer func(values []float64) float64
+
+// Reducers matching the KairosDB aggregators of the same name.
+var (
+	ReduceSum Reducer = func(values []float64) float64 {
+		sum := 0.0
+		for _, v := range values {
+			sum += v
+		}
+		return sum
+	}
+
+	ReduceAvg Reducer = func(values []float64) float64 {
+		return ReduceSum(values) / float64(len(values))
+	}
+
+	ReduceMin Reducer = func(values []float64) float64 {
+		min := math.Inf(1)
+		for _, v := range values {
+			min = math.Min(min, v)
+		}
+		return min
+	}
+
+	ReduceMax Reducer = func(values []float64) float64 {
+		max := math.Inf(-1)
+		for _, v := range values {
+			max = math.Max(max, v)
+		}
+		return max
+	}
+)
+
+// Decides how the data points of a series returned by several responses are
+// combined. It is given the data points of all the responses in the order the
+// responses were passed to Merge and may reorder the slice in place.
+type MergeStrategy func(points []builder.DataPoint) []builder.DataPoint
+
+// Merge strategies for the common cases.
+var (
+	// Keeps all the data points, response after response.
+	MergeConcat MergeStrategy = func(points []builder.DataPoint) []builder.DataPoint {
+		return points
+	}
+
+	// Keeps all the data points, sorted by timestamp.
+	MergeSorted MergeStrategy = func(points []builder.DataPoint) []builder.DataPoint {
+		sortPoints(points)
+		return points
+	}
+
+	// Sorts the data points by timestamp and keeps the first one given for a
+	// timestamp, e.g. for replicated clusters where the first is preferred.
+	MergeKeepFirst MergeStrategy = func(points []builder.DataPoint) []builder.DataPoint {
+		return dedupePoints(points, false)
+	}
+
+	// Sorts the data points by timestamp and keeps the last one given for a
+	// timestamp, e.g. for time chunks where later responses are fresher.
+	MergeKeepLast MergeStrategy = func(points []builder.DataPoint) []builder.DataPoint {
+		return dedupePoints(points, true)
+	}
+)
+
+// Returns a strategy combining the numeric data points sharing a timestamp
+// with reduce, e.g. ReduceSum for a metric sharded across clusters. Non
+// numeric data points are dropped.
+func MergeReduce(reduce Reducer) MergeStrategy {
+	return func(points []builder.DataPoint) []builder.DataPoint {
+		return Results{DataPoints: points}.Reaggregate(0, reduce).DataPoints
+	}
+}
+
+// Merges the responses of the same query run against several clusters or
+// time ranges into a new response. Series found in a single response are
+// kept as is, overlapping ones, i.e. with the same metric name and group, are
+// combined with strategy, MergeConcat when nil. Sample sizes and errors are
+// added up and the merged status code is the highest one of the responses,
+// so that a failure is not hidden. The responses are not modified.
+func Merge(strategy MergeStrategy, responses ...*QueryResponse) *QueryResponse {
+	if strategy == nil {
+		strategy = MergeConcat
+	}
+
+	merged := NewQueryResponse(0)
+	for _, resp := range responses {
+		if resp == nil {
+			continue
+		}
+
+		if resp.Response != nil {
+			if resp.GetStatusCode() > merged.GetStatusCode() {
+				merged.SetStatusCode(resp.GetStatusCode())
+			}
+			merged.Errors = append(merged.Errors, resp.Errors...)
+		}
+
+		merged.Merge(resp)
+	}
+
+	for i := range merged.QueriesArr {
+		results := merged.QueriesArr[i].ResultsArr
+		for j := range results {
+			results[j].DataPoints = strategy(results[j].DataPoints)
+		}
+	}
+
+	return merged
+}
+
+// Appends the results of src to the ones of qr, e.g. to combine the responses
+// of the same query run on several time ranges or clusters. Results of the
+// same query with the same metric name and group are concatenated and their
+// tags are merged. The sample sizes and errors of the queries are added up.
+func (qr *QueryResponse) Merge(src *QueryResponse) *QueryResponse {
+	for i, q := range src.QueriesArr {
+		if i >= len(qr.QueriesArr) {
+			qr.QueriesArr = append(qr.QueriesArr, Queries{})
+		}
+		dq := &qr.QueriesArr[i]
+		dq.SampleSize += q.SampleSize
+		dq.Errors = append(dq.Errors, q.Errors...)
+
+		for _, r := range q.ResultsArr {
+			key := mergeKey(r)
+			found := false
+			for j := range dq.ResultsArr {
+				if mergeKey(dq.ResultsArr[j]) == key {
+					dq.ResultsArr[j].merge(r)
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				dq.ResultsArr = append(dq.ResultsArr, r.clone())
+			}
+		}
+	}
+
+	return qr
+}
+
+// Re-aggregates the series over buckets of the given size, aligned on the
+// epoch, e.g. after merging results that overlap. Every bucket is reduced to
+// a single data point stamped with the start of the bucket. A zero size
+// reduces the data points sharing a timestamp. Non numeric data points are
+// dropped.
+//
+// Note that averaging averages is only correct when the merged series had
+// the same number of samples per bucket.
+func (r Results) Reaggregate(size time.Duration, reduce Reducer) Results {
+	bucketMs := size.Milliseconds()
+
+	buckets := make(map[int64][]float64)
+	for _, p := range numericPoints(r.DataPoints) {
+		ts := p.ts
+		if bucketMs > 0 {
+			ts -= ts % bucketMs
+			if ts > p.ts {
+				// Before the epoch, the remainder is negative.
+				ts -= bucketMs
+			}
+		}
+		buckets[ts] = append(buckets[ts], p.value)
+	}
+
+	starts := make([]int64, 0, len(buckets))
+	for ts := range buckets {
+		starts = append(starts, ts)
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+	out := r
+	out.DataPoints = make([]builder.DataPoint, 0, len(starts))
+	for _, ts := range starts {
+		out.DataPoints = append(out.DataPoints, *builder.NewDataPoint(ts, reduce(buckets[ts])))
+	}
+
+	return out
+}
+
+// Re-aggregates every series of the response, see Results.Reaggregate. The
+// response is modified in place and returned.
+func (qr *QueryResponse) Reaggregate(size time.Duration, reduce Reducer) *QueryResponse {
+	return qr.transform(func(r Results) Results { return r.Reaggregate(size, reduce) })
+}
+
+func mergeKey(r Results) string {
+	group, _ := json.Marshal(r.Group)
+	return r.Name + "\x00" + string(group)
+}
+
+// Returns a copy of the series that can be merged into without modifying r.
+func (r Results) clone() Results {
+	r.DataPoints = append([]builder.DataPoint(nil), r.DataPoints...)
+
+	tags := r.Tags
+	r.Tags = nil
+	if tags != nil {
+		r.Tags = make(map[string][]string, len(tags))
+		for k, vals := range tags {
+			r.Tags[k] = append([]string(nil), vals...)
+		}
+	}
+
+	return r
+}
+
+func (r *Results) merge(src Results) {
+	r.DataPoints = append(r.DataPoints, src.DataPoints...)
+
+	if r.Tags == nil && len(src.Tags) > 0 {
+		r.Tags = make(map[string][]string)
+	}
+
+	for k, vals := range src.Tags {
+		for _, v := range vals {
+			if !containsValue(r.Tags[k], v) {
+				r.Tags[k] = append(r.Tags[k], v)
+			}
+		}
+	}
+}
+
+func sortPoints(points []builder.DataPoint) {
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].Timestamp() < points[j].Timestamp()
+	})
+}
+
+func dedupePoints(points []builder.DataPoint, keepLast bool) []builder.DataPoint {
+	sortPoints(points)
+
+	out := points[:0]
+	for _, p := range points {
+		if n := len(out); n > 0 && out[n-1].Timestamp() == p.Timestamp() {
+			if keepLast {
+				out[n-1] = p
+			}
+			continue
+		}
+		out = append(out, p)
+	}
+
+	return out
+}
+
+func containsValue(vals []string, s string) bool {
+	for _, v := range vals {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
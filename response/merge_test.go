@@ -0,0 +1,101 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestQueryResponseMergeReaggregate(t *testing.T) {
+	var a, b QueryResponse
+	err := json.Unmarshal([]byte(`{"queries":[{"sample_size":2,"results":[{"name":"m1","tags":{"dc":["eu"]},"values":[[0,1],[1000,2]]}]}]}`), &a)
+	assert.Nil(t, err, "No error expected")
+	err = json.Unmarshal([]byte(`{"queries":[{"sample_size":2,"results":[{"name":"m1","tags":{"dc":["us"]},"values":[[500,3],[1500,"n/a"]]}]}]}`), &b)
+	assert.Nil(t, err, "No error expected")
+
+	merged := NewQueryResponse(http.StatusOK).Merge(&a).Merge(&b)
+	assert.EqualValues(t, 4, merged.QueriesArr[0].SampleSize, "Sample sizes must be added up")
+	assert.Len(t, merged.QueriesArr[0].ResultsArr, 1, "Results must be merged")
+	assert.Equal(t, []string{"eu", "us"}, merged.QueriesArr[0].ResultsArr[0].Tags["dc"], "Tags must be merged")
+
+	merged.Reaggregate(time.Second, ReduceSum)
+	assert.Equal(t, [][2]float64{{0, 4}, {1000, 2}}, valuesOf(t, merged.QueriesArr[0].ResultsArr[0]))
+}
+
+// Success test.
+func TestResultsReaggregate(t *testing.T) {
+	var r Results
+	err := json.Unmarshal([]byte(`{"name":"m1","values":[[-1500,1],[-500,3],[0,2],[0,6],[999,4]]}`), &r)
+	assert.Nil(t, err, "No error expected")
+
+	assert.Equal(t, [][2]float64{{-2000, 1}, {-1000, 3}, {0, 4}}, valuesOf(t, r.Reaggregate(time.Second, ReduceAvg)))
+	assert.Equal(t, [][2]float64{{-2000, 1}, {-1000, 3}, {0, 2}}, valuesOf(t, r.Reaggregate(time.Second, ReduceMin)))
+	assert.Equal(t, [][2]float64{{-1500, 1}, {-500, 3}, {0, 6}, {999, 4}}, valuesOf(t, r.Reaggregate(0, ReduceMax)))
+	assert.Len(t, r.DataPoints, 5, "Original series must not be modified")
+}
+
+// Success test.
+func TestMergeStrategies(t *testing.T) {
+	var a, b QueryResponse
+	err := json.Unmarshal([]byte(`{"queries":[{"sample_size":2,"results":[{"name":"m1","tags":{"dc":["eu"]},"values":[[2000,1],[1000,2]]}]}]}`), &a)
+	assert.Nil(t, err, "No error expected")
+	a.Response = &Response{}
+	a.SetStatusCode(http.StatusOK)
+	err = json.Unmarshal([]byte(`{"queries":[{"sample_size":2,"results":[{"name":"m1","tags":{"dc":["us"]},"values":[[1000,3],[3000,4]]},{"name":"m2","values":[[0,5]]}]}]}`), &b)
+	assert.Nil(t, err, "No error expected")
+	b.Response = &Response{}
+	b.SetStatusCode(http.StatusOK)
+
+	tests := []struct {
+		name     string
+		strategy MergeStrategy
+		values   [][2]float64
+	}{
+		{"concat", nil, [][2]float64{{2000, 1}, {1000, 2}, {1000, 3}, {3000, 4}}},
+		{"sorted", MergeSorted, [][2]float64{{1000, 2}, {1000, 3}, {2000, 1}, {3000, 4}}},
+		{"first", MergeKeepFirst, [][2]float64{{1000, 2}, {2000, 1}, {3000, 4}}},
+		{"last", MergeKeepLast, [][2]float64{{1000, 3}, {2000, 1}, {3000, 4}}},
+		{"sum", MergeReduce(ReduceSum), [][2]float64{{1000, 5}, {2000, 1}, {3000, 4}}},
+	}
+
+	for _, test := range tests {
+		merged := Merge(test.strategy, &a, nil, &b)
+		assert.Equal(t, http.StatusOK, merged.GetStatusCode(), test.name)
+		assert.EqualValues(t, 4, merged.QueriesArr[0].SampleSize, test.name)
+		assert.Len(t, merged.QueriesArr[0].ResultsArr, 2, test.name)
+		assert.Equal(t, test.values, valuesOf(t, merged.QueriesArr[0].ResultsArr[0]), test.name)
+		assert.Equal(t, [][2]float64{{0, 5}}, valuesOf(t, merged.QueriesArr[0].ResultsArr[1]), test.name)
+	}
+
+	assert.Equal(t, [][2]float64{{2000, 1}, {1000, 2}}, valuesOf(t, a.QueriesArr[0].ResultsArr[0]), "Responses must not be modified")
+	assert.Equal(t, []string{"eu"}, a.QueriesArr[0].ResultsArr[0].Tags["dc"], "Responses must not be modified")
+}
+
+// Failure test.
+func TestMergeStatusCode(t *testing.T) {
+	ok := NewQueryResponse(http.StatusOK)
+	failed := NewQueryResponse(http.StatusBadRequest)
+	failed.Errors = []string{"query.metric[0].name may not be empty"}
+
+	merged := Merge(nil, ok, failed)
+	assert.Equal(t, http.StatusBadRequest, merged.GetStatusCode(), "Failures must not be hidden")
+	assert.Equal(t, failed.Errors, merged.GetErrors(), "Errors must be kept")
+}
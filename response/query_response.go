@@ -14,10 +14,26 @@
 
 package response
 
-import "github.com/retoool/go-kairosdb/builder"
+import (
+	"regexp"
+	"sort"
+	"strconv"
 
+	"github.com/retoool/go-kairosdb/builder"
+)
+
+// How the data points of a result were grouped, by a group_by of the query
+// or, named "type", by the data type of their values. See the GroupResult
+// methods for typed access to the group a result belongs to.
 type GroupResult struct {
-	Name string `json:"name,omitempty"`
+	Name       string                 `json:"name,omitempty"`
+	Type       string                 `json:"type,omitempty"`        // Data type of the values of a "type" group, e.g. "number".
+	Tags       []string               `json:"tags,omitempty"`        // Tags grouped by of a "tag" group.
+	RangeSize  *GroupRange            `json:"range_size,omitempty"`  // Range size of a "time" or "value" group.
+	GroupCount int64                  `json:"group_count,omitempty"` // Number of groups of a "time" group.
+	Bins       []float64              `json:"bins,omitempty"`        // Bin bounds of a "bin" group.
+	Group      map[string]interface{} `json:"group,omitempty"`
+	Extras     Extras                 `json:"-"` // Fields unknown to this library, see Extras.
 }
 
 type Results struct {
@@ -25,16 +41,20 @@ type Results struct {
 	DataPoints []builder.DataPoint `json:"values,omitempty"`
 	Tags       map[string][]string `json:"tags,omitempty"`
 	Group      []GroupResult       `json:"group_by,omitempty"`
+	Alias      string              `json:"-"` // Alias of the query metric, see builder.QueryMetric.SetAlias.
+	Extras     Extras              `json:"-"` // Fields unknown to this library, see Extras.
 }
 
 type Queries struct {
 	SampleSize int64     `json:"sample_size,omitempty"`
 	ResultsArr []Results `json:"results,omitempty"`
+	Errors     []string  `json:"errors,omitempty"` // Errors specific to this query, if any.
+	Extras     Extras    `json:"-"`                // Fields unknown to this library, see Extras.
 }
 
 type QueryResponse struct {
 	*Response
-	QueriesArr []Queries `json:"queries",omitempty`
+	QueriesArr []Queries `json:"queries,omitempty"`
 }
 
 func NewQueryResponse(code int) *QueryResponse {
@@ -45,3 +65,49 @@ func NewQueryResponse(code int) *QueryResponse {
 	qr.SetStatusCode(code)
 	return qr
 }
+
+// Matches the metric index KairosDB puts in front of validation errors, e.g.
+// "query.metric[1].aggregators[0].sampling.value must be greater than 0".
+var metricIndexRegexp = regexp.MustCompile(`metric\[(\d+)\]`)
+
+// Returns the errors of the response by query index. It includes the errors
+// reported on the individual queries as well as the top level errors that
+// name the metric they apply to. Errors that cannot be attributed to a query
+// are left out, see GetErrors.
+func (qr *QueryResponse) QueryErrors() map[int][]string {
+	errs := make(map[int][]string)
+
+	for i, q := range qr.QueriesArr {
+		if len(q.Errors) > 0 {
+			errs[i] = append(errs[i], q.Errors...)
+		}
+	}
+
+	for _, e := range qr.GetErrors() {
+		m := metricIndexRegexp.FindStringSubmatch(e)
+		if m == nil {
+			continue
+		}
+
+		i, err := strconv.Atoi(m[1])
+		if err != nil {
+			continue
+		}
+		errs[i] = append(errs[i], e)
+	}
+
+	return errs
+}
+
+// Returns the sorted indexes of the queries that failed. The indexes match
+// the order in which the metrics were added to the query builder, so they
+// can be passed to builder.SelectMetrics to retry only the failed part.
+func (qr *QueryResponse) FailedQueries() []int {
+	var failed []int
+	for i := range qr.QueryErrors() {
+		failed = append(failed, i)
+	}
+
+	sort.Ints(failed)
+	return failed
+}
@@ -0,0 +1,47 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestQueryErrors(t *testing.T) {
+	data := `{"errors":["query.metric[2].aggregators[0].sampling.value must be greater than or equal to 1","unrelated"],` +
+		`"queries":[{"sample_size":1,"results":[]},{"errors":["metric not found"]},{}]}`
+
+	qr := NewQueryResponse(400)
+	err := json.Unmarshal([]byte(data), qr)
+	assert.Nil(t, err, "No error expected")
+
+	errs := qr.QueryErrors()
+	assert.Equal(t, []string{"metric not found"}, errs[1], "Query level errors expected")
+	assert.Equal(t, []string{"query.metric[2].aggregators[0].sampling.value must be greater than or equal to 1"}, errs[2],
+		"Top level errors naming a metric expected")
+	assert.Equal(t, []int{1, 2}, qr.FailedQueries(), "Failed queries must be sorted")
+}
+
+// Success test.
+func TestQueryErrorsNone(t *testing.T) {
+	qr := NewQueryResponse(200)
+	json.Unmarshal([]byte(`{"queries":[{"sample_size":1,"results":[]}]}`), qr)
+
+	assert.Empty(t, qr.QueryErrors(), "No errors expected")
+	assert.Nil(t, qr.FailedQueries(), "No failed queries expected")
+}
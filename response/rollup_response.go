@@ -0,0 +1,58 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+
+	"github.com/retoool/go-kairosdb/builder/utils"
+)
+
+// A roll-up of a task: the results of the query are saved as the SaveAs
+// metric.
+type Rollup struct {
+	SaveAs string          `json:"save_as,omitempty"`
+	Query  json.RawMessage `json:"query,omitempty"`
+}
+
+// A roll-up task as stored by KairosDB. Tasks returned on creation only
+// carry their ID, name and attributes.
+type RollupTask struct {
+	ID                string              `json:"id,omitempty"`
+	Name              string              `json:"name,omitempty"`
+	ExecutionInterval *utils.RelativeTime `json:"execution_interval,omitempty"`
+	Rollups           []Rollup            `json:"rollups,omitempty"`
+	LastModified      int64               `json:"lastModified,omitempty"`
+	Attributes        map[string]string   `json:"attributes,omitempty"`
+}
+
+type RollupResponse struct {
+	*Response
+	Tasks []RollupTask `json:"-"`
+}
+
+func NewRollupResponse(code int) *RollupResponse {
+	rr := &RollupResponse{
+		Response: &Response{},
+	}
+
+	rr.SetStatusCode(code)
+	return rr
+}
+
+// Returns the roll-up tasks of the response.
+func (rr *RollupResponse) GetTasks() []RollupTask {
+	return rr.Tasks
+}
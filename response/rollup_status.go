@@ -0,0 +1,158 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// The status of the last run of a roll-up of a task.
+type RollupMetricStatus struct {
+	MetricName      string
+	LastExecuted    time.Time
+	DataPointCount  int64
+	ExecutionLength time.Duration
+	Error           string
+}
+
+// The execution status of a roll-up task.
+type RollupStatus struct {
+	ExecutingHost string
+	NextScheduled time.Time
+	LastExecuted  time.Time
+	Statuses      []RollupMetricStatus
+}
+
+type RollupStatusResponse struct {
+	*Response
+	Status *RollupStatus `json:"-"`
+}
+
+func NewRollupStatusResponse(code int) *RollupStatusResponse {
+	rr := &RollupStatusResponse{
+		Response: &Response{},
+	}
+
+	rr.SetStatusCode(code)
+	return rr
+}
+
+// Returns the status of the task, nil if the request failed.
+func (rr *RollupStatusResponse) GetStatus() *RollupStatus {
+	return rr.Status
+}
+
+// Returns the errors of the last run of the task, prefixed with the name of
+// the metric they apply to.
+func (rs *RollupStatus) Errors() []string {
+	var errs []string
+	for _, s := range rs.Statuses {
+		if s.Error != "" {
+			errs = append(errs, s.MetricName+": "+s.Error)
+		}
+	}
+	return errs
+}
+
+func (rs *RollupStatus) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		ExecutingHost string               `json:"executingHost"`
+		NextScheduled flexTime             `json:"nextScheduled"`
+		LastExecuted  flexTime             `json:"lastExecuted"`
+		Statuses      []RollupMetricStatus `json:"statuses"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*rs = RollupStatus{
+		ExecutingHost: raw.ExecutingHost,
+		NextScheduled: time.Time(raw.NextScheduled),
+		LastExecuted:  time.Time(raw.LastExecuted),
+		Statuses:      raw.Statuses,
+	}
+	return nil
+}
+
+func (s *RollupMetricStatus) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		MetricName      string   `json:"metricName"`
+		LastExecuted    flexTime `json:"lastExecuted"`
+		DataPointCount  flexInt  `json:"dataPointCount"`
+		ExecutionLength flexInt  `json:"executionLength"`
+		ErrorMessage    string   `json:"errorMessage"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*s = RollupMetricStatus{
+		MetricName:      raw.MetricName,
+		LastExecuted:    time.Time(raw.LastExecuted),
+		DataPointCount:  int64(raw.DataPointCount),
+		ExecutionLength: time.Duration(raw.ExecutionLength) * time.Millisecond,
+		Error:           raw.ErrorMessage,
+	}
+	return nil
+}
+
+// An integer sent either as a JSON number or as a string, depending on the
+// KairosDB version.
+type flexInt int64
+
+func (fi *flexInt) UnmarshalJSON(data []byte) error {
+	s := string(bytes.Trim(data, `"`))
+	if s == "" || s == "null" {
+		return nil
+	}
+
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+
+	*fi = flexInt(v)
+	return nil
+}
+
+// A time sent either as milliseconds since the epoch or as an RFC 3339
+// string.
+type flexTime time.Time
+
+func (ft *flexTime) UnmarshalJSON(data []byte) error {
+	var ms flexInt
+	if err := ms.UnmarshalJSON(data); err == nil {
+		if ms != 0 {
+			*ft = flexTime(time.Unix(0, int64(ms)*int64(time.Millisecond)))
+		}
+		return nil
+	}
+
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return err
+	}
+
+	*ft = flexTime(t)
+	return nil
+}
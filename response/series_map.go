@@ -0,0 +1,87 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/retoool/go-kairosdb/builder"
+)
+
+// Returns the data points of the response by metric name and series key,
+// see SeriesKey. Series sharing a name and key, e.g. the same metric queried
+// twice, are concatenated in response order.
+func (qr *QueryResponse) SeriesMap() map[string]map[string][]builder.DataPoint {
+	series := make(map[string]map[string][]builder.DataPoint)
+	for _, q := range qr.QueriesArr {
+		for _, r := range q.ResultsArr {
+			byKey, ok := series[r.Name]
+			if !ok {
+				byKey = make(map[string][]builder.DataPoint)
+				series[r.Name] = byKey
+			}
+
+			key := SeriesKey(r)
+			byKey[key] = append(byKey[key], r.DataPoints...)
+		}
+	}
+
+	return series
+}
+
+// Returns a string identifying the series within its metric, made of sorted
+// "name=value" pairs joined by commas, e.g. "dc=eu,host=h1".
+//
+// The pairs come from the tag group of the series when the query grouped by
+// tag, and from its tags otherwise, several values of a tag being joined by
+// "|". Other groups, e.g. by time or value, are appended as
+// "group:type=number".
+func SeriesKey(r Results) string {
+	var pairs []string
+	tagGrouped := false
+	var others []string
+
+	for _, g := range r.Group {
+		if g.Name == "tag" {
+			tagGrouped = true
+			for k, v := range g.Group {
+				pairs = append(pairs, fmt.Sprintf("%s=%v", k, v))
+			}
+			continue
+		}
+
+		if number, ok := g.Group["group_number"]; ok {
+			others = append(others, fmt.Sprintf("group:%s=%v", g.Name, number))
+		} else {
+			data, _ := json.Marshal(g.Group)
+			others = append(others, fmt.Sprintf("group:%s=%s", g.Name, data))
+		}
+	}
+
+	if !tagGrouped {
+		for k, vals := range r.Tags {
+			sorted := append([]string(nil), vals...)
+			sort.Strings(sorted)
+			pairs = append(pairs, k+"="+strings.Join(sorted, "|"))
+		}
+	}
+
+	sort.Strings(pairs)
+	sort.Strings(others)
+	return strings.Join(append(pairs, others...), ",")
+}
@@ -0,0 +1,64 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestSeriesMap(t *testing.T) {
+	data := `{"queries":[
+		{"results":[
+			{"name":"cpu","group_by":[{"name":"tag","tags":["host"],"group":{"host":"h1"}}],"tags":{"host":["h1"],"dc":["eu"]},"values":[[1,1]]},
+			{"name":"cpu","group_by":[{"name":"tag","tags":["host"],"group":{"host":"h2"}}],"tags":{"host":["h2"],"dc":["eu"]},"values":[[1,2]]}
+		]},
+		{"results":[
+			{"name":"mem","tags":{"host":["h2","h1"]},"values":[[1,3],[2,4]]}
+		]},
+		{"results":[
+			{"name":"cpu","group_by":[{"name":"tag","tags":["host"],"group":{"host":"h1"}}],"tags":{"host":["h1"]},"values":[[2,5]]}
+		]}
+	]}`
+
+	qr := NewQueryResponse(200)
+	assert.Nil(t, json.Unmarshal([]byte(data), qr), "No error expected")
+
+	sm := qr.SeriesMap()
+	assert.Len(t, sm, 2)
+	assert.Len(t, sm["cpu"], 2)
+	assert.Len(t, sm["cpu"]["host=h1"], 2, "Series with the same key must be concatenated")
+	assert.Equal(t, int64(2), sm["cpu"]["host=h1"][1].Timestamp())
+	assert.Len(t, sm["cpu"]["host=h2"], 1)
+	assert.Len(t, sm["mem"]["host=h1|h2"], 2, "Ungrouped series must be keyed by their tags")
+}
+
+// Success test.
+func TestSeriesKey(t *testing.T) {
+	r := Results{
+		Name: "cpu",
+		Tags: map[string][]string{"host": {"h1"}, "dc": {"eu"}},
+		Group: []GroupResult{
+			{Name: "value", Group: map[string]interface{}{"group_number": 2.0}},
+			{Name: "tag", Tags: []string{"dc", "host"}, Group: map[string]interface{}{"host": "h1", "dc": "eu"}},
+		},
+	}
+
+	assert.Equal(t, "dc=eu,host=h1,group:value=2", SeriesKey(r))
+	assert.Equal(t, "", SeriesKey(Results{Name: "cpu"}), "Series without tags must have an empty key")
+}
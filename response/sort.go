@@ -0,0 +1,127 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Sorts the data points of the series by timestamp. Data points sharing a
+// timestamp keep their relative order.
+func (r Results) SortDataPoints() {
+	sort.SliceStable(r.DataPoints, func(i, j int) bool {
+		return r.DataPoints[i].Timestamp() < r.DataPoints[j].Timestamp()
+	})
+}
+
+// Returns an error naming the first data point whose timestamp is lower than
+// the one of the previous data point, if any.
+func (r Results) VerifyOrder() error {
+	for i := 1; i < len(r.DataPoints); i++ {
+		if r.DataPoints[i].Timestamp() < r.DataPoints[i-1].Timestamp() {
+			return fmt.Errorf("%w: %s at index %d", ErrorDataPointsUnordered, r.Name, i)
+		}
+	}
+
+	return nil
+}
+
+// Sorts the results of the query by metric name, then by tag set, then by
+// group. The tag values of every result are sorted as well.
+func (q Queries) SortResults() {
+	for _, r := range q.ResultsArr {
+		for _, vals := range r.Tags {
+			sort.Strings(vals)
+		}
+	}
+
+	sort.SliceStable(q.ResultsArr, func(i, j int) bool {
+		ri, rj := &q.ResultsArr[i], &q.ResultsArr[j]
+		if ri.Name != rj.Name {
+			return ri.Name < rj.Name
+		}
+		return seriesKey(ri) < seriesKey(rj)
+	})
+}
+
+// Sorts the response so that it can be compared with another one: the
+// results of every query are sorted, see Queries.SortResults, and so are
+// their data points. The response is modified in place and returned.
+func (qr *QueryResponse) Sort() *QueryResponse {
+	for i := range qr.QueriesArr {
+		qr.QueriesArr[i].SortResults()
+		for _, r := range qr.QueriesArr[i].ResultsArr {
+			r.SortDataPoints()
+		}
+	}
+
+	return qr
+}
+
+// Sorts the data points of every series of the response by timestamp,
+// leaving the results in place. Series already in order are not touched.
+func (qr *QueryResponse) SortDataPoints() *QueryResponse {
+	for i := range qr.QueriesArr {
+		for _, r := range qr.QueriesArr[i].ResultsArr {
+			if r.VerifyOrder() != nil {
+				r.SortDataPoints()
+			}
+		}
+	}
+
+	return qr
+}
+
+// Returns an error if the data points of any series of the response are not
+// in ascending timestamp order.
+func (qr *QueryResponse) VerifyOrder() error {
+	for i := range qr.QueriesArr {
+		for _, r := range qr.QueriesArr[i].ResultsArr {
+			if err := r.VerifyOrder(); err != nil {
+				return fmt.Errorf("query %d: %w", i, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns the key ordering results with the same name: the tag set followed
+// by the group.
+func seriesKey(r *Results) string {
+	names := make([]string, 0, len(r.Tags))
+	for name := range r.Tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		sb.WriteString(name)
+		sb.WriteByte('=')
+		sb.WriteString(strings.Join(r.Tags[name], ","))
+		sb.WriteByte(';')
+	}
+
+	// Maps are marshaled with sorted keys, which makes the group stable.
+	group, _ := json.Marshal(r.Group)
+	sb.WriteByte(0)
+	sb.Write(group)
+
+	return sb.String()
+}
@@ -0,0 +1,63 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestQueryResponseSort(t *testing.T) {
+	var a, b QueryResponse
+	err := json.Unmarshal([]byte(`{"queries":[{"results":[
+		{"name":"m2","values":[[2,1],[1,1]]},
+		{"name":"m1","tags":{"host":["h2","h1"]},"values":[[3,1],[1,1]]},
+		{"name":"m1","tags":{"host":["h0"]},"values":[[1,1]]}]}]}`), &a)
+	assert.Nil(t, err, "No error expected")
+	err = json.Unmarshal([]byte(`{"queries":[{"results":[
+		{"name":"m1","tags":{"host":["h1","h2"]},"values":[[1,1],[3,1]]},
+		{"name":"m2","values":[[1,1],[2,1]]},
+		{"name":"m1","tags":{"host":["h0"]},"values":[[1,1]]}]}]}`), &b)
+	assert.Nil(t, err, "No error expected")
+
+	assert.NotNil(t, a.VerifyOrder(), "Unordered data points expected")
+
+	a.Sort()
+	b.Sort()
+	assert.Nil(t, a.VerifyOrder(), "No error expected")
+
+	ja, _ := json.Marshal(a.QueriesArr)
+	jb, _ := json.Marshal(b.QueriesArr)
+	assert.JSONEq(t, string(jb), string(ja), "Sorted responses must be equal")
+	assert.JSONEq(t, `[{"results":[
+		{"name":"m1","tags":{"host":["h0"]},"values":[[1,1]]},
+		{"name":"m1","tags":{"host":["h1","h2"]},"values":[[1,1],[3,1]]},
+		{"name":"m2","values":[[1,1],[2,1]]}]}]`, string(ja), "Unexpected order")
+}
+
+// Failure test.
+func TestResultsVerifyOrder(t *testing.T) {
+	var r Results
+	err := json.Unmarshal([]byte(`{"name":"m1","values":[[1,1],[3,1],[2,1]]}`), &r)
+	assert.Nil(t, err, "No error expected")
+
+	err = r.VerifyOrder()
+	assert.True(t, errors.Is(err, ErrorDataPointsUnordered), "Unordered data points expected")
+	assert.Contains(t, err.Error(), "index 2")
+}
@@ -0,0 +1,108 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"sort"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+)
+
+// Returns the per unit rate of change of a counter series, e.g. per second
+// with time.Second. A decreasing value is taken as a counter reset: the
+// counter is assumed to have restarted from zero, so the new value itself is
+// the increase. The first data point has no rate and is dropped, as are non
+// numeric data points and data points sharing a timestamp with the previous
+// one.
+func (r Results) Rate(unit time.Duration) Results {
+	points := numericPoints(r.DataPoints)
+	sort.SliceStable(points, func(i, j int) bool { return points[i].ts < points[j].ts })
+
+	out := r
+	out.DataPoints = make([]builder.DataPoint, 0, len(points))
+	for i := 1; i < len(points); i++ {
+		prev, cur := points[i-1], points[i]
+		elapsed := time.Duration(cur.ts-prev.ts) * time.Millisecond
+		if elapsed <= 0 {
+			continue
+		}
+
+		delta := cur.value - prev.value
+		if delta < 0 {
+			delta = cur.value
+		}
+
+		rate := delta * float64(unit) / float64(elapsed)
+		out.DataPoints = append(out.DataPoints, *builder.NewDataPoint(cur.ts, rate))
+	}
+
+	return out
+}
+
+// Returns the series with all the values multiplied by factor, e.g. 1.0/1024
+// to convert bytes to kibibytes. Non numeric data points are dropped.
+func (r Results) Scale(factor float64) Results {
+	points := numericPoints(r.DataPoints)
+
+	out := r
+	out.DataPoints = make([]builder.DataPoint, 0, len(points))
+	for _, p := range points {
+		out.DataPoints = append(out.DataPoints, *builder.NewDataPoint(p.ts, p.value*factor))
+	}
+
+	return out
+}
+
+// Converts every series of the response to a per unit rate, see
+// Results.Rate. The response is modified in place and returned.
+func (qr *QueryResponse) Rate(unit time.Duration) *QueryResponse {
+	return qr.transform(func(r Results) Results { return r.Rate(unit) })
+}
+
+// Scales every series of the response, see Results.Scale. The response is
+// modified in place and returned.
+func (qr *QueryResponse) Scale(factor float64) *QueryResponse {
+	return qr.transform(func(r Results) Results { return r.Scale(factor) })
+}
+
+func (qr *QueryResponse) transform(fn func(Results) Results) *QueryResponse {
+	for i := range qr.QueriesArr {
+		results := qr.QueriesArr[i].ResultsArr
+		for j := range results {
+			results[j] = fn(results[j])
+		}
+	}
+
+	return qr
+}
+
+type numericPoint struct {
+	ts    int64
+	value float64
+}
+
+func numericPoints(dps []builder.DataPoint) []numericPoint {
+	points := make([]numericPoint, 0, len(dps))
+	for i := range dps {
+		if v, err := dps[i].Float64Value(); err == nil {
+			points = append(points, numericPoint{ts: dps[i].Timestamp(), value: v})
+		} else if v, err := dps[i].Int64Value(); err == nil {
+			points = append(points, numericPoint{ts: dps[i].Timestamp(), value: float64(v)})
+		}
+	}
+
+	return points
+}
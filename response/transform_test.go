@@ -0,0 +1,57 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func valuesOf(t *testing.T, r Results) [][2]float64 {
+	var out [][2]float64
+	for i := range r.DataPoints {
+		v, err := r.DataPoints[i].Float64Value()
+		assert.Nil(t, err, "Float values expected")
+		out = append(out, [2]float64{float64(r.DataPoints[i].Timestamp()), v})
+	}
+	return out
+}
+
+// Success test.
+func TestResultsRate(t *testing.T) {
+	var r Results
+	err := json.Unmarshal([]byte(`{"name":"requests","values":[[0,100],[10000,150],[20000,30],[20000,40],[30000,"n/a"],[40000,90]]}`), &r)
+	assert.Nil(t, err, "No error expected")
+
+	rate := r.Rate(time.Second)
+	assert.Equal(t, "requests", rate.Name, "Series name must be kept")
+	assert.Equal(t, [][2]float64{{10000, 5}, {20000, 3}, {40000, 2.5}}, valuesOf(t, rate),
+		"Per second rates with the reset handled expected")
+	assert.Len(t, r.DataPoints, 6, "Original series must not be modified")
+}
+
+// Success test.
+func TestQueryResponseScale(t *testing.T) {
+	qr := NewQueryResponse(200)
+	err := json.Unmarshal([]byte(`{"queries":[{"results":[{"name":"mem","values":[[0,2048],[1000,1024]]}]}]}`), qr)
+	assert.Nil(t, err, "No error expected")
+
+	qr.Scale(1.0 / 1024)
+	assert.Equal(t, [][2]float64{{0, 2}, {1000, 1}}, valuesOf(t, qr.QueriesArr[0].ResultsArr[0]),
+		"Scaled values expected")
+}
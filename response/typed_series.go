@@ -0,0 +1,168 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/retoool/go-kairosdb/builder"
+)
+
+// A data point with a typed value.
+type TypedPoint[T builder.Value] struct {
+	Timestamp int64
+	Value     T
+}
+
+// A query result whose data point values have been converted to T.
+type TypedSeries[T builder.Value] struct {
+	Name   string
+	Tags   map[string][]string
+	Group  []GroupResult
+	Points []TypedPoint[T]
+}
+
+// Converts the result to a typed series. Every data point value must be
+// representable as T: integral and in range for integer types, a string for
+// string. Otherwise ErrorValueType is returned.
+//
+// Integers are read exactly from the JSON, including beyond 2^53 where
+// their float64 value is rounded.
+func NewTypedSeries[T builder.Value](r Results) (TypedSeries[T], error) {
+	ts := TypedSeries[T]{
+		Name:   r.Name,
+		Tags:   r.Tags,
+		Group:  r.Group,
+		Points: make([]TypedPoint[T], 0, len(r.DataPoints)),
+	}
+
+	for i := range r.DataPoints {
+		v, ok := convertValue[T](exactValue(&r.DataPoints[i]))
+		if !ok {
+			return TypedSeries[T]{}, ErrorValueType
+		}
+		ts.Points = append(ts.Points, TypedPoint[T]{Timestamp: r.DataPoints[i].Timestamp(), Value: v})
+	}
+
+	return ts, nil
+}
+
+// Converts all the results of the response to typed series, in query order.
+func DecodeSeries[T builder.Value](qr *QueryResponse) ([]TypedSeries[T], error) {
+	var series []TypedSeries[T]
+	for _, q := range qr.QueriesArr {
+		for _, r := range q.ResultsArr {
+			ts, err := NewTypedSeries[T](r)
+			if err != nil {
+				return nil, err
+			}
+			series = append(series, ts)
+		}
+	}
+
+	return series, nil
+}
+
+func convertValue[T builder.Value](v interface{}) (T, bool) {
+	var out T
+	ok := true
+
+	switch p := any(&out).(type) {
+	case *string:
+		*p, ok = v.(string)
+	case *float64:
+		*p, ok = toFloat(v)
+	case *float32:
+		var f float64
+		f, ok = toFloat(v)
+		*p = float32(f)
+	case *int:
+		var i int64
+		i, ok = toInt(v, math.MinInt, math.MaxInt)
+		*p = int(i)
+	case *int8:
+		var i int64
+		i, ok = toInt(v, math.MinInt8, math.MaxInt8)
+		*p = int8(i)
+	case *int16:
+		var i int64
+		i, ok = toInt(v, math.MinInt16, math.MaxInt16)
+		*p = int16(i)
+	case *int32:
+		var i int64
+		i, ok = toInt(v, math.MinInt32, math.MaxInt32)
+		*p = int32(i)
+	case *int64:
+		*p, ok = toInt(v, math.MinInt64, math.MaxInt64)
+	case *uint:
+		var i int64
+		i, ok = toInt(v, 0, math.MaxInt64)
+		*p = uint(i)
+	case *uint8:
+		var i int64
+		i, ok = toInt(v, 0, math.MaxUint8)
+		*p = uint8(i)
+	case *uint16:
+		var i int64
+		i, ok = toInt(v, 0, math.MaxUint16)
+		*p = uint16(i)
+	case *uint32:
+		var i int64
+		i, ok = toInt(v, 0, math.MaxUint32)
+		*p = uint32(i)
+	case *uint64:
+		var i int64
+		i, ok = toInt(v, 0, math.MaxInt64)
+		*p = uint64(i)
+	}
+
+	return out, ok
+}
+
+// Returns the value of the data point, as an int64 when it was decoded from
+// an integer.
+func exactValue(dp *builder.DataPoint) interface{} {
+	if n, ok := dp.Number(); ok {
+		if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+			return i
+		}
+	}
+	return dp.Value()
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	}
+	return 0, false
+}
+
+func toInt(v interface{}, min, max int64) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, n >= min && n <= max
+	case float64:
+		// float64(math.MaxInt64) rounds up to 2^63, hence the strict bound.
+		if n != math.Trunc(n) || n < float64(min) || n >= float64(max)+1 {
+			return 0, false
+		}
+		return int64(n), true
+	}
+	return 0, false
+}
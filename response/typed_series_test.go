@@ -0,0 +1,63 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestDecodeSeries(t *testing.T) {
+	qr := NewQueryResponse(200)
+	err := json.Unmarshal([]byte(`{"queries":[{"results":[{"name":"m1","tags":{"host":["a"]},"values":[[1,10],[2,20]]}]},`+
+		`{"results":[{"name":"m2","values":[[3,30]]}]}]}`), qr)
+	assert.Nil(t, err, "No error expected")
+
+	series, err := DecodeSeries[int64](qr)
+	assert.Nil(t, err, "No error expected")
+	assert.Len(t, series, 2, "One series per result expected")
+	assert.Equal(t, "m1", series[0].Name, "Series name expected")
+	assert.Equal(t, []string{"a"}, series[0].Tags["host"], "Series tags expected")
+	assert.Equal(t, []TypedPoint[int64]{{1, 10}, {2, 20}}, series[0].Points, "Typed points expected")
+	assert.Equal(t, []TypedPoint[int64]{{3, 30}}, series[1].Points, "Typed points expected")
+
+	floats, err := DecodeSeries[float64](qr)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, 20.0, floats[0].Points[1].Value, "Float values expected")
+
+	var r Results
+	json.Unmarshal([]byte(`{"name":"m1","values":[[1,9007199254740993]]}`), &r)
+	exact, err := NewTypedSeries[int64](r)
+	assert.Nil(t, err, "No error expected")
+	assert.Equal(t, int64(9007199254740993), exact.Points[0].Value, "Longs beyond 2^53 must be exact")
+}
+
+// Failure test.
+func TestDecodeSeriesWrongType(t *testing.T) {
+	var r Results
+	json.Unmarshal([]byte(`{"name":"m1","values":[[1,1.5],[2,300]]}`), &r)
+
+	_, err := NewTypedSeries[int64](r)
+	assert.Equal(t, ErrorValueType, err, "Fractional value must not convert to an integer")
+
+	_, err = NewTypedSeries[uint8](Results{DataPoints: r.DataPoints[1:]})
+	assert.Equal(t, ErrorValueType, err, "Out of range value must not convert")
+
+	_, err = NewTypedSeries[string](r)
+	assert.Equal(t, ErrorValueType, err, "Number must not convert to a string")
+}
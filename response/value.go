@@ -0,0 +1,114 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"strconv"
+
+	"github.com/retoool/go-kairosdb/builder"
+)
+
+type ValueKind int
+
+const (
+	ValueNull ValueKind = iota
+	ValueLong
+	ValueDouble
+	ValueString
+	ValueComplex
+)
+
+// A data point value of a query result: a long, a double, a string or a
+// complex number, as a metric may hold a mix of them.
+//
+// KairosDB writes longs without a fraction or exponent and doubles with
+// one, e.g. 2 and 2.0. A number is a long when it was written as an integer
+// that fits in an int64, read exactly from the JSON rather than from its
+// float64, and a double otherwise. Double accepts both kinds.
+type Value struct {
+	kind    ValueKind
+	long    int64
+	double  float64
+	str     string
+	complex complex128
+}
+
+// Returns the value of a data point, see Value.
+func NewValue(dp builder.DataPoint) Value {
+	switch v := dp.Value().(type) {
+	case nil:
+		return Value{kind: ValueNull}
+	case string:
+		return Value{kind: ValueString, str: v}
+	case int64:
+		return Value{kind: ValueLong, long: v, double: float64(v)}
+	case int:
+		return Value{kind: ValueLong, long: int64(v), double: float64(v)}
+	case float64:
+		if n, ok := dp.Number(); ok {
+			if l, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+				return Value{kind: ValueLong, long: l, double: v}
+			}
+		}
+		return Value{kind: ValueDouble, double: v}
+	}
+
+	if c, err := dp.ComplexValue(); err == nil {
+		return Value{kind: ValueComplex, complex: c}
+	}
+	return Value{kind: ValueNull}
+}
+
+func (v Value) Kind() ValueKind {
+	return v.kind
+}
+
+func (v Value) IsNull() bool {
+	return v.kind == ValueNull
+}
+
+func (v Value) Long() (int64, bool) {
+	return v.long, v.kind == ValueLong
+}
+
+// Returns the value of a long or a double as a float64.
+func (v Value) Double() (float64, bool) {
+	return v.double, v.kind == ValueLong || v.kind == ValueDouble
+}
+
+func (v Value) Text() (string, bool) {
+	return v.str, v.kind == ValueString
+}
+
+func (v Value) Complex() (complex128, bool) {
+	return v.complex, v.kind == ValueComplex
+}
+
+// A data point of a query result with a typed value, see Value.
+type ValuePoint struct {
+	Timestamp int64
+	Value     Value
+}
+
+// Returns the data points of the result with typed values, for results
+// mixing longs, doubles and strings. See NewTypedSeries to convert them all
+// to a single type instead.
+func (r Results) ValuePoints() []ValuePoint {
+	points := make([]ValuePoint, len(r.DataPoints))
+	for i, dp := range r.DataPoints {
+		points[i] = ValuePoint{Timestamp: dp.Timestamp(), Value: NewValue(dp)}
+	}
+	return points
+}
@@ -0,0 +1,83 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Success test.
+func TestValuePoints(t *testing.T) {
+	qr := NewQueryResponse(http.StatusOK)
+	err := json.Unmarshal([]byte(`{"queries":[{"results":[{"name":"m1","values":`+
+		`[[1,42],[2,1.5],[3,"up"],[4,null],[5,{"real":1,"imaginary":2}],[6,1e300]]}]}]}`), qr)
+	assert.Nil(t, err, "No error expected")
+
+	points := qr.QueriesArr[0].ResultsArr[0].ValuePoints()
+	assert.Len(t, points, 6)
+	assert.Equal(t, int64(1), points[0].Timestamp)
+
+	l, ok := points[0].Value.Long()
+	assert.True(t, ok, "Long expected")
+	assert.Equal(t, int64(42), l)
+	d, ok := points[0].Value.Double()
+	assert.True(t, ok, "Long readable as a double expected")
+	assert.Equal(t, 42.0, d)
+
+	assert.Equal(t, ValueDouble, points[1].Value.Kind())
+	_, ok = points[1].Value.Long()
+	assert.False(t, ok, "No long expected")
+
+	s, ok := points[2].Value.Text()
+	assert.True(t, ok, "String expected")
+	assert.Equal(t, "up", s)
+	_, ok = points[2].Value.Double()
+	assert.False(t, ok, "No double expected")
+
+	assert.True(t, points[3].Value.IsNull(), "Null expected")
+
+	c, ok := points[4].Value.Complex()
+	assert.True(t, ok, "Complex expected")
+	assert.Equal(t, complex(1, 2), c)
+
+	assert.Equal(t, ValueDouble, points[5].Value.Kind(), "Integral number beyond 2^53 expected as a double")
+}
+
+// Success test.
+func TestValuePointsExact(t *testing.T) {
+	qr := NewQueryResponse(http.StatusOK)
+	err := json.Unmarshal([]byte(`{"queries":[{"results":[{"name":"m1","values":`+
+		`[[1,2.0],[2,9007199254740993],[3,-9223372036854775808],[4,9223372036854775808]]}]}]}`), qr)
+	assert.Nil(t, err, "No error expected")
+
+	points := qr.QueriesArr[0].ResultsArr[0].ValuePoints()
+	assert.Equal(t, ValueDouble, points[0].Value.Kind(), "A number written with a fraction is a double")
+	d, _ := points[0].Value.Double()
+	assert.Equal(t, 2.0, d)
+
+	l, ok := points[1].Value.Long()
+	assert.True(t, ok, "Long expected")
+	assert.Equal(t, int64(9007199254740993), l, "Longs beyond 2^53 must be exact")
+
+	l, ok = points[2].Value.Long()
+	assert.True(t, ok, "Long expected")
+	assert.Equal(t, int64(-9223372036854775808), l)
+
+	assert.Equal(t, ValueDouble, points[3].Value.Kind(), "Integers beyond int64 expected as doubles")
+}
@@ -0,0 +1,34 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package response
+
+type VersionResponse struct {
+	*Response
+	Version string `json:"version,omitempty"`
+}
+
+func NewVersionResponse(code int) *VersionResponse {
+	vr := &VersionResponse{
+		Response: &Response{},
+	}
+	vr.SetStatusCode(code)
+	return vr
+}
+
+// Returns the version reported by the server, e.g.
+// "KairosDB 1.2.2-1.20180716".
+func (vr *VersionResponse) GetVersion() string {
+	return vr.Version
+}
@@ -0,0 +1,24 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import "errors"
+
+var (
+	ErrorRulePattern     = errors.New("Retention rule without pattern")
+	ErrorRuleMaxAge      = errors.New("Retention rule max age must be > 0")
+	ErrorListMetricNames = errors.New("Metric names request returned an error status")
+	ErrorDeleteFailed    = errors.New("Retention delete failed")
+)
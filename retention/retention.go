@@ -0,0 +1,221 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retention deletes the data points of metrics once they are older
+// than the age declared for them, since KairosDB has no per-metric
+// retention of its own.
+package retention
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/retoool/go-kairosdb/client"
+	"github.com/retoool/go-kairosdb/clock"
+)
+
+// Declares how long the data points of the metrics whose name matches the
+// pattern are kept.
+type Rule struct {
+	Pattern *regexp.Regexp
+	MaxAge  time.Duration
+
+	// Restricts the rule to the series matching all the tags. May be nil.
+	Tags map[string]string
+}
+
+// Options of the Manager.
+type Options struct {
+	// Time between two runs of Run. Defaults to one hour.
+	Interval time.Duration
+
+	// Reports the deletes that would be issued without issuing them.
+	DryRun bool
+
+	// Called for every delete, issued or not. May be nil.
+	OnAction func(Action)
+
+	// Tells the cutoffs and the run times. Defaults to the wall clock.
+	Clock clock.Clock
+}
+
+// A delete issued, or only reported in dry run mode, by the Manager.
+type Action struct {
+	Metric string
+
+	// Index of the rule applied.
+	Rule int
+
+	// Data points before the cutoff are deleted.
+	Cutoff time.Time
+
+	DryRun bool
+
+	// Why the delete failed, if it did.
+	Err error
+}
+
+// A snapshot of the counters of the Manager, since its creation.
+type Stats struct {
+	Runs     int64
+	Deletes  int64 // Issued successfully, or reported in dry run mode.
+	Failures int64
+	LastRun  time.Time
+}
+
+// Applies retention rules to the metrics of a KairosDB cluster.
+type Manager struct {
+	c     client.Client
+	rules []Rule
+	opts  Options
+
+	runMu sync.Mutex // Serializes the runs.
+	mu    sync.Mutex // Guards stats.
+	stats Stats
+}
+
+// Creates a Manager applying the rules in order: the first rule whose
+// pattern matches the name of a metric applies to it, the others are
+// ignored. More specific rules must come first.
+func NewManager(c client.Client, rules []Rule, opts Options) (*Manager, error) {
+	for i, r := range rules {
+		if r.Pattern == nil {
+			return nil, fmt.Errorf("%w: rule %d", ErrorRulePattern, i)
+		}
+
+		if r.MaxAge <= 0 {
+			return nil, fmt.Errorf("%w: rule %d", ErrorRuleMaxAge, i)
+		}
+	}
+
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+	opts.Clock = clock.OrReal(opts.Clock)
+
+	return &Manager{
+		c:     c,
+		rules: rules,
+		opts:  opts,
+	}, nil
+}
+
+// Runs the rules once and then every interval until the context is done.
+// The errors of the runs are reported through OnAction and the counters,
+// not returned.
+func (m *Manager) Run(ctx context.Context) error {
+	for {
+		m.RunOnce(ctx)
+
+		timer := m.opts.Clock.NewTimer(m.opts.Interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C():
+		}
+	}
+}
+
+// Lists the metrics and issues a delete for every one matched by a rule.
+// A failed delete does not stop the run, an error wrapping
+// ErrorDeleteFailed is returned at the end.
+func (m *Manager) RunOnce(ctx context.Context) error {
+	m.runMu.Lock()
+	defer m.runMu.Unlock()
+
+	m.count(func(s *Stats) {
+		s.Runs++
+		s.LastRun = m.opts.Clock.Now()
+	})
+
+	names, err := m.c.GetMetricNames()
+	if err == nil && names.GetStatusCode() >= http.StatusMultipleChoices {
+		err = fmt.Errorf("%w: status %d: %v", ErrorListMetricNames, names.GetStatusCode(), names.GetErrors())
+	}
+	if err != nil {
+		m.count(func(s *Stats) { s.Failures++ })
+		return err
+	}
+
+	failed := 0
+	for _, metric := range names.GetResults() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		i, rule, ok := m.match(metric)
+		if !ok {
+			continue
+		}
+
+		action := Action{
+			Metric: metric,
+			Rule:   i,
+			Cutoff: m.opts.Clock.Now().Add(-rule.MaxAge),
+			DryRun: m.opts.DryRun,
+		}
+
+		if !m.opts.DryRun {
+			resp, err := client.DeleteBefore(m.c, metric, rule.Tags, action.Cutoff)
+			if err == nil && resp.GetStatusCode() >= http.StatusMultipleChoices {
+				err = fmt.Errorf("status %d: %v", resp.GetStatusCode(), resp.GetErrors())
+			}
+			action.Err = err
+		}
+
+		if action.Err != nil {
+			m.count(func(s *Stats) { s.Failures++ })
+			failed++
+		} else {
+			m.count(func(s *Stats) { s.Deletes++ })
+		}
+
+		if m.opts.OnAction != nil {
+			m.opts.OnAction(action)
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%w: %d metrics", ErrorDeleteFailed, failed)
+	}
+
+	return nil
+}
+
+// Returns a snapshot of the counters of the manager.
+func (m *Manager) Stats() Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats
+}
+
+func (m *Manager) count(update func(s *Stats)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	update(&m.stats)
+}
+
+func (m *Manager) match(metric string) (int, Rule, bool) {
+	for i, r := range m.rules {
+		if r.Pattern.MatchString(metric) {
+			return i, r, true
+		}
+	}
+	return 0, Rule{}, false
+}
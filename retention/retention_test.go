@@ -0,0 +1,146 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/client"
+	"github.com/stretchr/testify/assert"
+)
+
+// Serves the metric names and records the metrics deletes are issued for.
+// Deletes of the metric named "fails" are answered with an error.
+func newRetentionServer(names []string, deleted *[]string, mu *sync.Mutex) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/metricnames":
+			json.NewEncoder(w).Encode(map[string][]string{"results": names})
+		case "/api/v1/datapoints/delete":
+			var query struct {
+				Metrics []struct {
+					Name string `json:"name"`
+				} `json:"metrics"`
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			json.Unmarshal(body, &query)
+
+			mu.Lock()
+			*deleted = append(*deleted, query.Metrics[0].Name)
+			mu.Unlock()
+
+			if query.Metrics[0].Name == "fails" {
+				w.WriteHeader(http.StatusInternalServerError)
+				w.Write([]byte(`{"errors":["boom"]}`))
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+}
+
+// Success test.
+func TestRunOnce(t *testing.T) {
+	var mu sync.Mutex
+	var deleted []string
+	srv := newRetentionServer([]string{"debug.requests", "app.requests", "app.latency", "sys.cpu"}, &deleted, &mu)
+	defer srv.Close()
+
+	var actions []Action
+	m, err := NewManager(client.NewHttpClient(srv.URL), []Rule{
+		{Pattern: regexp.MustCompile(`^debug\.`), MaxAge: time.Hour},
+		{Pattern: regexp.MustCompile(`^(app|debug)\.`), MaxAge: 24 * time.Hour},
+	}, Options{OnAction: func(a Action) { actions = append(actions, a) }})
+	assert.Nil(t, err, "No error expected")
+
+	assert.Nil(t, m.RunOnce(context.Background()), "No error expected")
+	assert.Equal(t, []string{"debug.requests", "app.requests", "app.latency"}, deleted, "Matched metrics must be deleted")
+
+	assert.Len(t, actions, 3, "One action per delete expected")
+	assert.Equal(t, 0, actions[0].Rule, "First matching rule must win")
+	assert.Equal(t, 1, actions[1].Rule, "Second rule expected")
+	assert.WithinDuration(t, time.Now().Add(-24*time.Hour), actions[1].Cutoff, time.Minute, "Cutoff expected")
+
+	stats := m.Stats()
+	assert.Equal(t, int64(1), stats.Runs, "One run expected")
+	assert.Equal(t, int64(3), stats.Deletes, "Three deletes expected")
+	assert.Equal(t, int64(0), stats.Failures, "No failure expected")
+}
+
+// Success test.
+func TestRunOnceDryRun(t *testing.T) {
+	var mu sync.Mutex
+	var deleted []string
+	srv := newRetentionServer([]string{"app.requests"}, &deleted, &mu)
+	defer srv.Close()
+
+	var actions []Action
+	m, _ := NewManager(client.NewHttpClient(srv.URL), []Rule{{Pattern: regexp.MustCompile(`.`), MaxAge: time.Hour}},
+		Options{DryRun: true, OnAction: func(a Action) { actions = append(actions, a) }})
+
+	assert.Nil(t, m.RunOnce(context.Background()), "No error expected")
+	assert.Empty(t, deleted, "Nothing must be deleted in dry run mode")
+	assert.Len(t, actions, 1, "The delete must be reported")
+	assert.True(t, actions[0].DryRun, "Dry run expected")
+}
+
+// Success test.
+func TestRun(t *testing.T) {
+	var mu sync.Mutex
+	var deleted []string
+	srv := newRetentionServer([]string{"app.requests"}, &deleted, &mu)
+	defer srv.Close()
+
+	m, _ := NewManager(client.NewHttpClient(srv.URL), []Rule{{Pattern: regexp.MustCompile(`.`), MaxAge: time.Hour}},
+		Options{Interval: 20 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 70*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, m.Run(ctx), "Context error expected")
+	assert.True(t, m.Stats().Runs >= 2, "Several runs expected")
+}
+
+// Failure test.
+func TestRunOnceFailures(t *testing.T) {
+	var mu sync.Mutex
+	var deleted []string
+	srv := newRetentionServer([]string{"fails", "app.requests"}, &deleted, &mu)
+	defer srv.Close()
+
+	var actions []Action
+	m, _ := NewManager(client.NewHttpClient(srv.URL), []Rule{{Pattern: regexp.MustCompile(`.`), MaxAge: time.Hour}},
+		Options{OnAction: func(a Action) { actions = append(actions, a) }})
+
+	err := m.RunOnce(context.Background())
+	assert.True(t, errors.Is(err, ErrorDeleteFailed), "Delete error expected")
+	assert.Equal(t, []string{"fails", "app.requests"}, deleted, "A failure must not stop the run")
+	assert.NotNil(t, actions[0].Err, "The failure must be reported")
+	assert.Equal(t, int64(1), m.Stats().Failures, "One failure expected")
+
+	_, err = NewManager(nil, []Rule{{MaxAge: time.Hour}}, Options{})
+	assert.True(t, errors.Is(err, ErrorRulePattern), "Pattern error expected")
+
+	_, err = NewManager(nil, []Rule{{Pattern: regexp.MustCompile(`.`)}}, Options{})
+	assert.True(t, errors.Is(err, ErrorRuleMaxAge), "Max age error expected")
+}
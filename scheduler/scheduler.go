@@ -0,0 +1,268 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scheduler runs queries on a schedule and hands their results to
+// callbacks, the foundation of reporting and alerting loops.
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/client"
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/retoool/go-kairosdb/response"
+)
+
+// Tells when a job runs next.
+type Schedule interface {
+	// Returns the first run time strictly after the given time.
+	Next(after time.Time) time.Time
+}
+
+type every time.Duration
+
+// Returns a schedule running at every multiple of the interval since the
+// epoch, e.g. on the minute for one minute. Intervals below a millisecond
+// are rounded up to one.
+func Every(interval time.Duration) Schedule {
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	return every(interval)
+}
+
+func (e every) Next(after time.Time) time.Time {
+	return after.Truncate(time.Duration(e)).Add(time.Duration(e))
+}
+
+// The outcome of a run of a job.
+type Result struct {
+	Job string
+
+	// When the run was scheduled, before jitter.
+	Scheduled time.Time
+
+	// The response of the query. It is set along with an error wrapping
+	// ErrorQueryFailed when KairosDB answered with an error status.
+	Response *response.QueryResponse
+
+	Err error
+}
+
+// A query run on a schedule.
+type Job struct {
+	// Identifies the job, must be unique within a runner.
+	Name string
+
+	Query    builder.QueryBuilder
+	Schedule Schedule
+
+	// Builds the query of every run instead of Query, e.g. to query the
+	// absolute time range preceding the scheduled time.
+	BuildQuery func(scheduled time.Time) builder.QueryBuilder
+
+	// Every run is delayed by a random duration up to Jitter, so that jobs
+	// sharing a schedule do not hit KairosDB all at once. Keep it well below
+	// the interval of the schedule, runs are skipped otherwise.
+	Jitter time.Duration
+
+	// Receives the result of every run. The context is the one of the
+	// runner.
+	Handler func(ctx context.Context, r Result)
+}
+
+// A snapshot of the counters of a job.
+type JobStats struct {
+	Runs     int64
+	Failures int64
+
+	// Runs skipped because the previous one was still in progress.
+	Skipped int64
+}
+
+type jobState struct {
+	Job
+	running  atomic.Bool
+	runs     atomic.Int64
+	failures atomic.Int64
+	skipped  atomic.Int64
+}
+
+// Runs registered jobs against a client. A job never overlaps with itself:
+// a run due while the previous one is still in progress is skipped.
+type Runner struct {
+	c     client.MetricReader
+	clock clock.Clock
+
+	mu      sync.Mutex // Guards jobs and active.
+	jobs    []*jobState
+	active  bool
+	handled sync.WaitGroup
+}
+
+func NewRunner(c client.MetricReader) *Runner {
+	return &Runner{c: c, clock: clock.Real}
+}
+
+// Makes the runner follow the clock instead of the wall clock, e.g. a
+// clock.Fake in tests. Must be called before Run.
+func (r *Runner) SetClock(c clock.Clock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clock = clock.OrReal(c)
+}
+
+// Adds a job to the runner. Jobs must be registered before Run is called.
+func (r *Runner) Register(job Job) error {
+	switch {
+	case job.Name == "":
+		return ErrorJobName
+	case job.Query == nil && job.BuildQuery == nil:
+		return ErrorJobQuery
+	case job.Schedule == nil:
+		return ErrorJobSchedule
+	case job.Handler == nil:
+		return ErrorJobHandler
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.active {
+		return ErrorRunnerActive
+	}
+
+	for _, js := range r.jobs {
+		if js.Name == job.Name {
+			return fmt.Errorf("%w: %s", ErrorJobDuplicate, job.Name)
+		}
+	}
+
+	r.jobs = append(r.jobs, &jobState{Job: job})
+	return nil
+}
+
+// Runs the jobs until the context is done, then waits for the runs in
+// progress to finish. Returns ErrorRunnerActive when the runner is already
+// running.
+func (r *Runner) Run(ctx context.Context) error {
+	r.mu.Lock()
+	if r.active {
+		r.mu.Unlock()
+		return ErrorRunnerActive
+	}
+	r.active = true
+	jobs := r.jobs
+	clk := r.clock
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		r.active = false
+		r.mu.Unlock()
+	}()
+
+	var loops sync.WaitGroup
+	for _, js := range jobs {
+		loops.Add(1)
+		go func(js *jobState) {
+			defer loops.Done()
+			r.loop(ctx, clk, js)
+		}(js)
+	}
+
+	loops.Wait()
+	r.handled.Wait()
+	return ctx.Err()
+}
+
+// Returns a snapshot of the counters of the job, false for an unknown job.
+func (r *Runner) Stats(name string) (JobStats, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, js := range r.jobs {
+		if js.Name == name {
+			return JobStats{
+				Runs:     js.runs.Load(),
+				Failures: js.failures.Load(),
+				Skipped:  js.skipped.Load(),
+			}, true
+		}
+	}
+	return JobStats{}, false
+}
+
+func (r *Runner) loop(ctx context.Context, clk clock.Clock, js *jobState) {
+	for {
+		now := clk.Now()
+		scheduled := js.Schedule.Next(now)
+		due := scheduled
+		if js.Jitter > 0 {
+			due = due.Add(time.Duration(rand.Int63n(int64(js.Jitter))))
+		}
+
+		timer := clk.NewTimer(due.Sub(now))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+
+		if !js.running.CompareAndSwap(false, true) {
+			js.skipped.Add(1)
+			continue
+		}
+
+		r.handled.Add(1)
+		go func() {
+			defer r.handled.Done()
+			defer js.running.Store(false)
+			r.run(ctx, js, scheduled)
+		}()
+	}
+}
+
+func (r *Runner) run(ctx context.Context, js *jobState, scheduled time.Time) {
+	js.runs.Add(1)
+
+	qb := js.Query
+	if js.BuildQuery != nil {
+		qb = js.BuildQuery(scheduled)
+	}
+
+	resp, err := r.c.QueryContext(ctx, qb)
+	if err == nil && resp.GetStatusCode() >= http.StatusMultipleChoices {
+		err = fmt.Errorf("%w: status %d: %v", ErrorQueryFailed, resp.GetStatusCode(), resp.GetErrors())
+	}
+
+	if err != nil {
+		js.failures.Add(1)
+	}
+
+	js.Handler(ctx, Result{
+		Job:       js.Name,
+		Scheduled: scheduled,
+		Response:  resp,
+		Err:       err,
+	})
+}
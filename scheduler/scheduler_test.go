@@ -0,0 +1,245 @@
+// Copyright 2016 Ajit Yagaty
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/retoool/go-kairosdb/builder"
+	"github.com/retoool/go-kairosdb/builder/utils"
+	"github.com/retoool/go-kairosdb/client"
+	"github.com/retoool/go-kairosdb/clock"
+	"github.com/stretchr/testify/assert"
+)
+
+func testQuery() builder.QueryBuilder {
+	qb := builder.NewQueryBuilder().SetRelativeStart(1, utils.HOURS)
+	qb.AddMetric("m1")
+	return qb
+}
+
+// Answers with the number of queries received so far as the sample size.
+func newCountingServer(code int, delay time.Duration, hits *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(hits, 1)
+		time.Sleep(delay)
+		w.WriteHeader(code)
+		fmt.Fprintf(w, `{"queries":[{"sample_size":%d,"results":[]}]}`, n)
+	}))
+}
+
+// Success test.
+func TestEvery(t *testing.T) {
+	s := Every(time.Minute)
+	at := time.Date(2020, 1, 1, 10, 30, 15, 0, time.UTC)
+	assert.Equal(t, time.Date(2020, 1, 1, 10, 31, 0, 0, time.UTC), s.Next(at), "Next minute expected")
+	assert.Equal(t, time.Date(2020, 1, 1, 10, 32, 0, 0, time.UTC), s.Next(s.Next(at)), "Strictly after expected")
+}
+
+// Success test.
+func TestRunner(t *testing.T) {
+	var hits int32
+	srv := newCountingServer(http.StatusOK, 0, &hits)
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var results []Result
+	r := NewRunner(client.NewHttpClient(srv.URL))
+	err := r.Register(Job{
+		Name:     "count",
+		Query:    testQuery(),
+		Schedule: Every(20 * time.Millisecond),
+		Jitter:   5 * time.Millisecond,
+		Handler: func(ctx context.Context, res Result) {
+			mu.Lock()
+			results = append(results, res)
+			mu.Unlock()
+		},
+	})
+	assert.Nil(t, err, "No error expected")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 110*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, r.Run(ctx), "Context error expected")
+
+	assert.True(t, len(results) >= 3, "Several runs expected")
+	assert.Equal(t, "count", results[0].Job, "Job name expected")
+	assert.Nil(t, results[0].Err, "No error expected")
+	assert.Equal(t, int64(1), results[0].Response.QueriesArr[0].SampleSize, "Response expected")
+	assert.Equal(t, results[0].Scheduled, results[0].Scheduled.Truncate(20*time.Millisecond), "Aligned schedule expected")
+
+	stats, ok := r.Stats("count")
+	assert.True(t, ok, "Stats expected")
+	assert.Equal(t, int64(len(results)), stats.Runs, "Runs must be counted")
+}
+
+// Failure test.
+func TestRunnerRunTwice(t *testing.T) {
+	r := NewRunner(client.NewHttpClient("http://localhost:0"))
+	r.Register(Job{Name: "hourly", Query: testQuery(), Schedule: Every(time.Hour), Handler: func(context.Context, Result) {}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+	assert.Eventually(t, func() bool {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		return r.active
+	}, time.Second, time.Millisecond)
+
+	assert.Equal(t, ErrorRunnerActive, r.Run(ctx), "Concurrent runs must be rejected")
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+}
+
+// Success test.
+func TestRunnerBuildQuery(t *testing.T) {
+	var hits int32
+	srv := newCountingServer(http.StatusOK, 0, &hits)
+	defer srv.Close()
+
+	var built []time.Time
+	clk := clock.NewFake(time.Date(2020, 1, 1, 10, 0, 30, 0, time.UTC))
+	results := make(chan Result)
+	r := NewRunner(client.NewHttpClient(srv.URL))
+	r.SetClock(clk)
+	err := r.Register(Job{
+		Name:     "window",
+		Schedule: Every(time.Minute),
+		BuildQuery: func(scheduled time.Time) builder.QueryBuilder {
+			built = append(built, scheduled)
+			return testQuery()
+		},
+		Handler: func(ctx context.Context, res Result) { results <- res },
+	})
+	assert.Nil(t, err, "A query builder replaces the query")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+
+	// Runs are counted once handled, so none is cut short by the end of
+	// the test.
+	for i := 0; i < 3; i++ {
+		clk.BlockUntil(1)
+		clk.Advance(time.Minute)
+		assert.Nil(t, (<-results).Err, "No error expected")
+	}
+
+	cancel()
+	<-done
+	assert.Equal(t, []time.Time{
+		time.Date(2020, 1, 1, 10, 1, 0, 0, time.UTC),
+		time.Date(2020, 1, 1, 10, 2, 0, 0, time.UTC),
+		time.Date(2020, 1, 1, 10, 3, 0, 0, time.UTC),
+	}, built, "The query must be built for every run")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&hits), "One query per run expected")
+}
+
+// Success test.
+func TestRunnerClock(t *testing.T) {
+	var hits int32
+	srv := newCountingServer(http.StatusOK, 0, &hits)
+	defer srv.Close()
+
+	clk := clock.NewFake(time.Date(2020, 1, 1, 10, 0, 30, 0, time.UTC))
+	results := make(chan Result, 1)
+	r := NewRunner(client.NewHttpClient(srv.URL))
+	r.SetClock(clk)
+	err := r.Register(Job{
+		Name:     "minutely",
+		Query:    testQuery(),
+		Schedule: Every(time.Minute),
+		Handler:  func(ctx context.Context, res Result) { results <- res },
+	})
+	assert.Nil(t, err, "No error expected")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+
+	clk.BlockUntil(1)
+	clk.Advance(30 * time.Second)
+	res := <-results
+	assert.Nil(t, res.Err, "No error expected")
+	assert.Equal(t, time.Date(2020, 1, 1, 10, 1, 0, 0, time.UTC), res.Scheduled, "The schedule must follow the clock")
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+}
+
+// Success test.
+func TestRunnerOverlap(t *testing.T) {
+	var hits int32
+	srv := newCountingServer(http.StatusOK, 50*time.Millisecond, &hits)
+	defer srv.Close()
+
+	r := NewRunner(client.NewHttpClient(srv.URL))
+	r.Register(Job{Name: "slow", Query: testQuery(), Schedule: Every(10 * time.Millisecond), Handler: func(context.Context, Result) {}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Millisecond)
+	defer cancel()
+	r.Run(ctx)
+
+	stats, _ := r.Stats("slow")
+	assert.True(t, stats.Skipped > 0, "Overlapping runs must be skipped")
+	assert.True(t, atomic.LoadInt32(&hits) <= 3, "Runs must not overlap")
+}
+
+// Failure test.
+func TestRunnerFailures(t *testing.T) {
+	var hits int32
+	srv := newCountingServer(http.StatusBadRequest, 0, &hits)
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var errs []error
+	r := NewRunner(client.NewHttpClient(srv.URL))
+	r.Register(Job{Name: "bad", Query: testQuery(), Schedule: Every(10 * time.Millisecond), Handler: func(ctx context.Context, res Result) {
+		mu.Lock()
+		errs = append(errs, res.Err)
+		mu.Unlock()
+	}})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 35*time.Millisecond)
+	defer cancel()
+	r.Run(ctx)
+
+	assert.NotEmpty(t, errs, "Runs expected")
+	assert.True(t, errors.Is(errs[0], ErrorQueryFailed), "Status error expected")
+	stats, _ := r.Stats("bad")
+	assert.Equal(t, int64(len(errs)), stats.Failures, "Failures must be counted")
+
+	handler := func(context.Context, Result) {}
+	assert.Equal(t, ErrorJobName, r.Register(Job{Query: testQuery(), Schedule: Every(time.Second), Handler: handler}), "Name error expected")
+	assert.Equal(t, ErrorJobQuery, r.Register(Job{Name: "j", Schedule: Every(time.Second), Handler: handler}), "Query error expected")
+	assert.Equal(t, ErrorJobSchedule, r.Register(Job{Name: "j", Query: testQuery(), Handler: handler}), "Schedule error expected")
+	assert.Equal(t, ErrorJobHandler, r.Register(Job{Name: "j", Query: testQuery(), Schedule: Every(time.Second)}), "Handler error expected")
+
+	err := r.Register(Job{Name: "bad", Query: testQuery(), Schedule: Every(time.Second), Handler: handler})
+	assert.True(t, errors.Is(err, ErrorJobDuplicate), "Duplicate error expected")
+
+	_, ok := r.Stats("unknown")
+	assert.False(t, ok, "Unknown job")
+}